@@ -0,0 +1,17 @@
+CREATE TABLE block (hash VARCHAR(64) NOT NULL, number INTEGER NOT NULL, medianTime INT NOT NULL, time INT NOT NULL, parameters VARCHAR(255), joiners TEXT, actives TEXT, leavers TEXT, revoked TEXT, excluded TEXT, certifications TEXT, PRIMARY KEY (number, hash));
+CREATE TABLE i_index (op VARCHAR(10) NOT NULL, uid VARCHAR(100), pub VARCHAR(50) NOT NULL, hash VARCHAR(80), writtenOn INTEGER NOT NULL, member BOOLEAN);
+CREATE TABLE idty (pubkey VARCHAR(50) NOT NULL, uid VARCHAR(255) NOT NULL, buid VARCHAR(100) NOT NULL, hash VARCHAR(64) NOT NULL, revocation_sig VARCHAR(100), expires_on INTEGER);
+CREATE TABLE membership (membership CHAR(2) NOT NULL, issuer VARCHAR(50) NOT NULL, number INTEGER NOT NULL, blockNumber INTEGER, blockHash VARCHAR(64) NOT NULL, userid VARCHAR(255) NOT NULL, idtyHash VARCHAR(64), expires_on INTEGER NOT NULL);
+CREATE TABLE cert ([from] VARCHAR(50) NOT NULL, [to] VARCHAR(50) NOT NULL, target CHAR(64) NOT NULL, block_number INTEGER NOT NULL, block_hash VARCHAR(64) NOT NULL, expires_on INTEGER);
+INSERT INTO block (hash, number, medianTime, time, parameters, joiners, actives, leavers, revoked, excluded, certifications) VALUES ('00000000000000000000000000000000000000000000000000000000B10C0000', 0, 1488987127, 1488987127, '0.0488:86400:1000:432000:100:5259600:63115200:2:5259600:5259600:0.8:31557600:5:24:300:12:0.67:1488970800:1490094000:15778800', '["7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:c2lnbmF0dXJl:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:alice","8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF:c2lnbmF0dXJl:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:bob","9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y:c2lnbmF0dXJl:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:carol"]', '[]', '[]', '[]', '[]', '["7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF:0:c2lnbmF0dXJl","7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y:0:c2lnbmF0dXJl","8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF:7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:0:c2lnbmF0dXJl","8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF:9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y:0:c2lnbmF0dXJl","9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y:7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:0:c2lnbmF0dXJl","9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y:8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF:0:c2lnbmF0dXJl"]');
+INSERT INTO i_index VALUES ('CREATE', 'alice', '7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ', '1111111111111111111111111111111111111111111111111111111111111111', 0, 1);
+INSERT INTO i_index VALUES ('CREATE', 'bob', '8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF', '2222222222222222222222222222222222222222222222222222222222222222', 0, 1);
+INSERT INTO i_index VALUES ('CREATE', 'carol', '9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y', '3333333333333333333333333333333333333333333333333333333333333333', 0, 1);
+INSERT INTO block (hash, number, medianTime, time, parameters, joiners, actives, leavers, revoked, excluded, certifications) VALUES ('00000000000000000000000000000000000000000000000000000000B10C0001', 1, 1488987427, 1488987437, '', '[]', '[]', '[]', '[]', '[]', '[]');
+INSERT INTO block (hash, number, medianTime, time, parameters, joiners, actives, leavers, revoked, excluded, certifications) VALUES ('00000000000000000000000000000000000000000000000000000000B10C0002', 2, 1488987727, 1488987732, '', '["2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT:c2lnbmF0dXJl:1-000000A1B2C3D4E5F60718293A4B5C6D7E8F90A1B2C3D4E5F60718293A4B5C:1-000000A1B2C3D4E5F60718293A4B5C6D7E8F90A1B2C3D4E5F60718293A4B5C:dave"]', '[]', '[]', '[]', '[]', '["7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT:1:c2lnbmF0dXJl","8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF:2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT:1:c2lnbmF0dXJl","9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y:2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT:1:c2lnbmF0dXJl"]');
+INSERT INTO i_index VALUES ('CREATE', 'dave', '2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT', '4444444444444444444444444444444444444444444444444444444444444444', 2, 1);
+INSERT INTO block (hash, number, medianTime, time, parameters, joiners, actives, leavers, revoked, excluded, certifications) VALUES ('00000000000000000000000000000000000000000000000000000000B10C0003', 3, 1488988027, 1488988030, '', '[]', '["7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ:c2lnbmF0dXJl:2-000000A1B2C3D4E5F60718293A4B5C6D7E8F90A1B2C3D4E5F60718293A4B5C:0-E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855:alice"]', '[]', '[]', '[]', '[]');
+INSERT INTO idty VALUES ('4XtNpd9DHiXWXoFQ7aVPsjDr4H7eCbTVbYw5NKmFB5hN', 'erin', '3-00000000000000000000000000000000000000000000000000000000B10C0003', '5555555555555555555555555555555555555555555555555555555555555555', NULL, 1800000000);
+INSERT INTO membership VALUES ('IN', '4XtNpd9DHiXWXoFQ7aVPsjDr4H7eCbTVbYw5NKmFB5hN', 3, 3, '00000000000000000000000000000000000000000000000000000000B10C0003', 'erin', '5555555555555555555555555555555555555555555555555555555555555555', 1800000000);
+INSERT INTO cert VALUES ('7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ', '4XtNpd9DHiXWXoFQ7aVPsjDr4H7eCbTVbYw5NKmFB5hN', '5555555555555555555555555555555555555555555555555555555555555555', 3, '00000000000000000000000000000000000000000000000000000000B10C0003', 1800000000);
+INSERT INTO cert VALUES ('8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF', '4XtNpd9DHiXWXoFQ7aVPsjDr4H7eCbTVbYw5NKmFB5hN', '5555555555555555555555555555555555555555555555555555555555555555', 3, '00000000000000000000000000000000000000000000000000000000B10C0003', 1800000000);
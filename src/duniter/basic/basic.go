@@ -15,18 +15,18 @@ package basic
 import (
 	
 	A	"util/avl"
+	C	"util/config"
 	F	"path/filepath"
 	M	"util/misc"
 	R	"util/resources"
-		"bufio"
 		"bytes"
 		"errors"
 		"flag"
-		"fmt"
 		"log"
 		"os"
-		"text/scanner"
+		"strconv"
 		"strings"
+		"time"
 		"unicode"
 
 )
@@ -41,14 +41,36 @@ const (
 	
 	duniBaseDef = "$HOME/.config/duniter/duniter_default/wotwizard-export.db"
 	
-	// Name of the file where the path to the Duniter database is written
+	// Name of the configuration file
+	configName = "config.json"
+	// Environment variables of the configuration begin with envPrefix + "_"
+	envPrefix = "WW"
+	
+	maxSizeDef = 430000000 // Default value for the greatest allowed allocated memory size
+	samplesDef = 1000
+	samplesMax = 1000000
+	syncDelayDef = 15 * time.Second
+	syncDelayMin = 10 * time.Second // Must exceed the sum of the delays of the "file" trigger, in blockchain
+	secureGapDef = 100
+	secureGapMax = 1000 // Number of blocks whose operations are kept for forks, in blockchain
+	changesDepthDef = 10000
+	
+	// Former name of the file where the path to the Duniter database was written
 	initName = "init.txt"
-	logName = "log.txt"
-	logOldName = "log1.txt"
-	minLogSize = 0x3200000 // 50MB
+	logRoot = "log"
+	logExt = ".txt"
+	logName = logRoot + logExt
 	
 	serverDefaultAddress = "localhost:8080"
-	serverAddressName = "serverAddress.txt"
+	serverAddressName = "serverAddress.txt" // Former name of the file of the server address
+	
+	// Directory of the files of the currencies, in rsrcDir, for the "-currency" option
+	currenciesDirName = "Currencies"
+	
+	// Values of Sampling
+	SamplingNever = "never" // All the permutations are computed, up to MaxSize
+	SamplingAuto = "auto" // The permutations are sampled when MaxSize is exceeded
+	SamplingAlways = "always" // The permutations are always sampled
 	
 	Never = M.MaxInt64 // In WotWizard window
 	Revoked = M.MinInt64 // Limit date for revoked members
@@ -62,24 +84,87 @@ const (
 
 var (
 	
-	Lg *log.Logger
+	Lg *log.Logger // Logger of util/misc, whose lines are written as errors; use Logger elsewhere
 	
 	DuniDir,
 	DuniBase string // Path to the Duniter database
 	
-	rsrcDir = F.Join(R.FindDir(), "duniter")
-	initPath = F.Join(rsrcDir, initName)
-	logPath = F.Join(rsrcDir, logName)
-	logOldPath = F.Join(rsrcDir, logOldName)
+	UpdateTrigger string // Kind of trigger of updates: "file", "watch", "timer[:period]" or "http[:address]"
+	IngestionPolicy string // What to do with a faulty block: "halt" or "quarantine" (skip its faulty entries)
+	
+	CheckBase, // Check the integrity of the WotWizard database and stop
+	RepairBase, // Check the integrity of the WotWizard database, rebuild its faulty indexes and stop
+	Backtest bool // Compute again the recorded WotWizard forecasts, compare them with the actual entries and stop
+	
+	ExportSnapshot, // Path of the snapshot file to be written from the WotWizard database before stopping, or ""
+	ImportSnapshot string // Path of the snapshot file to be read into the WotWizard database before stopping, or ""
 	
-	serverAddress = serverDefaultAddress
+	Backups int // Number of backups of the WotWizard database kept; no backup if 0
+	BackupEvery time.Duration // Minimum delay between two backups
+	RestoreBackup string // Block number of the backup to be restored before stopping, "last", or ""
+	
+	MaxSize int64 // Greatest allowed allocated memory size for the computation of WotWizard permutations
+	TimeBudget time.Duration // Greatest duration of the computation of WotWizard permutations; no limit if 0
+	ConcurrencyWindow time.Duration // WotWizard dossiers whose dates differ by less than ConcurrencyWindow are concurrent; the avgGenTime of the currency if 0
+	AdminToken string // Secret to be given to the GraphQL mutations which change the settings of the server; they are refused if ""
+	Sampling string // When the WotWizard permutations are drawn at random instead of being all computed: SamplingNever, SamplingAuto or SamplingAlways
+	Samples int // Number of random draws of WotWizard permutations, when they are sampled
+	SamplingSeed int64 // Seed of the random generator used for the sampling of WotWizard permutations
+	ForecastEvery time.Duration // Minimum delay between two recorded WotWizard forecasts; none recorded if 0
+	SyncDelay time.Duration // Waiting time of Duniter after its creation of updating.txt
+	SecureGap int32 // Number of last blocks to be read again at every update, since they could have changed
+	ChangesDepth int32 // Number of last blocks whose changes of the web of trust are kept
+	
+	Currency, // Name of the currency whose files are in rsrcDir/Currencies/Currency, or "" if they are in rsrcDir
+	Currencies string // Path of the description of the currencies served by a front server, or ""
+	
+	rsrcDir, // Directory of the files of the server, set by Configure
+	initPath,
+	logPath string
+	
+	serverAddress string
+	
+	cfg *C.Set
+	
+	configured = false
+	configProcs []func () // Procedures called by Configure
 
 )
 
+// Directory of the files of the server, in the directory of resources of the executable
+func DefaultRsrcDir () string {
+	return F.Join(R.FindDir(), "duniter")
+} //DefaultRsrcDir
+
 func RsrcDir () string {
 	return rsrcDir
 }
 
+// Is name a correct currency name? Only letters, digits, '-' and '_' are allowed
+func CorrectCurrency (name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+} //CorrectCurrency
+
+// Place the files of the server in the directory of the currency name, or in rsrcDir if name is empty
+func setCurrency (name string) {
+	if name != "" {
+		M.Assert(CorrectCurrency(name), "Incorrect -currency option: " + name, 100)
+		rsrcDir = F.Join(rsrcDir, currenciesDirName, name)
+		initPath = F.Join(rsrcDir, initName)
+		logPath = F.Join(rsrcDir, logName)
+	}
+	Currency = name
+	err := os.MkdirAll(rsrcDir, 0777); M.Assert(err == nil, err, 101)
+} //setCurrency
+
 // Extract the significant characters in s; only alphanumeric characters are significant, and their case of lowest rank is returned
 func ToDown (s string) string {
 	rs := bytes.Runes([]byte(s))
@@ -170,89 +255,278 @@ func Prefix (s1, s2 string) bool {
 	return strings.HasPrefix(s2, s1)
 } //Prefix
 
-func setLog () {
-	fi, err := os.Stat(logPath)
-	M.Assert(err == nil || os.IsNotExist(err), err, 100)
-	var f *os.File
-	if err != nil || fi.Size() >= minLogSize {
-		err := os.Remove(logOldPath)
-		M.Assert(err == nil || os.IsNotExist(err), err, 101)
-		err = os.Rename(logPath, logOldPath)
-		M.Assert(err == nil || os.IsNotExist(err), err, 102)
-		f, err = os.Create(logPath)
-		M.Assert(err == nil, 103)
-	} else {
-		f, err = os.OpenFile(logPath, os.O_APPEND | os.O_WRONLY, 0644)
+// Read the former files of the configuration, init.txt and serverAddress.txt, if they exist
+func legacyConfig () map[string] string {
+	m := make(map[string] string)
+	if buf, err := os.ReadFile(initPath); err == nil {
+		m["du"] = strings.TrimSpace(string(buf))
+	}
+	if buf, err := os.ReadFile(F.Join(rsrcDir, serverAddressName)); err == nil {
+		m["address"] = strings.Trim(strings.TrimSpace(string(buf)), "\"")
 	}
-	Lg = log.New(f, "", log.Ldate | log.Ltime | log.Lshortfile)
-	M.SetLog(Lg)
-} //setLog
+	return m
+} //legacyConfig
 
-// À vérifier : Est-ce que l'option -du fonctionne bien ?
-func setDuniterPath () {
-	
-	storeDuniBase := func (du string) {
-		DuniBase = du
-		f, err := os.Create(initPath); M.Assert(err == nil, err, 100)
-		_, err = f.WriteString(DuniBase); M.Assert(err == nil, err, 101)
-		f.Close()
+// Check the update trigger spec: "file", "watch", "timer[:period]" or "http[:address]"
+func checkTrigger (spec string) error {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i + 1:]
 	}
+	switch kind {
+	case "file", "watch":
+		if arg == "" {
+			return nil
+		}
+	case "timer":
+		if arg == "" {
+			return nil
+		}
+		if d, err := time.ParseDuration(arg); err == nil && d > 0 {
+			return nil
+		}
+	case "http":
+		return nil
+	}
+	return errors.New("\"file\", \"watch\", \"timer[:period]\" or \"http[:address]\" expected, instead of \"" + spec + "\"")
+} //checkTrigger
+
+// Read the options of the command line args, defined in fs, and the configuration
+func setDuniterPath (fs *flag.FlagSet, args []string) {
+	cfg = C.New(envPrefix, fs)
+	du := cfg.String("du", duniBaseDef, "Path to the Duniter sql database, or to a JSON-lines file or directory of blocks; stored for the next starts")
+	cfg.Store("du")
+	addr := cfg.String("address", serverDefaultAddress, "Address of the GraphQL server; stored for the next starts")
+	cfg.Store("address")
+	tr := cfg.String("trigger", "file", "Trigger of updates: \"file\" (handshake with Duniter through updating.txt), \"watch\" (modification of the Duniter database), \"timer[:period]\" (e.g. timer:5m) or \"http[:address]\" (POST on /newBlock, e.g. http:localhost:8081)")
+	onError := cfg.String("onError", "halt", "What to do with a faulty block of the Duniter database: \"halt\" (stop reading before it; it's checked again at each update) or \"quarantine\" (skip its faulty entries, record them, and read the rest of it)")
+	backups := cfg.Int("backups", 0, "Number of rolling backups of the WotWizard database (DBase.data, SBase.json and DPars.json) kept in System/Backups; 0 for none")
+	backupEvery := cfg.Duration("backupEvery", 24 * time.Hour, "Minimum delay between two backups, made at the end of updates (e.g. 6h)")
+	logLevelS := cfg.String("logLevel", "info", "Minimum level of the log entries written: \"debug\", \"info\", \"warn\" or \"error\", possibly followed by levels of components, e.g. \"warn,blockchain=debug\"")
+	logFormatS := cfg.String("logFormat", TextFormat, "Format of the log: \"" + TextFormat + "\" or \"" + JSONFormat + "\" (JSON lines)")
+	logSizeS := cfg.Int("logSize", logSizeDef, "Size of the log, in MB, beyond which it's moved into log1.txt (and log1.txt into log2.txt...); no rotation if 0")
+	logCountS := cfg.Int("logCount", logCountDef, "Number of old logs kept (log1.txt, log2.txt...)")
+	maxSize := cfg.Int64("maxSize", maxSizeDef, "Greatest memory size, in bytes, allowed for the computation of the WotWizard permutations")
+	timeBudget := cfg.Duration("timeBudget", 0, "Greatest duration of the computation of the WotWizard permutations (e.g. 30s), beyond which it's stopped like when maxSize is exceeded; no limit if 0")
+	concurrencyWindow := cfg.Duration("concurrencyWindow", 0, "WotWizard dossiers whose dates differ by less than concurrencyWindow are concurrent, i.e. may enter in any order (e.g. 5m); the avgGenTime of the currency if 0")
+	adminToken := cfg.String("adminToken", "", "Secret to be given to the GraphQL mutations which change the settings of the server (changeWotWizardSettings); they are refused if empty")
+	sampling := cfg.String("sampling", SamplingAuto, "When the WotWizard permutations are drawn at random instead of being all computed: \"" + SamplingNever + "\", \"" + SamplingAuto + "\" (when maxSize is exceeded) or \"" + SamplingAlways + "\"")
+	samples := cfg.Int("samples", samplesDef, "Number of random draws of WotWizard permutations, when they are sampled")
+	samplingSeed := cfg.Int64("samplingSeed", 1, "Seed of the random generator used for the sampling of WotWizard permutations")
+	forecastEvery := cfg.Duration("forecastEvery", 24 * time.Hour, "Minimum delay between two WotWizard forecasts recorded in System/Forecasts, with the sandbox they were computed from, for the measure of their accuracy (e.g. 6h); none recorded if 0")
+	syncDelay := cfg.Duration("syncDelay", syncDelayDef, "Waiting time of Duniter after its creation of updating.txt, with the \"file\" trigger")
+	secureGap := cfg.Int("secureGap", secureGapDef, "Number of last blocks read again at every update, since they could have changed")
+	changesDepth := cfg.Int("changesDepth", changesDepthDef, "Number of last blocks whose changes of the web of trust are kept for Query.changes; the changes of older blocks are erased")
+	
+	check := fs.Bool("check", false, "Check the integrity of the WotWizard database, including the membership and certification histories, and stop; the server must not be running")
+	repair := fs.Bool("repair", false, "Check the integrity of the WotWizard database, rebuild its faulty indexes from the data records (the blocks and the membership and certification histories can't be rebuilt), and stop; the server must not be running")
+	exp := fs.String("export", "", "Write a portable snapshot of the WotWizard database into the given file and stop; the server must not be running")
+	imp := fs.String("import", "", "Replace the WotWizard database by the content of the given snapshot file and stop; the previous database is kept in DBase.data.bak and the server must not be running")
+	backtest := fs.Bool("backtest", false, "Compute again the WotWizard forecasts recorded in System/Forecasts from their sandboxes, with the current settings, compare them and the recorded ones with the actual entries, and stop; the server must not be running")
+	restore := fs.String("restore", "", "Restore the most recent verified backup whose last block is at most the given number, or \"last\", and stop; the server must not be running")
+	currency := fs.String("currency", "", "Name of the currency served; its files (configuration, log, WotWizard database, money parameters) are kept in rsrc/duniter/Currencies/<name>")
+	currencies := fs.String("currencies", "", "Path of a JSON file describing several currencies; this server only supervises them: each one is served by its own server process, started and restarted by this one, and the GraphQL requests are dispatched to them from the address of this one (status 404 for an unknown currency)")
+	config := fs.String("config", "", "Path of the configuration file (default: " + configName + " in the resource directory of the server)")
 	
-	du := flag.String("du", "", "Path to the Duniter sql database")
-	flag.Parse()
-	ok := *du != "" && F.Ext(*du) == ".db"
-	if ok {
-		f, err := os.Open(*du)
-		ok = err == nil
-		if ok {
-			f.Close()
+	cfg.Check("du", func () error {
+		path := os.ExpandEnv(*du)
+		fi, err := os.Stat(path)
+		if err != nil {
+			if cfg.Source("du") >= C.Env {
+				return errors.New("no such file or directory: " + path)
+			}
+			return nil // Duniter may not have created it yet
 		}
+		if !(fi.IsDir() || F.Ext(path) == ".db" || F.Ext(path) == ".jsonl") {
+			return errors.New(path + " is neither a directory, nor a .db or .jsonl file")
+		}
+		return nil
+	})
+	cfg.Check("address", func () error {
+		if *addr == "" {
+			return errors.New("empty address")
+		}
+		return nil
+	})
+	cfg.Check("trigger", func () error {
+		return checkTrigger(*tr)
+	})
+	cfg.Check("onError", func () error {
+		if *onError != "halt" && *onError != "quarantine" {
+			return errors.New("\"halt\" or \"quarantine\" expected, instead of \"" + *onError + "\"")
+		}
+		return nil
+	})
+	cfg.Check("backups", func () error {
+		if *backups < 0 {
+			return errors.New("negative number")
+		}
+		return nil
+	})
+	cfg.Check("backupEvery", func () error {
+		if *backupEvery < 0 {
+			return errors.New("negative duration")
+		}
+		return nil
+	})
+	cfg.Check("logLevel", func () error {
+		if _, _, ok := parseLogLevels(*logLevelS); !ok {
+			return errors.New("\"debug\", \"info\", \"warn\" or \"error\", possibly followed by levels of components, e.g. \"warn,blockchain=debug\", expected, instead of \"" + *logLevelS + "\"")
+		}
+		return nil
+	})
+	cfg.Check("logFormat", func () error {
+		if *logFormatS != TextFormat && *logFormatS != JSONFormat {
+			return errors.New("\"" + TextFormat + "\" or \"" + JSONFormat + "\" expected, instead of \"" + *logFormatS + "\"")
+		}
+		return nil
+	})
+	cfg.Check("logSize", func () error {
+		if *logSizeS < 0 {
+			return errors.New("negative size")
+		}
+		return nil
+	})
+	cfg.Check("logCount", func () error {
+		if *logCountS < 0 {
+			return errors.New("negative number")
+		}
+		return nil
+	})
+	cfg.Check("maxSize", func () error {
+		if *maxSize <= 0 {
+			return errors.New("positive size expected")
+		}
+		return nil
+	})
+	cfg.Check("timeBudget", func () error {
+		if *timeBudget < 0 {
+			return errors.New("negative duration")
+		}
+		return nil
+	})
+	cfg.Check("concurrencyWindow", func () error {
+		if *concurrencyWindow < 0 {
+			return errors.New("negative duration")
+		}
+		return nil
+	})
+	cfg.Check("sampling", func () error {
+		if *sampling != SamplingNever && *sampling != SamplingAuto && *sampling != SamplingAlways {
+			return errors.New("\"" + SamplingNever + "\", \"" + SamplingAuto + "\" or \"" + SamplingAlways + "\" expected, instead of \"" + *sampling + "\"")
+		}
+		return nil
+	})
+	cfg.Check("samples", func () error {
+		if *samples < 1 || *samples > samplesMax {
+			return errors.New("number between 1 and " + strconv.Itoa(samplesMax) + " expected")
+		}
+		return nil
+	})
+	cfg.Check("forecastEvery", func () error {
+		if *forecastEvery < 0 {
+			return errors.New("negative duration")
+		}
+		return nil
+	})
+	cfg.Check("syncDelay", func () error {
+		if *syncDelay < syncDelayMin {
+			return errors.New("at least " + syncDelayMin.String() + " expected")
+		}
+		return nil
+	})
+	cfg.Check("secureGap", func () error {
+		if *secureGap < 1 || *secureGap > secureGapMax {
+			return errors.New("number between 1 and " + strconv.Itoa(secureGapMax) + " expected")
+		}
+		return nil
+	})
+	cfg.Check("changesDepth", func () error {
+		if *changesDepth < 1 {
+			return errors.New("positive number expected")
+		}
+		return nil
+	})
+	
+	if err := fs.Parse(args); err != nil {
+		C.Exit(err)
 	}
-	if ok {
-		storeDuniBase(*du)
+	M.Assert(*currency == "" || *currencies == "", "-currency and -currencies are exclusive", 105)
+	M.Assert(*exp == "" || *imp == "", "-export and -import are exclusive", 103)
+	setCurrency(*currency)
+	path := *config
+	if path == "" {
+		path = F.Join(rsrcDir, configName)
+	}
+	if err := cfg.Load(path, legacyConfig); err != nil {
+		C.Exit(err)
+	}
+	os.Remove(initPath); os.Remove(F.Join(rsrcDir, serverAddressName)) // Replaced by the configuration file
+	
+	setLogLevels(*logLevelS)
+	logFormat = *logFormatS
+	logSize = int64(*logSizeS) << 20
+	logCount = *logCountS
+	Currencies = *currencies
+	serverAddress = *addr
+	UpdateTrigger = *tr
+	IngestionPolicy = *onError
+	Backups = *backups
+	BackupEvery = *backupEvery
+	MaxSize = *maxSize
+	TimeBudget = *timeBudget
+	ConcurrencyWindow = *concurrencyWindow
+	AdminToken = *adminToken
+	Sampling = *sampling
+	Samples = *samples
+	SamplingSeed = *samplingSeed
+	ForecastEvery = *forecastEvery
+	SyncDelay = *syncDelay
+	SecureGap = int32(*secureGap)
+	ChangesDepth = int32(*changesDepth)
+	RestoreBackup = *restore
+	ExportSnapshot = *exp
+	ImportSnapshot = *imp
+	CheckBase = *check || *repair
+	RepairBase = *repair
+	Backtest = *backtest
+	DuniBase = os.ExpandEnv(*du)
+	if fi, err := os.Stat(DuniBase); err == nil && fi.IsDir() {
+		DuniDir = DuniBase
 	} else {
-		f, err := os.Open(initPath)
-		if err == nil {
-			sc := bufio.NewScanner(f)
-			b := sc.Scan(); M.Assert(b, 100)
-			DuniBase = sc.Text()
-			b = sc.Scan(); M.Assert(!b && sc.Err() == nil, 101)
-			f.Close()
-		} else {
-			storeDuniBase(os.ExpandEnv(duniBaseDef))
-		}
+		DuniDir = F.Dir(DuniBase)
 	}
-	DuniDir = F.Dir(DuniBase)
 } //setDuniterPath
 
 func ServerAddress () string {
 	return serverAddress
 }
 
-func fixServerAddress () {
-	name := F.Join(rsrcDir, serverAddressName)
-	f, err := os.Open(name)
-	if err == nil {
-		defer f.Close()
-		s := new(scanner.Scanner)
-		s.Init(f)
-		s.Error = func(s *scanner.Scanner, msg string) {panic(errors.New("File " + name + " incorrect"))}
-		s.Mode = scanner.ScanStrings
-		s.Scan()
-		ss := s.TokenText()
-		M.Assert(ss[0] == '"' && ss[len(ss) - 1] == '"', ss, 101)
-		serverAddress = ss[1:len(ss) - 1]
-	} else {
-		f, err := os.Create(name)
-		M.Assert(err == nil, err, 102)
-		defer f.Close()
-		fmt.Fprint(f, "\"" + serverAddress + "\"")
-	}
+// Path of the configuration file
+func ConfigPath () string {
+	return cfg.Path()
 }
 
-func init () {
-	err := os.MkdirAll(rsrcDir, 0777); M.Assert(err == nil, err, 100)
+// Register proc, which reads the configuration, to be called by Configure; proc is called at once if Configure has already been called
+func AddConfigProc (proc func ()) {
+	if configured {
+		proc()
+		return
+	}
+	configProcs = append(configProcs, proc)
+} //AddConfigProc
+
+// Configure the server, whose files are in the directory dir, from the command line args, whose options are defined in fs, from the environment and from the configuration file, then call the procedures registered by AddConfigProc; must be called once, before any other use of the server: by the main program with DefaultRsrcDir(), flag.CommandLine and os.Args[1:], or by a test with its own directory and FlagSet
+func Configure (dir string, fs *flag.FlagSet, args []string) {
+	M.Assert(!configured, 20)
+	rsrcDir = dir
+	initPath = F.Join(rsrcDir, initName)
+	logPath = F.Join(rsrcDir, logName)
+	setDuniterPath(fs, args)
 	setLog()
-	setDuniterPath()
-	fixServerAddress()
-} //init
+	configured = true
+	for _, proc := range configProcs {
+		proc()
+	}
+	configProcs = nil
+} //Configure
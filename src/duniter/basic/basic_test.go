@@ -0,0 +1,27 @@
+package basic
+
+import (
+
+	F	"path/filepath"
+	M	"util/misc"
+		"flag"
+		"os"
+		"testing"
+
+)
+
+func TestConfigure (t *testing.T) {
+	var before, after int
+	AddConfigProc(func () {before = int(SecureGap)})
+	dir := t.TempDir()
+	Configure(dir, flag.NewFlagSet("test", flag.ContinueOnError), []string{"-secureGap", "50", "-currency", "g1-test"})
+	M.Want(before == 50 && SecureGap == 50, t) // Called by Configure, after the command line was read
+	AddConfigProc(func () {after = int(SecureGap)})
+	M.Want(after == 50, t) // Called at once
+	M.Want(Currency == "g1-test" && RsrcDir() == F.Join(dir, currenciesDirName, "g1-test"), t)
+	M.Want(ConfigPath() == F.Join(RsrcDir(), configName), t)
+	_, err := os.Stat(ConfigPath())
+	M.Want(err == nil, t) // The stored settings are written
+	_, err = os.Stat(logPath)
+	M.Want(err == nil && F.Dir(logPath) == RsrcDir(), t)
+}
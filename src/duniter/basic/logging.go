@@ -0,0 +1,303 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package basic
+
+// Levelled logging into log.txt, with component tags, correlation ids, text or JSON-lines output, and rotation into log1.txt, log2.txt...
+
+import (
+	
+	F	"path/filepath"
+	J	"encoding/json"
+	M	"util/misc"
+		"fmt"
+		"log"
+		"os"
+		"runtime"
+		"strconv"
+		"strings"
+		"sync"
+		"time"
+
+)
+
+const (
+	
+	// Levels of log entries
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	
+	// Formats of the log, for the "-logFormat" option
+	TextFormat = "text"
+	JSONFormat = "json"
+	
+	logSizeDef = 50 // Default maximum size of the log, in MB
+	logCountDef = 1 // Default number of old logs kept
+	
+	timeFormat = "2006/01/02 15:04:05"
+
+)
+
+type (
+	
+	Level int
+	
+	// Logger of a component; its entries may be correlated by an id
+	Logger struct {
+		component,
+		id string
+	}
+	
+	// Entry of the log in JSON-lines format
+	logEntry struct {
+		Time string `json:"time"`
+		Level string `json:"level"`
+		Component string `json:"component"`
+		Id string `json:"id,omitempty"`
+		Source string `json:"source,omitempty"`
+		Msg string `json:"msg"`
+	}
+	
+	// Output of the log, rotated when it's too big
+	logSink struct {
+		mut sync.Mutex
+		f *os.File
+		size int64
+	}
+	
+	// Writer of Lg, whose lines are entries of the "misc" component at level ErrorLevel
+	lgWriter struct {
+	}
+
+)
+
+var (
+	
+	levelNames = [...]string{DebugLevel: "debug", InfoLevel: "info", WarnLevel: "warn", ErrorLevel: "error"}
+	
+	// Minimum level of the entries written, and exceptions by components; set by the "-logLevel" option
+	logLevel = InfoLevel
+	componentLevels = make(map[string]Level)
+	
+	logFormat = TextFormat
+	logSize int64 = logSizeDef << 20 // Size beyond which the log is rotated, in bytes; no rotation if 0
+	logCount = logCountDef // Number of old logs kept
+	
+	sink logSink
+	
+	idMut sync.Mutex
+	idNums = make(map[string]int) // Last correlation id number by prefix
+
+)
+
+func (lv Level) String () string {
+	return levelNames[lv]
+} //String
+
+// Return the level of name; ok == false if there is no such level
+func levelOf (name string) (lv Level, ok bool) {
+	for l, n := range levelNames {
+		if n == name {
+			return Level(l), true
+		}
+	}
+	return
+} //levelOf
+
+// Read the "-logLevel" option s, e.g. "info" or "warn,blockchain=debug", into the minimum level lv and the levels of components cls; ok == false if s is incorrect
+func parseLogLevels (s string) (lv Level, cls map[string]Level, ok bool) {
+	lv = InfoLevel
+	cls = make(map[string]Level)
+	for _, f := range strings.Split(s, ",") {
+		if i := strings.IndexByte(f, '='); i >= 0 {
+			l, ok := levelOf(f[i + 1:])
+			if !ok || i == 0 {
+				return lv, cls, false
+			}
+			cls[f[:i]] = l
+		} else {
+			l, ok := levelOf(f)
+			if !ok {
+				return lv, cls, false
+			}
+			lv = l
+		}
+	}
+	return lv, cls, true
+} //parseLogLevels
+
+// Apply the "-logLevel" option s, already checked by parseLogLevels
+func setLogLevels (s string) {
+	lv, cls, ok := parseLogLevels(s); M.Assert(ok, s, 100)
+	logLevel = lv
+	componentLevels = cls
+} //setLogLevels
+
+// Name of the i-th old log; the present one if i == 0
+func logPathNb (i int) string {
+	if i == 0 {
+		return logPath
+	}
+	return F.Join(rsrcDir, logRoot + strconv.Itoa(i) + logExt)
+} //logPathNb
+
+// Open the log at the end of logPath
+func (s *logSink) open () {
+	f, err := os.OpenFile(logPath, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0644); M.Assert(err == nil, err, 100)
+	fi, err := f.Stat(); M.Assert(err == nil, err, 101)
+	s.f = f
+	s.size = fi.Size()
+} //open
+
+// Shift the old logs, keeping logCount of them, and start a new log
+func (s *logSink) rotate () {
+	s.f.Close()
+	if logCount == 0 {
+		os.Remove(logPath)
+	} else {
+		os.Remove(logPathNb(logCount))
+		for i := logCount - 1; i >= 0; i-- {
+			os.Rename(logPathNb(i), logPathNb(i + 1))
+		}
+	}
+	s.open()
+} //rotate
+
+// Write b into the log, or onto the standard error before Configure has opened it
+func (s *logSink) write (b []byte) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.f == nil {
+		os.Stderr.Write(b)
+		return
+	}
+	if logSize > 0 && s.size > 0 && s.size + int64(len(b)) > logSize {
+		s.rotate()
+	}
+	n, _ := s.f.Write(b)
+	s.size += int64(n)
+} //write
+
+// Write an entry of level lv, component component, correlation id id, source source and message msg
+func writeEntry (lv Level, component, id, source, msg string) {
+	now := time.Now()
+	var b []byte
+	if logFormat == JSONFormat {
+		if msg == "" {
+			return
+		}
+		var err error
+		b, err = J.Marshal(&logEntry{Time: now.Format(time.RFC3339Nano), Level: lv.String(), Component: component, Id: id, Source: source, Msg: msg}); M.Assert(err == nil, err, 100)
+		b = append(b, '\n')
+	} else if msg == "" {
+		b = []byte{'\n'}
+	} else {
+		var sb strings.Builder
+		sb.WriteString(now.Format(timeFormat))
+		sb.WriteString(" " + strings.ToUpper(lv.String()) + " [" + component)
+		if id != "" {
+			sb.WriteString(" " + id)
+		}
+		sb.WriteString("] ")
+		if source != "" {
+			sb.WriteString(source + ": ")
+		}
+		sb.WriteString(msg)
+		sb.WriteByte('\n')
+		b = []byte(sb.String())
+	}
+	sink.write(b)
+} //writeEntry
+
+func (lgWriter) Write (p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	source := ""
+	if i := strings.Index(msg, ": "); i >= 0 && strings.Contains(msg[:i], ".go:") {
+		source = msg[:i]
+		msg = msg[i + 2:]
+	}
+	writeEntry(ErrorLevel, "misc", "", source, msg)
+	return len(p), nil
+} //Write
+
+// Return a new logger for the component component
+func NewLogger (component string) *Logger {
+	return &Logger{component: component}
+} //NewLogger
+
+// Return a copy of l whose entries are correlated by id
+func (l *Logger) In (id string) *Logger {
+	return &Logger{component: l.component, id: id}
+} //In
+
+// Return a new correlation id, made of prefix and a number
+func NewId (prefix string) string {
+	idMut.Lock()
+	idNums[prefix]++
+	n := idNums[prefix]
+	idMut.Unlock()
+	return prefix + strconv.Itoa(n)
+} //NewId
+
+// Correlation id of l
+func (l *Logger) Id () string {
+	return l.id
+} //Id
+
+// Are the entries of level lv of l written?
+func (l *Logger) Enabled (lv Level) bool {
+	min, ok := componentLevels[l.component]
+	if !ok {
+		min = logLevel
+	}
+	return lv >= min
+} //Enabled
+
+func (l *Logger) output (lv Level, v ... interface{}) {
+	if !l.Enabled(lv) {
+		return
+	}
+	source := ""
+	if _, file, line, ok := runtime.Caller(2); ok {
+		source = F.Base(file) + ":" + strconv.Itoa(line)
+	}
+	writeEntry(lv, l.component, l.id, source, strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+} //output
+
+func (l *Logger) Debug (v ... interface{}) {
+	l.output(DebugLevel, v...)
+} //Debug
+
+func (l *Logger) Info (v ... interface{}) {
+	l.output(InfoLevel, v...)
+} //Info
+
+func (l *Logger) Warn (v ... interface{}) {
+	l.output(WarnLevel, v...)
+} //Warn
+
+func (l *Logger) Error (v ... interface{}) {
+	l.output(ErrorLevel, v...)
+} //Error
+
+// Same as Info
+func (l *Logger) Println (v ... interface{}) {
+	l.output(InfoLevel, v...)
+} //Println
+
+func setLog () {
+	sink.open()
+	Lg = log.New(lgWriter{}, "", log.Lshortfile)
+	M.SetLog(Lg)
+} //setLog
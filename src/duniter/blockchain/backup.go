@@ -0,0 +1,252 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Rolling backups of dBase, sBase and dPars, made at the end of updates, with checksums, and their restoration
+
+import (
+	
+	BA	"duniter/basic"
+	F	"path/filepath"
+	J	"encoding/json"
+	M	"util/misc"
+	SH	"crypto/sha256"
+		"encoding/hex"
+		"fmt"
+		"io"
+		"os"
+		"sort"
+		"strconv"
+		"time"
+
+)
+
+const (
+	
+	// Directory of the backups in system
+	backupsDirName = "Backups"
+	// Description of a backup, in its directory; a backup without it is incomplete
+	manifestName = "manifest.json"
+
+)
+
+type (
+	
+	// File of a backup
+	backupFile struct {
+		Name string
+		Size int64
+		Sha256 string // Hexadecimal checksum
+	}
+	
+	// Description of a backup
+	backupManifest struct {
+		Block int32 // Last block of the saved dBase
+		Date int64 // Real time of the backup, in s
+		Files []backupFile
+	}
+	
+	// Backup found in backupsDir
+	backupT struct {
+		dir string
+		man *backupManifest // nil if incomplete
+	}
+
+)
+
+var (
+	
+	backupsDir string // Set by setPaths
+	
+	// Real time of the last backup, zero if unknown yet; Updt
+	lastBackup time.Time
+
+)
+
+// Copy the file src into dst; return the size and checksum of src
+func copyFile (src, dst string) (size int64, sum string) {
+	f, err := os.Open(src); M.Assert(err == nil, err, 100)
+	defer f.Close()
+	fC, err := os.Create(dst); M.Assert(err == nil, err, 101)
+	defer fC.Close()
+	h := SH.New()
+	size, err = io.Copy(io.MultiWriter(fC, h), f); M.Assert(err == nil, err, 102)
+	err = fC.Sync(); M.Assert(err == nil, err, 103)
+	return size, hex.EncodeToString(h.Sum(nil))
+} //copyFile
+
+// Size and checksum of the file path; ok == false if it can't be read
+func checksum (path string) (size int64, sum string, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	h := SH.New()
+	size, err = io.Copy(h, f)
+	return size, hex.EncodeToString(h.Sum(nil)), err == nil
+} //checksum
+
+// Return the backups of backupsDir, by increasing blocks and dates
+func listBackups () []backupT {
+	ds, err := os.ReadDir(backupsDir)
+	if err != nil {
+		return nil
+	}
+	bs := make([]backupT, 0, len(ds))
+	for _, d := range ds {
+		if !d.IsDir() {
+			continue
+		}
+		b := backupT{dir: F.Join(backupsDir, d.Name())}
+		if bb, err := os.ReadFile(F.Join(b.dir, manifestName)); err == nil {
+			man := new(backupManifest)
+			if J.Unmarshal(bb, man) == nil {
+				b.man = man
+			}
+		}
+		bs = append(bs, b)
+	}
+	sort.Slice(bs, func (i, j int) bool {return bs[i].dir < bs[j].dir}) // Names begin with the block number, then the date
+	return bs
+} //listBackups
+
+// Verify the files of the backup b against its checksums; return the first fault, or ""
+func verifyBackup (b backupT) string {
+	if b.man == nil {
+		return "incomplete backup"
+	}
+	for _, bf := range b.man.Files {
+		size, sum, ok := checksum(F.Join(b.dir, bf.Name))
+		if !ok {
+			return bf.Name + " unreadable"
+		}
+		if size != bf.Size || sum != bf.Sha256 {
+			return bf.Name + " corrupted"
+		}
+	}
+	return ""
+} //verifyBackup
+
+// Updt
+// Copy dBase, sBase and dPars into a new directory of backupsDir, verify the copies and write the manifest; dBase must be up to date on disk
+func makeBackup () {
+	lgU.Println("Backing up \"" + dBaseName + "\" at block", lastBlock)
+	now := time.Now()
+	dir := F.Join(backupsDir, fmt.Sprintf("%010d-%d", lastBlock, now.Unix()))
+	err := os.MkdirAll(dir, 0777); M.Assert(err == nil, err, 100)
+	man := &backupManifest{Block: lastBlock, Date: now.Unix(), Files: make([]backupFile, 0, 3)}
+	for _, src := range [3]string{dBase, sBase, dPars} {
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		name := F.Base(src)
+		dst := F.Join(dir, name)
+		size, sum := copyFile(src, dst)
+		if s2, sum2, ok := checksum(dst); !ok || s2 != size || sum2 != sum {
+			lgU.Error("Backup of", name, "differs from the original; backup abandoned")
+			os.RemoveAll(dir)
+			return
+		}
+		man.Files = append(man.Files, backupFile{Name: name, Size: size, Sha256: sum})
+	}
+	bb, err := J.MarshalIndent(man, "", "\t"); M.Assert(err == nil, err, 101)
+	err = os.WriteFile(F.Join(dir, manifestName), bb, 0666); M.Assert(err == nil, err, 102)
+	lastBackup = now
+	lgU.Println("Backup made in", dir)
+} //makeBackup
+
+// Updt
+// Remove the oldest backups, and the incomplete ones, so that BA.Backups ones remain
+func pruneBackups () {
+	bs := listBackups()
+	complete := make([]backupT, 0, len(bs))
+	for _, b := range bs {
+		if b.man == nil {
+			os.RemoveAll(b.dir)
+		} else {
+			complete = append(complete, b)
+		}
+	}
+	sort.SliceStable(complete, func (i, j int) bool {return complete[i].man.Date < complete[j].man.Date})
+	for len(complete) > BA.Backups {
+		lgU.Println("Removing backup", complete[0].dir)
+		os.RemoveAll(complete[0].dir)
+		complete = complete[1:]
+	}
+} //pruneBackups
+
+// Updt
+// Make a backup if backups are on and the last one is older than BA.BackupEvery; called at the end of an update, when dBase is up to date on disk, so that no backup catches an update in progress
+func backupUpdt () {
+	if BA.Backups <= 0 {
+		return
+	}
+	if lastBackup.IsZero() {
+		for _, b := range listBackups() {
+			if b.man != nil && b.man.Date > lastBackup.Unix() {
+				lastBackup = time.Unix(b.man.Date, 0)
+			}
+		}
+	}
+	if time.Since(lastBackup) < BA.BackupEvery {
+		return
+	}
+	makeBackup()
+	pruneBackups()
+} //backupUpdt
+
+// Restore dBase, sBase and dPars from the most recent verified backup whose last block is at most which, or from the most recent verified backup if which is "last"; the server must not be running, and the replaced dBase is kept as dCopy; return a report, and whether a backup was restored
+func RestoreBackup (which string) (report []string, ok bool) {
+	var maxBlock int32 = M.MaxInt32
+	if which != "last" {
+		n, err := strconv.Atoi(which)
+		if err != nil || n < 0 {
+			return []string{"Incorrect block number: " + which}, false
+		}
+		maxBlock = int32(n)
+	}
+	bs := make([]backupT, 0)
+	for _, b := range listBackups() {
+		if b.man != nil {
+			bs = append(bs, b)
+		}
+	}
+	sort.SliceStable(bs, func (i, j int) bool {
+		return bs[i].man.Block > bs[j].man.Block || bs[i].man.Block == bs[j].man.Block && bs[i].man.Date > bs[j].man.Date
+	})
+	for _, b := range bs {
+		if b.man.Block > maxBlock {
+			continue
+		}
+		if fault := verifyBackup(b); fault != "" {
+			report = append(report, "Backup " + b.dir + " skipped: " + fault)
+			continue
+		}
+		saveBase()
+		for _, path := range [3]string{dBase, sBase, dPars} { // Files absent from the backup mustn't be mixed with it
+			err := os.Remove(path); M.Assert(err == nil || os.IsNotExist(err), err, 100)
+		}
+		for _, bf := range b.man.Files {
+			copyFile(F.Join(b.dir, bf.Name), F.Join(system, bf.Name))
+		}
+		report = append(report, fmt.Sprint("Backup ", b.dir, " of block ", b.man.Block, " restored"))
+		lg.Println(report[len(report) - 1])
+		return report, true
+	}
+	report = append(report, "No usable backup up to block " + which)
+	for _, b := range bs {
+		report = append(report, fmt.Sprint("Available: block ", b.man.Block, " of ", time.Unix(b.man.Date, 0).Format("2/01/2006 15:04:05")))
+	}
+	return report, false
+} //RestoreBackup
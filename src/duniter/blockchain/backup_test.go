@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+
+	BA	"duniter/basic"
+	F	"path/filepath"
+	M	"util/misc"
+		"os"
+		"reflect"
+		"strings"
+		"testing"
+		"time"
+
+)
+
+// Keep n backups, made at every update, for the duration of the test t
+func setBackups (t *testing.T, n int) {
+	oldN, oldEvery := BA.Backups, BA.BackupEvery
+	BA.Backups, BA.BackupEvery = n, 0
+	lastBackup = time.Time{}
+	os.RemoveAll(backupsDir)
+	t.Cleanup(func () {BA.Backups, BA.BackupEvery = oldN, oldEvery; os.RemoveAll(backupsDir)})
+} //setBackups
+
+// Read src into the open dBase and back it up, as at the end of an update; return the snapshot of dBase
+func updateAndBackup (t *testing.T, src BlockSource) *snapshot {
+	updateFrom(src)
+	M.Want(lastIngErr == nil, t)
+	exportParameters()
+	database.UpdateBase()
+	backupUpdt()
+	return takeSnapshot()
+} //updateAndBackup
+
+func TestBackups (t *testing.T) {
+	setBackups(t, 2)
+	newBase()
+	updateAndBackup(t, NewSource(fixture("expiry")))
+	s101 := updateAndBackup(t, expiryWith(t, joinBlock))
+	updateAndBackup(t, expiryWith(t, joinBlock, exclusionBlock))
+
+	// Rotation: the backup of the block 100 is removed
+	bs := listBackups()
+	M.Want(len(bs) == 2 && bs[0].man.Block == 101 && bs[1].man.Block == 102, t)
+	for _, b := range bs {
+		M.Want(verifyBackup(b) == "" && len(b.man.Files) == 2, t) // dBase and dPars, without sandbox
+	}
+
+	// The most recent backup is corrupted: it's skipped, and the previous one is restored
+	dBase102 := F.Join(bs[1].dir, dBaseName)
+	b, err := os.ReadFile(dBase102); M.Assert(err == nil, err, 100)
+	b[len(b) / 2] ^= 0xFF
+	err = os.WriteFile(dBase102, b, 0666); M.Assert(err == nil, err, 101)
+	M.Want(verifyBackup(bs[1]) == dBaseName + " corrupted", t)
+	closeB()
+	report, ok := RestoreBackup("last")
+	M.Want(ok && len(report) == 2 && strings.HasSuffix(report[0], "skipped: " + dBaseName + " corrupted") && strings.HasSuffix(report[1], "of block 101 restored"), t)
+	openB()
+	if !reflect.DeepEqual(s101, takeSnapshot()) {
+		t.Error("The restored dBase differs from the backed up one")
+	}
+	closeB()
+
+	// No backup up to the block 100 is left
+	report, ok = RestoreBackup("100")
+	M.Want(!ok && len(report) == 3 && report[0] == "No usable backup up to block 100", t)
+	report, ok = RestoreBackup("-1")
+	M.Want(!ok && len(report) == 1, t)
+
+	// An incomplete backup is removed by the next backup
+	err = os.Mkdir(F.Join(backupsDir, "0000000050-1"), 0777); M.Assert(err == nil, err, 102)
+	openB()
+	updateAndBackup(t, expiryWith(t, joinBlock, exclusionBlock))
+	bs = listBackups()
+	M.Want(len(bs) == 2, t)
+	for _, b := range bs {
+		M.Want(b.man != nil, t)
+	}
+}
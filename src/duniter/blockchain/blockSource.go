@@ -0,0 +1,376 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Sources of Duniter blocks: the SQLite export of Duniter and JSON-lines files (or fixture directories)
+
+import (
+	
+	BA	"duniter/basic"
+	F	"path/filepath"
+	J	"encoding/json"
+	M	"util/misc"
+	Q	"database/sql"
+		"bufio"
+		"bytes"
+		"io"
+		"os"
+	_	"github.com/mattn/go-sqlite3"
+
+)
+
+const (
+	
+	// Extension of JSON-lines sources
+	jsonLinesExt = ".jsonl"
+	// Name of the JSON-lines file in a fixture directory
+	jsonLinesName = "blocks.jsonl"
+
+)
+
+type (
+	
+	// A block, as delivered by a BlockSource; lists are Duniter inline documents in the bracketed form read by scanBlocksUpdt (see schema.go)
+	SourceBlock struct {
+		Number int
+		Hash Hash
+		MedianTime,
+		Time int64
+		Joiners,
+		Actives,
+		Leavers,
+		Revoked,
+		Excluded,
+		Certifications string
+	}
+	
+	// Source of the Duniter blockchain (fork blocks excluded)
+	BlockSource interface {
+		Open ()
+		Close ()
+		// Parameters string of block 0
+		Parameters () string
+		// Number of the last block; ok == false if the source is empty
+		LastNumber () (n int, ok bool)
+		// Median time of the block number n; ok == false if it doesn't exist
+		MedianTime (n int) (mTime int64, ok bool)
+		// Hash of the block number n, "" if unknown; ok == false if the block doesn't exist
+		BlockHash (n int) (h Hash, ok bool)
+		// Call do for all the blocks whose numbers are between from and to (both included), in increasing order
+		Blocks (from, to int, do func (b *SourceBlock))
+		// Hashes of the last identities written with the pubkeys ps in the block number bnb or before; a pubkey without such an identity is absent from the result
+		IdHashes (ps []Pubkey, bnb int) map[Pubkey] Hash
+	}
+	
+	// SQLite export of Duniter
+	sqlSource struct {
+		path string
+		d *DuniterDB
+	}
+	
+	// JSON-lines source; each line describes one block
+	jsonSource struct {
+		path string
+		offsets []int64 // Offsets of the lines in the file, indexed by block numbers
+		mTimes []int64 // Median times, indexed by block numbers
+		bHashes []Hash // Hashes of blocks, indexed by block numbers
+		hashes map[Pubkey][]idHash // Identities written with each pubkey, by increasing blocks
+		params string
+	}
+	
+	// Identity written in a JSON-lines source
+	idHash struct {
+		bnb int // Block where it's written
+		hash Hash
+	}
+	
+	// Line of a JSON-lines source
+	jsonBlock struct {
+		Number int `json:"number"`
+		Hash Hash `json:"hash"`
+		MedianTime int64 `json:"medianTime"`
+		Time int64 `json:"time"`
+		Parameters string `json:"parameters"`
+		Joiners J.RawMessage `json:"joiners"`
+		Actives J.RawMessage `json:"actives"`
+		Leavers J.RawMessage `json:"leavers"`
+		Revoked J.RawMessage `json:"revoked"`
+		Excluded J.RawMessage `json:"excluded"`
+		Certifications J.RawMessage `json:"certifications"`
+		// Identities written in this block, with their hashes (i_index)
+		Identities []jsonIdentity `json:"identities"`
+	}
+	
+	jsonIdentity struct {
+		Pub Pubkey `json:"pub"`
+		Hash Hash `json:"hash"`
+	}
+
+)
+
+var (
+	
+	// Current source
+	source BlockSource = nil
+
+)
+
+// Fix the source of blocks; must be called before Start; by default, the source is deduced from BA.DuniBase
+func SetBlockSource (s BlockSource) {
+	M.Assert(s != nil, 20)
+	source = s
+} //SetBlockSource
+
+// Return the current source of blocks
+func Source () BlockSource {
+	if source == nil {
+		source = NewSource(BA.DuniBase)
+	}
+	return source
+} //Source
+
+// Return a new source for path: a JSON-lines source if path is a directory or a ".jsonl" file, a SQLite source otherwise
+func NewSource (path string) BlockSource {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return &jsonSource{path: F.Join(path, jsonLinesName)}
+	}
+	if F.Ext(path) == jsonLinesExt {
+		return &jsonSource{path: path}
+	}
+	return &sqlSource{path: path}
+} //NewSource
+
+// Return the path of the current source if it's a SQLite database, where the sandbox can be found; use the Schema of OpenDuniterDB to know whether it's there
+func SqlBase () (path string, ok bool) {
+	var s *sqlSource
+	s, ok = Source().(*sqlSource)
+	if ok {
+		path = s.path
+	}
+	return
+} //SqlBase
+
+func (s *sqlSource) Open () {
+	lgU.Println("Opening Duniter database")
+	s.d = OpenDuniterDB(s.path)
+	lgU.Println("Schema of Duniter database:", s.d.Schema().Version)
+} //Open
+
+func (s *sqlSource) Close () {
+	s.d.Close()
+	s.d = nil
+} //Close
+
+func (s *sqlSource) Parameters () string {
+	row := s.d.QueryRow("SELECT parameters FROM block b WHERE number == 0 AND NOT " + s.d.Schema().Fork("b"))
+	var ns Q.NullString
+	err := row.Scan(&ns)
+	M.Assert(err == nil, err, 100)
+	M.Assert(ns.Valid, 101)
+	return ns.String
+} //Parameters
+
+func (s *sqlSource) LastNumber () (n int, ok bool) {
+	row := s.d.QueryRow("SELECT max(number) FROM block b WHERE NOT " + s.d.Schema().Fork("b"))
+	ok = row.Scan(&n) == nil
+	return
+} //LastNumber
+
+func (s *sqlSource) MedianTime (n int) (mTime int64, ok bool) {
+	row := s.d.QueryRow("SELECT medianTime FROM block b WHERE NOT " + s.d.Schema().Fork("b") + " AND number = ?", n)
+	var m interface{}
+	ok = row.Scan(&m) == nil && m != nil
+	if ok {
+		mTime = unixTime(m)
+	}
+	return
+} //MedianTime
+
+func (s *sqlSource) BlockHash (n int) (h Hash, ok bool) {
+	row := s.d.QueryRow("SELECT hash FROM block b WHERE NOT " + s.d.Schema().Fork("b") + " AND number = ?", n)
+	var ns Q.NullString
+	ok = row.Scan(&ns) == nil
+	if ok && ns.Valid {
+		h = Hash(ns.String)
+	}
+	return
+} //BlockHash
+
+func (s *sqlSource) Blocks (from, to int, do func (b *SourceBlock)) {
+	rs := s.d.Query("SELECT number, hash, medianTime, time, joiners, actives, leavers, revoked, excluded, certifications FROM block b WHERE NOT " + s.d.Schema().Fork("b") + " AND number BETWEEN ? AND ? ORDER BY number ASC", from, to)
+	defer rs.Close()
+	for rs.Next() {
+		var (
+			b SourceBlock
+			h Q.NullString
+			m,
+			t interface{}
+			j,
+			a,
+			l,
+			r,
+			e,
+			c Q.NullString
+		)
+		err := rs.Scan(&b.Number, &h, &m, &t, &j, &a, &l, &r, &e, &c)
+		M.Assert(err == nil, err, 101)
+		if h.Valid {
+			b.Hash = Hash(h.String)
+		}
+		b.MedianTime = unixTime(m)
+		b.Time = unixTime(t)
+		sch := s.d.Schema()
+		b.Joiners = sch.list(j)
+		b.Actives = sch.list(a)
+		b.Leavers = sch.list(l)
+		b.Revoked = sch.list(r)
+		b.Excluded = sch.list(e)
+		b.Certifications = sch.list(c)
+		do(&b)
+	}
+	M.Assert(rs.Err() == nil, rs.Err(), 60)
+} //Blocks
+
+func (s *sqlSource) IdHashes (ps []Pubkey, bnb int) map[Pubkey] Hash {
+	return s.d.IdHashes(ps, bnb)
+} //IdHashes
+
+// Call do for each line of the source from the offset pos, in order, with its offset, until do returns false
+func (s *jsonSource) lines (pos int64, do func (b *jsonBlock, pos int64) bool) {
+	f, err := os.Open(s.path); M.Assert(err == nil, err, 100)
+	defer f.Close()
+	_, err = f.Seek(pos, io.SeekStart); M.Assert(err == nil, err, 101)
+	r := bufio.NewReader(f)
+	for {
+		l, err := r.ReadBytes('\n')
+		M.Assert(err == nil || err == io.EOF, err, 102)
+		start := pos
+		pos += int64(len(l))
+		if l = bytes.TrimSpace(l); len(l) > 0 {
+			b := new(jsonBlock)
+			e := J.Unmarshal(l, b); M.Assert(e == nil, e, 103)
+			if !do(b, start) {
+				return
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+	}
+} //lines
+
+// Read the whole source once, to fix the offsets of the blocks, their median times and hashes, and the parameters
+func (s *jsonSource) Open () {
+	lgU.Println("Opening", s.path)
+	s.offsets = make([]int64, 0)
+	s.mTimes = make([]int64, 0)
+	s.bHashes = make([]Hash, 0)
+	s.hashes = make(map[Pubkey][]idHash)
+	s.params = ""
+	s.lines(0, func (b *jsonBlock, pos int64) bool {
+		M.Assert(b.Number == len(s.mTimes), b.Number, 100)
+		s.offsets = append(s.offsets, pos)
+		s.mTimes = append(s.mTimes, b.MedianTime)
+		s.bHashes = append(s.bHashes, b.Hash)
+		if b.Number == 0 {
+			s.params = b.Parameters
+		}
+		for _, id := range b.Identities {
+			s.hashes[id.Pub] = append(s.hashes[id.Pub], idHash{bnb: b.Number, hash: id.Hash})
+		}
+		return true
+	})
+} //Open
+
+func (s *jsonSource) Close () {
+	s.offsets = nil
+	s.mTimes = nil
+	s.bHashes = nil
+	s.hashes = nil
+} //Close
+
+func (s *jsonSource) Parameters () string {
+	M.Assert(s.params != "", 100)
+	return s.params
+} //Parameters
+
+func (s *jsonSource) LastNumber () (n int, ok bool) {
+	n = len(s.mTimes) - 1
+	ok = n >= 0
+	return
+} //LastNumber
+
+func (s *jsonSource) MedianTime (n int) (mTime int64, ok bool) {
+	ok = n >= 0 && n < len(s.mTimes)
+	if ok {
+		mTime = s.mTimes[n]
+	}
+	return
+} //MedianTime
+
+func (s *jsonSource) BlockHash (n int) (h Hash, ok bool) {
+	ok = n >= 0 && n < len(s.bHashes)
+	if ok {
+		h = s.bHashes[n]
+	}
+	return
+} //BlockHash
+
+// Bracketed form of a JSON list of inline documents, as for the SQLite export; an absent or null list is empty
+func jsonList (l J.RawMessage) string {
+	var docs []string
+	if len(l) > 0 {
+		err := J.Unmarshal(l, &docs); M.Assert(err == nil, err, 100)
+	}
+	return bracketed(docs)
+} //jsonList
+
+func (s *jsonSource) Blocks (from, to int, do func (b *SourceBlock)) {
+	if from < 0 {
+		from = 0
+	}
+	if from > to || from >= len(s.offsets) {
+		return
+	}
+	s.lines(s.offsets[from], func (b *jsonBlock, _ int64) bool {
+		if b.Number > to {
+			return false
+		}
+		do(&SourceBlock{
+			Number: b.Number,
+			Hash: b.Hash,
+			MedianTime: b.MedianTime,
+			Time: b.Time,
+			Joiners: jsonList(b.Joiners),
+			Actives: jsonList(b.Actives),
+			Leavers: jsonList(b.Leavers),
+			Revoked: jsonList(b.Revoked),
+			Excluded: jsonList(b.Excluded),
+			Certifications: jsonList(b.Certifications),
+		})
+		return true
+	})
+} //Blocks
+
+func (s *jsonSource) IdHashes (ps []Pubkey, bnb int) map[Pubkey] Hash {
+	hs := make(map[Pubkey] Hash)
+	for _, p := range ps {
+		for _, h := range s.hashes[p] {
+			if h.bnb > bnb {
+				break
+			}
+			hs[p] = h.hash
+		}
+	}
+	return hs
+} //IdHashes
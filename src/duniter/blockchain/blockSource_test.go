@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+
+	BA	"duniter/basic"
+	F	"path/filepath"
+	M	"util/misc"
+		"flag"
+		"os"
+		"testing"
+
+)
+
+const (
+
+	alice = Pubkey("7F6oyFQywURCACWZZGtG97Girh9EL1kg2WBwftEZxDoJ")
+	bob = Pubkey("8KTNxGqGzqHuBg3vVBQA3R8x7DWbo2mnYFfUuBvTWzGF")
+	carol = Pubkey("9ZCVm6fKrJjDpJ9RqrMq1q5DZnyjrX4UDdGzcDA8tH6Y")
+	dave = Pubkey("2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT")
+
+)
+
+// Directory of the fixture name
+func fixture (name string) string {
+	dir, err := F.Abs(F.Join("..", "..", "..", "rsrc", "duniter", "Fixtures", name)); M.Assert(err == nil, err, 100)
+	return dir
+} //fixture
+
+// Empty dBase, which is left open, and forget the ingestion errors
+func newBase () {
+	if database == nil {
+		openB()
+	}
+	rebuildB()
+	lastIngErr = nil
+} //newBase
+
+// Read the blocks of src into the open dBase
+func updateFrom (src BlockSource) {
+	src.Open()
+	defer src.Close()
+	paramsUpdt(src)
+	scanBlocksUpdt(src)
+} //updateFrom
+
+// Read src into a new dBase, which is left open
+func readSource (t *testing.T, src BlockSource) {
+	newBase()
+	updateFrom(src)
+	M.Want(lastIngErr == nil, t)
+} //readSource
+
+func TestMain (m *testing.M) {
+	dir, err := os.MkdirTemp("", "wotWizard"); M.Assert(err == nil, err, 100)
+	BA.Configure(dir, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	code := m.Run()
+	if database != nil {
+		closeB()
+	}
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestJsonBlocks (t *testing.T) {
+	src := NewSource(fixture("basic"))
+	_, ok := src.(*jsonSource)
+	M.Want(ok, t)
+	src.Open()
+	defer src.Close()
+	n, ok := src.LastNumber()
+	M.Want(ok && n == 3, t)
+	numbers := func (from, to int) []int {
+		var ns []int
+		src.Blocks(from, to, func (b *SourceBlock) {
+			ns = append(ns, b.Number)
+		})
+		return ns
+	}
+	ns := numbers(1, 2)
+	M.Want(len(ns) == 2 && ns[0] == 1 && ns[1] == 2, t)
+	ns = numbers(2, 10)
+	M.Want(len(ns) == 2 && ns[0] == 2 && ns[1] == 3, t)
+	ns = numbers(-1, 0)
+	M.Want(len(ns) == 1 && ns[0] == 0, t)
+	M.Want(len(numbers(4, 10)) == 0 && len(numbers(2, 1)) == 0, t)
+	src.Blocks(2, 2, func (b *SourceBlock) {
+		M.Want(b.MedianTime == 1488987727 && b.Hash == "00000000000000000000000000000000000000000000000000000000B10C0002", t)
+		M.Want(b.Actives == "[]" && b.Leavers == "[]", t)
+	})
+	m, ok := src.MedianTime(3)
+	M.Want(ok && m == 1488988027, t)
+	_, ok = src.MedianTime(4)
+	M.Want(!ok, t)
+	hs := src.IdHashes([]Pubkey{dave, "unknown"}, 3)
+	M.Want(len(hs) == 1 && hs[dave] == "4444444444444444444444444444444444444444444444444444444444444444", t)
+	M.Want(len(src.IdHashes([]Pubkey{dave}, 1)) == 0, t) // Written in the block 2
+}
+
+func TestScanJsonSource (t *testing.T) {
+	readSource(t, NewSource(fixture("basic")))
+	M.Want(LastBlock() == 3, t)
+	M.Want(IdLen() == 4 && IdLenM() == 4, t)
+	for _, id := range []struct {uid string; p Pubkey} {{"alice", alice}, {"bob", bob}, {"carol", carol}, {"dave", dave}} {
+		p, ok := IdUid(id.uid)
+		M.Want(ok && p == id.p, t)
+	}
+	_, member, hash, bnb, _, _, ok := IdPubComplete(dave)
+	M.Want(ok && member && bnb == 2, t)
+	M.Want(hash == "4444444444444444444444444444444444444444444444444444444444444444", t)
+	bnb, _, ok = Cert(alice, bob)
+	M.Want(ok && bnb == 0, t)
+	bnb, _, ok = Cert(carol, dave)
+	M.Want(ok && bnb == 2, t)
+	_, _, ok = Cert(dave, alice)
+	M.Want(!ok, t)
+	var pos CertPos
+	M.Want(CertTo(dave, &pos) && pos.CertPosLen() == 3, t)
+	M.Want(CertFrom(alice, &pos) && pos.CertPosLen() == 3, t)
+	M.Want(CertTo(alice, &pos) && pos.CertPosLen() == 2, t)
+}
+
+// The identity of dave is written again in a later block, with another hash: the joiner of the block 2 keeps the hash written before it
+func TestIdHashesAsOfBlock (t *testing.T) {
+	const (
+		hash2 = "4444444444444444444444444444444444444444444444444444444444444444"
+		hash4 = "5555555555555555555555555555555555555555555555555555555555555555"
+	)
+	buf, err := os.ReadFile(F.Join(fixture("basic"), "blocks.jsonl")); M.Assert(err == nil, err, 100)
+	dir := t.TempDir()
+	block4 := `{"number": 4, "hash": "00000000000000000000000000000000000000000000000000000000B10C0004", "medianTime": 1488988327, "time": 1488988330, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": [], "identities": [{"pub": "` + string(dave) + `", "hash": "` + hash4 + `"}]}`
+	err = os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(string(buf) + block4 + "\n"), 0644); M.Assert(err == nil, err, 101)
+	src := NewSource(dir)
+	src.Open()
+	M.Want(src.IdHashes([]Pubkey{dave}, 3)[dave] == hash2 && src.IdHashes([]Pubkey{dave}, 4)[dave] == hash4, t)
+	src.Close()
+	readSource(t, src)
+	M.Want(LastBlock() == 4, t)
+	_, member, hash, _, _, _, ok := IdPubComplete(dave)
+	M.Want(ok && member && hash == hash2, t)
+	
+	for _, name := range []string{"sqlite17", "sqlite18"} {
+		src := NewSource(F.Join(fixture(name), "wotwizard-export.db"))
+		src.Open()
+		M.Want(len(src.IdHashes([]Pubkey{dave}, 1)) == 0 && src.IdHashes([]Pubkey{dave}, 2)[dave] == hash2, t)
+		src.Close()
+	}
+}
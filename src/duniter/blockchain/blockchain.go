@@ -21,17 +21,14 @@ import (
 	F	"path/filepath"
 	J	"util/json"
 	M	"util/misc"
-	Q	"database/sql"
-	S	"util/sort"
 	SC	"syscall"
 	U	"util/sets2"
 		"bytes"
-		"fmt"
+		"math/bits"
 		"os"
 		"os/signal"
 		"sync"
 		"time"
-	_	"github.com/mattn/go-sqlite3"
 
 )
 
@@ -41,7 +38,6 @@ const (
 	driver = "sqlite3";
 	
 	syncName = "updating.txt";
-	syncDelay = 15 * time.Second // Waiting time of Duniter after its creation of syncName
 	verifyPeriod = 2 * time.Second // Minimum delay between two verifications of the presence of syncName
 	secureDelay = 2 * time.Second // Security delay before the end of syncDelay
 	addDelay = 5 * time.Second // Increment of syncDelay when approching the end and update is not finished
@@ -69,15 +65,15 @@ const (
 var (
 	
 	 // Path to the Duniter synchronization file
-	duniSync = F.Join(BA.DuniDir, syncName)
+	duniSync string
 	
-	// Directory of the WW database and names of files inside it
-	system = F.Join(BA.RsrcDir(), systemDef)
-	dPars = F.Join(system, dParsName)
-	dBase = F.Join(system, dBaseName)
-	dCopy =F.Join( system, dCopyName)
-	dCopy1 =F.Join( system, dCopy1Name)
-	sBase = F.Join(system, sBaseName)
+	// Directory of the WW database and names of files inside it; set by setPaths
+	system,
+	dPars,
+	dBase,
+	dCopy,
+	dCopy1,
+	sBase string
 	
 	addDelayInt int64 = addDelay.Nanoseconds() / 1000000
 
@@ -88,14 +84,24 @@ const (
 	// Max length of a Pubkey
 	PubkeyLen = 44
 	
-	// Number of last blocks to be read again at every update, since they could have changed
-	secureGap = 100
+	// Number of last blocks whose operations are kept in undoListT, so that a fork up to this depth can be undone; a deeper fork needs a rebuild of dBase
+	undoDepth = 1000
+	
+	// Version of the layout of dBase, with block hashes, aux2 in undoListT and changesT; a dBase with the former layout (no format place) is migrated, and a dBase with another layout is rebuilt
+	dBaseFormat = 1
 	
 	// Number of pages used by UtilBTree
 	pageNb = 256000
 	
+	// Number of unused places at the beginning of dBase; the places of the former layout began there, and must not move
+	firstPlace = 3
+
+)
+
+const (
+	
 	// Numbers of the places of the indexes in dBase
-	timePlace = iota // Index timeT
+	timePlace = firstPlace + iota // Index timeT
 	timeMPlace // Index timeMT
 	joinAndLeavePlace // Index joinAndLeaveT
 	idPubPlace // Index idPubT
@@ -108,8 +114,13 @@ const (
 	undoListPlace // Head of the chained list of the operations to be undone before every update
 	lastNPlace // Last read block
 	idLenPlace // Number of actual members
+	formatPlace // dBaseFormat
+	changesPlace // Index changesT
 	
 	placeNb // Number of places
+	
+	// Number of places of the former layout of dBase, which ended with idLenPlace
+	formerPlaceNb = formatPlace
 
 )
 
@@ -121,6 +132,12 @@ type (
 		Name () string
 	}
 	
+	// Actioner whose log entries are correlated by an id
+	CorrelatedActioner interface {
+		Actioner
+		LogId () string
+	}
+	
 	// Procedure called at every update
 	UpdateProc = func (... interface{})
 	
@@ -260,18 +277,20 @@ type (
 		typ byte
 		// timeList -> timeTy; joinList, activeList, leaveList -> identity; certAddList, certRemoveList -> Certification
 		ref B.FilePos
-		aux, aux2 int64
+		aux int64
+		aux2 int64 // activeList -> previous application block of the identity
 	}
 	
 	// Factory of undoListT
 	undoListFacT struct {
 	}
 	
-	// Blocks and their times
+	// Blocks, their times and their hashes
 	timeTy struct {
 		bnb int32
 		mTime,
 		time int64
+		hash Hash
 	}
 	
 	// Factory of timeTy
@@ -458,9 +477,16 @@ const (
 
 var (
 	
+	// Settings of the configuration, set by setPaths
+	
+	syncDelay time.Duration // Waiting time of Duniter after its creation of syncName
+	// Number of last blocks to be read again at every update, since they could have changed
+	secureGap int32
+	
 	// Shared variables
 	
-	lg = BA.Lg
+	lg = BA.NewLogger("blockchain")
+	lgU = lg // Logger of the update in progress, or of the last one; Updt, read in Cmds under mutex
 	
 	pars Parameters // Duniter parameters
 	parsJ J.Json
@@ -561,29 +587,25 @@ var (
 	poST *A.Tree
 	poSTMut sync.RWMutex
 	members membersFinder
-	memberF = S.TF{Finder: &members}
 
 )
 
-func (m *membersFinder) Less (i, j int) bool {
-	findMemberNumMutex.RLock()
-	b := m.m[i].p < m.m[j].p
-	findMemberNumMutex.RUnlock()
-	return b
-} //Less
-
 // Data & Data factories procedures
 
 func (t *timeTy) Read (r *B.Reader) {
 	t.bnb = r.InInt32()
 	t.mTime = r.InInt64()
 	t.time = r.InInt64()
+	if r.Pos() < r.Len() { // Records of the former layout have no hash
+		t.hash = Hash(r.InString())
+	}
 } //Read
 
 func (t *timeTy) Write (w *B.Writer) {
 	w.OutInt32(t.bnb)
 	w.OutInt64(t.mTime)
 	w.OutInt64(t.time)
+	w.OutString(string(t.hash))
 } //Write
 
 func (timeFacT) New (size int) B.Data {
@@ -705,6 +727,9 @@ func (l *undoListT) Read (r *B.Reader) {
 	l.typ = r.InByte()
 	l.ref = r.InFilePos()
 	l.aux = r.InInt64()
+	if r.Pos() < r.Len() { // Records of the former layout have no aux2
+		l.aux2 = r.InInt64()
+	}
 } //Read
 
 func (l *undoListT) Write (w *B.Writer) {
@@ -712,6 +737,7 @@ func (l *undoListT) Write (w *B.Writer) {
 	w.OutByte(l.typ)
 	w.OutFilePos(l.ref)
 	w.OutInt64(l.aux)
+	w.OutInt64(l.aux2)
 } //Write
 
 func (undoListFacT) New (size int) B.Data {
@@ -967,15 +993,22 @@ func (certKTimeManT) CompP (l1, l2 B.Data) B.Comp {
 func (certKTimeManT) PrefP (p1 B.Data, p2 *B.Data) {
 } //PrefP
 
-var findMemberNumMutex = new(sync.RWMutex)
-
+// Return the index of p in members.m, or members.len if p is not a member; members.m is only read, so that concurrent searches are allowed (see wotWizard.CalcPermutations)
 func findMemberNum (p Pubkey) (int, bool) {
-	n := members.len
-	findMemberNumMutex.Lock()
-	members.m[n].p = p
-	findMemberNumMutex.Unlock()
-	memberF.BinSearch(0, n - 1, &n)
-	return n, n < members.len
+	i := 0; j := members.len
+	for i < j {
+		k := (i + j) / 2
+		if members.m[k].p < p {
+			i = k + 1
+		} else {
+			j = k
+		}
+	}
+	ok := i < members.len && members.m[i].p == p
+	if !ok {
+		i = members.len
+	}
+	return i, ok
 } //findMemberNum
 
 func Driver () string {
@@ -1044,29 +1077,30 @@ func RealNow () int64 {
 	return rNow
 } //RealNow
 
-// Open the duniter0 database
-func openB () {
-	M.Assert(database == nil, 100)
-	B.Fac.CloseBase(dBase)
-	database = B.Fac.OpenBase(dBase, pageNb)
-	if database == nil {
-		b := B.Fac.CreateBase(dBase, placeNb); M.Assert(b, 101)
-		database = B.Fac.OpenBase(dBase, pageNb); M.Assert(database != nil, 102)
-		database.WritePlace(timePlace, int64(database.CreateIndex(timeKeyS)))
-		database.WritePlace(timeMPlace, int64(database.CreateIndex(timeMKeyS)))
-		database.WritePlace(joinAndLeavePlace, int64(database.CreateIndex(0)))
-		database.WritePlace(idPubPlace, int64(database.CreateIndex(0)))
-		database.WritePlace(idUidPlace, int64(database.CreateIndex(0)))
-		database.WritePlace(idHashPlace, int64(database.CreateIndex(0)))
-		database.WritePlace(idTimePlace, int64(database.CreateIndex(idTimeKeyS)))
-		database.WritePlace(certFromPlace, int64(database.CreateIndex(0)))
-		database.WritePlace(certToPlace, int64(database.CreateIndex(0)))
-		database.WritePlace(certTimePlace, int64(database.CreateIndex(certTimeKeyS)))
-		database.WritePlace(undoListPlace, int64(B.BNil))
-		database.WritePlace(lastNPlace, -1)
-		database.WritePlace(idLenPlace, 0)
-		lg.Println("\"" + dBaseName + "\" created")
-	}
+// Create an empty dBase and open it
+func createB () {
+	b := B.Fac.CreateBase(dBase, placeNb); M.Assert(b, 100)
+	database = B.Fac.OpenBase(dBase, pageNb); M.Assert(database != nil, 101)
+	database.WritePlace(timePlace, int64(database.CreateIndex(timeKeyS)))
+	database.WritePlace(timeMPlace, int64(database.CreateIndex(timeMKeyS)))
+	database.WritePlace(joinAndLeavePlace, int64(database.CreateIndex(0)))
+	database.WritePlace(idPubPlace, int64(database.CreateIndex(0)))
+	database.WritePlace(idUidPlace, int64(database.CreateIndex(0)))
+	database.WritePlace(idHashPlace, int64(database.CreateIndex(0)))
+	database.WritePlace(idTimePlace, int64(database.CreateIndex(idTimeKeyS)))
+	database.WritePlace(certFromPlace, int64(database.CreateIndex(0)))
+	database.WritePlace(certToPlace, int64(database.CreateIndex(0)))
+	database.WritePlace(certTimePlace, int64(database.CreateIndex(certTimeKeyS)))
+	database.WritePlace(undoListPlace, int64(B.BNil))
+	database.WritePlace(lastNPlace, -1)
+	database.WritePlace(idLenPlace, 0)
+	database.WritePlace(formatPlace, dBaseFormat)
+	database.WritePlace(changesPlace, int64(database.CreateIndex(timeKeyS)))
+	lg.Println("\"" + dBaseName + "\" created")
+} //createB
+
+// Open the data managers and the indexes of the open dBase; changesT is nil if dBase has the former layout
+func openIndexes () {
 	timeMan = database.CreateDataMan(timeFac)
 	joinAndLeaveLMan = database.CreateDataMan(joinAndLeaveLFac)
 	joinAndLeaveMan = database.CreateDataMan(joinAndLeaveFac)
@@ -1075,6 +1109,7 @@ func openB () {
 	certMan = database.CreateDataMan(certificationFac)
 	certToForkMan = database.CreateDataMan(certToForkFac)
 	undoListMan = database.CreateDataMan(undoListFac)
+	changeMan = database.CreateDataMan(changeFac)
 	timeT = database.OpenIndex(B.FilePos(database.ReadPlace(timePlace)), intKeyMan, intKeyFac)
 	timeMT = database.OpenIndex(B.FilePos(database.ReadPlace(timeMPlace)), lIntKeyMan, lIntKeyFac)
 	joinAndLeaveT = database.OpenIndex(B.FilePos(database.ReadPlace(joinAndLeavePlace)), pubKeyMan, pubKeyFac)
@@ -1085,6 +1120,30 @@ func openB () {
 	certFromT = database.OpenIndex(B.FilePos(database.ReadPlace(certFromPlace)), pubKeyMan, pubKeyFac)
 	certToT = database.OpenIndex(B.FilePos(database.ReadPlace(certToPlace)), pubKeyMan, pubKeyFac)
 	certTimeT = database.OpenIndex(B.FilePos(database.ReadPlace(certTimePlace)), certKTimeMan, filePosKeyFac)
+	changesT = nil
+	if database.PlaceNb() > changesPlace {
+		changesT = database.OpenIndex(B.FilePos(database.ReadPlace(changesPlace)), intKeyMan, intKeyFac)
+	}
+} //openIndexes
+
+// Open the duniter0 database; a dBase with the former layout is migrated, and a dBase with an unknown layout is rebuilt
+func openB () {
+	M.Assert(database == nil, 100)
+	B.Fac.CloseBase(dBase)
+	database = B.Fac.OpenBase(dBase, pageNb)
+	if database != nil && database.PlaceNb() == formerPlaceNb {
+		migrateB()
+	}
+	if database != nil && (database.PlaceNb() != placeNb || database.ReadPlace(formatPlace) != dBaseFormat) {
+		lg.Warn("\"" + dBaseName + "\" has an unknown layout; rebuilding it from block 0")
+		database.CloseBase()
+		err := os.Remove(dBase); M.Assert(err == nil, err, 101)
+		database = nil
+	}
+	if database == nil {
+		createB()
+	}
+	openIndexes()
 	lg.Println("\"" + dBaseName + "\" opened")
 } //openB
 
@@ -1710,36 +1769,41 @@ func percentOfSentries (pubkeys PubkeysT, distOrQual bool) (dist float64) {
 	poSE := &poSET{pubkeys: pubkeys, distOrQual: distOrQual}
 	dist, ok := find(poSE)
 	if !ok {
-		nbSentries := float64(SentriesLen())
-		set := U.NewSet()
-		frontier := U.NewSet()
-		for i := 0; i < len(pubkeys); i++ {
-			if e, b := findMemberNum(pubkeys[i]); b {
-				set.Incl(e)
-				frontier.Incl(e)
-			}
-		}
 		n := int(pars.StepMax)
 		if !distOrQual {
 			n--
 		}
-		for i := 1; i < n; i++ {
-			newFrontier := U.NewSet()
-			frontierI := frontier.Attach()
-			e, ok := frontierI.FirstE()
-			for ok {
-				newFrontier.Add(members.m[e].links)
-				e, ok = frontierI.NextE()
-			}
-			frontier = newFrontier
-			set.Add(frontier)
-		}
-		dist = float64(set.Inter(sentriesS).NbElems()) / nbSentries
+		dist = sentriesReached(pubkeys, n, members.m, findMemberNum, sentriesS)
 		store(poSE, dist)
 	}
 	return
 } //percentOfSentries
 
+// Array of certifiers' pubkeys -> % of sentries reached in n - 1 steps in the graph ids, where find gives the index of a pubkey and sentries is the set of sentries
+func sentriesReached (pubkeys PubkeysT, n int, ids membersT, find func (p Pubkey) (int, bool), sentries U.Set) float64 {
+	nbSentries := float64(sentries.NbElems())
+	set := U.NewSet()
+	frontier := U.NewSet()
+	for i := 0; i < len(pubkeys); i++ {
+		if e, b := find(pubkeys[i]); b {
+			set.Incl(e)
+			frontier.Incl(e)
+		}
+	}
+	for i := 1; i < n; i++ {
+		newFrontier := U.NewSet()
+		frontierI := frontier.Attach()
+		e, ok := frontierI.FirstE()
+		for ok {
+			newFrontier.Add(ids[e].links)
+			e, ok = frontierI.NextE()
+		}
+		frontier = newFrontier
+		set.Add(frontier)
+	}
+	return float64(set.Inter(sentries).NbElems()) / nbSentries
+} //sentriesReached
+
 // Array of certifiers' pubkeys -> % of sentries reached in pars.stepMax - 1 steps
 func Distance (pubkeys PubkeysT) float64 {
 	return percentOfSentries(pubkeys, true)
@@ -1755,6 +1819,78 @@ func DistanceRuleOk (pubkeys PubkeysT) bool {
 	return Distance(pubkeys) >= pars.Xpercent
 } //DistanceRuleOk
 
+// Array of certifiers' pubkeys -> Distance(pubkeys) in base, and, for every member p, the increase of Distance when p is added to pubkeys, in gains; the sentries not reached yet are marked in bit sets, which are propagated along the links pars.stepMax - 1 times, so that all members are computed together, and nothing is cached
+func DistanceGains (pubkeys PubkeysT) (base float64, gains map[Pubkey] float64) {
+	nbSentries := float64(sentriesS.NbElems())
+	reached := U.NewSet()
+	for _, p := range pubkeys {
+		if e, b := findMemberNum(p); b {
+			reached.Incl(e)
+		}
+	}
+	links := make([][]int, members.len)
+	for e := 0; e < members.len; e++ {
+		lI := members.m[e].links.Attach()
+		f, ok := lI.FirstE()
+		for ok {
+			links[e] = append(links[e], f)
+			f, ok = lI.NextE()
+		}
+	}
+	for i := 1; i < int(pars.StepMax); i++ {
+		newReached := reached.Copy()
+		rI := reached.Attach()
+		e, ok := rI.FirstE()
+		for ok {
+			for _, f := range links[e] {
+				newReached.Incl(f)
+			}
+			e, ok = rI.NextE()
+		}
+		reached = newReached
+	}
+	base = float64(reached.Inter(sentriesS).NbElems()) / nbSentries
+	rank := make(map[int] int) // Ranks of the sentries not reached yet, in the bit sets
+	sI := sentriesS.Attach()
+	e, ok := sI.FirstE()
+	for ok {
+		if !reached.In(e) {
+			rank[e] = len(rank)
+		}
+		e, ok = sI.NextE()
+	}
+	words := (len(rank) + 63) / 64
+	cur := make([][]uint64, members.len)
+	for e := range cur {
+		cur[e] = make([]uint64, words)
+		if r, b := rank[e]; b {
+			cur[e][r / 64] |= 1 << uint(r % 64)
+		}
+	}
+	for i := 1; i < int(pars.StepMax); i++ {
+		next := make([][]uint64, members.len)
+		for e := range cur {
+			next[e] = make([]uint64, words)
+			copy(next[e], cur[e])
+			for _, f := range links[e] {
+				for w := 0; w < words; w++ {
+					next[e][w] |= cur[f][w]
+				}
+			}
+		}
+		cur = next
+	}
+	gains = make(map[Pubkey] float64)
+	for e := 0; e < members.len; e++ {
+		n := 0
+		for _, w := range cur[e] {
+			n += bits.OnesCount64(w)
+		}
+		gains[members.m[e].p] = float64(n) / nbSentries
+	}
+	return
+} //DistanceGains
+
 // Updt
 // Scan the string s from position i to the position of stop excluded; update i and return the scanned string in sub
 func scanS (s []rune, stop rune, i *int) string {
@@ -1767,30 +1903,16 @@ func scanS (s []rune, stop rune, i *int) string {
 	return string(sub.Bytes())
 } //scanS
 
-// Updt
-// Skip the string s from position i to the position of stop excluded; update i
-func skipS (s []rune, stop rune, i *int) {
-	for *i < len(s) && s[*i] != stop {
-		*i++
-	}
-	*i++
-} //skipS
-
 // Updt
 // Extract Duniter parameters from block 0
-func paramsUpdt (d *Q.DB) {
+func paramsUpdt (src BlockSource) {
 	
 	const
 		txWindow = 60 * 60 * 24 * 7
 	
-	lg.Println("Reading money parameters")
-	row := d.QueryRow("SELECT parameters FROM block WHERE number == 0 AND NOT fork")
-	var ns Q.NullString
-	err := row.Scan(&ns)
-	M.Assert(err == nil, err, 100)
-	M.Assert(ns.Valid, 101)
-	ss := bytes.Runes([]byte(ns.String))
-	var n int
+	lgU.Println("Reading money parameters")
+	ss := bytes.Runes([]byte(src.Parameters()))
+	var (n int; err error)
 	i := 0
 	s := scanS(ss, ':', &i); pars.C, err = C.ParseFloat(s, 64); M.Assert(err == nil, err, 102)
 	s = scanS(ss, ':', &i); n, err = C.Atoi(s); M.Assert(err == nil, err, 103); pars.Dt = int32(n);
@@ -1826,8 +1948,8 @@ func params () {
 
 // Updt
 // Add a block in timeT and timeMT
-func times (withList bool, bnb int, mTime, time int64) {
-	t := &timeTy{bnb: int32(bnb), mTime: mTime, time: time}
+func times (withList bool, bnb int, mTime, time int64, hash Hash) {
+	t := &timeTy{bnb: int32(bnb), mTime: mTime, time: time, hash: hash}
 	tRef := timeMan.WriteAllocateData(t)
 	iw := timeT.Writer()
 	b := iw.SearchIns(&intKey{ref: int32(bnb)}); M.Assert(!b, bnb, 100)
@@ -1919,7 +2041,7 @@ func revokeId (withList bool, p Pubkey) {
 
 // Updt
 // For one block, add joining & leaving identities in joinAndLeaveT and updade identities in idPubT and idUidT; update certFromT & certToT too
-func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB) {
+func identities (withList bool, l *blockLists, nb int) {
 	
 	iwP := idPubT.Writer()
 	iwU := idUidT.Writer()
@@ -1927,36 +2049,13 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 	iwJ := joinAndLeaveT.Writer()
 	
 	var b bool
-	ss := bytes.Runes([]byte(ssJ))
-	i := 1
-	for ss[i] != ']' { // joiners : Insert id
-		i++
-		idLenM++
-		id := &identity{member: true}
-		id.pubkey = Pubkey(scanS(ss, ':', &i))
-		skipS(ss, ':', &i)
-		s := scanS(ss, '-', &i); n, err := C.Atoi(s); M.Assert(err == nil, err, 100)
-		id.application = int32(n)
-		id.expires_on, _, b = TimeOf(int32(n)); M.Assert(b, n, 102)
+	ingField = "joiners"
+	for _, j := range l.joiners {
+		id := &identity{member: true, pubkey: j.pubkey, application: j.application, uid: j.uid}
+		id.expires_on, _, b = TimeOf(j.application); M.Assert(b, j.application, 102)
 		id.expires_on += int64(pars.MsValidity)
-		skipS(ss, ':', &i)
-		skipS(ss, ':', &i)
-		id.uid = scanS(ss, '"', &i)
-		if ss[i] != ']' {
-			i++
-		}
-		rows, err := d.Query("SELECT hash FROM i_index WHERE pub == '" + string(id.pubkey) + "' ORDER BY writtenOn ASC")
-		M.Assert(err == nil, err, 103)
-		id.hash = ""
-		for rows.Next() {
-			var s Q.NullString
-			err = rows.Scan(&s)
-			M.Assert(err == nil, err, 104)
-			if s.Valid {
-				id.hash = Hash(s.String)
-			}
-		}
-		M.Assert(rows.Err() == nil, rows.Err(), 60)
+		idLenM++
+		id.hash = l.hashes[id.pubkey]
 		M.Assert(id.hash != "", 105)
 		bnb := int32(nb)
 		id.block_number = bnb
@@ -2015,20 +2114,12 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 			idL := &undoListT{next: undoList, typ: joinList, ref: idRef, aux: 0}
 			undoList = undoListMan.WriteAllocateData(idL)
 		}
+		addChange(Joined, id.pubkey, "")
 	}
 	
-	ss = bytes.Runes([]byte(ssA))
-	i = 1
-	for ss[i] != ']' { // actives
-		i++
-		idP := new(pubKey)
-		idP.ref = Pubkey(scanS(ss, ':', &i))
-		skipS(ss, ':', &i)
-		s := scanS(ss, '-', &i); n, err := C.Atoi(s); M.Assert(err == nil, err, 113)
-		skipS(ss, '"', &i)
-		if ss[i] != ']' {
-			i++
-		}
+	ingField = "actives"
+	for _, a := range l.actives {
+		idP := &pubKey{ref: a.pubkey}
 		b = iwP.Search(idP); M.Assert(b, idP.ref, 114)
 		idRef := iwP.ReadValue()
 		id := idMan.ReadData(idRef).(*identity)
@@ -2037,24 +2128,16 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 			idL := &undoListT{next: undoList, typ: activeList, ref: idRef, aux: id.expires_on, aux2: int64(id.application)}
 			undoList = undoListMan.WriteAllocateData(idL)
 		}
-		id.application = int32(n)
-		id.expires_on, _, b = TimeOf(int32(n)); M.Assert(b, n, 117)
+		id.application = a.application
+		id.expires_on, _, b = TimeOf(a.application); M.Assert(b, a.application, 117)
 		id.expires_on += int64(pars.MsValidity)
 		idMan.WriteData(idRef, id)
+		addChange(Renewed, id.pubkey, "")
 	}
 	
-	ss = bytes.Runes([]byte(ssL))
-	i = 1
-	for ss[i] != ']' { // leavers
-		i++
-		idP := new(pubKey)
-		idP.ref = Pubkey(scanS(ss, ':', &i))
-		skipS(ss, ':', &i)
-		s := scanS(ss, '-', &i); n, err := C.Atoi(s); M.Assert(err == nil, err, 118)
-		skipS(ss, '"', &i)
-		if ss[i] != ']' {
-			i++
-		}
+	ingField = "leavers"
+	for _, lv := range l.leavers {
+		idP := &pubKey{ref: lv.pubkey}
 		b = iwP.Search(idP); M.Assert(b, idP.ref, 119)
 		idRef := iwP.ReadValue()
 		id := idMan.ReadData(idRef).(*identity)
@@ -2062,20 +2145,14 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 			idL := &undoListT{next: undoList, typ: activeList, ref: idRef, aux: id.expires_on, aux2: int64(id.application)}
 			undoList = undoListMan.WriteAllocateData(idL)
 		}
-		id.application = int32(n)
+		id.application = lv.application
 		id.expires_on = - M.Abs64(id.expires_on) // id.expires_on < 0 if leaving
 		idMan.WriteData(idRef, id)
+		addChange(Left, id.pubkey, "")
 	}
 	
-	ss = bytes.Runes([]byte(ssR))
-	i = 1
-	for ss[i] != ']' { // revoked
-		i++
-		p := Pubkey(scanS(ss, ':', &i))
-		skipS(ss, '"', &i)
-		if ss[i] != ']' {
-			i++
-		}
+	ingField = "revoked"
+	for _, p := range l.revoked {
 		idP := &pubKey{ref: p}
 		b = iwP.Search(idP); M.Assert(b, idP.ref, 120)
 		idRef := iwP.ReadValue()
@@ -2084,18 +2161,13 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 			undoList = undoListMan.WriteAllocateData(idL)
 		}
 		revokeId(withList, p);
+		addChange(Revoked, p, "")
 	}
 	
-	ss = bytes.Runes([]byte(ssE))
-	i = 1
-	for ss[i] != ']' { // excluded
-		i++
+	ingField = "excluded"
+	for _, p := range l.excluded {
 		idLenM--
-		idP := new(pubKey)
-		idP.ref = Pubkey(scanS(ss, '"', &i))
-		if ss[i] != ']' {
-			i++
-		}
+		idP := &pubKey{ref: p}
 		b = iwP.Search(idP); M.Assert(b, idP.ref, 121)
 		idRef := iwP.ReadValue()
 		id := idMan.ReadData(idRef).(*identity)
@@ -2113,6 +2185,7 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 			idL := &undoListT{next: undoList, typ: leaveList, ref: idRef, aux: 0}
 			undoList = undoListMan.WriteAllocateData(idL)
 		}
+		addChange(Excluded, id.pubkey, "")
 		if id.expires_on != BA.Revoked {
 			if withList {
 				idL := &undoListT{next: undoList, typ: activeList, ref: idRef, aux: id.expires_on, aux2: int64(id.application)}
@@ -2135,29 +2208,18 @@ func identities (withList bool, ssJ, ssA, ssL, ssR, ssE string, nb int, d *Q.DB)
 
 // Updt
 // Add certifications of one block in certFromT, certToT and certTimeT
-func certifications (withList bool, ssC string, nb int) {
+func certifications (withList bool, certs []certEntry, nb int) {
 	
 	iwF := certFromT.Writer()
 	iwT := certToT.Writer()
 	iwTi := certTimeT.Writer()
 	iwP := idPubT.Writer()
 	
-	ss := bytes.Runes([]byte(ssC))
-	i := 1
-	for ss[i] != ']' {
-		i++
-		c := new(certification)
-		c.from = Pubkey(scanS(ss, ':', &i))
-		c.to = Pubkey(scanS(ss, ':', &i))
-		s := scanS(ss, ':', &i)
-		skipS(ss, '"', &i)
-		if ss[i] != ']' {
-			i++
-		}
-		c.block_number = int32(nb)
-		n, err := C.Atoi(s); M.Assert(err == nil, err, 100)
+	ingField = "certifications"
+	for _, ce := range certs {
+		c := &certification{from: ce.from, to: ce.to, block_number: int32(nb)}
 		var b bool
-		c.expires_on, _, b = TimeOf(int32(n)); M.Assert(b, n, 101)
+		c.expires_on, _, b = TimeOf(ce.block); M.Assert(b, ce.block, 101)
 		c.expires_on += int64(pars.SigValidity)
 		pC := certMan.WriteAllocateData(c)
 		var v, vE B.FilePos
@@ -2176,6 +2238,11 @@ func certifications (withList bool, ssC string, nb int) {
 			oldPC = iw.ReadValue()
 		}
 		iw.WriteValue(pC)
+		if oldPC == B.BNil {
+			addChange(CertWritten, c.from, c.to)
+		} else {
+			addChange(CertReplaced, c.from, c.to)
+		}
 		// Insert into certToT
 		idP.ref = c.to
 		if iwT.SearchIns(idP) {
@@ -2366,6 +2433,7 @@ func removeExpiredCerts (now, secureNow int64) {
 		removeCert(c, pC)
 		withList := c.expires_on >= secureNow
 		b := iw.Erase(&filePosKey{ref: pC}); M.Assert(b, 100)
+		addChange(CertExpired, c.from, c.to)
 		if withList {
 			cL := &undoListT{next: undoList, typ: certRemoveList, ref: pC, aux: 0}
 			undoList = undoListMan.WriteAllocateData(cL)
@@ -2393,14 +2461,20 @@ func revokeExpiredIds (now, secureNow int64) {
 			undoList = undoListMan.WriteAllocateData(idL)
 		}
 		revokeId(withList, id.pubkey)
+		addChange(Revoked, id.pubkey, "")
 	}
 } //revokeExpiredIds
 
 // Updt
-// Undo the last operations done from the secureGap last blocks
-func removeSecureGap () {
+// Undo the operations done from the block number from on
+func unwind (from int32) {
+	boundary := true
 	for undoList != B.BNil {
+		if boundary && blockOf(undoList) < from {
+			break
+		}
 		l := undoListMan.ReadData(undoList).(*undoListT)
+		boundary = l.typ == timeList
 		switch l.typ {
 			case timeList:
 				// Erase the timeTy data pointed by l.ref and the corresponding keys in timeT and timeMT
@@ -2636,7 +2710,8 @@ func removeSecureGap () {
 		undoListMan.EraseData(undoList)
 		undoList = l.next
 	}
-} //removeSecureGap
+	eraseChanges(from)
+} //unwind
 
 //Cmds
 func threshold (m, s int) int {
@@ -2668,9 +2743,9 @@ func SentryThreshold () int {
 
 // Cmds
 // Initialize members and sentriesS
-func calculateSentries (... interface{}) {
+func buildSentries () {
 	members.len = IdLen()
-	members.m = make(membersT, members.len + 1)
+	members.m = make(membersT, members.len)
 	var (pst *Position; pos CertPos)
 	i := 0
 	p, ok := IdNextPubkey(true, &pst)
@@ -2693,93 +2768,134 @@ func calculateSentries (... interface{}) {
 		}
 	}
 	
-	sentriesS = U.NewSet()
-	n := SentryThreshold()
-	if n == 0 {
-		return
+	sentriesThreshold = SentryThreshold()
+	sentriesS = fillSentries(sentriesThreshold)
+	poST = A.New()
+} //buildSentries
+
+// Cmds
+// Update members and sentriesS, incrementally when the last updates allow it
+func calculateSentries (... interface{}) {
+	ps, all := takeTouched()
+	rebuild := all || members.m == nil
+	if rebuild {
+		buildSentries()
+	} else {
+		updateSentries(ps)
 	}
-	p, ok = IdNextPubkeyM(true, &pst)
-	for ok {
-		if CertFrom(p, &pos) && pos.CertPosLen() >= n && CertTo(p, &pos) && pos.CertPosLen() >= n {
-			e, b := findMemberNum(p); M.Assert(b, 103)
-			sentriesS.Incl(e)
-		}
-		p, ok = IdNextPubkeyM(false, &pst)
+	if rebuild || len(ps) > 0 {
+		wotVersion++
 	}
-	
-	poST = A.New()
+	resetPast()
 } //calculateSentries
 
 // Updt
-// Insert datas from all the blocks from the secureGapth block before the last read
-func scanBlocksUpdt (d *Q.DB) {
-	lg.Println("Updating \"" + dBaseName + "\"")
+// Insert datas from all the blocks from the secureGapth block before the last read, or from the first block of a deeper fork
+func scanBlocksUpdt (src BlockSource) {
+	lgU.Println("Updating \"" + dBaseName + "\"")
 	idLenM = int(database.ReadPlace(idLenPlace))
 	undoList = B.FilePos(database.ReadPlace(undoListPlace))
-	removeSecureGap();
 	lastBlock = int32(database.ReadPlace(lastNPlace))
-	maxN := -1
-	row := d.QueryRow("SELECT max(number) FROM block WHERE NOT fork")
-	var r int
-	err := row.Scan(&r)
-	if err == nil {
-		maxN = r
+	from := lastBlock - secureGap + 1
+	if f := forkPoint(src); f < from {
+		logFork(src, f)
+		if f >= oldestUndoable() {
+			from = f
+		} else {
+			lgU.Warn("Fork deeper than", undoDepth, "blocks; rebuilding \"" + dBaseName + "\"")
+			rebuildB()
+			from = 0
+		}
+	}
+	unwind(from)
+	// The present times of the reverted blocks must not outlive them, in case the new chain is shorter
+	now, rNow = 0, 0
+	if from > 0 {
+		if m, t, ok := TimeOf(from - 1); ok {
+			now, rNow = m, t
+		}
+	}
+	changedFrom = from
+	maxN, ok := src.LastNumber()
+	if !ok {
+		maxN = -1
 	}
 	var secureNow int64 = M.MaxInt64
 	var n = 0
-	if maxN >= secureGap {
-		n = maxN - secureGap + 1
-	}
-	s := C.Itoa(n)
-	row = d.QueryRow("SELECT medianTime FROM block WHERE NOT fork AND number = " + s)
-	var m time.Time
-	err = row.Scan(&m)
-	if err == nil {
-		secureNow = m.Unix()
-	}
-	s = C.Itoa(int(lastBlock - secureGap + 1))
-	rs, err := d.Query("SELECT number, medianTime, time, joiners, actives, leavers, revoked, excluded, certifications FROM block WHERE NOT fork AND number >= " + s + " ORDER BY number ASC")
-	M.Assert(err == nil, err, 100)
-	defer rs.Close()
-	for rs.Next() {
-		var (
-			number int
-			t time.Time
-			j,
-			a,
-			l,
-			r,
-			e,
-			c Q.NullString
-		)
-		err = rs.Scan(&number, &m, &t, &j, &a, &l, &r, &e, &c)
-		M.Assert(err == nil, err, 101)
-		medianTime := m.Unix()
-		time := t.Unix()
-		M.Assert(j.Valid, 102); joiners := j.String
-		M.Assert(a.Valid, 103); actives := a.String
-		M.Assert(l.Valid, 104); leavers := l.String
-		M.Assert(r.Valid, 105); revoked := r.String
-		M.Assert(e.Valid, 106); excluded := e.String
-		M.Assert(c.Valid, 107); certificationList := c.String
-		if number > maxN - 10 || number % 5000 == 0 {
-			lg.Println("Added block ", number)
-		}
-		withList := number >= n
-		times(withList , number, medianTime, time)
-		revokeExpiredIds(medianTime, secureNow)
-		identities(withList, joiners, actives, leavers, revoked, excluded, number, d)
-		removeExpiredCerts(medianTime, secureNow) // Élimine toutes les certifications expirées avec réversibilité dans secureGap
-		certifications(withList, certificationList, number)
-	}
-	M.Assert(rs.Err() == nil, rs.Err(), 60)
+	if maxN >= undoDepth {
+		n = maxN - undoDepth + 1
+	}
+	if m, ok := src.MedianTime(n); ok {
+		secureNow = m
+	}
+	var medianTime int64 = 0
+	last := int(from) - 1 // Last block read without error
+	halted := false
+	quarantine := ingestionPolicy() == QuarantinePolicy
+	e := ingest(-1, func () {
+		src.Blocks(int(from), maxN, func (b *SourceBlock) {
+			if halted {
+				return
+			}
+			number := b.Number
+			if number > maxN - 10 || number % 5000 == 0 {
+				lgU.Debug("Added block ", number)
+			}
+			pendingChanges = nil
+			// The block is checked before anything of it is written
+			var (l *blockLists; fault *IngestionError)
+			if e := ingest(number, func () {l, fault = checkBlock(b, src, quarantine)}); e != nil {
+				l = nil; fault = e
+			}
+			if fault != nil {
+				reportIngestion(fault)
+				if l == nil {
+					halted = true
+					return
+				}
+			}
+			// Every block is first recorded in undoListT, so that it can be undone in case of fork; the blocks before the undoDepth last ones are made definitive at once
+			oldNow, oldRNow, oldIdLenM := now, rNow, idLenM
+			if e := ingest(number, func () {
+				ingField = "medianTime"
+				times(true, number, b.MedianTime, b.Time, b.Hash)
+				ingField = "expirations"
+				revokeExpiredIds(b.MedianTime, secureNow)
+				identities(true, l, number)
+				ingField = "expirations"
+				removeExpiredCerts(b.MedianTime, secureNow) // Élimine toutes les certifications expirées avec réversibilité dans undoDepth
+				certifications(true, l.certs, number)
+			}); e != nil { // Unexpected fault, after the check: the operations of the block journaled in undoListT are undone
+				unwind(int32(number))
+				now, rNow, idLenM = oldNow, oldRNow, oldIdLenM
+				pendingChanges = nil
+				reportIngestion(e)
+				halted = true
+				return
+			}
+			recordChanges(int32(number))
+			last = number
+			medianTime = b.MedianTime
+			if number < n {
+				trimUndoList(int32(number) + 1)
+			}
+		})
+	})
+	if e != nil {
+		e.Block = last + 1
+		e.Field = sourceField
+		reportIngestion(e)
+	}
+	touchUnwound()
+	trimUndoList(int32(n))
+	pruneChanges(int32(last) - BA.ChangesDepth + 1)
 	database.WritePlace(undoListPlace, int64(undoList))
-	lastBlock = int32(maxN)
+	lastBlock = int32(last)
 	database.WritePlace(lastNPlace, int64(lastBlock))
 	database.WritePlace(idLenPlace, int64(idLenM))
-	lg.Println("\"" + dBaseName + "\" updated")
-	lg.Println("Median Time:", m.Local().Format("2/01/2006 15:04:05"))
-	lg.Println("Number of members: ", idLenM)
+	lgU.Println("\"" + dBaseName + "\" updated")
+	lgU.Println("Median Time:", time.Unix(medianTime, 0).Local().Format("2/01/2006 15:04:05"))
+	lgU.Println("Number of members: ", idLenM)
 } //scanBlocksUpdt
 
 // Updt
@@ -2801,40 +2917,58 @@ func AddUpdateProcUpdt (updateProc UpdateProc, params ... interface{}) {
 // Updt
 // Scan the Duniter database
 func scan (... interface{}) {
-	lg.Println("Opening Duniter database (bis)")
-	d, err := Q.Open(driver, BA.DuniBase)
-	M.Assert(err == nil, err, 100)
-	defer d.Close()
-	scanBlocksUpdt(d)
+	src := Source()
+	if e := ingest(-1, src.Open); e != nil {
+		reportIngestion(e)
+		return
+	}
+	defer src.Close()
+	scanBlocksUpdt(src)
 } //scan
 
 // Updt
-// Scan the Duniter parameters in block 0
-func scan1 () {
-	lg.Println("Opening Duniter database")
-	d, err := Q.Open(driver, BA.DuniBase)
-	M.Assert(err == nil, err, 100)
-	defer d.Close()
-	paramsUpdt(d)
+// Scan the Duniter parameters in block 0; return false if they can't be read
+func scan1 () bool {
+	src := Source()
+	e := ingest(-1, src.Open)
+	if e == nil {
+		defer src.Close()
+		e = ingest(0, func () {
+			ingField = "parameters"
+			paramsUpdt(src)
+		})
+	}
+	if e != nil {
+		reportIngestion(e)
+		return false
+	}
+	return true
 } //scan1
 
 // Updt
 func exportParameters () {
-	lg.Println("Exporting money parameters")
+	lgU.Println("Exporting money parameters")
 	f, err := os.Create(dPars); M.Assert(err == nil, err, 100)
 	defer f.Close()
 	j := J.BuildJsonFrom(&pars); M.Assert(j != nil, 101)
 	j.Write(f)
-	lg.Println("Money parameters exported")
+	lgU.Println("Money parameters exported")
 } //exportParameters
 
 // Updt
 func doUpdates (done, updateReady chan<- bool) {
 	mutex.Lock()
-	lg.Println("Updating WotWizard database")
+	start := time.Now()
+	lgU = lg.In(BA.NewId("u"))
+	lgU.Println("Updating WotWizard database")
 	if doScan1 {
+		if !scan1() { // Nothing can be done without parameters
+			mutex.Unlock()
+			lgU.Warn("WotWizard database not updated")
+			done <- true
+			return
+		}
 		doScan1 = false
-		scan1()
 		exportParameters()
 	}
 	l := updateListUpdt
@@ -2843,73 +2977,25 @@ func doUpdates (done, updateReady chan<- bool) {
 		l = l.next
 	}
 	database.UpdateBase()
+	backupUpdt()
+	lgU.Println("WotWizard database updated in", time.Since(start))
 	mutex.Unlock()
-	lg.Println("WotWizard database updated")
 	done <- true
 	updateReady <- true
 } //doUpdates
 
-// Updt
-func readSyncTime () int64 {
-	f, err := os.Open(duniSync); M.Assert(err == nil, err, 100)
-	defer f.Close()
-	var t int64
-	_, err = fmt.Fscanf(f, "%d", &t); M.Assert(err == nil, err, 101)
-	return t
-} //readSyncTime
-
-// Updt
-func writeSyncTime (t int64) {
-	f, err := os.Create(duniSync); M.Assert(err == nil, err, 100)
-	defer f.Close()
-	_, err = fmt.Fprintf(f, "%d", t); M.Assert(err == nil, err, 101)
-} //writeSyncTime
-
 // Updt
 func updateAllUpdt (stopProg <-chan os.Signal, updateReady chan<- bool) {
-	for {
-		err := os.Remove(duniSync)
-		M.Assert(err == nil || os.IsNotExist(err), err, 100)
-		lg.Println("\"" +  syncName + "\" erased")
-		lg.Println("Looking for", duniSync); lg.Println()
-		f, err := os.Open(duniSync)
-		for os.IsNotExist(err) {
-			select {
-			case <-stopProg:
-				lg.Println("Halting"); lg.Println()
-				mutex.Lock()
-				closeB()
-				return
-			default:
-			}
-			time.Sleep(verifyPeriod)
-			f, err = os.Open(duniSync)
-		}
-		M.Assert(err == nil, err, 101)
-		f.Close()
-		lg.Println("\"" + syncName + "\" seen; reading it")
+	tr := Trigger()
+	lg.Println("Update trigger:", tr.Name())
+	for tr.Wait(stopProg) {
 		var done = make(chan bool)
-		t0 := readSyncTime()
-		ct1 := time.NewTicker(syncDelay - verifyPeriod - addDelay - secureDelay)
 		go doUpdates(done, updateReady)
-		select {
-		case <- done:
-			ct1.Stop()
-		case <- ct1.C:
-			ct1.Reset(addDelay)
-			innerLoop:
-			for {
-				select {
-				case <- done:
-					ct1.Stop()
-					break innerLoop
-				case <- ct1.C:
-					t0 += addDelayInt
-					writeSyncTime(t0)
-				}
-			}
-		}
+		tr.Updating(done)
 	}
+	lg.Println("Halting"); lg.Println()
+	mutex.Lock()
+	closeB()
 } //updateAllUpdt
 
 // Updt
@@ -2980,6 +3066,12 @@ func RemoveUpdateProc (name string) {
 	updateProMutex.Unlock()
 } //RemoveUpdateProc
 
+// Updt
+// Correlation id of the log entries of the update in progress; also readable in Cmds under mutex, where it's the one of the last update
+func UpdateId () string {
+	return lgU.Id()
+} //UpdateId
+
 // Cmds
 func FixSandBoxFUpdt (updateProc UpdateProc) {
 	sbFirstUpdt = updateProc
@@ -2996,19 +3088,21 @@ func updateAll () {
 
 // Cmds
 func updateCmds () {
-	lg.Println("Starting update of commands")
+	start := time.Now()
+	lgU.Println("Starting update of commands")
 	if firstUpdate {
 		params()
 		sbFirstUpdt()
 		firstUpdate = false
 	}
 	updateAll()
-	lg.Println("Update of commands done")
+	lgU.Println("Update of commands done in", time.Since(start))
 } //updateCmds
 
 // Cmds
 func updateFirstCmds () {
-	lg.Println("Starting first update")
+	start := time.Now()
+	lgU.Println("Starting first update")
 	params()
 	idLenM = int(database.ReadPlace(idLenPlace))
 	lastBlock = int32(database.ReadPlace(lastNPlace))
@@ -3018,18 +3112,23 @@ func updateFirstCmds () {
 	}
 	calculateSentries()
 	sbFirstUpdt()
-	lg.Println("First update done")
+	lgU.Println("First update done in", time.Since(start))
 } //updateFirstCmds
 
 // Cmds
 func doAction (a Actioner) {
-	lg.Println("Starting action", a.Name())
+	l := lg
+	if ca, ok := a.(CorrelatedActioner); ok {
+		l = lg.In(ca.LogId())
+	}
+	start := time.Now()
+	l.Debug("Starting action", a.Name())
 	mutexCmds.RLock()
 	mutex.RLock()
 	a.Activate()
 	mutex.RUnlock()
 	mutexCmds.RUnlock()
-	lg.Println("Action", a.Name(), "done")
+	l.Println("Action", a.Name(), "done in", time.Since(start))
 } //doAction
 
 // Cmds
@@ -3083,6 +3182,42 @@ func Start (newAction chan Actioner) {
 	updateAllUpdt(stopProg, updateReady)
 } //Start
 
+// Open dBase and the money parameters, run do on the state of the last block read, and close dBase; the server must not be running
+func Offline (do func ()) {
+	openB()
+	defer closeB()
+	params()
+	idLenM = int(database.ReadPlace(idLenPlace))
+	lastBlock = int32(database.ReadPlace(lastNPlace))
+	if lastBlock >= 0 {
+		var b bool
+		now, rNow, b = TimeOf(lastBlock); M.Assert(b, lastBlock, 100)
+	}
+	do()
+} //Offline
+
+// Open dBase, read the Duniter database at path as an update of the server does, with the update procedures added by the Initialize functions and the update of the commands, run do on the result like an action, and close dBase; the server must not be running
+func UpdateOffline (path string, do func ()) {
+	openB()
+	defer closeB()
+	old := source
+	source = NewSource(path)
+	defer func () {source = old}()
+	done := make(chan bool, 1)
+	updateReady := make(chan bool, 1)
+	doUpdates(done, updateReady)
+	mutexCmds.Lock()
+	mutex.RLock()
+	updateCmds()
+	mutex.RUnlock()
+	mutexCmds.Unlock()
+	mutexCmds.RLock()
+	mutex.RLock()
+	do()
+	mutex.RUnlock()
+	mutexCmds.RUnlock()
+} //UpdateOffline
+
 func virgin () bool {
 	f, err := os.Open(dPars)
 	if err == nil {
@@ -3106,6 +3241,21 @@ func Initialize () {
 	AddUpdateProc(blockchainName, calculateSentries)
 } //Initialize
 
-func init() {
+// Fix the paths of the files and the settings from the configuration
+func setPaths () {
+	duniSync = F.Join(BA.DuniDir, syncName)
+	system = F.Join(BA.RsrcDir(), systemDef)
+	dPars = F.Join(system, dParsName)
+	dBase = F.Join(system, dBaseName)
+	dCopy = F.Join(system, dCopyName)
+	dCopy1 = F.Join(system, dCopy1Name)
+	sBase = F.Join(system, sBaseName)
+	backupsDir = F.Join(system, backupsDirName)
+	syncDelay = BA.SyncDelay
+	secureGap = BA.SecureGap
 	os.MkdirAll(system, 0777)
+} //setPaths
+
+func init() {
+	BA.AddConfigProc(setPaths)
 } //init
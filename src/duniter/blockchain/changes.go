@@ -0,0 +1,250 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Per-block changelog of the web of trust, kept in changesT
+
+import (
+
+	B	"util/gbTree"
+	M	"util/misc"
+
+)
+
+const (
+
+	// Kinds of changes
+	Joined ChangeKind = iota // Joiner, first time or not
+	Renewed // Active
+	Left // Leaver
+	Excluded
+	Revoked // Explicitly or by expiration
+	CertWritten // New certification
+	CertReplaced // Renewed certification
+	CertExpired
+
+)
+
+type (
+
+	ChangeKind byte
+
+	// Change of an identity or of a certification
+	Change struct {
+		Kind ChangeKind
+		Pubkey, // Identity concerned, or certifier
+		To Pubkey // Certified identity, for certification changes; "" otherwise
+	}
+
+	// Changes of a block, in the order of their application
+	BlockChanges struct {
+		Block int32
+		Changes []Change
+	}
+
+	// Chained list of the changes of a block, the first applied first
+	changeTy struct {
+		next B.FilePos
+		kind byte
+		pubkey,
+		to Pubkey
+	}
+
+	// Factory of changeTy
+	changeFacT struct {
+	}
+
+)
+
+var (
+
+	changeFac changeFacT
+	changeMan *B.DataMan
+
+	changesT *B.Index // intKey -> changeTy; changes of the web of trust by block
+
+	// Changes of the block being read; Updt
+	pendingChanges []Change
+
+	// First block read by the last update; Updt, read in Cmds under mutex
+	changedFrom int32 = 0
+	
+	// Changes of the blocks unwound by the update in progress, by block, until they are read again; Updt
+	unwoundChanges = make(map[int32][]Change)
+	
+	// Pubkeys whose identity, membership or certifications may have changed since the last call to takeTouched, and whether everything may have changed; written in Updt, taken in Cmds under mutex
+	touched = make(map[Pubkey]bool)
+	touchedAll = true
+
+)
+
+func (c *changeTy) Read (r *B.Reader) {
+	c.next = r.InFilePos()
+	c.kind = r.InByte()
+	c.pubkey = Pubkey(r.InString())
+	c.to = Pubkey(r.InString())
+} //Read
+
+func (c *changeTy) Write (w *B.Writer) {
+	w.OutFilePos(c.next)
+	w.OutByte(c.kind)
+	w.OutString(string(c.pubkey))
+	w.OutString(string(c.to))
+} //Write
+
+func (changeFacT) New (size int) B.Data {
+	return new(changeTy)
+} //New
+
+// Updt
+// Note a change of the block being read
+func addChange (kind ChangeKind, pubkey, to Pubkey) {
+	pendingChanges = append(pendingChanges, Change{Kind: kind, Pubkey: pubkey, To: to})
+} //addChange
+
+// Updt
+// Note the pubkeys concerned by the changes cs in touched
+func touchChanges (cs []Change) {
+	for _, c := range cs {
+		touched[c.Pubkey] = true
+		if c.To != "" {
+			touched[c.To] = true
+		}
+	}
+} //touchChanges
+
+// Updt
+// Compare the changes noted for the block bnb with the ones it had before it was unwound, if any, and note the pubkeys concerned in touched if they differ
+func touchBlock (bnb int32) {
+	old, ok := unwoundChanges[bnb]
+	delete(unwoundChanges, bnb)
+	same := ok && len(old) == len(pendingChanges)
+	for i := 0; same && i < len(old); i++ {
+		same = old[i] == pendingChanges[i]
+	}
+	if !same {
+		touchChanges(old)
+		touchChanges(pendingChanges)
+	}
+} //touchBlock
+
+// Updt
+// Note in touched the pubkeys concerned by the changes of the blocks unwound and not read again
+func touchUnwound () {
+	for bnb, cs := range unwoundChanges {
+		touchChanges(cs)
+		delete(unwoundChanges, bnb)
+	}
+} //touchUnwound
+
+// Cmds
+// Return the pubkeys whose identity, membership or certifications may have changed since the last call, and whether everything may have changed; the caller must hold mutex
+func takeTouched () (ps map[Pubkey]bool, all bool) {
+	ps, all = touched, touchedAll
+	touched = make(map[Pubkey]bool)
+	touchedAll = false
+	return
+} //takeTouched
+
+// Updt
+// Record the changes noted for the block bnb, which was read without error
+func recordChanges (bnb int32) {
+	touchBlock(bnb)
+	if len(pendingChanges) == 0 {
+		return
+	}
+	ref := B.BNil
+	for i := len(pendingChanges) - 1; i >= 0; i-- {
+		c := pendingChanges[i]
+		ref = changeMan.WriteAllocateData(&changeTy{next: ref, kind: byte(c.Kind), pubkey: c.Pubkey, to: c.To})
+	}
+	iw := changesT.Writer()
+	if iw.SearchIns(&intKey{ref: bnb}) { // Shouldn't happen, since the changes of unwound blocks are erased
+		eraseChangeList(iw.ReadValue())
+	}
+	iw.WriteValue(ref)
+	pendingChanges = nil
+} //recordChanges
+
+// Updt
+// Erase the list of changes ref and return its content
+func eraseChangeList (ref B.FilePos) []Change {
+	cs := make([]Change, 0)
+	for ref != B.BNil {
+		c := changeMan.ReadData(ref).(*changeTy)
+		cs = append(cs, Change{Kind: ChangeKind(c.kind), Pubkey: c.pubkey, To: c.to})
+		changeMan.EraseData(ref)
+		ref = c.next
+	}
+	return cs
+} //eraseChangeList
+
+// Updt
+// Erase the changes of the blocks from the block number from on, and keep them in unwoundChanges
+func eraseChanges (from int32) {
+	pendingChanges = nil
+	iw := changesT.Writer()
+	iw.Search(&intKey{ref: from})
+	for iw.PosSet() {
+		bnb := iw.CurrentKey().(*intKey).ref
+		ref := iw.ReadValue()
+		iw.Next()
+		unwoundChanges[bnb] = eraseChangeList(ref)
+		b := iw.Erase(&intKey{ref: bnb}); M.Assert(b, bnb, 100)
+	}
+} //eraseChanges
+
+// Updt
+// Erase the changes of the blocks before the block number from, so that changesT keeps only the changes of the last BA.ChangesDepth blocks
+func pruneChanges (from int32) {
+	iw := changesT.Writer()
+	iw.ResetPos()
+	iw.Next()
+	for iw.PosSet() {
+		bnb := iw.CurrentKey().(*intKey).ref
+		if bnb >= from {
+			break
+		}
+		ref := iw.ReadValue()
+		iw.Next()
+		eraseChangeList(ref)
+		b := iw.Erase(&intKey{ref: bnb}); M.Assert(b, bnb, 100)
+	}
+} //pruneChanges
+
+// Return the changes of the blocks from the block number from to the block number to, by increasing block numbers; blocks without changes are omitted, and so are the blocks whose changes have been pruned, i.e. the ones before the last BA.ChangesDepth blocks
+func Changes (from, to int32) []BlockChanges {
+	bcs := make([]BlockChanges, 0)
+	ir := changesT.NewReader()
+	ir.Search(&intKey{ref: from})
+	for ir.PosSet() {
+		bnb := ir.CurrentKey().(*intKey).ref
+		if bnb > to {
+			break
+		}
+		bc := BlockChanges{Block: bnb, Changes: make([]Change, 0)}
+		for ref := ir.ReadValue(); ref != B.BNil; {
+			c := changeMan.ReadData(ref).(*changeTy)
+			bc.Changes = append(bc.Changes, Change{Kind: ChangeKind(c.kind), Pubkey: c.pubkey, To: c.to})
+			ref = c.next
+		}
+		bcs = append(bcs, bc)
+		ir.Next()
+	}
+	return bcs
+} //Changes
+
+// Return the number of the first block read by the last update; the changes of the blocks from there may replace the ones given before
+func ChangedFrom () int32 {
+	return changedFrom
+} //ChangedFrom
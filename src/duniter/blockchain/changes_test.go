@@ -0,0 +1,24 @@
+package blockchain
+
+import (
+
+	BA	"duniter/basic"
+	M	"util/misc"
+		"testing"
+
+)
+
+func TestPruneChanges (t *testing.T) {
+	readSource(t, NewSource(fixture("expiry")))
+	M.Want(LastBlock() == 100, t)
+	all := Changes(0, LastBlock())
+	M.Want(len(all) == 4 && all[0].Block == 0 && all[3].Block == 70, t)
+	depth := BA.ChangesDepth
+	defer func () {
+		BA.ChangesDepth = depth
+	}()
+	BA.ChangesDepth = 40
+	readSource(t, NewSource(fixture("expiry")))
+	bcs := Changes(0, LastBlock())
+	M.Want(len(bcs) == 1 && bcs[0].Block == 70 && len(bcs[0].Changes) == len(all[3].Changes), t)
+}
@@ -0,0 +1,665 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Integrity checker of dBase, and rebuilding of its indexes from the data records
+// timeT, joinAndLeaveT and the sub-indexes certifiersIO and certifiedIO of identities hold primary data and are checked, but can't be rebuilt; idPubT, idUidT, idHashT and idTimeT are rebuilt from identity records, certFromT, certToT and certTimeT from certification records, timeMT from timeT, and idLenPlace from identity records
+
+import (
+	
+	B	"util/gbTree"
+	BA	"duniter/basic"
+		"fmt"
+
+)
+
+type (
+	
+	// State of a check
+	checker struct {
+		msgs []string // Faults found
+		bad map[int]bool // Places of the faulty indexes
+		idRefs, // Identity records, found from idPubT
+		certRefs map[B.FilePos]bool // Certification records, found from certFromT
+	}
+
+)
+
+// Pseudo-place of the sub-indexes certifiersIO and certifiedIO of identities, which have no place of their own
+const certIOPlace = -1
+
+// Names of the checked places
+var placeNames = map[int]string{
+	certIOPlace: "certifiersIO/certifiedIO",
+	timePlace: "timeT",
+	timeMPlace: "timeMT",
+	joinAndLeavePlace: "joinAndLeaveT",
+	idPubPlace: "idPubT",
+	idUidPlace: "idUidT",
+	idHashPlace: "idHashT",
+	idTimePlace: "idTimeT",
+	certFromPlace: "certFromT",
+	certToPlace: "certToT",
+	certTimePlace: "certTimeT",
+	idLenPlace: "idLenPlace",
+	changesPlace: "changesT",
+}
+
+// Record a fault of the index, or place, place
+func (c *checker) fault (place int, format string, a ... interface{}) {
+	s := placeNames[place] + ": " + fmt.Sprintf(format, a...)
+	lg.Warn(s)
+	c.msgs = append(c.msgs, s)
+	c.bad[place] = true
+} //fault
+
+// Run f; return false if it panicked
+func safe (f func ()) (ok bool) {
+	defer func () {
+		if r := recover(); r != nil {
+			ok = false
+		}
+	}()
+	f()
+	return true
+} //safe
+
+// Read the identity record at ref; ok == false if it's not readable
+func readId (ref B.FilePos) (id *identity, ok bool) {
+	ok = safe(func () {id = idMan.ReadData(ref).(*identity)}) && id.pubkey != "" && len(id.pubkey) <= PubkeyLen
+	return
+} //readId
+
+// Read the certification record at ref; ok == false if it's not readable
+func readCert (ref B.FilePos) (c *certification, ok bool) {
+	ok = safe(func () {c = certMan.ReadData(ref).(*certification)}) && c.from != "" && c.to != ""
+	return
+} //readCert
+
+// Is id in idTimeT, i.e. excluded and not yet revoked?
+func waitsRevocation (id *identity) bool {
+	return !id.member && id.expires_on != BA.Revoked
+} //waitsRevocation
+
+// Check timeT, timeMT and changesT
+func (c *checker) checkTimes () {
+	var n int32 = 0
+	ir := timeT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		bnb := ir.CurrentKey().(*intKey).ref
+		t := timeMan.ReadData(ir.ReadValue()).(*timeTy)
+		if t.bnb != bnb {
+			c.fault(timePlace, "block %d recorded under key %d", t.bnb, bnb)
+		}
+		if bnb != n {
+			c.fault(timePlace, "block %d missing", n)
+			n = bnb
+		}
+		pst := timeMT.NewReader()
+		if !pst.Search(&lIntKey{ref: t.mTime}) {
+			c.fault(timeMPlace, "median time %d of block %d missing", t.mTime, bnb)
+		}
+		n++
+		ir.Next()
+	}
+	if n != lastBlock + 1 {
+		c.fault(timePlace, "last block is %d instead of %d", n - 1, lastBlock)
+	}
+	ir = timeMT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		mTime := ir.CurrentKey().(*lIntKey).ref
+		if t := timeMan.ReadData(ir.ReadValue()).(*timeTy); t.mTime != mTime {
+			c.fault(timeMPlace, "block %d recorded under median time %d instead of %d", t.bnb, mTime, t.mTime)
+		}
+		ir.Next()
+	}
+	ir = changesT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		if bnb := ir.CurrentKey().(*intKey).ref; bnb < 0 || bnb > lastBlock {
+			c.fault(changesPlace, "changes of the unknown block %d", bnb)
+		}
+		ir.Next()
+	}
+} //checkTimes
+
+// Check idPubT, idUidT, idHashT, idTimeT, joinAndLeaveT and idLenPlace
+func (c *checker) checkIds () {
+	members := 0
+	ir := idPubT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		pub := ir.CurrentKey().(*pubKey).ref
+		ref := ir.ReadValue()
+		id, ok := readId(ref)
+		if !ok {
+			c.fault(idPubPlace, "unreadable identity record for %s", pub)
+			ir.Next()
+			continue
+		}
+		c.idRefs[ref] = true
+		if id.pubkey != pub {
+			c.fault(idPubPlace, "identity %s recorded under key %s", id.pubkey, pub)
+		}
+		if id.member {
+			members++
+		}
+		pst := idUidT.NewReader()
+		if !pst.Search(&B.String{C: id.uid}) || pst.ReadValue() != ref {
+			c.fault(idUidPlace, "identity %s (%s) not reachable", id.uid, pub)
+		}
+		pst = idHashT.NewReader()
+		if !pst.Search(&hashKey{ref: id.hash}) || pst.ReadValue() != ref {
+			c.fault(idHashPlace, "identity %s (%s) not reachable", id.hash, pub)
+		}
+		pst = idTimeT.NewReader()
+		if pst.Search(&filePosKey{ref: ref}) != waitsRevocation(id) {
+			c.fault(idTimePlace, "identity %s present if and only if not waiting for its revocation", pub)
+		}
+		pst = joinAndLeaveT.NewReader()
+		if pst.Search(&pubKey{ref: pub}) {
+			jl := joinAndLeaveMan.ReadData(pst.ReadValue()).(*joinAndLeave)
+			jlL := joinAndLeaveLMan.ReadData(jl.list).(*joinAndLeaveL)
+			if jl.pubkey != pub || (jlL.leavingBlock == HasNotLeaved) != id.member {
+				c.fault(joinAndLeavePlace, "history of %s inconsistent with its membership", pub)
+			}
+		} else if id.member {
+			c.fault(joinAndLeavePlace, "no history for member %s", pub)
+		}
+		ir.Next()
+	}
+	
+	reverse := func (place int, ind *B.Index, name func (key B.Data) string) {
+		ir := ind.NewReader()
+		ir.Next()
+		for ir.PosSet() {
+			if !c.idRefs[ir.ReadValue()] {
+				c.fault(idPubPlace, "identity %s missing", name(ir.CurrentKey()))
+			}
+			ir.Next()
+		}
+		if ind.NumberOfKeys() != len(c.idRefs) && !c.bad[idPubPlace] {
+			c.fault(place, "%d keys instead of %d", ind.NumberOfKeys(), len(c.idRefs))
+		}
+	} //reverse
+	
+	reverse(idUidPlace, idUidT, func (key B.Data) string {return key.(*B.String).C})
+	reverse(idHashPlace, idHashT, func (key B.Data) string {return string(key.(*hashKey).ref)})
+	ir = idTimeT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		if !c.idRefs[ir.CurrentKey().(*filePosKey).ref] {
+			c.fault(idPubPlace, "identity waiting for its revocation missing")
+		}
+		ir.Next()
+	}
+	if n := int(database.ReadPlace(idLenPlace)); n != members && !c.bad[idPubPlace] {
+		c.fault(idLenPlace, "%d instead of %d members", n, members)
+	}
+} //checkIds
+
+// Fault of the periods ps, the most recent first: a period outside the blockchain, an open period which isn't the most recent one, or periods which overlap; "" if none
+func periodsFault (ps []snapPeriod) string {
+	if len(ps) == 0 {
+		return "no period"
+	}
+	for i, p := range ps {
+		if p.In < 0 || p.In > lastBlock {
+			return fmt.Sprintf("period beginning in the unknown block %d", p.In)
+		}
+		if p.Out == HasNotLeaved {
+			if i > 0 {
+				return fmt.Sprintf("period beginning in block %d not closed", p.In)
+			}
+		} else if p.Out < p.In || p.Out > lastBlock {
+			return fmt.Sprintf("period %d-%d incorrect", p.In, p.Out)
+		}
+		if i > 0 && p.Out > ps[i - 1].In {
+			return fmt.Sprintf("period %d-%d overlapping the next one", p.In, p.Out)
+		}
+	}
+	return ""
+} //periodsFault
+
+// Identity uid, and whether it's readable
+func uidId (uid string) (*identity, bool) {
+	pst := idUidT.NewReader()
+	if !pst.Search(&B.String{C: uid}) {
+		return nil, false
+	}
+	return readId(pst.ReadValue())
+} //uidId
+
+// Check joinAndLeaveT and the sub-indexes certifiersIO and certifiedIO of identities; the periods of a certification must be the same on both sides, and only those of active certifications are open
+func (c *checker) checkHistories () {
+	ir := joinAndLeaveT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		pub := ir.CurrentKey().(*pubKey).ref
+		jl := joinAndLeaveMan.ReadData(ir.ReadValue()).(*joinAndLeave)
+		if jl.pubkey != pub {
+			c.fault(joinAndLeavePlace, "history of %s recorded under key %s", jl.pubkey, pub)
+		}
+		if pst := idPubT.NewReader(); !pst.Search(&pubKey{ref: pub}) {
+			c.fault(joinAndLeavePlace, "history of the unknown identity %s", pub)
+		}
+		ps := make([]snapPeriod, 0)
+		for ref := jl.list; ref != B.BNil; {
+			jlL := joinAndLeaveLMan.ReadData(ref).(*joinAndLeaveL)
+			ps = append(ps, snapPeriod{In: jlL.joiningBlock, Out: jlL.leavingBlock})
+			ref = jlL.next
+		}
+		if f := periodsFault(ps); f != "" {
+			c.fault(joinAndLeavePlace, "history of %s: %s", pub, f)
+		}
+		ir.Next()
+	}
+	ir = idPubT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		id, ok := readId(ir.ReadValue())
+		if !ok {
+			ir.Next()
+			continue
+		}
+		for _, l := range linksOf(id.certifiersIO) {
+			idF, ok := uidId(l.Uid)
+			if !ok {
+				c.fault(certIOPlace, "unknown certifier %s of %s", l.Uid, id.uid)
+				continue
+			}
+			if f := periodsFault(l.Periods); f != "" {
+				c.fault(certIOPlace, "certification %s -> %s: %s", l.Uid, id.uid, f)
+				continue
+			}
+			if _, _, active := Cert(idF.pubkey, id.pubkey); active != (l.Periods[0].Out == HasNotLeaved) {
+				c.fault(certIOPlace, "certification %s -> %s open if and only if not active", l.Uid, id.uid)
+			}
+			var mirror []snapPeriod
+			for _, m := range linksOf(idF.certifiedIO) {
+				if m.Uid == id.uid {
+					mirror = m.Periods
+				}
+			}
+			same := len(mirror) == len(l.Periods)
+			for i := 0; same && i < len(mirror); i++ {
+				same = mirror[i] == l.Periods[i]
+			}
+			if !same {
+				c.fault(certIOPlace, "certification %s -> %s with other periods in certifiedIO", l.Uid, id.uid)
+			}
+		}
+		for _, l := range linksOf(id.certifiedIO) {
+			idT, ok := uidId(l.Uid)
+			if !ok {
+				c.fault(certIOPlace, "unknown identity %s certified by %s", l.Uid, id.uid)
+				continue
+			}
+			if idT.certifiersIO == B.BNil || !database.OpenIndex(idT.certifiersIO, uidKeyMan, uidKeyFac).NewReader().Search(&B.String{C: id.uid}) {
+				c.fault(certIOPlace, "certification %s -> %s missing in certifiersIO", id.uid, l.Uid)
+			}
+		}
+		ir.Next()
+	}
+} //checkHistories
+
+// Check certFromT, certToT and certTimeT
+func (c *checker) checkCerts () {
+	ir := certFromT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		from := ir.CurrentKey().(*pubKey).ref
+		ir2 := database.OpenIndex(ir.ReadValue(), pubKeyMan, pubKeyFac).NewReader()
+		ir2.Next()
+		for ir2.PosSet() {
+			to := ir2.CurrentKey().(*pubKey).ref
+			ref := ir2.ReadValue()
+			cert, ok := readCert(ref)
+			if !ok {
+				c.fault(certFromPlace, "unreadable certification record %s -> %s", from, to)
+				ir2.Next()
+				continue
+			}
+			c.certRefs[ref] = true
+			if cert.from != from || cert.to != to {
+				c.fault(certFromPlace, "certification %s -> %s recorded under %s -> %s", cert.from, cert.to, from, to)
+			}
+			for _, p := range [2]Pubkey{from, to} {
+				if pst := idPubT.NewReader(); !pst.Search(&pubKey{ref: p}) {
+					c.fault(idPubPlace, "identity %s of certification %s -> %s missing", p, from, to)
+				}
+			}
+			pst := certToT.NewReader()
+			if !pst.Search(&pubKey{ref: to}) {
+				c.fault(certToPlace, "certification %s -> %s not reachable", from, to)
+			} else {
+				ctf := certToForkMan.ReadData(pst.ReadValue()).(*certToFork)
+				pst = database.OpenIndex(ctf.byPub, pubKeyMan, pubKeyFac).NewReader()
+				if !pst.Search(&pubKey{ref: from}) || pst.ReadValue() != ref {
+					c.fault(certToPlace, "certification %s -> %s not reachable by pubkey", from, to)
+				}
+				pst = database.OpenIndex(ctf.byExp, certKTimeMan, filePosKeyFac).NewReader()
+				if !pst.Search(&filePosKey{ref: ref}) {
+					c.fault(certToPlace, "certification %s -> %s not reachable by expiration date", from, to)
+				}
+			}
+			if pst := certTimeT.NewReader(); !pst.Search(&filePosKey{ref: ref}) {
+				c.fault(certTimePlace, "certification %s -> %s missing", from, to)
+			}
+			ir2.Next()
+		}
+		ir.Next()
+	}
+	n := 0
+	ir = certToT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		to := ir.CurrentKey().(*pubKey).ref
+		ctf := certToForkMan.ReadData(ir.ReadValue()).(*certToFork)
+		byPub := database.OpenIndex(ctf.byPub, pubKeyMan, pubKeyFac)
+		if byExp := database.OpenIndex(ctf.byExp, certKTimeMan, filePosKeyFac); byExp.NumberOfKeys() != byPub.NumberOfKeys() {
+			c.fault(certToPlace, "%d certifications of %s by expiration date instead of %d", byExp.NumberOfKeys(), to, byPub.NumberOfKeys())
+		}
+		ir2 := byPub.NewReader()
+		ir2.Next()
+		for ir2.PosSet() {
+			if !c.certRefs[ir2.ReadValue()] {
+				c.fault(certFromPlace, "certification %s -> %s missing", ir2.CurrentKey().(*pubKey).ref, to)
+			}
+			n++
+			ir2.Next()
+		}
+		ir.Next()
+	}
+	ir = certTimeT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		if !c.certRefs[ir.CurrentKey().(*filePosKey).ref] {
+			c.fault(certFromPlace, "certification waiting for its expiration missing")
+		}
+		ir.Next()
+	}
+	if n != len(c.certRefs) && !c.bad[certFromPlace] {
+		c.fault(certToPlace, "%d certifications instead of %d", n, len(c.certRefs))
+	}
+	if m := certTimeT.NumberOfKeys(); m != len(c.certRefs) && !c.bad[certFromPlace] {
+		c.fault(certTimePlace, "%d certifications instead of %d", m, len(c.certRefs))
+	}
+} //checkCerts
+
+// Delete the index at place, and its sub-indexes if any; corrupted parts are abandoned
+func deleteIndex (place int) {
+	ref := B.FilePos(database.ReadPlace(place))
+	safe(func () {
+		switch place {
+		case certFromPlace:
+			ir := certFromT.NewReader()
+			ir.Next()
+			for ir.PosSet() {
+				database.DeleteIndex(ir.ReadValue())
+				ir.Next()
+			}
+		case certToPlace:
+			ir := certToT.NewReader()
+			ir.Next()
+			for ir.PosSet() {
+				ctfRef := ir.ReadValue()
+				ctf := certToForkMan.ReadData(ctfRef).(*certToFork)
+				database.DeleteIndex(ctf.byPub)
+				database.DeleteIndex(ctf.byExp)
+				certToForkMan.EraseData(ctfRef)
+				ir.Next()
+			}
+		}
+	})
+	safe(func () {database.DeleteIndex(ref)})
+} //deleteIndex
+
+// Add to refs the records reachable from ind which are readable by read; the records of idTimeT and certTimeT are their keys, and those of certFromT and certToT (sub == true) are in their sub-indexes
+func collect (refs map[B.FilePos]bool, ind *B.Index, sub bool, read func (ref B.FilePos) (string, bool)) {
+	safe(func () {
+		ir := ind.NewReader()
+		ir.Next()
+		for ir.PosSet() {
+			if sub { // ind is certFromT or certToT
+				v := ir.ReadValue()
+				var subRef B.FilePos
+				if ind == certToT {
+					subRef = certToForkMan.ReadData(v).(*certToFork).byPub
+				} else {
+					subRef = v
+				}
+				collect(refs, database.OpenIndex(subRef, pubKeyMan, pubKeyFac), false, read)
+			} else {
+				ref := ir.ReadValue()
+				if k, ok := ir.CurrentKey().(*filePosKey); ok {
+					ref = k.ref
+				}
+				if _, ok := read(ref); ok {
+					refs[ref] = true
+				}
+			}
+			ir.Next()
+		}
+	})
+} //collect
+
+// Rebuild the faulty identity indexes and idLenPlace from the identity records
+func (c *checker) rebuildIds () {
+	refs := make(map[B.FilePos]bool)
+	readI := func (ref B.FilePos) (string, bool) {id, ok := readId(ref); if ok {return string(id.pubkey), true}; return "", false}
+	collect(refs, idPubT, false, readI)
+	collect(refs, idUidT, false, readI)
+	collect(refs, idHashT, false, readI)
+	collect(refs, idTimeT, false, readI)
+	ids := make(map[Pubkey]B.FilePos)
+	for ref := range refs {
+		id, _ := readId(ref)
+		if old, ok := ids[id.pubkey]; !ok || old > ref {
+			ids[id.pubkey] = ref
+		}
+	}
+	for _, place := range [4]int{idPubPlace, idUidPlace, idHashPlace, idTimePlace} {
+		if !c.bad[place] {
+			continue
+		}
+		deleteIndex(place)
+		var ind *B.Index
+		switch place {
+		case idTimePlace:
+			database.WritePlace(place, int64(database.CreateIndex(idTimeKeyS)))
+			idTimeT = database.OpenIndex(B.FilePos(database.ReadPlace(place)), idKTimeMan, filePosKeyFac)
+			ind = idTimeT
+		default:
+			database.WritePlace(place, int64(database.CreateIndex(0)))
+			switch place {
+			case idPubPlace:
+				idPubT = database.OpenIndex(B.FilePos(database.ReadPlace(place)), pubKeyMan, pubKeyFac)
+				ind = idPubT
+			case idUidPlace:
+				idUidT = database.OpenIndex(B.FilePos(database.ReadPlace(place)), uidKeyMan, uidKeyFac)
+				ind = idUidT
+			case idHashPlace:
+				idHashT = database.OpenIndex(B.FilePos(database.ReadPlace(place)), hashKeyMan, hashKeyFac)
+				ind = idHashT
+			}
+		}
+		iw := ind.Writer()
+		for _, ref := range ids {
+			id, _ := readId(ref)
+			var key B.Data
+			switch place {
+			case idPubPlace:
+				key = &pubKey{ref: id.pubkey}
+			case idUidPlace:
+				key = &B.String{C: id.uid}
+			case idHashPlace:
+				key = &hashKey{ref: id.hash}
+			case idTimePlace:
+				if !waitsRevocation(id) {
+					continue
+				}
+				key = &filePosKey{ref: ref}
+			}
+			if iw.SearchIns(key) {
+				lg.Warn(placeNames[place] + ": duplicate key for", id.pubkey, "dropped")
+			} else if place != idTimePlace {
+				iw.WriteValue(ref)
+			}
+		}
+		lg.Println(placeNames[place], "rebuilt")
+	}
+	if c.bad[idLenPlace] || c.bad[idPubPlace] {
+		members := 0
+		for _, ref := range ids {
+			if id, _ := readId(ref); id.member {
+				members++
+			}
+		}
+		database.WritePlace(idLenPlace, int64(members))
+		lg.Println(placeNames[idLenPlace], "rebuilt")
+	}
+} //rebuildIds
+
+// Rebuild the faulty certification indexes from the certification records
+func (c *checker) rebuildCerts () {
+	if !c.bad[certFromPlace] && !c.bad[certToPlace] && !c.bad[certTimePlace] {
+		return
+	}
+	refs := make(map[B.FilePos]bool)
+	readC := func (ref B.FilePos) (string, bool) {c, ok := readCert(ref); if ok {return string(c.from) + ":" + string(c.to), true}; return "", false}
+	collect(refs, certFromT, true, readC)
+	collect(refs, certToT, true, readC)
+	collect(refs, certTimeT, false, readC)
+	certs := make(map[string]B.FilePos)
+	for ref := range refs {
+		k, _ := readC(ref)
+		if old, ok := certs[k]; !ok || old > ref {
+			certs[k] = ref
+		}
+	}
+	if c.bad[certFromPlace] {
+		deleteIndex(certFromPlace)
+		database.WritePlace(certFromPlace, int64(database.CreateIndex(0)))
+		certFromT = database.OpenIndex(B.FilePos(database.ReadPlace(certFromPlace)), pubKeyMan, pubKeyFac)
+		iwF := certFromT.Writer()
+		for _, ref := range certs {
+			cert, _ := readCert(ref)
+			var v B.FilePos
+			if iwF.SearchIns(&pubKey{ref: cert.from}) {
+				v = iwF.ReadValue()
+			} else {
+				v = database.CreateIndex(pubKeyS)
+				iwF.WriteValue(v)
+			}
+			iw := database.OpenIndex(v, pubKeyMan, pubKeyFac).Writer()
+			iw.SearchIns(&pubKey{ref: cert.to})
+			iw.WriteValue(ref)
+		}
+		lg.Println(placeNames[certFromPlace], "rebuilt")
+	}
+	if c.bad[certToPlace] {
+		deleteIndex(certToPlace)
+		database.WritePlace(certToPlace, int64(database.CreateIndex(0)))
+		certToT = database.OpenIndex(B.FilePos(database.ReadPlace(certToPlace)), pubKeyMan, pubKeyFac)
+		iwT := certToT.Writer()
+		for _, ref := range certs {
+			cert, _ := readCert(ref)
+			var ctf *certToFork
+			if iwT.SearchIns(&pubKey{ref: cert.to}) {
+				ctf = certToForkMan.ReadData(iwT.ReadValue()).(*certToFork)
+			} else {
+				ctf = &certToFork{byPub: database.CreateIndex(pubKeyS), byExp: database.CreateIndex(certTimeKeyS)}
+				iwT.WriteValue(certToForkMan.WriteAllocateData(ctf))
+			}
+			iw := database.OpenIndex(ctf.byPub, pubKeyMan, pubKeyFac).Writer()
+			iw.SearchIns(&pubKey{ref: cert.from})
+			iw.WriteValue(ref)
+			iw = database.OpenIndex(ctf.byExp, certKTimeMan, filePosKeyFac).Writer()
+			iw.SearchIns(&filePosKey{ref: ref})
+			iw.WriteValue(ref)
+		}
+		lg.Println(placeNames[certToPlace], "rebuilt")
+	}
+	if c.bad[certTimePlace] || c.bad[certFromPlace] {
+		deleteIndex(certTimePlace)
+		database.WritePlace(certTimePlace, int64(database.CreateIndex(certTimeKeyS)))
+		certTimeT = database.OpenIndex(B.FilePos(database.ReadPlace(certTimePlace)), certKTimeMan, filePosKeyFac)
+		iw := certTimeT.Writer()
+		for _, ref := range certs {
+			iw.SearchIns(&filePosKey{ref: ref})
+		}
+		lg.Println(placeNames[certTimePlace], "rebuilt")
+	}
+} //rebuildCerts
+
+// Rebuild timeMT from timeT
+func (c *checker) rebuildTimes () {
+	if !c.bad[timeMPlace] {
+		return
+	}
+	deleteIndex(timeMPlace)
+	database.WritePlace(timeMPlace, int64(database.CreateIndex(timeMKeyS)))
+	timeMT = database.OpenIndex(B.FilePos(database.ReadPlace(timeMPlace)), lIntKeyMan, lIntKeyFac)
+	iw := timeMT.Writer()
+	ir := timeT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		t := timeMan.ReadData(ir.ReadValue()).(*timeTy)
+		if !iw.SearchIns(&lIntKey{ref: t.mTime}) { // The first block with a given median time
+			iw.WriteValue(ir.ReadValue())
+		}
+		ir.Next()
+	}
+	lg.Println(placeNames[timeMPlace], "rebuilt")
+} //rebuildTimes
+
+// Run all the checks and return their faults
+func (c *checker) run () {
+	c.msgs = make([]string, 0)
+	c.bad = make(map[int]bool)
+	c.idRefs = make(map[B.FilePos]bool)
+	c.certRefs = make(map[B.FilePos]bool)
+	for _, f := range [4]func (){c.checkTimes, c.checkIds, c.checkCerts, c.checkHistories} {
+		if !safe(f) {
+			c.msgs = append(c.msgs, "Check interrupted by an unreadable index")
+		}
+	}
+} //run
+
+// Check dBase, which must not be in use by a running server; if repair, rebuild the faulty indexes which can be and check again; return the faults found, and whether dBase is sound at the end
+func CheckBase (repair bool) (faults []string, ok bool) {
+	lg.Println("Checking \"" + dBaseName + "\"")
+	openB()
+	defer closeB()
+	lastBlock = int32(database.ReadPlace(lastNPlace))
+	c := new(checker)
+	c.run()
+	faults = c.msgs
+	if repair && len(c.msgs) > 0 {
+		lg.Println("Repairing \"" + dBaseName + "\"")
+		c.rebuildTimes()
+		c.rebuildIds()
+		c.rebuildCerts()
+		database.UpdateBase()
+		c.run()
+		for _, s := range c.msgs {
+			faults = append(faults, "After repair: " + s)
+		}
+	}
+	ok = len(c.msgs) == 0
+	lg.Println("\"" + dBaseName + "\" checked; sound:", ok)
+	return
+} //CheckBase
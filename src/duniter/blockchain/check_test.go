@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+
+	B	"util/gbTree"
+	M	"util/misc"
+		"strings"
+		"testing"
+
+)
+
+// Faults of dBase at place
+func faultsAt (place int) int {
+	c := new(checker)
+	c.run()
+	n := 0
+	for _, s := range c.msgs {
+		if strings.HasPrefix(s, placeNames[place] + ":") {
+			n++
+		}
+	}
+	return n
+} //faultsAt
+
+func TestCheckHistories (t *testing.T) {
+	readSource(t, NewSource(fixture("expiry")))
+	M.Want(sound(), t)
+
+	// Close the period of an active certification on the certifiers side only
+	var cioR B.FilePos = B.BNil
+	ir := idPubT.NewReader()
+	ir.Next()
+	for ir.PosSet() && cioR == B.BNil {
+		id := idMan.ReadData(ir.ReadValue()).(*identity)
+		if id.certifiersIO != B.BNil {
+			ir2 := database.OpenIndex(id.certifiersIO, uidKeyMan, uidKeyFac).NewReader()
+			ir2.Next()
+			for ir2.PosSet() && cioR == B.BNil {
+				if cio := certInOutMan.ReadData(ir2.ReadValue()).(*certInOut); cio.outBlock == HasNotLeaved {
+					cioR = ir2.ReadValue()
+				}
+				ir2.Next()
+			}
+		}
+		ir.Next()
+	}
+	M.Want(cioR != B.BNil, t)
+	if cioR == B.BNil {
+		return
+	}
+	cio := certInOutMan.ReadData(cioR).(*certInOut)
+	cio.outBlock = LastBlock()
+	certInOutMan.WriteData(cioR, cio)
+	M.Want(faultsAt(certIOPlace) == 2, t)
+
+	// Make a membership period end after the last block
+	readSource(t, NewSource(fixture("expiry")))
+	ir = joinAndLeaveT.NewReader()
+	ir.Next()
+	M.Want(ir.PosSet(), t)
+	jl := joinAndLeaveMan.ReadData(ir.ReadValue()).(*joinAndLeave)
+	jlL := joinAndLeaveLMan.ReadData(jl.list).(*joinAndLeaveL)
+	jlL.joiningBlock = LastBlock() + 1
+	joinAndLeaveLMan.WriteData(jl.list, jlL)
+	M.Want(faultsAt(joinAndLeavePlace) == 1, t)
+}
@@ -0,0 +1,119 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Read-only access to the SQLite export of Duniter: all queries go through prepared statements, and values are only passed as parameters
+
+import (
+	
+	M	"util/misc"
+	Q	"database/sql"
+		"net/url"
+		"strings"
+
+)
+
+const (
+	
+	// Maximal number of pubkeys in one query of IdHashes
+	idHashesBatch = 100
+
+)
+
+type (
+	
+	// Read-only connection to the SQLite export of Duniter, with its prepared statements
+	DuniterDB struct {
+		d *Q.DB
+		sch *Schema
+		stmts map[string] *Q.Stmt // Prepared statements, by their texts
+	}
+
+)
+
+// Data source name of the SQLite database at path, opened read-only
+func readOnlyDSN (path string) string {
+	return "file:" + (&url.URL{Path: path}).EscapedPath() + "?mode=ro"
+} //readOnlyDSN
+
+// Open the SQLite export of Duniter at path, read-only, and detect its layout
+func OpenDuniterDB (path string) *DuniterDB {
+	d, err := Q.Open(driver, readOnlyDSN(path)); M.Assert(err == nil, err, 100)
+	return &DuniterDB{d: d, sch: DetectSchema(d), stmts: make(map[string] *Q.Stmt)}
+} //OpenDuniterDB
+
+func (db *DuniterDB) Close () {
+	for _, st := range db.stmts {
+		st.Close()
+	}
+	db.stmts = nil
+	db.d.Close()
+} //Close
+
+// Layout of db
+func (db *DuniterDB) Schema () *Schema {
+	return db.sch
+} //Schema
+
+// Return the statement query, prepared at its first use; the Fork method of Schema may be used in query, the values must be parameters ('?')
+func (db *DuniterDB) stmt (query string) *Q.Stmt {
+	st, ok := db.stmts[query]
+	if !ok {
+		var err error
+		st, err = db.d.Prepare(query); M.Assert(err == nil, err, query, 100)
+		db.stmts[query] = st
+	}
+	return st
+} //stmt
+
+// Run the prepared statement query with the parameters args
+func (db *DuniterDB) Query (query string, args ... interface{}) *Q.Rows {
+	rows, err := db.stmt(query).Query(args...); M.Assert(err == nil, err, query, 100)
+	return rows
+} //Query
+
+// Run the prepared statement query, which returns at most one row, with the parameters args
+func (db *DuniterDB) QueryRow (query string, args ... interface{}) *Q.Row {
+	return db.stmt(query).QueryRow(args...)
+} //QueryRow
+
+// Return the hashes of the last identities written with the pubkeys ps in the block number bnb or before, in batches; a pubkey without such an identity is absent from the result
+func (db *DuniterDB) IdHashes (ps []Pubkey, bnb int) map[Pubkey] Hash {
+	hs := make(map[Pubkey] Hash)
+	for len(ps) > 0 {
+		n := len(ps)
+		if n > idHashesBatch {
+			n = idHashesBatch
+		}
+		args := make([]interface{}, n + 1)
+		for i, p := range ps[:n] {
+			args[i] = string(p)
+		}
+		args[n] = bnb
+		rows := db.Query("SELECT pub, hash FROM i_index WHERE pub IN (?" + strings.Repeat(", ?", n - 1) + ") AND writtenOn <= ? ORDER BY writtenOn ASC", args...)
+		for rows.Next() {
+			var (
+				p string
+				h Q.NullString
+			)
+			err := rows.Scan(&p, &h); M.Assert(err == nil, err, 101)
+			if h.Valid {
+				hs[Pubkey(p)] = Hash(h.String)
+			}
+		}
+		M.Assert(rows.Err() == nil, rows.Err(), 60)
+		rows.Close()
+		ps = ps[n:]
+	}
+	return hs
+} //IdHashes
@@ -0,0 +1,158 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Recovery from forks deeper than secureGap: detection by comparison of block hashes, unwinding of undoListT or rebuilding of dBase
+
+import (
+	
+	B	"util/gbTree"
+	M	"util/misc"
+		"os"
+
+)
+
+// Updt
+// Hash of the block number bnb recorded in dBase; ok == false if bnb is not in dBase
+func hashOf (bnb int32) (hash Hash, ok bool) {
+	pst := timeT.NewReader()
+	ok = pst.Search(&intKey{ref: bnb})
+	if ok {
+		hash = timeMan.ReadData(pst.ReadValue()).(*timeTy).hash
+	}
+	return
+} //hashOf
+
+// Updt
+// Is the block number bnb of dBase the same as the one of src? Unknown hashes ("") match anything
+func sameBlock (src BlockSource, bnb int32) bool {
+	h1, ok := hashOf(bnb); M.Assert(ok, bnb, 100)
+	h2, ok := src.BlockHash(int(bnb))
+	return ok && (h1 == h2 || h1 == "" || h2 == "")
+} //sameBlock
+
+// Updt
+// Return the number of the first block of dBase which differs from the block with the same number in src, the common ancestor being the previous one; blocks of the secureGap last ones are not compared, since they are read again anyway; return lastBlock - secureGap + 1 if there is no such block
+// Since every block includes the hash of the previous one, if a block is the same in dBase and in src, all previous blocks are the same too, and a binary search is possible
+func forkPoint (src BlockSource) int32 {
+	top := lastBlock - secureGap
+	if top < 0 || sameBlock(src, top) {
+		return top + 1
+	}
+	lo, hi := int32(0), top // hi differs
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if sameBlock(src, mid) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+} //forkPoint
+
+// Updt
+// Log the blocks of dBase from f on, which are going to be reverted
+func logFork (src BlockSource, f int32) {
+	lgU.Warn("Fork detected: blocks", f, "to", lastBlock, "differ from Duniter ones and are reverted")
+	h1, _ := hashOf(f)
+	h2, ok := src.BlockHash(int(f))
+	if !ok {
+		h2 = "absent"
+	}
+	lgU.Println("Block", f, "was", h1, "and is now", h2)
+	if f > 0 {
+		h, _ := hashOf(f - 1)
+		lgU.Println("Common ancestor:", f - 1, h)
+	} else {
+		lgU.Println("No common ancestor")
+	}
+} //logFork
+
+// Updt
+// Return the number of the block of the element ref of undoListT; the operations of a block are pushed onto undoListT after its timeList element, and so precede it in the list
+func blockOf (ref B.FilePos) int32 {
+	l := undoListMan.ReadData(ref).(*undoListT)
+	for l.typ != timeList {
+		M.Assert(l.next != B.BNil, 100)
+		l = undoListMan.ReadData(l.next).(*undoListT)
+	}
+	return timeMan.ReadData(l.ref).(*timeTy).bnb
+} //blockOf
+
+// Updt
+// Return the number of the oldest block whose operations are kept in undoListT, i.e. the oldest block to which dBase can be unwound; lastBlock + 1 if undoListT is empty
+func oldestUndoable () int32 {
+	oldest := lastBlock + 1
+	for ref := undoList; ref != B.BNil; {
+		l := undoListMan.ReadData(ref).(*undoListT)
+		if l.typ == timeList {
+			oldest = timeMan.ReadData(l.ref).(*timeTy).bnb
+		}
+		ref = l.next
+	}
+	return oldest
+} //oldestUndoable
+
+// Updt
+// Make the operations of the blocks before the block number n definitive, i.e. remove them from undoListT, and erase the certifications they kept alive
+func trimUndoList (n int32) {
+	var (prevRef = B.BNil; prev *undoListT = nil)
+	ref := undoList
+	boundary := true
+	for ref != B.BNil {
+		if boundary && blockOf(ref) < n {
+			break
+		}
+		l := undoListMan.ReadData(ref).(*undoListT)
+		boundary = l.typ == timeList
+		prevRef = ref; prev = l
+		ref = l.next
+	}
+	if ref == B.BNil {
+		return
+	}
+	if prev == nil {
+		undoList = B.BNil
+	} else {
+		prev.next = B.BNil
+		undoListMan.WriteData(prevRef, prev)
+	}
+	for ref != B.BNil {
+		l := undoListMan.ReadData(ref).(*undoListT)
+		switch l.typ {
+		case certAddList:
+			if B.FilePos(l.aux) != B.BNil {
+				certMan.EraseData(B.FilePos(l.aux))
+			}
+		case certRemoveList:
+			certMan.EraseData(l.ref)
+		}
+		undoListMan.EraseData(ref)
+		ref = l.next
+	}
+} //trimUndoList
+
+// Updt
+// Replace dBase by an empty one
+func rebuildB () {
+	closeB()
+	err := os.Remove(dBase); M.Assert(err == nil, err, 100)
+	openB()
+	undoList = B.BNil
+	lastBlock = -1
+	idLenM = 0
+	now = 0
+	rNow = 0
+	touchedAll = true
+} //rebuildB
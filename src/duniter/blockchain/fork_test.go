@@ -0,0 +1,136 @@
+package blockchain
+
+import (
+
+	F	"path/filepath"
+	M	"util/misc"
+		"fmt"
+		"os"
+		"reflect"
+		"strings"
+		"testing"
+
+)
+
+// Set secureGap to gap for the duration of the test t
+func setSecureGap (t *testing.T, gap int32) {
+	old := secureGap
+	secureGap = gap
+	t.Cleanup(func () {secureGap = old})
+} //setSecureGap
+
+// Lines of the blocks of the fixture expiry
+func expiryBlocks () []string {
+	buf, err := os.ReadFile(F.Join(fixture("expiry"), "blocks.jsonl")); M.Assert(err == nil, err, 100)
+	return strings.Split(strings.TrimSpace(string(buf)), "\n")
+} //expiryBlocks
+
+// Source of the blocks lines
+func sourceOf (t *testing.T, lines []string) BlockSource {
+	dir := t.TempDir()
+	err := os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(strings.Join(lines, "\n") + "\n"), 0644); M.Assert(err == nil, err, 100)
+	return NewSource(dir)
+} //sourceOf
+
+// Lines of the empty blocks from the block number from to the block number to, following the last block of the fixture expiry, with the hash prefix h
+func emptyBlocks (from, to int, h string) []string {
+	const (medianTime100 = 1549467127; avgGenTime = 300)
+	lines := make([]string, 0, to - from + 1)
+	for n := from; n <= to; n++ {
+		m := medianTime100 + int64(n - 100) * avgGenTime
+		lines = append(lines, fmt.Sprintf(`{"number": %d, "hash": "%058d%s%07X", "medianTime": %d, "time": %d, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": [], "identities": []}`, n, 0, h, n, m, m + 5))
+	}
+	return lines
+} //emptyBlocks
+
+// Rewrite the blocks lines from the index from on, as in a fork: their hashes change, and dave is excluded in the block number excl
+func forkFrom (lines []string, from, excl int) []string {
+	f := append([]string(nil), lines...)
+	for i := from; i < len(f); i++ {
+		j := strings.Index(f[i], `"hash": "`) + len(`"hash": "`) + 56
+		f[i] = f[i][:j] + "F" + f[i][j + 1:]
+	}
+	f[excl] = strings.Replace(f[excl], `"excluded": []`, `"excluded": ["` + eDave + `"]`, 1)
+	return f
+} //forkFrom
+
+// Snapshot of the open dBase, without its changelog, which depends on the history of the updates
+func contentOf () *snapshot {
+	s := takeSnapshot()
+	s.Changes = nil
+	return s
+} //contentOf
+
+// Check that the open dBase, after the update from src, holds the same content as a dBase made from src alone
+func wantFreshRead (t *testing.T, step string, src BlockSource) {
+	s := contentOf()
+	readSource(t, src)
+	if !reflect.DeepEqual(s, contentOf()) {
+		t.Error(step, ": dBase differs from a fresh read of the new chain")
+	}
+} //wantFreshRead
+
+func TestDeepFork (t *testing.T) {
+	setSecureGap(t, 5)
+	lines := expiryBlocks()
+	readSource(t, sourceOf(t, lines))
+	M.Want(lastBlock == 100, t)
+	fork := forkFrom(lines, 60, 80) // Far below lastBlock - secureGap
+	src := sourceOf(t, fork)
+	src.Open()
+	M.Want(forkPoint(src) == 60, t)
+	src.Close()
+	updateFrom(src)
+	M.Want(lastIngErr == nil && lastBlock == 100, t)
+	h, _ := hashOf(60)
+	M.Want(strings.Contains(string(h), "F"), t)
+	_, member, _, _, _, _, ok := IdPubComplete(eDave)
+	M.Want(ok && !member, t)
+	wantFreshRead(t, "unwound fork", src)
+}
+
+func TestForkBeyondUndo (t *testing.T) {
+	setSecureGap(t, 5)
+	lines := append(expiryBlocks(), emptyBlocks(101, 100 + undoDepth + 50, "E")...)
+	readSource(t, sourceOf(t, lines))
+	M.Want(oldestUndoable() > 60, t)
+	src := sourceOf(t, forkFrom(lines, 60, 80)) // Deeper than undoListT: dBase is rebuilt
+	updateFrom(src)
+	M.Want(lastIngErr == nil && int(lastBlock) == 100 + undoDepth + 50, t)
+	_, member, _, _, _, _, ok := IdPubComplete(eDave)
+	M.Want(ok && !member, t)
+	wantFreshRead(t, "rebuilt fork", src)
+}
+
+func TestForkShorterChain (t *testing.T) {
+	setSecureGap(t, 5)
+	lines := expiryBlocks()
+	readSource(t, sourceOf(t, append(lines, emptyBlocks(101, 105, "E")...)))
+	M.Want(Now() == 1549467127 + 5 * 300, t)
+	updateFrom(sourceOf(t, forkFrom(lines, 60, 80)))
+	M.Want(lastIngErr == nil && lastBlock == 100 && Now() == 1549467127, t) // The present time is the one of the new last block
+}
+
+// The fixture former holds a dBase of the former layout, made by the version of WotWizard preceding the changelog from the database of the fixture sqlite17
+func TestMigration (t *testing.T) {
+	if database != nil {
+		closeB()
+	}
+	copyFile(F.Join(fixture("former"), dBaseName), dBase)
+	os.Remove(dCopy)
+	openB()
+	M.Want(database.PlaceNb() == placeNb && database.ReadPlace(formatPlace) == dBaseFormat, t)
+	_, err := os.Stat(dCopy)
+	M.Want(err == nil, t) // The former dBase is kept
+	s := contentOf()
+	M.Want(s.LastBlock == 3 && len(s.Identities) == 4, t)
+	// The former layout didn't record the hashes of the blocks
+	readSource(t, NewSource(F.Join(fixture("sqlite17"), "wotwizard-export.db")))
+	fresh := contentOf()
+	for i := range fresh.Blocks {
+		fresh.Blocks[i].Hash = ""
+	}
+	if !reflect.DeepEqual(s, fresh) {
+		t.Error("The migrated dBase differs from a fresh read of its source")
+	}
+}
@@ -0,0 +1,390 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Errors met while reading the Duniter source, and what to do with them
+
+import (
+	
+	BA	"duniter/basic"
+	J	"encoding/json"
+	M	"util/misc"
+		"errors"
+		"fmt"
+		"strconv"
+		"strings"
+		"sync"
+		"time"
+
+)
+
+const (
+	
+	// Policies for ingestion errors, as given by the "-onError" option
+	
+	// Stop reading at the block before the faulty one; it's checked again at each update
+	HaltPolicy = "halt"
+	// Skip the faulty entries of the block, record them, and go on with the rest of the block
+	QuarantinePolicy = "quarantine"
+	
+	// Field of ingestion errors which don't happen in a block field
+	sourceField = "source"
+
+)
+
+type (
+	
+	// Error met while reading the Duniter source
+	IngestionError struct {
+		Block int // Number of the faulty block; -1 if unknown
+		Field string // Block field being read: "parameters", "medianTime", "expirations", "joiners", "actives", "leavers", "revoked", "excluded", "certifications", or sourceField if the source itself can't be read
+		Msg string
+		Date int64 // Real time of the error, in s
+		Quarantined bool // The faulty entries of the block were skipped and reading went on; otherwise reading stopped before the block
+		Skipped []string // Skipped entries, as "field: entry: message", if Quarantined
+	}
+	
+	// Membership of a joiners, actives or leavers entry
+	msEntry struct {
+		pubkey Pubkey
+		application int32 // Number of the block of the membership application
+		uid string
+	}
+	
+	// Certification of a certifications entry
+	certEntry struct {
+		from,
+		to Pubkey
+		block int32 // Number of the block of the certification
+	}
+	
+	// Checked lists of a block
+	blockLists struct {
+		joiners,
+		actives,
+		leavers []msEntry
+		revoked,
+		excluded []Pubkey
+		certs []certEntry
+		hashes map[Pubkey] Hash // Hashes of the joiners
+	}
+
+)
+
+var (
+	
+	// Block field being read; Updt
+	ingField string
+	
+	// Last ingestion error, nil if none; written in Updt, read in Cmds, under ingMut
+	lastIngErr *IngestionError = nil
+	ingMut sync.Mutex
+	
+)
+
+func (e *IngestionError) Error () string {
+	s := "Ingestion error"
+	if e.Block >= 0 {
+		s += " in block " + strconv.Itoa(e.Block)
+	}
+	return s + ", field " + e.Field + ": " + e.Msg
+} //Error
+
+// Return the policy for ingestion errors; halt is the default
+func ingestionPolicy () string {
+	if BA.IngestionPolicy == QuarantinePolicy {
+		return QuarantinePolicy
+	}
+	return HaltPolicy
+} //ingestionPolicy
+
+// Updt
+// Run f, which reads the block bnb (-1 if unknown) of the Duniter source; return the panic raised by f, if any, as an *IngestionError, or nil
+func ingest (bnb int, f func ()) (e *IngestionError) {
+	defer func () {
+		if r := recover(); r != nil {
+			field := ingField
+			if field == "" {
+				field = sourceField
+			}
+			msg := fmt.Sprint(r)
+			if err, ok := r.(error); ok {
+				msg = err.Error()
+			} else if _, ok := r.(int); ok {
+				msg = "assertion " + msg + " failed"
+			}
+			e = &IngestionError{Block: bnb, Field: field, Msg: msg, Date: time.Now().Unix()}
+		}
+	}()
+	ingField = ""
+	f()
+	return
+} //ingest
+
+// Updt
+// Record e as the last ingestion error
+func reportIngestion (e *IngestionError) {
+	M.Assert(e != nil, 20)
+	lgU.Error(e.Error())
+	if e.Quarantined {
+		for _, s := range e.Skipped {
+			lgU.Warn("Block", e.Block, "- entry skipped:", s)
+		}
+	} else {
+		lgU.Warn("Reading of the Duniter source halted")
+	}
+	ingMut.Lock()
+	lastIngErr = e
+	ingMut.Unlock()
+} //reportIngestion
+
+// Cmds
+// Return a copy of the last ingestion error since the start of the server; ok == false if none
+func LastIngestionError () (e IngestionError, ok bool) {
+	ingMut.Lock()
+	defer ingMut.Unlock()
+	ok = lastIngErr != nil
+	if ok {
+		e = *lastIngErr
+	}
+	return
+} //LastIngestionError
+
+// Updt
+// Parse the lists of the block b and check them against dBase, before anything of b is written; with the quarantine policy, the faulty entries are dropped and recorded in e, and l holds the others; otherwise, e is the first fault met and l is nil; e is nil if there is no fault
+func checkBlock (b *SourceBlock, src BlockSource, quarantine bool) (l *blockLists, e *IngestionError) {
+	var skipped []string
+	fField, fMsg := "", ""
+	
+	// Record the fault err of the entry s of the list field
+	fault := func (field, s string, err error) {
+		if fField == "" {
+			fField = field
+			fMsg = s + ": " + err.Error()
+		}
+		skipped = append(skipped, field + ": " + s + ": " + err.Error())
+	} //fault
+	
+	// Entries of the JSON list ss of the list field
+	entries := func (field, ss string) []string {
+		var es []string
+		if err := J.Unmarshal([]byte(ss), &es); err != nil {
+			fault(field, ss, err)
+			return nil
+		}
+		return es
+	} //entries
+	
+	// Number of the block referenced by s, "number-hash" or "number", which must be b or a block already read if known
+	blockRef := func (s string, known bool) (int32, error) {
+		if k := strings.IndexByte(s, '-'); k >= 0 {
+			s = s[:k]
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, err
+		}
+		if known && n != b.Number {
+			if _, _, ok := TimeOf(int32(n)); !ok || n > b.Number {
+				return 0, errors.New("unknown block " + s)
+			}
+		}
+		return int32(n), nil
+	} //blockRef
+	
+	// Fields of the entry s, which must have at least min of them and no empty one before the min-th
+	fields := func (s string, min int) ([]string, error) {
+		fs := strings.Split(s, ":")
+		if len(fs) < min {
+			return nil, errors.New("missing fields")
+		}
+		for _, f := range fs[:min] {
+			if f == "" {
+				return nil, errors.New("empty field")
+			}
+		}
+		return fs, nil
+	} //fields
+	
+	l = &blockLists{hashes: make(map[Pubkey] Hash)}
+	var (
+		joined = make(map[Pubkey] bool)
+		excluded = make(map[Pubkey] bool)
+		uids = make(map[string] bool)
+		hashes = make(map[Hash] bool)
+	)
+	
+	exists := func (p Pubkey) bool {
+		_, ok := IdPub(p)
+		return ok || joined[p]
+	} //exists
+	
+	member := func (p Pubkey) bool {
+		_, m, _, _, _, _, ok := IdPubComplete(p)
+		return (ok && m || joined[p]) && !excluded[p]
+	} //member
+	
+	uidUsed := func (uid string) bool {
+		_, ok := IdUid(uid)
+		return ok || uids[uid]
+	} //uidUsed
+	
+	hashUsed := func (h Hash) bool {
+		_, ok := IdHash(h)
+		return ok || hashes[h]
+	} //hashUsed
+	
+	// Parse the entry s, "pubkey:signature:application:...:uid", with uid if withUid; the application block must be known if known
+	membership := func (s string, withUid, known bool) (ms msEntry, err error) {
+		min := 3
+		if withUid {
+			min = 5
+		}
+		fs, err := fields(s, min)
+		if err != nil {
+			return
+		}
+		ms.pubkey = Pubkey(fs[0])
+		if ms.application, err = blockRef(fs[2], known); err != nil {
+			return
+		}
+		if withUid {
+			ms.uid = fs[len(fs) - 1]
+		}
+		return
+	} //membership
+	
+	//checkBlock
+	ingField = "joiners"
+	var js []msEntry
+	for _, s := range entries(ingField, b.Joiners) {
+		if ms, err := membership(s, true, true); err != nil {
+			fault(ingField, s, err)
+		} else {
+			js = append(js, ms)
+		}
+	}
+	if len(js) > 0 {
+		ps := make([]Pubkey, len(js))
+		for k, ms := range js {
+			ps[k] = ms.pubkey
+		}
+		hs := src.IdHashes(ps, b.Number) // All at once, as of this block
+		for _, ms := range js {
+			s := string(ms.pubkey) + ":" + ms.uid
+			h := hs[ms.pubkey]
+			uid, m, hash, _, _, _, ok := IdPubComplete(ms.pubkey)
+			switch {
+			case h == "":
+				fault(ingField, s, errors.New("no identity hash"))
+			case joined[ms.pubkey]:
+				fault(ingField, s, errors.New("joins twice"))
+			case ok && m:
+				fault(ingField, s, errors.New("already member"))
+			case ok && (uid != ms.uid || hash != h):
+				fault(ingField, s, errors.New("other identity with this pubkey"))
+			case !ok && uidUsed(ms.uid):
+				fault(ingField, s, errors.New("uid already used"))
+			case !ok && hashUsed(h):
+				fault(ingField, s, errors.New("identity hash already used"))
+			default:
+				joined[ms.pubkey] = true
+				uids[ms.uid] = true
+				hashes[h] = true
+				l.hashes[ms.pubkey] = h
+				l.joiners = append(l.joiners, ms)
+			}
+		}
+	}
+	
+	ingField = "actives"
+	for _, s := range entries(ingField, b.Actives) {
+		ms, err := membership(s, false, true)
+		if err == nil && !member(ms.pubkey) {
+			err = errors.New("not a member")
+		}
+		if err != nil {
+			fault(ingField, s, err)
+		} else {
+			l.actives = append(l.actives, ms)
+		}
+	}
+	
+	ingField = "leavers"
+	for _, s := range entries(ingField, b.Leavers) {
+		ms, err := membership(s, false, false)
+		if err == nil && !exists(ms.pubkey) {
+			err = errors.New("unknown identity")
+		}
+		if err != nil {
+			fault(ingField, s, err)
+		} else {
+			l.leavers = append(l.leavers, ms)
+		}
+	}
+	
+	ingField = "revoked"
+	for _, s := range entries(ingField, b.Revoked) {
+		fs, err := fields(s, 1)
+		if err == nil && !exists(Pubkey(fs[0])) {
+			err = errors.New("unknown identity")
+		}
+		if err != nil {
+			fault(ingField, s, err)
+		} else {
+			l.revoked = append(l.revoked, Pubkey(fs[0]))
+		}
+	}
+	
+	ingField = "excluded"
+	for _, s := range entries(ingField, b.Excluded) {
+		p := Pubkey(s)
+		if !member(p) {
+			fault(ingField, s, errors.New("not a member"))
+		} else {
+			excluded[p] = true
+			l.excluded = append(l.excluded, p)
+		}
+	}
+	
+	ingField = "certifications"
+	for _, s := range entries(ingField, b.Certifications) {
+		fs, err := fields(s, 3)
+		var c certEntry
+		if err == nil {
+			c.from = Pubkey(fs[0]); c.to = Pubkey(fs[1])
+			c.block, err = blockRef(fs[2], true)
+		}
+		if err == nil && !exists(c.from) {
+			err = errors.New("unknown certifier")
+		}
+		if err == nil && !exists(c.to) {
+			err = errors.New("unknown certified identity")
+		}
+		if err != nil {
+			fault(ingField, s, err)
+		} else {
+			l.certs = append(l.certs, c)
+		}
+	}
+	
+	if fField != "" {
+		e = &IngestionError{Block: b.Number, Field: fField, Msg: fMsg, Date: time.Now().Unix(), Quarantined: quarantine}
+		if quarantine {
+			e.Skipped = skipped
+		} else {
+			l = nil
+		}
+	}
+	return
+} //checkBlock
@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+
+	F	"path/filepath"
+	J	"encoding/json"
+	BA	"duniter/basic"
+	M	"util/misc"
+		"os"
+		"strings"
+		"testing"
+
+)
+
+// Is dBase sound?
+func sound () bool {
+	c := new(checker)
+	c.run()
+	return len(c.msgs) == 0
+} //sound
+
+// Write into dir the blocks of the fixture basic, with the block 2 modified by edit
+func writeFaulty (t *testing.T, dir string, edit func (b map[string] interface{})) {
+	buf, err := os.ReadFile(F.Join(fixture("basic"), "blocks.jsonl")); M.Assert(err == nil, err, 100)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	var b map[string] interface{}
+	err = J.Unmarshal([]byte(lines[2]), &b); M.Assert(err == nil, err, 101)
+	edit(b)
+	bb, err := J.Marshal(b); M.Assert(err == nil, err, 102)
+	lines[2] = string(bb)
+	err = os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(strings.Join(lines, "\n") + "\n"), 0644); M.Assert(err == nil, err, 103)
+} //writeFaulty
+
+// Certification from an unknown identity, added last in the block 2
+const unknownCert = "3LJRrLQCio4GL7Xd48ydnYuuaeWAgqX4qXYFbXDTJpAa:2sZF6j2PkxBDNAqUde7Dgo5x3crkerZpQ4rBqqJGn8QT:1:c2lnbmF0dXJl"
+
+func addUnknownCert (b map[string] interface{}) {
+	b["certifications"] = append(b["certifications"].([]interface{}), unknownCert)
+} //addUnknownCert
+
+// Make the membership application of dave, the joiner of the block 2, reference an unknown block
+func badApplication (b map[string] interface{}) {
+	js := b["joiners"].([]interface{})
+	js[0] = strings.Replace(js[0].(string), ":1-", ":99-", 1)
+} //badApplication
+
+// Set the ingestion policy to p for the duration of the test t
+func setPolicy (t *testing.T, p string) {
+	old := BA.IngestionPolicy
+	BA.IngestionPolicy = p
+	t.Cleanup(func () {BA.IngestionPolicy = old})
+} //setPolicy
+
+func TestHaltPolicy (t *testing.T) {
+	setPolicy(t, HaltPolicy)
+	dir := t.TempDir()
+	writeFaulty(t, dir, addUnknownCert)
+	newBase()
+	updateFrom(NewSource(dir))
+	e, ok := LastIngestionError()
+	M.Want(ok && e.Block == 2 && e.Field == "certifications" && !e.Quarantined && len(e.Skipped) == 0, t)
+	M.Want(strings.Contains(e.Msg, "unknown certifier"), t)
+	// Nothing of the block 2 was written, not even its joiner, nor its times
+	M.Want(LastBlock() == 1 && IdLen() == 3, t)
+	_, ok = IdUid("dave")
+	M.Want(!ok, t)
+	_, _, ok = Cert(alice, dave)
+	M.Want(!ok, t)
+	_, _, ok = TimeOf(2)
+	M.Want(!ok, t)
+	M.Want(sound(), t)
+
+	updateFrom(NewSource(dir)) // Stops again before the block 2
+	M.Want(LastBlock() == 1 && IdLen() == 3 && sound(), t)
+
+	updateFrom(NewSource(fixture("basic"))) // The block 2 has been corrected
+	M.Want(LastBlock() == 3 && IdLen() == 4 && sound(), t)
+	_, _, ok = Cert(alice, dave)
+	M.Want(ok, t)
+}
+
+func TestQuarantinePolicy (t *testing.T) {
+	setPolicy(t, QuarantinePolicy)
+
+	// Only the faulty certification is skipped
+	dir := t.TempDir()
+	writeFaulty(t, dir, addUnknownCert)
+	newBase()
+	updateFrom(NewSource(dir))
+	e, ok := LastIngestionError()
+	M.Want(ok && e.Block == 2 && e.Field == "certifications" && e.Quarantined, t)
+	M.Want(len(e.Skipped) == 1 && strings.HasPrefix(e.Skipped[0], "certifications: " + unknownCert + ": "), t)
+	M.Want(LastBlock() == 3 && IdLen() == 4 && sound(), t)
+	for _, p := range []Pubkey{alice, bob, carol} {
+		_, _, ok = Cert(p, dave)
+		M.Want(ok, t)
+	}
+	_, _, ok = Cert("3LJRrLQCio4GL7Xd48ydnYuuaeWAgqX4qXYFbXDTJpAa", dave)
+	M.Want(!ok, t)
+
+	// The faulty joiner is skipped, and the certifications of dave with it
+	writeFaulty(t, dir, badApplication)
+	newBase()
+	updateFrom(NewSource(dir))
+	e, ok = LastIngestionError()
+	M.Want(ok && e.Block == 2 && e.Field == "joiners" && e.Quarantined && strings.Contains(e.Msg, "unknown block 99"), t)
+	M.Want(len(e.Skipped) == 4 && strings.HasPrefix(e.Skipped[0], "joiners: "), t)
+	for _, s := range e.Skipped[1:] {
+		M.Want(strings.HasPrefix(s, "certifications: ") && strings.HasSuffix(s, "unknown certified identity"), t)
+	}
+	M.Want(LastBlock() == 3 && IdLen() == 3 && sound(), t)
+	_, ok = IdUid("dave")
+	M.Want(!ok, t)
+	_, _, ok = TimeOf(2)
+	M.Want(ok, t)
+	_, _, _, _, app, _, ok := IdPubComplete(alice)
+	M.Want(ok && app == 2, t) // The block 3 was read
+}
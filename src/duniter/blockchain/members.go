@@ -0,0 +1,279 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Incremental maintenance of members, of their links and of sentriesS, from the pubkeys concerned by the changes of the last updates, and selective invalidation of poST
+
+import (
+	
+	A	"util/avl"
+	M	"util/misc"
+	U	"util/sets2"
+		"sort"
+
+)
+
+var (
+	
+	// Sentry threshold used for sentriesS; Cmds
+	sentriesThreshold int
+	
+	// Number of updates which changed the web of trust; Cmds
+	wotVersion int
+
+)
+
+// Cmds
+// Is the identity pubkey a sentry for the threshold n?
+func isSentry (pubkey Pubkey, n int) bool {
+	var pos CertPos
+	_, member := IdPubM(pubkey)
+	return n > 0 && member && CertFrom(pubkey, &pos) && pos.CertPosLen() >= n && CertTo(pubkey, &pos) && pos.CertPosLen() >= n
+} //isSentry
+
+// Cmds
+// Return the set of sentries for the threshold n; members must be up to date
+func fillSentries (n int) U.Set {
+	s := U.NewSet()
+	if n == 0 {
+		return s
+	}
+	var pst *Position
+	p, ok := IdNextPubkeyM(true, &pst)
+	for ok {
+		if isSentry(p, n) {
+			e, b := findMemberNum(p); M.Assert(b, 100)
+			s.Incl(e)
+		}
+		p, ok = IdNextPubkeyM(false, &pst)
+	}
+	return s
+} //fillSentries
+
+// Cmds
+// Return the set s, whose elements are numbers of members, with the numbers nums (-1 if removed); lost == true if an element was removed
+func renumberSet (s U.Set, nums []int) (t U.Set, lost bool) {
+	t = U.NewSet()
+	sI := s.Attach()
+	e, ok := sI.FirstE()
+	for ok {
+		if nums[e] >= 0 {
+			t.Incl(nums[e])
+		} else {
+			lost = true
+		}
+		e, ok = sI.NextE()
+	}
+	return
+} //renumberSet
+
+// Cmds
+// Insert the identities added into members and remove the ones of removed, renumber links and sentriesS accordingly, and include in dirty the members whose links changed; return true if a sentry was removed
+func renumberMembers (added PubkeysT, removed map[Pubkey]bool, dirty U.Set) (sentryLost bool) {
+	sort.Slice(added, func (i, j int) bool {return added[i] < added[j]})
+	old := members.m[:members.len]
+	m := make(membersT, 0, len(old) + len(added) + 1)
+	nums := make([]int, len(old)) // Old number -> new number, or -1 if removed
+	i, j := 0, 0
+	for i < len(old) || j < len(added) {
+		if j == len(added) || i < len(old) && old[i].p < added[j] {
+			if removed[old[i].p] {
+				nums[i] = -1
+			} else {
+				nums[i] = len(m)
+				m = append(m, old[i])
+			}
+			i++
+		} else {
+			M.Assert(i == len(old) || old[i].p != added[j], added[j], 100)
+			m = append(m, member{p: added[j], links: U.NewSet()})
+			j++
+		}
+	}
+	for k := range m {
+		var lost bool
+		m[k].links, lost = renumberSet(m[k].links, nums)
+		if lost {
+			dirty.Incl(k)
+		}
+	}
+	sentriesS, sentryLost = renumberSet(sentriesS, nums)
+	members.len = len(m)
+	members.m = m
+	
+	// Links of the added identities' certifications, the ones to them being computed by updateSentries
+	var pos CertPos
+	for _, p := range added {
+		a, b := findMemberNum(p); M.Assert(b, p, 101)
+		if CertFrom(p, &pos) {
+			_, to, ok := pos.CertNextPos()
+			for ok {
+				e, b := findMemberNum(to); M.Assert(b, to, 102)
+				members.m[e].links.Incl(a)
+				dirty.Incl(e)
+				_, to, ok = pos.CertNextPos()
+			}
+		}
+	}
+	return
+} //renumberMembers
+
+// Cmds
+// Remove from poST the distances which may have changed, knowing that the pubkeys of ps are concerned by changes and that the links of the members of dirty changed
+func prunePoST (ps map[Pubkey]bool, dirty U.Set) {
+	if len(ps) == 0 && dirty.IsEmpty() {
+		return
+	}
+	
+	// Number of steps from each member to the nearest member of dirty, following the links, up to pars.StepMax - 2 steps
+	maxSteps := int(pars.StepMax) - 2
+	steps := make(map[int]int)
+	frontier := make([]int, 0, dirty.NbElems())
+	dI := dirty.Attach()
+	e, ok := dI.FirstE()
+	for ok {
+		steps[e] = 0
+		frontier = append(frontier, e)
+		e, ok = dI.NextE()
+	}
+	if maxSteps > 0 && len(frontier) > 0 {
+		certified := make([][]int, members.len) // Members whose links contain each member
+		for i := 0; i < members.len; i++ {
+			lI := members.m[i].links.Attach()
+			e, ok := lI.FirstE()
+			for ok {
+				certified[e] = append(certified[e], i)
+				e, ok = lI.NextE()
+			}
+		}
+		for s := 1; s <= maxSteps && len(frontier) > 0; s++ {
+			newFrontier := make([]int, 0)
+			for _, e := range frontier {
+				for _, i := range certified[e] {
+					if _, ok := steps[i]; !ok {
+						steps[i] = s
+						newFrontier = append(newFrontier, i)
+					}
+				}
+			}
+			frontier = newFrontier
+		}
+	}
+	
+	// percentOfSentries follows the links of the members reached in n - 2 steps at most, with n = pars.StepMax for distances, and n = pars.StepMax - 1 for qualities
+	t := A.New()
+	for el := poST.Next(nil); el != nil; el = poST.Next(el) {
+		poSE := el.Val().(*poSET)
+		n := maxSteps
+		if !poSE.distOrQual {
+			n--
+		}
+		keep := true
+		for _, p := range poSE.pubkeys {
+			if ps[p] {
+				keep = false
+				break
+			}
+			if e, ok := findMemberNum(p); ok {
+				if s, ok := steps[e]; ok && s <= n {
+					keep = false
+					break
+				}
+			}
+		}
+		if keep {
+			t.Append(poSE)
+		}
+	}
+	poST = t
+} //prunePoST
+
+// Cmds
+// Update members, their links and sentriesS for the changes concerning the pubkeys of ps, and forget the distances of poST they may modify
+func updateSentries (ps map[Pubkey]bool) {
+	dirty := U.NewSet() // Members whose links changed
+	sentriesChanged := false
+	
+	// Identities added, or removed by a fork
+	added := make(PubkeysT, 0)
+	removed := make(map[Pubkey]bool)
+	for p := range ps {
+		_, isId := IdPub(p)
+		_, wasId := findMemberNum(p)
+		if isId && !wasId {
+			added = append(added, p)
+		} else if !isId && wasId {
+			removed[p] = true
+		}
+	}
+	if len(added) > 0 || len(removed) > 0 {
+		sentriesChanged = renumberMembers(added, removed, dirty)
+	}
+	
+	// Links of the identities concerned
+	var pos CertPos
+	for p := range ps {
+		e, ok := findMemberNum(p)
+		if !ok {
+			continue
+		}
+		links := U.NewSet()
+		if CertTo(p, &pos) {
+			from, _, ok := pos.CertNextPos()
+			for ok {
+				f, b := findMemberNum(from); M.Assert(b, from, 100)
+				links.Incl(f)
+				from, _, ok = pos.CertNextPos()
+			}
+		}
+		if !links.Equal(members.m[e].links) {
+			members.m[e].links = links
+			dirty.Incl(e)
+		}
+	}
+	
+	// Sentries
+	if n := SentryThreshold(); n != sentriesThreshold {
+		s := fillSentries(n)
+		sentriesChanged = sentriesChanged || !s.Equal(sentriesS)
+		sentriesS = s
+		sentriesThreshold = n
+	} else {
+		for p := range ps {
+			e, ok := findMemberNum(p)
+			if !ok {
+				continue
+			}
+			if b := isSentry(p, n); b != sentriesS.In(e) {
+				if b {
+					sentriesS.Incl(e)
+				} else {
+					sentriesS.Excl(e)
+				}
+				sentriesChanged = true
+			}
+		}
+	}
+	
+	if sentriesChanged { // Every distance may have changed
+		poST = A.New()
+	} else {
+		prunePoST(ps, dirty)
+	}
+} //updateSentries
+
+// Cmds
+// Return a number which changes whenever identities, memberships or certifications of the blockchain may have changed, so that the results computed from them can be reused as long as it's the same
+func WotVersion () int {
+	return wotVersion
+} //WotVersion
@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+
+	F	"path/filepath"
+	M	"util/misc"
+		"math"
+		"os"
+		"strings"
+		"testing"
+
+)
+
+const (
+
+	// Pubkeys of the fixture expiry
+	eAlice = "aTtLQKCrnnbjJ82GRupTHxZtMhwkoS9c49usyDAynbct"
+	eBob = "E4vbLtyMtM4BT6mrLvHjSX5b6bhGFbHdBtK4oyYeSuYF"
+	eCarol = "bHJPPbeuTS1aqN6qpvs3hoxKMM8N9bvGYuKV8HG5kTLk"
+	eDave = "voURJ6LSiEyM13xde4PkiYSUgHmLVzrBqTEDEwQpynDF"
+	eFrank = "H4yHxnQvURn3bcar12hbqMmeMtsjyCsPefjMfGuJdVV8"
+
+	// Newcomer of the block 101
+	eGina = "GinaGinaGinaGinaGinaGinaGinaGinaGinaGinaGina"
+
+	block100 = "100-00000000000000000000000000000000000000000000000000000000E0000064"
+
+	// gina joins, certified by alice, bob and carol
+	joinBlock = `{"number": 101, "hash": "00000000000000000000000000000000000000000000000000000000E0000065", "medianTime": 1549467427, "time": 1549467432, "joiners": ["` + eGina + `:sig:` + block100 + `:` + block100 + `:gina"], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": ["` + eAlice + `:` + eGina + `:100:sig", "` + eBob + `:` + eGina + `:100:sig", "` + eCarol + `:` + eGina + `:100:sig"], "identities": [{"pub": "` + eGina + `", "hash": "0000000000000000000000000000000000000000000000000000000000006714"}]}`
+
+	// frank is excluded, and gina certifies alice and bob, which makes her a sentry
+	exclusionBlock = `{"number": 102, "hash": "00000000000000000000000000000000000000000000000000000000E0000066", "medianTime": 1549467727, "time": 1549467732, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": ["` + eFrank + `"], "certifications": ["` + eGina + `:` + eAlice + `:101:sig", "` + eGina + `:` + eBob + `:101:sig"], "identities": []}`
+
+	// Block 102 of a fork, where dave is excluded instead
+	forkBlock = `{"number": 102, "hash": "00000000000000000000000000000000000000000000000000000000F0000066", "medianTime": 1549467727, "time": 1549467732, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": ["` + eDave + `"], "certifications": [], "identities": []}`
+
+)
+
+// Source of the blocks of the fixture expiry followed by blocks
+func expiryWith (t *testing.T, blocks ... string) BlockSource {
+	buf, err := os.ReadFile(F.Join(fixture("expiry"), "blocks.jsonl")); M.Assert(err == nil, err, 100)
+	dir := t.TempDir()
+	err = os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(strings.TrimSpace(string(buf)) + "\n" + strings.Join(blocks, "\n") + "\n"), 0644); M.Assert(err == nil, err, 101)
+	return NewSource(dir)
+} //expiryWith
+
+// Put into poST the distances and the qualities of all the identities and of gina, alone and by pairs
+func fillPoST () {
+	ps := PubkeysT{eGina}
+	var pst *Position
+	p, ok := IdNextPubkey(true, &pst)
+	for ok {
+		if p != eGina {
+			ps = append(ps, p)
+		}
+		p, ok = IdNextPubkey(false, &pst)
+	}
+	for i, p := range ps {
+		Distance(PubkeysT{p}); Quality(PubkeysT{p})
+		for _, q := range ps[i + 1:] {
+			Distance(PubkeysT{p, q}); Quality(PubkeysT{p, q})
+		}
+	}
+} //fillPoST
+
+// Read src, update the sentries incrementally and check that members, sentriesS and the distances kept in poST are the same as after buildSentries; return the number of distances kept
+func checkSentries (t *testing.T, step string, src BlockSource) (kept int) {
+	fillPoST()
+	updateFrom(src)
+	M.Want(lastIngErr == nil, t)
+	ps, all := takeTouched()
+	M.Want(!all, t)
+	updateSentries(ps)
+	m := members.m[:members.len]
+	s := sentriesS
+	n := sentriesThreshold
+	dists := make([]*poSET, 0)
+	for el := poST.Next(nil); el != nil; el = poST.Next(el) {
+		dists = append(dists, el.Val().(*poSET))
+	}
+	buildSentries()
+	ok := members.len == len(m) && s.Equal(sentriesS) && n == sentriesThreshold
+	for i := 0; ok && i < len(m); i++ {
+		ok = m[i].p == members.m[i].p && m[i].links.Equal(members.m[i].links)
+	}
+	if !ok {
+		t.Error(step, ": members or sentries differ")
+	}
+	for _, d := range dists {
+		dist := percentOfSentries(append(PubkeysT(nil), d.pubkeys...), d.distOrQual)
+		if dist != d.dist && !(math.IsNaN(dist) && math.IsNaN(d.dist)) {
+			t.Error(step, ": distance of", d.pubkeys, "kept at", d.dist, "instead of", dist)
+		}
+	}
+	return len(dists)
+} //checkSentries
+
+func TestIncrementalSentries (t *testing.T) {
+	readSource(t, NewSource(fixture("expiry")))
+	calculateSentries()
+	checkSentries(t, "join", expiryWith(t, joinBlock))
+	_, ok := findMemberNum(eGina)
+	M.Want(ok, t)
+	checkSentries(t, "exclusion", expiryWith(t, joinBlock, exclusionBlock))
+	e, ok := findMemberNum(eGina)
+	M.Want(ok && sentriesS.In(e), t)
+	checkSentries(t, "fork", expiryWith(t, joinBlock, forkBlock))
+	fillPoST()
+	full := poST.NumberOfElems()
+	M.Want(checkSentries(t, "re-read", expiryWith(t, joinBlock, forkBlock)) == full, t) // Nothing changed
+	checkSentries(t, "unwind", NewSource(fixture("expiry")))
+	_, ok = findMemberNum(eGina)
+	M.Want(!ok, t)
+}
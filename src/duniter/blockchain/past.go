@@ -0,0 +1,271 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// The web of trust at a past block, rebuilt from the histories of memberships (joinAndLeaveT) and of certifications (identity.certifiersIO & identity.certifiedIO)
+
+import (
+	
+	B	"util/gbTree"
+	BA	"duniter/basic"
+	M	"util/misc"
+	S	"util/sort"
+	U	"util/sets2"
+		"sync"
+
+)
+
+const (
+	
+	// Number of past webs of trust kept in memory; each one holds a graph of the identities existing at its block
+	pastCacheSize = 8
+
+)
+
+type (
+	
+	// Certification valid at a past block; In is the block where its uninterrupted validity period began and Out the block where it ended (HasNotLeaved if it's still valid)
+	PastCert struct {
+		P Pubkey // Certifier or certified identity
+		In,
+		Out int32
+	}
+	
+	PastCerts []PastCert
+	
+	pastCertsSort struct {
+		c PastCerts
+	}
+	
+	// The web of trust at a past block
+	Past struct {
+		block int32
+		mut sync.Mutex
+		built bool // Are the fields below computed?
+		nums map[Pubkey]int // Pubkey -> index in ids
+		ids membersT // Identities existing at block, sorted by pubkeys; links are sets of certifiers
+		membersS, // Members at block
+		sentriesS U.Set // Sentries at block
+		threshold int
+	}
+
+)
+
+var (
+	
+	pastMut = new(sync.Mutex)
+	pastCache = make([]*Past, 0, pastCacheSize) // Most recently used last
+
+)
+
+func (s *pastCertsSort) Less (i, j int) bool {
+	return s.c[i].P < s.c[j].P
+} //Less
+
+func (s *pastCertsSort) Swap (i, j int) {
+	s.c[i], s.c[j] = s.c[j], s.c[i]
+} //Swap
+
+// Cmds
+// Forget all past webs of trust, since the blocks of the secure gap may have changed
+func resetPast () {
+	pastMut.Lock()
+	pastCache = pastCache[:0]
+	pastMut.Unlock()
+} //resetPast
+
+// Return the web of trust at block bnb; ok == false if bnb is not in the blockchain
+func PastAt (bnb int32) (p *Past, ok bool) {
+	ok = bnb >= 0 && bnb <= LastBlock()
+	if !ok {
+		return
+	}
+	pastMut.Lock()
+	defer pastMut.Unlock()
+	for i, q := range pastCache {
+		if q.block == bnb {
+			copy(pastCache[i:], pastCache[i + 1:])
+			pastCache[len(pastCache) - 1] = q
+			p = q
+			return
+		}
+	}
+	p = &Past{block: bnb, built: false}
+	if len(pastCache) == pastCacheSize {
+		copy(pastCache, pastCache[1:])
+		pastCache = pastCache[:len(pastCache) - 1]
+	}
+	pastCache = append(pastCache, p)
+	return
+} //PastAt
+
+// Block number of the web of trust
+func (p *Past) Block () int32 {
+	return p.block
+} //Block
+
+// Pubkey -> identity existed at p.Block(), was a member, is now revoked; ok == false if it didn't exist then
+// The revocation block is not kept: a revoked identity is considered revoked from its last exit out of the web of trust
+func (p *Past) IdPub (pubkey Pubkey) (member, revoked, ok bool) {
+	_, _, _, block_number, _, exp, b := IdPubComplete(pubkey)
+	ok = b && block_number <= p.block
+	if !ok {
+		return
+	}
+	list, b := JLPub(pubkey); M.Assert(b, pubkey, 100)
+	var lastExit int32 = -1
+	joining, leaving, b := JLPubLNext(&list)
+	for b && !member {
+		if joining <= p.block {
+			if leaving == HasNotLeaved || leaving > p.block {
+				member = true
+			} else if leaving > lastExit {
+				lastExit = leaving
+			}
+		}
+		joining, leaving, b = JLPubLNext(&list)
+	}
+	revoked = !member && exp == BA.Revoked && lastExit >= 0
+	return
+} //IdPub
+
+// Certifications of the index ioRef (identity.certifiersIO or identity.certifiedIO) valid at p.Block(), sorted by pubkeys
+func (p *Past) certs (ioRef B.FilePos) PastCerts {
+	cs := make(PastCerts, 0)
+	if ioRef == B.BNil {
+		return cs
+	}
+	pst := database.OpenIndex(ioRef, uidKeyMan, uidKeyFac).NewReader()
+	pst.Next()
+	for pst.PosSet() {
+		cioR := pst.ReadValue()
+		for cioR != B.BNil {
+			cio := certInOutMan.ReadData(cioR).(*certInOut)
+			if cio.inBlock <= p.block && (cio.outBlock == HasNotLeaved || cio.outBlock > p.block) {
+				pub, b := IdUid(pst.CurrentKey().(*B.String).C); M.Assert(b, 100)
+				cs = append(cs, PastCert{P: pub, In: cio.inBlock, Out: cio.outBlock})
+				break
+			}
+			cioR = cio.next
+		}
+		pst.Next()
+	}
+	ts := S.TS{Sorter: &pastCertsSort{c: cs}}
+	ts.QuickSort(0, len(cs) - 1)
+	return cs
+} //certs
+
+// Certifications received by pubkey and valid at p.Block(), sorted by certifiers' pubkeys
+func (p *Past) Certifiers (pubkey Pubkey) PastCerts {
+	pst := idPubT.NewReader()
+	if !pst.Search(&pubKey{ref: pubkey}) {
+		return make(PastCerts, 0)
+	}
+	return p.certs(idMan.ReadData(pst.ReadValue()).(*identity).certifiersIO)
+} //Certifiers
+
+// Certifications sent by pubkey and valid at p.Block(), sorted by certified pubkeys
+func (p *Past) Certified (pubkey Pubkey) PastCerts {
+	pst := idPubT.NewReader()
+	if !pst.Search(&pubKey{ref: pubkey}) {
+		return make(PastCerts, 0)
+	}
+	return p.certs(idMan.ReadData(pst.ReadValue()).(*identity).certifiedIO)
+} //Certified
+
+// Build the graph of certifications, the set of members and the set of sentries at p.Block(), once
+// Its cost grows with the size of dBase, not with the age of p.Block(): the membership history of every identity of dBase, and then the certification history of every identity existing at p.Block(), are read; so, the first query at a new block is slow, and only the pastCacheSize most recently used webs are kept
+func (p *Past) build () {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.built {
+		return
+	}
+	p.nums = make(map[Pubkey]int)
+	p.ids = make(membersT, 0)
+	p.membersS = U.NewSet()
+	var pst *Position
+	pubkey, ok := IdNextPubkey(true, &pst)
+	for ok {
+		if isMember, _, b := p.IdPub(pubkey); b {
+			n := len(p.ids)
+			p.nums[pubkey] = n
+			p.ids = append(p.ids, member{p: pubkey, links: U.NewSet()})
+			if isMember {
+				p.membersS.Incl(n)
+			}
+		}
+		pubkey, ok = IdNextPubkey(false, &pst)
+	}
+	sent := make([]int, len(p.ids))
+	for i := range p.ids {
+		for _, c := range p.Certifiers(p.ids[i].p) {
+			n, b := p.nums[c.P]; M.Assert(b, c.P, 100)
+			p.ids[i].links.Incl(n)
+			sent[n]++
+		}
+	}
+	p.sentriesS = U.NewSet()
+	p.threshold = threshold(p.membersS.NbElems(), int(pars.StepMax))
+	if p.threshold > 0 {
+		for i := range p.ids {
+			if p.membersS.In(i) && p.ids[i].links.NbElems() >= p.threshold && sent[i] >= p.threshold {
+				p.sentriesS.Incl(i)
+			}
+		}
+	}
+	p.built = true
+} //build
+
+// Number of members at p.Block()
+func (p *Past) MembersNb () int {
+	p.build()
+	return p.membersS.NbElems()
+} //MembersNb
+
+// Minimum number of sent and received certifications of a sentry at p.Block()
+func (p *Past) SentryThreshold () int {
+	p.build()
+	return p.threshold
+} //SentryThreshold
+
+// Was pubkey a sentry at p.Block()?
+func (p *Past) IsSentry (pubkey Pubkey) bool {
+	p.build()
+	n, ok := p.nums[pubkey]
+	return ok && p.sentriesS.In(n)
+} //IsSentry
+
+// Sentries at p.Block(), sorted by pubkeys
+func (p *Past) Sentries () PubkeysT {
+	p.build()
+	sentries := make(PubkeysT, 0, p.sentriesS.NbElems())
+	for i := range p.ids {
+		if p.sentriesS.In(i) {
+			sentries = append(sentries, p.ids[i].p)
+		}
+	}
+	return sentries
+} //Sentries
+
+// Array of certifiers' pubkeys -> % of sentries reached at p.Block() in pars.stepMax - 1 steps
+func (p *Past) Distance (pubkeys PubkeysT) float64 {
+	p.build()
+	return sentriesReached(pubkeys, int(pars.StepMax), p.ids, func (pubkey Pubkey) (int, bool) {n, ok := p.nums[pubkey]; return n, ok}, p.sentriesS)
+} //Distance
+
+// Array of certifiers' pubkeys -> % of sentries reached at p.Block() in pars.stepMax - 2 steps
+func (p *Past) Quality (pubkeys PubkeysT) float64 {
+	p.build()
+	return sentriesReached(pubkeys, int(pars.StepMax) - 1, p.ids, func (pubkey Pubkey) (int, bool) {n, ok := p.nums[pubkey]; return n, ok}, p.sentriesS)
+} //Quality
@@ -0,0 +1,165 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Detection of the layout of the SQLite export of Duniter, and mapping of its columns to the form read by scanBlocksUpdt and by the sandbox
+
+/*
+Layouts of the export ("wotwizard-export.db"):
+
+Table block: number INTEGER, hash VARCHAR(64), parameters VARCHAR(255) (block 0 only), and
+	1.7: fork BOOLEAN; medianTime and time DATETIME; joiners, actives, leavers, revoked, excluded and certifications TEXT, never NULL
+	1.8+: fork BOOLEAN, absent when only the main chain is exported; medianTime and time INT (seconds); the six lists TEXT or JSON, NULL when empty
+	In both layouts, a list holds a JSON array of Duniter inline documents, e.g. ["pub:sig:12-HASH:12-HASH:uid"], whose strings may be escaped like any JSON string ("\u00e9" for "é"); Schema.list decodes it and writes the documents back in the bracketed form read by scanBlocksUpdt, ["doc1","doc2"], without escapes and spaces
+Table i_index: pub, hash and writtenOn, in both layouts
+Tables membership (idtyHash, membership, issuer, number, userid, expires_on, blockHash, blockNumber), idty (pubkey, uid, buid, expires_on, revocation_sig, hash) and cert (from, to, target, block_number, block_hash, expires_on): the sandbox, the same in both layouts; absent from some exports, whose sandbox is then read elsewhere
+*/
+
+import (
+	
+	C	"strconv"
+	J	"encoding/json"
+	M	"util/misc"
+	Q	"database/sql"
+		"strings"
+		"time"
+
+)
+
+const (
+	
+	// Versions of the export schema
+	
+	// Duniter 1.7: block times are DATETIME
+	Schema17 = "1.7"
+	// Duniter 1.8 and later: block times are integers (seconds); the fork column may be absent when only the main chain is exported
+	Schema18 = "1.8+"
+
+)
+
+type (
+	
+	// Layout of a SQLite export of Duniter
+	Schema struct {
+		Version string
+		fork, // block has a fork column
+		sandbox bool // membership, idty and cert tables are present
+	}
+	
+	// Columns of a table, with their declared types
+	columnsT map[string]string
+
+)
+
+// Return the columns of table in d, with their declared types in upper case; the map is empty if table doesn't exist
+func columns (d *Q.DB, table string) columnsT {
+	rows, err := d.Query("SELECT name, type FROM pragma_table_info(?)", table)
+	M.Assert(err == nil, err, 100)
+	defer rows.Close()
+	cols := make(columnsT)
+	for rows.Next() {
+		var name, typ string
+		err = rows.Scan(&name, &typ)
+		M.Assert(err == nil, err, 101)
+		cols[name] = strings.ToUpper(typ)
+	}
+	M.Assert(rows.Err() == nil, rows.Err(), 60)
+	return cols
+} //columns
+
+// Recognize the layout of the Duniter export d; halt if it's unknown
+func DetectSchema (d *Q.DB) *Schema {
+	
+	has := func (cols columnsT, names ... string) bool {
+		for _, n := range names {
+			if _, ok := cols[n]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	
+	bl := columns(d, "block")
+	M.Assert(has(bl, "number", "hash", "medianTime", "time", "parameters", "joiners", "actives", "leavers", "revoked", "excluded", "certifications"), "Unknown schema of the table block", 100)
+	M.Assert(has(columns(d, "i_index"), "pub", "hash", "writtenOn"), "Unknown schema of the table i_index", 101)
+	s := &Schema{fork: has(bl, "fork")}
+	if t := bl["medianTime"]; strings.Contains(t, "DATE") || strings.Contains(t, "TIME") {
+		s.Version = Schema17
+		M.Assert(s.fork, "Missing column block.fork", 102)
+	} else {
+		s.Version = Schema18
+	}
+	s.sandbox =
+		has(columns(d, "membership"), "idtyHash", "membership", "issuer", "number", "userid", "expires_on", "blockHash", "blockNumber") &&
+		has(columns(d, "idty"), "pubkey", "uid", "buid", "expires_on", "revocation_sig", "hash") &&
+		has(columns(d, "cert"), "from", "to", "target", "block_number", "block_hash", "expires_on")
+	return s
+} //DetectSchema
+
+// SQL expression which is true if the block aliased by t is a fork block
+func (s *Schema) Fork (t string) string {
+	if s.fork {
+		return t + ".fork"
+	}
+	return "0"
+} //Fork
+
+// Does the export contain the sandbox?
+func (s *Schema) HasSandbox () bool {
+	return s.sandbox
+} //HasSandbox
+
+// Convert the list column v of a block (joiners, actives, leavers, revoked, excluded or certifications) into its bracketed form, read by scanBlocksUpdt
+func (s *Schema) list (v Q.NullString) string {
+	if !v.Valid {
+		M.Assert(s.Version == Schema18, "NULL list in a Duniter " + s.Version + " export", 100)
+		return "[]"
+	}
+	var docs []string
+	err := J.Unmarshal([]byte(v.String), &docs); M.Assert(err == nil, err, 101)
+	return bracketed(docs)
+} //list
+
+// Bracketed form of the list of inline documents docs: ["doc1","doc2"], or [] if docs is empty; a document can't hold '"', which would end it
+func bracketed (docs []string) string {
+	if len(docs) == 0 {
+		return "[]"
+	}
+	for _, d := range docs {
+		M.Assert(!strings.ContainsRune(d, '"'), "Incorrect document:", d, 100)
+	}
+	return "[\"" + strings.Join(docs, "\",\"") + "\"]"
+} //bracketed
+
+// Convert a block time, read from a DATETIME or an integer column, into seconds
+func unixTime (v interface{}) int64 {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Unix()
+	case int64:
+		return t
+	case float64:
+		return int64(t)
+	case []byte:
+		return unixTime(string(t))
+	case string:
+		if n, err := C.ParseInt(t, 10, 64); err == nil {
+			return n
+		}
+		tt, err := time.Parse(time.RFC3339, t); M.Assert(err == nil, err, 100)
+		return tt.Unix()
+	default:
+		M.Halt(t, 101)
+		return 0
+	}
+} //unixTime
@@ -0,0 +1,80 @@
+package blockchain
+
+import (
+
+	F	"path/filepath"
+	M	"util/misc"
+	Q	"database/sql"
+		"strings"
+		"testing"
+
+)
+
+// Blocks of src, by their numbers
+func sourceBlocks (src BlockSource) []SourceBlock {
+	src.Open()
+	defer src.Close()
+	n, _ := src.LastNumber()
+	var bs []SourceBlock
+	src.Blocks(0, n, func (b *SourceBlock) {
+		bs = append(bs, *b)
+	})
+	return bs
+} //sourceBlocks
+
+func TestDetectSchema (t *testing.T) {
+	for _, x := range []struct {fixture, version string; fork bool} {{"sqlite17", Schema17, true}, {"sqlite18", Schema18, false}} {
+		d := OpenDuniterDB(F.Join(fixture(x.fixture), "wotwizard-export.db"))
+		s := d.Schema()
+		M.Want(s.Version == x.version && s.fork == x.fork && s.HasSandbox(), t)
+		d.Close()
+	}
+}
+
+func TestReadOnly (t *testing.T) {
+	d := OpenDuniterDB(F.Join(fixture("sqlite18"), "wotwizard-export.db"))
+	defer d.Close()
+	for _, x := range []struct {query string; args []interface{}} {{"CREATE TABLE intruder (x INTEGER)", nil}, {"DELETE FROM block", nil}, {"UPDATE block SET hash = ? WHERE number = ?", []interface{}{"0", 0}}} {
+		_, err := d.d.Exec(x.query, x.args...)
+		M.Want(err != nil && strings.Contains(err.Error(), "readonly"), t)
+	}
+	var n int
+	err := d.QueryRow("SELECT count(*) FROM block WHERE number >= ?", 0).Scan(&n)
+	M.Want(err == nil && n == 4, t)
+}
+
+func TestSqlBlocks (t *testing.T) {
+	want := sourceBlocks(NewSource(fixture("basic")))
+	M.Want(len(want) == 4, t)
+	for _, name := range []string{"sqlite17", "sqlite18"} {
+		src := NewSource(F.Join(fixture(name), "wotwizard-export.db"))
+		_, ok := src.(*sqlSource)
+		M.Want(ok, t)
+		bs := sourceBlocks(src)
+		M.Want(len(bs) == len(want), t)
+		for i := 0; i < len(bs) && i < len(want); i++ {
+			M.Want(bs[i] == want[i], t)
+		}
+		src.Open()
+		M.Want(src.Parameters() == "0.0488:86400:1000:432000:100:5259600:63115200:2:5259600:5259600:0.8:31557600:5:24:300:12:0.67:1488970800:1490094000:15778800", t)
+		m, ok := src.MedianTime(2)
+		M.Want(ok && m == 1488987727, t)
+		src.Close()
+	}
+}
+
+func TestList (t *testing.T) {
+	s17 := &Schema{Version: Schema17}
+	s18 := &Schema{Version: Schema18}
+	M.Want(s17.list(Q.NullString{String: "[]", Valid: true}) == "[]", t)
+	M.Want(s18.list(Q.NullString{}) == "[]", t)
+	M.Want(s18.list(Q.NullString{String: `["a:b:0-H:0-H:renée", "c:d:0-H:0-H:eve"]`, Valid: true}) == `["a:b:0-H:0-H:renée","c:d:0-H:0-H:eve"]`, t)
+	M.Want(jsonList(nil) == "[]" && jsonList([]byte("null")) == "[]", t)
+	M.Want(jsonList([]byte(`[ "p1" ,"p2" ]`)) == `["p1","p2"]`, t)
+	func () {
+		defer func () {
+			M.Want(recover() != nil, t)
+		}()
+		s17.list(Q.NullString{})
+	}()
+}
@@ -0,0 +1,521 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Portable snapshots of the WotWizard database: export of dBase, dPars and sBase into a versioned JSON file, independent of the page layout of dBase, and import of such a file into a new dBase
+// Only primary data are exported; the indexes are rebuilt at import
+
+import (
+	
+	B	"util/gbTree"
+	J	"encoding/json"
+	M	"util/misc"
+		"bufio"
+		"os"
+
+)
+
+const (
+	
+	// Identification of snapshot files
+	snapshotFormat = "WotWizard snapshot"
+	// Version of the layout of snapshots; snapshots with a later version can't be imported
+	snapshotVersion = 2
+	
+	// Absent certification, in snapUndo
+	noCert = -1
+
+)
+
+type (
+	
+	// Portable image of dBase, dPars and sBase; blocks are designated by their numbers, identities by their pubkeys, and certification records by their ranks in Certs
+	snapshot struct {
+		Format string // snapshotFormat
+		Version int // snapshotVersion
+		LastBlock int32
+		Members int
+		Parameters, // Content of dPars; null if absent
+		Sandbox J.RawMessage // Content of sBase; null if absent
+		Blocks []snapBlock // By increasing numbers
+		Identities []snapIdentity // By increasing pubkeys
+		Certs []snapCert // Active certifications first, by increasing certifiers and certified pubkeys, then the ones kept by Undo
+		Undo []snapUndo // Undo journal, the most recent operation first
+		Changes []BlockChanges // Changelog, by increasing blocks; since version 2
+	}
+	
+	snapBlock struct {
+		Number int32
+		MedianTime,
+		Time int64
+		Hash Hash
+	}
+	
+	// Period of membership or of certification; Out is HasNotLeaved if the period is not over
+	snapPeriod struct {
+		In,
+		Out int32
+	}
+	
+	// Certifier or certified identity, with its certification periods, the most recent first
+	snapLink struct {
+		Uid string
+		Periods []snapPeriod
+	}
+	
+	snapIdentity struct {
+		Pubkey Pubkey
+		Uid string
+		Member bool
+		Hash Hash
+		Block, // Where the identity is written
+		Application int32 // Block of last membership application
+		ExpiresOn int64
+		Certifiers, // Uids; null if the index doesn't exist
+		Certified []string
+		CertifiersIO, // null if the index doesn't exist
+		CertifiedIO []snapLink
+		History []snapPeriod // Membership periods, the most recent first; null if never member
+	}
+	
+	snapCert struct {
+		From,
+		To Pubkey
+		Block int32 // Where the certification is written
+		ExpiresOn int64
+		Active bool // Present in certFromT, certToT and certTimeT
+	}
+	
+	// Element of undoListT
+	snapUndo struct {
+		Op string // One of undoOps
+		Block int32 // "time"
+		Pubkey, // Operations on identities, "remCertifiers" and "remCertified"
+		Other Pubkey // "remCertifiers" and "remCertified": the certifier, or certified, identity to put back
+		Cert, // "certAdd" and "certRemove"
+		OldCert int // "certAdd": the replaced certification, or noCert
+		Aux, // "time": 1 if the median time was new; "active": previous expiration date
+		Aux2 int64 // "active": previous application block
+	}
+
+)
+
+// Names of the types of elements of undoListT in snapshots
+var undoOps = [...]string{
+	timeList: "time",
+	idAddList: "idAdd",
+	joinList: "join",
+	activeList: "active",
+	leaveList: "leave",
+	idAddTimeList: "idAddTime",
+	idRemoveTimeList: "idRemoveTime",
+	certAddList: "certAdd",
+	certRemoveList: "certRemove",
+	remCertifiers: "remCertifiers",
+	remCertified: "remCertified",
+}
+
+// Content of the file path, or nil if it doesn't exist
+func readRaw (path string) J.RawMessage {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	M.Assert(err == nil, err, 100)
+	return b
+} //readRaw
+
+// Uids of the sub-index ind of an identity; nil if ind doesn't exist
+func uidsOf (ind B.FilePos) []string {
+	if ind == B.BNil {
+		return nil
+	}
+	uids := make([]string, 0)
+	ir := database.OpenIndex(ind, uidKeyMan, uidKeyFac).NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		uids = append(uids, ir.CurrentKey().(*B.String).C)
+		ir.Next()
+	}
+	return uids
+} //uidsOf
+
+// Contents of the sub-index ind of an identity, with stacks of certInOut; nil if ind doesn't exist
+func linksOf (ind B.FilePos) []snapLink {
+	if ind == B.BNil {
+		return nil
+	}
+	links := make([]snapLink, 0)
+	ir := database.OpenIndex(ind, uidKeyMan, uidKeyFac).NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		l := snapLink{Uid: ir.CurrentKey().(*B.String).C, Periods: make([]snapPeriod, 0)}
+		for ref := ir.ReadValue(); ref != B.BNil; {
+			cio := certInOutMan.ReadData(ref).(*certInOut)
+			l.Periods = append(l.Periods, snapPeriod{In: cio.inBlock, Out: cio.outBlock})
+			ref = cio.next
+		}
+		links = append(links, l)
+		ir.Next()
+	}
+	return links
+} //linksOf
+
+// Build a sub-index of an identity with uids; BNil if uids is nil
+func uidIndex (uids []string) B.FilePos {
+	if uids == nil {
+		return B.BNil
+	}
+	ind := database.CreateIndex(0)
+	iw := database.OpenIndex(ind, uidKeyMan, uidKeyFac).Writer()
+	for _, u := range uids {
+		b := iw.SearchIns(&B.String{C: u}); M.Assert(!b, u, 100)
+	}
+	return ind
+} //uidIndex
+
+// Build a sub-index of an identity with links; BNil if links is nil
+func linkIndex (links []snapLink) B.FilePos {
+	if links == nil {
+		return B.BNil
+	}
+	ind := database.CreateIndex(0)
+	iw := database.OpenIndex(ind, uidKeyMan, uidKeyFac).Writer()
+	for _, l := range links {
+		ref := B.BNil
+		for i := len(l.Periods) - 1; i >= 0; i-- {
+			ref = certInOutMan.WriteAllocateData(&certInOut{next: ref, inBlock: l.Periods[i].In, outBlock: l.Periods[i].Out})
+		}
+		b := iw.SearchIns(&B.String{C: l.Uid}); M.Assert(!b, l.Uid, 100)
+		iw.WriteValue(ref)
+	}
+	return ind
+} //linkIndex
+
+// Insert the active certification c, recorded at ref, into certFromT, certToT and certTimeT
+func insertCert (ref B.FilePos, c *certification) {
+	var v B.FilePos
+	iwF := certFromT.Writer()
+	if iwF.SearchIns(&pubKey{ref: c.from}) {
+		v = iwF.ReadValue()
+	} else {
+		v = database.CreateIndex(pubKeyS)
+		iwF.WriteValue(v)
+	}
+	iw := database.OpenIndex(v, pubKeyMan, pubKeyFac).Writer()
+	b := iw.SearchIns(&pubKey{ref: c.to}); M.Assert(!b, c.from, c.to, 100)
+	iw.WriteValue(ref)
+	var ctf *certToFork
+	iwT := certToT.Writer()
+	if iwT.SearchIns(&pubKey{ref: c.to}) {
+		ctf = certToForkMan.ReadData(iwT.ReadValue()).(*certToFork)
+	} else {
+		ctf = &certToFork{byPub: database.CreateIndex(pubKeyS), byExp: database.CreateIndex(certTimeKeyS)}
+		iwT.WriteValue(certToForkMan.WriteAllocateData(ctf))
+	}
+	iw = database.OpenIndex(ctf.byPub, pubKeyMan, pubKeyFac).Writer()
+	iw.SearchIns(&pubKey{ref: c.from})
+	iw.WriteValue(ref)
+	iw = database.OpenIndex(ctf.byExp, certKTimeMan, filePosKeyFac).Writer()
+	iw.SearchIns(&filePosKey{ref: ref})
+	iw.WriteValue(ref)
+	certTimeT.Writer().SearchIns(&filePosKey{ref: ref})
+} //insertCert
+
+// Snapshot of the open dBase, dPars and sBase; the changelog is empty if dBase has the former layout
+func takeSnapshot () *snapshot {
+	s := &snapshot{
+		Format: snapshotFormat,
+		Version: snapshotVersion,
+		LastBlock: int32(database.ReadPlace(lastNPlace)),
+		Members: int(database.ReadPlace(idLenPlace)),
+		Parameters: readRaw(dPars),
+		Sandbox: readRaw(sBase),
+		Blocks: make([]snapBlock, 0),
+		Identities: make([]snapIdentity, 0),
+		Certs: make([]snapCert, 0),
+		Undo: make([]snapUndo, 0),
+	}
+	
+	ir := timeT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		t := timeMan.ReadData(ir.ReadValue()).(*timeTy)
+		s.Blocks = append(s.Blocks, snapBlock{Number: t.bnb, MedianTime: t.mTime, Time: t.time, Hash: t.hash})
+		ir.Next()
+	}
+	
+	idPos := make(map[B.FilePos]Pubkey)
+	ir = idPubT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		id := idMan.ReadData(ir.ReadValue()).(*identity)
+		idPos[ir.ReadValue()] = id.pubkey
+		si := snapIdentity{
+			Pubkey: id.pubkey,
+			Uid: id.uid,
+			Member: id.member,
+			Hash: id.hash,
+			Block: id.block_number,
+			Application: id.application,
+			ExpiresOn: id.expires_on,
+			Certifiers: uidsOf(id.certifiers),
+			Certified: uidsOf(id.certified),
+			CertifiersIO: linksOf(id.certifiersIO),
+			CertifiedIO: linksOf(id.certifiedIO),
+		}
+		if pst := joinAndLeaveT.NewReader(); pst.Search(&pubKey{ref: id.pubkey}) {
+			si.History = make([]snapPeriod, 0)
+			for ref := joinAndLeaveMan.ReadData(pst.ReadValue()).(*joinAndLeave).list; ref != B.BNil; {
+				jlL := joinAndLeaveLMan.ReadData(ref).(*joinAndLeaveL)
+				si.History = append(si.History, snapPeriod{In: jlL.joiningBlock, Out: jlL.leavingBlock})
+				ref = jlL.next
+			}
+		}
+		s.Identities = append(s.Identities, si)
+		ir.Next()
+	}
+	
+	certPos := make(map[B.FilePos]int)
+	
+	addCert := func (ref B.FilePos, active bool) int {
+		if i, ok := certPos[ref]; ok {
+			return i
+		}
+		c := certMan.ReadData(ref).(*certification)
+		certPos[ref] = len(s.Certs)
+		s.Certs = append(s.Certs, snapCert{From: c.from, To: c.to, Block: c.block_number, ExpiresOn: c.expires_on, Active: active})
+		return certPos[ref]
+	} //addCert
+	
+	ir = certFromT.NewReader()
+	ir.Next()
+	for ir.PosSet() {
+		ir2 := database.OpenIndex(ir.ReadValue(), pubKeyMan, pubKeyFac).NewReader()
+		ir2.Next()
+		for ir2.PosSet() {
+			addCert(ir2.ReadValue(), true)
+			ir2.Next()
+		}
+		ir.Next()
+	}
+	
+	for ref := B.FilePos(database.ReadPlace(undoListPlace)); ref != B.BNil; {
+		l := undoListMan.ReadData(ref).(*undoListT)
+		u := snapUndo{Op: undoOps[l.typ], Cert: noCert, OldCert: noCert}
+		switch l.typ {
+		case timeList:
+			u.Block = timeMan.ReadData(l.ref).(*timeTy).bnb
+			u.Aux = l.aux
+		case certAddList, certRemoveList:
+			u.Cert = addCert(l.ref, false)
+			if l.typ == certAddList && B.FilePos(l.aux) != B.BNil {
+				u.OldCert = addCert(B.FilePos(l.aux), false)
+			}
+		default:
+			var ok bool
+			u.Pubkey, ok = idPos[l.ref]; M.Assert(ok, u.Op, 100)
+			switch l.typ {
+			case activeList:
+				u.Aux, u.Aux2 = l.aux, l.aux2
+			case remCertifiers, remCertified:
+				u.Other, ok = idPos[B.FilePos(l.aux)]; M.Assert(ok, u.Op, 101)
+			}
+		}
+		s.Undo = append(s.Undo, u)
+		ref = l.next
+	}
+	
+	if changesT != nil {
+		s.Changes = Changes(0, s.LastBlock)
+	}
+	return s
+} //takeSnapshot
+
+// Write into the file path a snapshot of dBase, dPars and sBase, which must not be in use by a running server
+func ExportSnapshot (path string) {
+	lg.Println("Exporting \"" + dBaseName + "\" into", path)
+	openB()
+	defer closeB()
+	s := takeSnapshot()
+	f, err := os.Create(path); M.Assert(err == nil, err, 100)
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := J.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	err = enc.Encode(s); M.Assert(err == nil, err, 101)
+	err = w.Flush(); M.Assert(err == nil, err, 102)
+	lg.Println("Snapshot exported:", len(s.Blocks), "blocks,", len(s.Identities), "identities,", len(s.Certs), "certifications")
+} //ExportSnapshot
+
+// Replace dBase, dPars and sBase by the content of the snapshot file path; dBase must not be in use by a running server, and its previous version is kept as dCopy; the imported dBase is checked, and its faults, if any, are returned
+func ImportSnapshot (path string) (faults []string, ok bool) {
+	lg.Println("Importing", path, "into \"" + dBaseName + "\"")
+	f, err := os.Open(path); M.Assert(err == nil, err, 100)
+	s := new(snapshot)
+	err = J.NewDecoder(bufio.NewReader(f)).Decode(s)
+	f.Close()
+	M.Assert(err == nil, err, 101)
+	M.Assert(s.Format == snapshotFormat, path + " is not a WotWizard snapshot", 102)
+	M.Assert(s.Version >= 1 && s.Version <= snapshotVersion, "Unknown snapshot version", s.Version, 103)
+	
+	saveBase()
+	err = os.Remove(dBase); M.Assert(err == nil || os.IsNotExist(err), err, 104)
+	openB()
+	defer closeB()
+	putSnapshot(s)
+	
+	for _, r := range [2]struct{path string; content J.RawMessage}{{dPars, s.Parameters}, {sBase, s.Sandbox}} {
+		if r.content != nil && string(r.content) != "null" {
+			err = os.WriteFile(r.path, r.content, 0666); M.Assert(err == nil, err, 105)
+		}
+	}
+	lg.Println("Snapshot imported:", len(s.Blocks), "blocks,", len(s.Identities), "identities,", len(s.Certs), "certifications")
+	
+	lastBlock = s.LastBlock
+	c := new(checker)
+	c.run()
+	ok = len(c.msgs) == 0
+	return c.msgs, ok
+} //ImportSnapshot
+
+// Write the content of s into the open and empty dBase
+func putSnapshot (s *snapshot) {
+	blockPos := make(map[int32]B.FilePos)
+	iwT := timeT.Writer()
+	iwM := timeMT.Writer()
+	for _, sb := range s.Blocks {
+		ref := timeMan.WriteAllocateData(&timeTy{bnb: sb.Number, mTime: sb.MedianTime, time: sb.Time, hash: sb.Hash})
+		blockPos[sb.Number] = ref
+		b := iwT.SearchIns(&intKey{ref: sb.Number}); M.Assert(!b, sb.Number, 100)
+		iwT.WriteValue(ref)
+		if !iwM.SearchIns(&lIntKey{ref: sb.MedianTime}) { // The first block with a given median time
+			iwM.WriteValue(ref)
+		}
+	}
+	
+	idPos := make(map[Pubkey]B.FilePos)
+	iwP := idPubT.Writer()
+	iwU := idUidT.Writer()
+	iwH := idHashT.Writer()
+	iwJ := joinAndLeaveT.Writer()
+	for _, si := range s.Identities {
+		id := &identity{
+			pubkey: si.Pubkey,
+			uid: si.Uid,
+			member: si.Member,
+			hash: si.Hash,
+			block_number: si.Block,
+			application: si.Application,
+			expires_on: si.ExpiresOn,
+			certifiers: uidIndex(si.Certifiers),
+			certified: uidIndex(si.Certified),
+			certifiersIO: linkIndex(si.CertifiersIO),
+			certifiedIO: linkIndex(si.CertifiedIO),
+		}
+		ref := idMan.WriteAllocateData(id)
+		idPos[id.pubkey] = ref
+		b := iwP.SearchIns(&pubKey{ref: id.pubkey}); M.Assert(!b, id.pubkey, 101)
+		iwP.WriteValue(ref)
+		b = iwU.SearchIns(&B.String{C: id.uid}); M.Assert(!b, id.uid, 102)
+		iwU.WriteValue(ref)
+		b = iwH.SearchIns(&hashKey{ref: id.hash}); M.Assert(!b, id.hash, 103)
+		iwH.WriteValue(ref)
+		if si.History != nil {
+			list := B.BNil
+			for i := len(si.History) - 1; i >= 0; i-- {
+				list = joinAndLeaveLMan.WriteAllocateData(&joinAndLeaveL{next: list, joiningBlock: si.History[i].In, leavingBlock: si.History[i].Out})
+			}
+			iwJ.SearchIns(&pubKey{ref: id.pubkey})
+			iwJ.WriteValue(joinAndLeaveMan.WriteAllocateData(&joinAndLeave{pubkey: id.pubkey, list: list}))
+		}
+	}
+	iwI := idTimeT.Writer()
+	for _, si := range s.Identities {
+		ref := idPos[si.Pubkey]
+		if waitsRevocation(idMan.ReadData(ref).(*identity)) {
+			iwI.SearchIns(&filePosKey{ref: ref})
+		}
+	}
+	
+	certRefs := make([]B.FilePos, len(s.Certs))
+	for i, sc := range s.Certs {
+		c := &certification{from: sc.From, to: sc.To, block_number: sc.Block, expires_on: sc.ExpiresOn}
+		certRefs[i] = certMan.WriteAllocateData(c)
+		if sc.Active {
+			insertCert(certRefs[i], c)
+		}
+	}
+	
+	typs := make(map[string]byte)
+	for typ, op := range undoOps {
+		typs[op] = byte(typ)
+	}
+	undo := B.BNil
+	for i := len(s.Undo) - 1; i >= 0; i-- {
+		u := &s.Undo[i]
+		typ, ok := typs[u.Op]; M.Assert(ok, "Unknown undo operation", u.Op, 104)
+		l := &undoListT{next: undo, typ: typ}
+		switch typ {
+		case timeList:
+			l.ref, ok = blockPos[u.Block]; M.Assert(ok, u.Block, 105)
+			l.aux = u.Aux
+		case certAddList, certRemoveList:
+			M.Assert(u.Cert >= 0 && u.Cert < len(certRefs), u.Cert, 106)
+			l.ref = certRefs[u.Cert]
+			if typ == certAddList {
+				l.aux = int64(B.BNil)
+				if u.OldCert != noCert {
+					M.Assert(u.OldCert >= 0 && u.OldCert < len(certRefs), u.OldCert, 107)
+					l.aux = int64(certRefs[u.OldCert])
+				}
+			}
+		default:
+			l.ref, ok = idPos[u.Pubkey]; M.Assert(ok, u.Pubkey, 108)
+			switch typ {
+			case activeList:
+				l.aux, l.aux2 = u.Aux, u.Aux2
+			case remCertifiers, remCertified:
+				var ref B.FilePos
+				ref, ok = idPos[u.Other]; M.Assert(ok, u.Other, 109)
+				l.aux = int64(ref)
+			}
+		}
+		undo = undoListMan.WriteAllocateData(l)
+	}
+	
+	for _, bc := range s.Changes {
+		pendingChanges = bc.Changes
+		recordChanges(bc.Block)
+	}
+
+	database.WritePlace(undoListPlace, int64(undo))
+	database.WritePlace(lastNPlace, int64(s.LastBlock))
+	database.WritePlace(idLenPlace, int64(s.Members))
+	database.UpdateBase()
+} //putSnapshot
+
+// Replace the open dBase, which has the former layout, by a dBase of the present layout with the same content, the changelog excepted; the former dBase is kept as dCopy
+func migrateB () {
+	lg.Warn("\"" + dBaseName + "\" has the layout of a former version of WotWizard; migrating it")
+	openIndexes()
+	s := takeSnapshot()
+	database.CloseBase()
+	database = nil
+	saveBase()
+	err := os.Remove(dBase); M.Assert(err == nil, err, 100)
+	createB()
+	openIndexes()
+	putSnapshot(s)
+	lg.Println("\"" + dBaseName + "\" migrated:", len(s.Blocks), "blocks,", len(s.Identities), "identities,", len(s.Certs), "certifications")
+} //migrateB
@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+
+	F	"path/filepath"
+	J	"encoding/json"
+	M	"util/misc"
+		"bytes"
+		"os"
+		"reflect"
+		"testing"
+
+)
+
+// Does do halt the program?
+func halts (do func ()) (halted bool) {
+	defer func () {
+		halted = recover() != nil
+	}()
+	do()
+	return
+} //halts
+
+// Snapshot of the open dBase, whose dPars and sBase are compacted, since their layouts are not kept by snapshots
+func compactSnapshot () *snapshot {
+	s := takeSnapshot()
+	for _, r := range []*J.RawMessage{&s.Parameters, &s.Sandbox} {
+		if *r != nil {
+			b := new(bytes.Buffer)
+			err := J.Compact(b, *r); M.Assert(err == nil, err, 100)
+			*r = b.Bytes()
+		}
+	}
+	return s
+} //compactSnapshot
+
+func TestSnapshotRoundTrip (t *testing.T) {
+	readSource(t, expiryWith(t, joinBlock, exclusionBlock))
+	exportParameters()
+	err := os.WriteFile(sBase, []byte(`{"block": 102, "identities": []}`), 0666); M.Assert(err == nil, err, 100)
+	s1 := compactSnapshot()
+	M.Want(len(s1.Undo) > 0 && len(s1.Changes) > 0 && s1.Parameters != nil && s1.Sandbox != nil, t)
+	closeB()
+
+	dir := t.TempDir()
+	path := F.Join(dir, "snapshot.json")
+	ExportSnapshot(path)
+	os.Remove(dCopy)
+	faults, ok := ImportSnapshot(path)
+	M.Want(ok && len(faults) == 0, t)
+	_, err = os.Stat(dCopy)
+	M.Want(err == nil, t) // The replaced dBase is kept
+	openB()
+	if !reflect.DeepEqual(s1, compactSnapshot()) {
+		t.Error("dBase differs after an export and an import")
+	}
+	closeB()
+
+	// Exported again, the snapshot is the same
+	path2 := F.Join(dir, "snapshot2.json")
+	ExportSnapshot(path2)
+	b1, err := os.ReadFile(path); M.Assert(err == nil, err, 101)
+	b2, err := os.ReadFile(path2); M.Assert(err == nil, err, 102)
+	M.Want(bytes.Equal(b1, b2), t)
+
+	// A file which is not a snapshot is refused, and dBase is left untouched
+	foreign := F.Join(dir, "foreign.json")
+	err = os.WriteFile(foreign, []byte(`{"Format": "something else", "Version": 1}`), 0644); M.Assert(err == nil, err, 103)
+	M.Want(halts(func () {ImportSnapshot(foreign)}), t)
+	openB()
+	M.Want(reflect.DeepEqual(s1, compactSnapshot()), t)
+}
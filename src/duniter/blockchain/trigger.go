@@ -0,0 +1,320 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package blockchain
+
+// Triggers of updates: the handshake with Duniter through syncName, the watching of the Duniter source, a periodic timer and a local HTTP endpoint
+
+import (
+	
+	BA	"duniter/basic"
+	F	"path/filepath"
+	M	"util/misc"
+		"fmt"
+		"net/http"
+		"os"
+		"strings"
+		"time"
+
+)
+
+const (
+	
+	// Kinds of triggers, as given by the "-trigger" option
+	fileTriggerName = "file"
+	watchTriggerName = "watch"
+	timerTriggerName = "timer"
+	httpTriggerName = "http"
+	
+	// Default period of timer triggers
+	timerPeriodDef = 5 * time.Minute
+	// Default address of http triggers
+	httpAddressDef = "localhost:8081"
+	// Path of the "new block" endpoint of http triggers
+	newBlockPath = "/newBlock"
+	
+	// Delay during which a watched source must stay unchanged before an update, so as not to read a partially written source
+	quietDelay = 2 * verifyPeriod
+
+)
+
+type (
+	
+	// Trigger of updates
+	UpdateTrigger interface {
+		// Name of the trigger, for the log
+		Name () string
+		// Wait for the next update request; return false if stop was signalled before
+		Wait (stop <-chan os.Signal) bool
+		// Called while an update runs; return when done is signalled
+		Updating (done <-chan bool)
+	}
+	
+	// Handshake with Duniter: Duniter creates syncName, writes in it the time it's ready to wait, and waits for its removal; the update begins when syncName appears, and syncName is removed when the update is done (at the beginning of the following Wait)
+	fileTrigger struct {
+		t0 int64 // Time written by Duniter, in ms
+	}
+	
+	// Update when the modification time or the size of the Duniter source changed, and then stayed the same during quietDelay; suited for an export shipped from another machine
+	watchTrigger struct {
+		path string
+		lastMod time.Time
+		lastSize int64
+	}
+	
+	// Update every period, the first time immediately
+	timerTrigger struct {
+		period time.Duration
+		next time.Time
+	}
+	
+	// Update when a POST request is received on newBlockPath at address; the answer is "202 Accepted" and requests received during an update are merged into one
+	httpTrigger struct {
+		address string
+		requests chan bool
+		started bool
+	}
+
+)
+
+var (
+	
+	// Current trigger
+	trigger UpdateTrigger = nil
+
+)
+
+// Fix the trigger of updates; must be called before Start; by default, the trigger is deduced from BA.UpdateTrigger
+func SetUpdateTrigger (t UpdateTrigger) {
+	M.Assert(t != nil, 20)
+	trigger = t
+} //SetUpdateTrigger
+
+// Return the current trigger of updates
+func Trigger () UpdateTrigger {
+	if trigger == nil {
+		trigger = NewTrigger(BA.UpdateTrigger)
+	}
+	return trigger
+} //Trigger
+
+// Return a new trigger described by spec: "file", "watch", "timer[:period]" (a Go duration, e.g. "timer:10m") or "http[:address]" (e.g. "http:localhost:8081")
+func NewTrigger (spec string) UpdateTrigger {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i + 1:]
+	}
+	switch kind {
+	case fileTriggerName:
+		M.Assert(arg == "", spec, 20)
+		return new(fileTrigger)
+	case watchTriggerName:
+		M.Assert(arg == "", spec, 21)
+		path := BA.DuniBase
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			path = F.Join(path, jsonLinesName)
+		}
+		return &watchTrigger{path: path}
+	case timerTriggerName:
+		period := timerPeriodDef
+		if arg != "" {
+			var err error
+			period, err = time.ParseDuration(arg); M.Assert(err == nil && period > 0, spec, 22)
+		}
+		return &timerTrigger{period: period}
+	case httpTriggerName:
+		if arg == "" {
+			arg = httpAddressDef
+		}
+		return &httpTrigger{address: arg, requests: make(chan bool, 1)}
+	default:
+		M.Halt("Unknown update trigger: " + spec, 23)
+		return nil
+	}
+} //NewTrigger
+
+// Updt
+func readSyncTime () int64 {
+	f, err := os.Open(duniSync); M.Assert(err == nil, err, 100)
+	defer f.Close()
+	var t int64
+	_, err = fmt.Fscanf(f, "%d", &t); M.Assert(err == nil, err, 101)
+	return t
+} //readSyncTime
+
+// Updt
+func writeSyncTime (t int64) {
+	f, err := os.Create(duniSync); M.Assert(err == nil, err, 100)
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%d", t); M.Assert(err == nil, err, 101)
+} //writeSyncTime
+
+func (t *fileTrigger) Name () string {
+	return fileTriggerName + " (" + duniSync + ")"
+} //Name
+
+// Updt
+func (t *fileTrigger) Wait (stop <-chan os.Signal) bool {
+	err := os.Remove(duniSync)
+	M.Assert(err == nil || os.IsNotExist(err), err, 100)
+	lg.Println("\"" +  syncName + "\" erased")
+	lg.Println("Looking for", duniSync); lg.Println()
+	f, err := os.Open(duniSync)
+	for os.IsNotExist(err) {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		time.Sleep(verifyPeriod)
+		f, err = os.Open(duniSync)
+	}
+	M.Assert(err == nil, err, 101)
+	f.Close()
+	lg.Println("\"" + syncName + "\" seen; reading it")
+	t.t0 = readSyncTime()
+	return true
+} //Wait
+
+// Updt
+// Make Duniter wait longer, by addDelay steps, if the update is not done before the end of syncDelay
+func (t *fileTrigger) Updating (done <-chan bool) {
+	ct1 := time.NewTicker(syncDelay - verifyPeriod - addDelay - secureDelay)
+	select {
+	case <- done:
+		ct1.Stop()
+	case <- ct1.C:
+		ct1.Reset(addDelay)
+		innerLoop:
+		for {
+			select {
+			case <- done:
+				ct1.Stop()
+				break innerLoop
+			case <- ct1.C:
+				t.t0 += addDelayInt
+				writeSyncTime(t.t0)
+			}
+		}
+	}
+} //Updating
+
+func (t *watchTrigger) Name () string {
+	return watchTriggerName + " (" + t.path + ")"
+} //Name
+
+// Updt
+func (t *watchTrigger) Wait (stop <-chan os.Signal) bool {
+	lg.Println("Watching", t.path); lg.Println()
+	var (
+		mod time.Time
+		size int64 = -1
+		since time.Time
+	)
+	for {
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		if fi, err := os.Stat(t.path); err == nil {
+			if !fi.ModTime().Equal(mod) || fi.Size() != size {
+				mod, size, since = fi.ModTime(), fi.Size(), time.Now()
+			} else if (!mod.Equal(t.lastMod) || size != t.lastSize) && time.Since(since) >= quietDelay {
+				t.lastMod, t.lastSize = mod, size
+				lg.Println("\"" + t.path + "\" modified")
+				return true
+			}
+		}
+		time.Sleep(verifyPeriod)
+	}
+} //Wait
+
+// Updt
+func (t *watchTrigger) Updating (done <-chan bool) {
+	<-done
+} //Updating
+
+func (t *timerTrigger) Name () string {
+	return timerTriggerName + " (" + t.period.String() + ")"
+} //Name
+
+// Updt
+func (t *timerTrigger) Wait (stop <-chan os.Signal) bool {
+	if !t.next.IsZero() {
+		lg.Println("Next update at", t.next.Local().Format("2/01/2006 15:04:05")); lg.Println()
+		tm := time.NewTimer(time.Until(t.next))
+		select {
+		case <-stop:
+			tm.Stop()
+			return false
+		case <-tm.C:
+		}
+	}
+	t.next = time.Now().Add(t.period)
+	return true
+} //Wait
+
+// Updt
+func (t *timerTrigger) Updating (done <-chan bool) {
+	<-done
+} //Updating
+
+func (t *httpTrigger) Name () string {
+	return httpTriggerName + " (" + t.address + newBlockPath + ")"
+} //Name
+
+// Start the "new block" endpoint
+func (t *httpTrigger) start () {
+	r := http.NewServeMux()
+	r.HandleFunc(newBlockPath, func (w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		select {
+		case t.requests <- true:
+		default: // An update is already requested
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := &http.Server{
+		Addr: t.address,
+		Handler: r,
+	}
+	go func () {
+		err := server.ListenAndServe()
+		lg.Error("Update trigger endpoint stopped:", err)
+	}()
+	t.started = true
+} //start
+
+// Updt
+func (t *httpTrigger) Wait (stop <-chan os.Signal) bool {
+	if !t.started {
+		t.start()
+	}
+	lg.Println("Waiting for POST", "http://" + t.address + newBlockPath); lg.Println()
+	select {
+	case <-stop:
+		return false
+	case <-t.requests:
+		lg.Println("New block announced")
+		return true
+	}
+} //Wait
+
+// Updt
+func (t *httpTrigger) Updating (done <-chan bool) {
+	<-done
+} //Updating
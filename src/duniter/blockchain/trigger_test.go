@@ -0,0 +1,83 @@
+package blockchain
+
+import (
+
+	M	"util/misc"
+		"net"
+		"net/http"
+		"os"
+		"testing"
+		"time"
+
+)
+
+// Free local address for an http trigger
+func freeAddress () string {
+	l, err := net.Listen("tcp", "127.0.0.1:0"); M.Assert(err == nil, err, 100)
+	defer l.Close()
+	return l.Addr().String()
+} //freeAddress
+
+// Status of the request with method to url
+func status (method, url string) (code int, err error) {
+	req, err := http.NewRequest(method, url, nil); M.Assert(err == nil, err, 100)
+	resp, err := http.DefaultClient.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		code = resp.StatusCode
+	}
+	return
+} //status
+
+// Wait for an update announced on updateReady, for at most one minute
+func waitUpdate (t *testing.T, updateReady <-chan bool) bool {
+	select {
+	case <-updateReady:
+		return true
+	case <-time.After(time.Minute):
+		t.Error("No update")
+		return false
+	}
+} //waitUpdate
+
+func TestTriggerFiresUpdate (t *testing.T) {
+	oldSource, oldTrigger, oldList := source, trigger, updateListUpdt
+	t.Cleanup(func () {source, trigger, updateListUpdt = oldSource, oldTrigger, oldList})
+	newBase()
+	source = NewSource(fixture("basic"))
+	updateListUpdt = nil
+	AddUpdateProcUpdt(scan)
+	doScan1 = true
+	address := freeAddress()
+	SetUpdateTrigger(NewTrigger(httpTriggerName + ":" + address))
+	M.Want(Trigger().Name() == "http (" + address + newBlockPath + ")", t)
+
+	stopProg := make(chan os.Signal, 1)
+	updateReady := make(chan bool, 1)
+	halted := make(chan bool)
+	go func () {
+		updateAllUpdt(stopProg, updateReady)
+		halted <- true
+	}()
+	url := "http://" + address + newBlockPath
+	code, err := status(http.MethodPost, url)
+	for i := 0; i < 100 && err != nil; i++ { // Wait for the start of the endpoint
+		time.Sleep(10 * time.Millisecond)
+		code, err = status(http.MethodPost, url)
+	}
+	M.Want(code == http.StatusAccepted, t)
+	if waitUpdate(t, updateReady) {
+		mutex.RLock()
+		M.Want(lastBlock == 3 && IdLen() == 4, t)
+		mutex.RUnlock()
+	}
+	code, _ = status(http.MethodGet, url)
+	M.Want(code == http.StatusMethodNotAllowed, t)
+	code, _ = status(http.MethodPost, url)
+	M.Want(code == http.StatusAccepted && waitUpdate(t, updateReady), t) // Every announced block fires an update
+
+	stopProg <- os.Interrupt
+	<-halted
+	M.Want(database == nil, t)
+	mutex.Unlock() // Left locked by updateAllUpdt, since the program halts
+}
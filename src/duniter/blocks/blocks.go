@@ -74,11 +74,67 @@ func blockUtcRR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value
 	}
 } //blockUtcRR
 
+func lastIngestionErrorR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	if e, ok := B.LastIngestionError(); ok {
+		return GQ.Wrap(e)
+	}
+	return G.MakeNullValue()
+} //lastIngestionErrorR
+
+func ingestionErrorOf (rootValue *G.OutputObjectValue) B.IngestionError {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case B.IngestionError:
+		return e
+	default:
+		M.Halt(e, 100)
+		return B.IngestionError{}
+	}
+} //ingestionErrorOf
+
+func ingErrBlockR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	e := ingestionErrorOf(rootValue)
+	if e.Block < 0 {
+		return G.MakeNullValue()
+	}
+	return G.MakeIntValue(e.Block)
+} //ingErrBlockR
+
+func ingErrFieldR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeStringValue(ingestionErrorOf(rootValue).Field)
+} //ingErrFieldR
+
+func ingErrMessageR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeStringValue(ingestionErrorOf(rootValue).Msg)
+} //ingErrMessageR
+
+func ingErrDateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(ingestionErrorOf(rootValue).Date)
+} //ingErrDateR
+
+func ingErrQuarantinedR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeBooleanValue(ingestionErrorOf(rootValue).Quarantined)
+} //ingErrQuarantinedR
+
+func ingErrSkippedR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	l := G.NewListValue()
+	for _, s := range ingestionErrorOf(rootValue).Skipped {
+		l.Append(G.MakeStringValue(s))
+	}
+	return l
+} //ingErrSkippedR
+
 func fixFieldResolvers (ts G.TypeSystem) {
 	ts.FixFieldResolver("Query", "now", nowR)
 	ts.FixFieldResolver("Block", "number", blockNumberR)
 	ts.FixFieldResolver("Block", "bct", blockBctR)
 	ts.FixFieldResolver("Block", "utc0", blockUtcRR)
+	ts.FixFieldResolver("Query", "lastIngestionError", lastIngestionErrorR)
+	ts.FixFieldResolver("IngestionError", "block", ingErrBlockR)
+	ts.FixFieldResolver("IngestionError", "field", ingErrFieldR)
+	ts.FixFieldResolver("IngestionError", "message", ingErrMessageR)
+	ts.FixFieldResolver("IngestionError", "date", ingErrDateR)
+	ts.FixFieldResolver("IngestionError", "quarantined", ingErrQuarantinedR)
+	ts.FixFieldResolver("IngestionError", "skipped", ingErrSkippedR)
 	ts.FixFieldResolver("Subscription", "now", nowR)
 } //fixFieldResolvers
 
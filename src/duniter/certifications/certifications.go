@@ -103,9 +103,22 @@ func rCertsLimitR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Val
 	}
 } //rCertsLimitR
 
+// Past web of trust and validity period of a certification seen at a past block; ok == false if it's seen at present
+func pastOf (rootValue *G.OutputObjectValue) (past *B.Past, pc B.PastCert, ok bool) {
+	ok = GQ.WrappedNb(rootValue) > 4
+	if ok {
+		past = GQ.Unwrap(rootValue, 3).(*B.Past)
+		pc = GQ.Unwrap(rootValue, 4).(B.PastCert)
+	}
+	return
+} //pastOf
+
 func certFromR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch from := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
+		if past, _, ok := pastOf(rootValue); ok {
+			return GQ.Wrap(from, past)
+		}
 		return GQ.Wrap(from)
 	default:
 		M.Halt(from, 100)
@@ -116,6 +129,9 @@ func certFromR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value
 func certToR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch to := GQ.Unwrap(rootValue, 1).(type) {
 	case B.Hash:
+		if past, _, ok := pastOf(rootValue); ok {
+			return GQ.Wrap(to, past)
+		}
 		return GQ.Wrap(to)
 	default:
 		M.Halt(to, 100)
@@ -134,6 +150,9 @@ func certPendingR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Val
 } //certPendingR
 
 func certBlockR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	if _, pc, ok := pastOf(rootValue); ok {
+		return GQ.Wrap(pc.In)
+	}
 	var (from, to B.Pubkey; toH B.Hash; idInBC bool)
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
@@ -162,6 +181,10 @@ func certBlockR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value
 } //certBlockR
 
 func certExpR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	if _, pc, ok := pastOf(rootValue); ok && pc.Out != B.HasNotLeaved {
+		exp, _, b := B.TimeOf(pc.Out); M.Assert(b, 105)
+		return G.MakeInt64Value(exp)
+	}
 	var (from, to B.Pubkey; toH B.Hash; idInBC, pending bool)
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
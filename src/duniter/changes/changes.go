@@ -0,0 +1,155 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package changes
+
+// Per-block changelog of the web of trust
+
+import (
+	
+	A	"util/avl"
+	B	"duniter/blockchain"
+	G	"util/graphQL"
+	GQ	"duniter/gqlReceiver"
+	M	"util/misc"
+
+)
+
+var (
+	
+	changesStream = GQ.CreateStream("changes")
+	
+	kindNames = [...]string{
+		B.Joined: "JOINED",
+		B.Renewed: "RENEWED",
+		B.Left: "LEFT",
+		B.Excluded: "EXCLUDED",
+		B.Revoked: "REVOKED",
+		B.CertWritten: "CERT_WRITTEN",
+		B.CertReplaced: "CERT_REPLACED",
+		B.CertExpired: "CERT_EXPIRED",
+	}
+
+)
+
+func changesStreamResolver (rootValue *G.OutputObjectValue, argumentValues *A.Tree) *G.EventStream { // *G.ValMapItem
+	return changesStream
+} //changesStreamResolver
+
+func blockChangesList (bcs []B.BlockChanges) *G.ListValue {
+	l := G.NewListValue()
+	for _, bc := range bcs {
+		l.Append(GQ.Wrap(bc))
+	}
+	return l
+} //blockChangesList
+
+func changesR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	var from int32 = 0
+	to := B.LastBlock()
+	var v G.Value
+	if G.GetValue(argumentValues, "fromBlock", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			from = int32(v.Int)
+		default:
+			M.Halt(v, 100)
+		}
+	}
+	if G.GetValue(argumentValues, "toBlock", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			to = int32(v.Int)
+		case *G.NullValue:
+		default:
+			M.Halt(v, 101)
+		}
+	}
+	return blockChangesList(B.Changes(from, to))
+} //changesR
+
+func subChangesR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return blockChangesList(B.Changes(B.ChangedFrom(), B.LastBlock()))
+} //subChangesR
+
+func blockChangesOf (rootValue *G.OutputObjectValue) B.BlockChanges {
+	switch bc := GQ.Unwrap(rootValue, 0).(type) {
+	case B.BlockChanges:
+		return bc
+	default:
+		M.Halt(bc, 100)
+		return B.BlockChanges{}
+	}
+} //blockChangesOf
+
+func bcBlockR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return GQ.Wrap(blockChangesOf(rootValue).Block)
+} //bcBlockR
+
+func bcChangesR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	l := G.NewListValue()
+	for _, c := range blockChangesOf(rootValue).Changes {
+		l.Append(GQ.Wrap(c))
+	}
+	return l
+} //bcChangesR
+
+func changeOf (rootValue *G.OutputObjectValue) B.Change {
+	switch c := GQ.Unwrap(rootValue, 0).(type) {
+	case B.Change:
+		return c
+	default:
+		M.Halt(c, 100)
+		return B.Change{}
+	}
+} //changeOf
+
+func identityOf (p B.Pubkey) G.Value {
+	_, _, hash, _, _, _, ok := B.IdPubComplete(p); M.Assert(ok, p, 100)
+	return GQ.Wrap(hash)
+} //identityOf
+
+func changeKindR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeEnumValue(kindNames[changeOf(rootValue).Kind])
+} //changeKindR
+
+func changeIdentityR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return identityOf(changeOf(rootValue).Pubkey)
+} //changeIdentityR
+
+func changeCertifiedR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	c := changeOf(rootValue)
+	if c.To == "" {
+		return G.MakeNullValue()
+	}
+	return identityOf(c.To)
+} //changeCertifiedR
+
+func fixFieldResolvers (ts G.TypeSystem) {
+	ts.FixFieldResolver("Query", "changes", changesR)
+	ts.FixFieldResolver("Subscription", "changes", subChangesR)
+	ts.FixFieldResolver("BlockChanges", "block", bcBlockR)
+	ts.FixFieldResolver("BlockChanges", "changes", bcChangesR)
+	ts.FixFieldResolver("Change", "kind", changeKindR)
+	ts.FixFieldResolver("Change", "identity", changeIdentityR)
+	ts.FixFieldResolver("Change", "certified", changeCertifiedR)
+} //fixFieldResolvers
+
+func fixStreamResolvers (ts G.TypeSystem) {
+	ts.FixStreamResolver("changes", changesStreamResolver)
+} //fixStreamResolvers
+
+func init () {
+	ts := GQ.TS()
+	fixFieldResolvers(ts)
+	fixStreamResolvers(ts)
+} //init
@@ -0,0 +1,255 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package currencies
+
+// Supervisor of several currencies: the state of a WotWizard server (files directory, parameters, database) is global to its packages, so every currency is served by its own WotWizard process (option "-currency"), started and restarted here; this front server holds no currency state, and only dispatches the GraphQL requests to these processes by the path of their URL ("/g1"), or by a "currency" argument, in the URL query or in the JSON request; without selector, the first currency is used, and an unknown currency gets the status 404
+
+import (
+	
+	BA	"duniter/basic"
+	H	"net/http/httputil"
+	J	"encoding/json"
+	M	"util/misc"
+	SC	"syscall"
+		"bytes"
+		"fmt"
+		"io"
+		"net/http"
+		"net/url"
+		"os"
+		"os/exec"
+		"os/signal"
+		"strings"
+		"sync"
+		"time"
+
+)
+
+const (
+	
+	// Delay before the restart of a stopped server, doubled at each stop up to maxRestartDelay
+	restartDelay = 5 * time.Second
+	maxRestartDelay = 5 * time.Minute
+	// A server which ran that long before stopping is restarted after restartDelay again
+	stableRun = 10 * time.Minute
+	
+	// Name of the argument selecting the currency, in the URL query or in the JSON request
+	selectorName = "currency"
+
+)
+
+type (
+	
+	// Description of a currency in the file BA.Currencies, e.g. {"name": "g1", "du": "/path/to/wotwizard-export.db", "address": "localhost:8081", "options": ["-trigger", "watch"]}
+	currencyT struct {
+		Name string
+		Du string // Path to the Duniter database of the currency; the stored one if empty
+		Address string // Address of the server of the currency
+		Options []string // Other options of the server of the currency
+	}
+	
+	// Server of a currency
+	server struct {
+		cur currencyT
+		proxy *H.ReverseProxy
+	}
+	
+	// Selector of currency in a JSON request
+	selectorT struct {
+		Currency string
+	}
+	
+	// GraphQL response holding only errors
+	errorsT struct {
+		Errors []errorT `json:"errors"`
+	}
+	
+	errorT struct {
+		Message string `json:"message"`
+	}
+
+)
+
+var (
+	
+	lg = BA.NewLogger("currencies")
+
+)
+
+// Read the description of the currencies in the file path, and verify it
+func readCurrencies (path string) []currencyT {
+	bs, err := os.ReadFile(path); M.Assert(err == nil, err, 100)
+	curs := make([]currencyT, 0)
+	err = J.Unmarshal(bs, &curs); M.Assert(err == nil, "Incorrect file " + path + ": ", err, 101)
+	M.Assert(len(curs) > 0, "No currency in " + path, 102)
+	names := make(map[string]bool)
+	addrs := map[string]bool{BA.ServerAddress(): true}
+	for _, cur := range curs {
+		M.Assert(BA.CorrectCurrency(cur.Name), "Incorrect currency name in " + path + ": " + cur.Name, 103)
+		M.Assert(!names[cur.Name], "Duplicated currency in " + path + ": " + cur.Name, 104)
+		M.Assert(cur.Address != "" && !addrs[cur.Address], "Missing or duplicated address in " + path + ": " + cur.Name, 105)
+		names[cur.Name] = true
+		addrs[cur.Address] = true
+	}
+	return curs
+} //readCurrencies
+
+// Arguments of the server of s
+func (s *server) args () []string {
+	args := []string{"-currency", s.cur.Name, "-address", s.cur.Address}
+	if s.cur.Du != "" {
+		args = append(args, "-du", s.cur.Du)
+	}
+	return append(args, s.cur.Options...)
+} //args
+
+// Run the server of s with the executable exe, and restart it each time it stops, until stop is closed; then interrupt it
+func (s *server) watch (exe string, stop <-chan bool, wg *sync.WaitGroup) {
+	defer wg.Done()
+	delay := restartDelay
+	for {
+		cmd := exec.Command(exe, s.args()...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		start := time.Now()
+		err := cmd.Start()
+		if err == nil {
+			lg.Println("Server of", s.cur.Name, "started on", s.cur.Address)
+			done := make(chan error, 1)
+			go func () {done <- cmd.Wait()}()
+			select {
+			case <-stop:
+				cmd.Process.Signal(os.Interrupt)
+				<-done
+				lg.Println("Server of", s.cur.Name, "stopped")
+				return
+			case err = <-done:
+			}
+			if time.Since(start) >= stableRun {
+				delay = restartDelay
+			}
+		}
+		if err != nil {
+			lg.Error("Server of", s.cur.Name, "stopped:", err)
+		} else {
+			lg.Println("Server of", s.cur.Name, "stopped")
+		}
+		lg.Warn("Restart of the server of", s.cur.Name, "in", delay)
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		delay = 2 * delay
+		if delay > maxRestartDelay {
+			delay = maxRestartDelay
+		}
+	}
+} //watch
+
+// Write an error with the HTTP status status, as a GraphQL response: {"errors": [{"message": msg}]}
+func writeError (w http.ResponseWriter, status int, msg string) {
+	bs, err := J.Marshal(errorsT{Errors: []errorT{{Message: msg}}}); M.Assert(err == nil, err, 100)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bs)
+	lg.Error(msg)
+} //writeError
+
+// Return the handler dispatching the requests to servers, the first one of list by default
+func makeHandler (servers map[string]*server, list []*server) func (w http.ResponseWriter, req *http.Request) {
+	
+	return func (w http.ResponseWriter, req *http.Request) {
+		path := strings.TrimPrefix(req.URL.Path, "/")
+		name := path
+		rest := ""
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			name = path[:i]
+			rest = path[i + 1:]
+		}
+		if name != "" {
+			req.URL.Path = "/" + rest
+		} else if name = req.URL.Query().Get(selectorName); name == "" {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			var sel selectorT
+			if J.Unmarshal(body, &sel) == nil {
+				name = sel.Currency
+			}
+		}
+		s := list[0]
+		if name != "" {
+			var ok bool
+			if s, ok = servers[name]; !ok {
+				writeError(w, http.StatusNotFound, "Unknown currency " + name)
+				return
+			}
+		}
+		s.proxy.ServeHTTP(w, req)
+	}
+
+} //makeHandler
+
+// Serve the currencies described in BA.Currencies until the reception of a stop signal
+func Start () {
+	curs := readCurrencies(BA.Currencies)
+	exe, err := os.Executable(); M.Assert(err == nil, err, 100)
+	servers := make(map[string]*server)
+	list := make([]*server, len(curs))
+	for i, cur := range curs {
+		s := &server{cur: cur, proxy: H.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: cur.Address})}
+		name := cur.Name
+		s.proxy.ErrorHandler = func (w http.ResponseWriter, req *http.Request, err error) {
+			writeError(w, http.StatusBadGateway, "Server of " + name + " unavailable: " + err.Error())
+		}
+		servers[name] = s
+		list[i] = s
+	}
+	
+	stop := make(chan bool)
+	wg := new(sync.WaitGroup)
+	for _, s := range list {
+		wg.Add(1)
+		go s.watch(exe, stop, wg)
+	}
+	
+	r := http.NewServeMux()
+	r.HandleFunc("/", makeHandler(servers, list))
+	srv := &http.Server{
+		Addr: BA.ServerAddress(),
+		Handler: r,
+	}
+	go func () {
+		s := fmt.Sprint("Dispatching currencies on ", BA.ServerAddress(), " ...")
+		lg.Println(s)
+		fmt.Println(s)
+		err := srv.ListenAndServe()
+		if err != http.ErrServerClosed {
+			lg.Error(err)
+			fmt.Println(err)
+		}
+	}()
+	
+	stopProg := make(chan os.Signal, 1)
+	signal.Notify(stopProg, SC.SIGHUP, SC.SIGINT, SC.SIGTERM)
+	<-stopProg
+	lg.Println("Halting")
+	srv.Close()
+	close(stop)
+	wg.Wait()
+	lg.Println("Halted"); lg.Println()
+} //Start
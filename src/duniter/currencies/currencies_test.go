@@ -0,0 +1,68 @@
+package currencies
+
+import (
+
+	BA	"duniter/basic"
+	H	"net/http/httputil"
+	J	"encoding/json"
+	M	"util/misc"
+		"flag"
+		"io"
+		"net/http"
+		"net/http/httptest"
+		"net/url"
+		"os"
+		"strings"
+		"testing"
+
+)
+
+func TestMain (m *testing.M) {
+	dir, err := os.MkdirTemp("", "wotWizard"); M.Assert(err == nil, err, 100)
+	BA.Configure(dir, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Handler dispatching to a test server for each of names, which answers with its name and the path of the request
+func testHandler (t *testing.T, names ... string) http.HandlerFunc {
+	servers := make(map[string]*server)
+	list := make([]*server, len(names))
+	for i, name := range names {
+		name := name
+		ts := httptest.NewServer(http.HandlerFunc(func (w http.ResponseWriter, req *http.Request) {
+			io.WriteString(w, name + " " + req.URL.Path)
+		}))
+		t.Cleanup(ts.Close)
+		u, err := url.Parse(ts.URL); M.Assert(err == nil, err, 100)
+		s := &server{cur: currencyT{Name: name, Address: u.Host}, proxy: H.NewSingleHostReverseProxy(u)}
+		servers[name] = s
+		list[i] = s
+	}
+	return makeHandler(servers, list)
+} //testHandler
+
+func TestDispatch (t *testing.T) {
+	h := testHandler(t, "g1", "g1-test")
+	for _, x := range []struct {method, target, body string; status int; answer string} {
+		{"POST", "/", `{"query": "{now{number}}"}`, http.StatusOK, "g1 /"},
+		{"POST", "/g1-test", `{"query": "{now{number}}"}`, http.StatusOK, "g1-test /"},
+		{"POST", "/?currency=g1-test", `{"query": "{now{number}}"}`, http.StatusOK, "g1-test /"},
+		{"POST", "/", `{"currency": "g1-test", "query": "{now{number}}"}`, http.StatusOK, "g1-test /"},
+		{"POST", "/g2", `{"query": "{now{number}}"}`, http.StatusNotFound, ""},
+		{"POST", "/?currency=g2", `{"query": "{now{number}}"}`, http.StatusNotFound, ""},
+		{"POST", "/", `{"currency": "g2", "query": "{now{number}}"}`, http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(x.method, x.target, strings.NewReader(x.body)))
+		M.Want(w.Code == x.status, t)
+		if x.status == http.StatusOK {
+			M.Want(w.Body.String() == x.answer, t)
+		} else {
+			var r errorsT
+			M.Want(J.Unmarshal(w.Body.Bytes(), &r) == nil, t)
+			M.Want(len(r.Errors) == 1 && r.Errors[0].Message == "Unknown currency g2", t)
+		}
+	}
+}
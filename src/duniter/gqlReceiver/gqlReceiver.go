@@ -55,6 +55,7 @@ type (
 		variableValues *A.Tree
 		w http.ResponseWriter
 		c chan bool
+		lg *BA.Logger // Logger of the request, correlated by its id
 	}
 	
 	readSubsAction struct {
@@ -89,15 +90,15 @@ type (
 
 var (
 	
-	serverAddress = BA.ServerAddress()
-	
-	storeSubsPath = F.Join(BA.RsrcDir(), storeSubsFile)
+	// Set by setPaths
+	serverAddress,
+	storeSubsPath string
 	
-	lg = BA.Lg
+	lg = BA.NewLogger("gqlReceiver")
 	
 	ts *typeSystem
 	
-	typeSystemPath = F.Join(B.System(), typeSystemName)
+	typeSystemPath = typeSystemName // Read at initialization, before the configuration, and provided by duniter/static, which recognizes its name
 	
 	responseStreamsByDoc = make(responseStreamers)
 	responseStreamsByAddr = make(responseStreamers)
@@ -106,12 +107,12 @@ var (
 
 )
 
-func printErrors (errors *A.Tree) {
+func printErrors (l *BA.Logger, errors *A.Tree) {
 	e := errors.Next(nil)
 	for e != nil {
 		el := e.Val().(*G.ErrorElem)
 		var b strings.Builder
-		b.WriteString("***ERROR*** " + el.Message)
+		b.WriteString(el.Message)
 		if el.Location != nil {
 			b.WriteString(" at ")
 			b.WriteString(SC.Itoa(el.Location.Line))
@@ -135,7 +136,7 @@ func printErrors (errors *A.Tree) {
 				b.WriteString(".")
 			}
 		}
-		lg.Println(b.String())
+		l.Error(b.String())
 		e = errors.Next(e)
 	}
 } //printErrors
@@ -154,7 +155,7 @@ func (a *action) Activate () {
 	r := a.es.Execute(a.doc, a.opName, a.variableValues)
 	errors := r.Errors()
 	if errors != nil {
-		printErrors(errors)
+		printErrors(a.lg, errors)
 	}
 	switch r := r.(type) {
 	case *G.InstantResponse:
@@ -174,6 +175,10 @@ func (a *action) Activate () {
 	a.c <- true
 } //Activate
 
+func (a *action) LogId () string {
+	return a.lg.Id()
+} //LogId
+
 func (a *action) Name () string {
 	if a.opName == "" {
 		return "anonymous"
@@ -226,7 +231,7 @@ func unsubscribe (returnAddr, streamName string, varVals J.Json) {
 func (rs *responseStreamer) ManageResponseEvent (r G.Response) {
 	errors := r.Errors()
 	if errors != nil {
-		printErrors(errors)
+		printErrors(lg.In(B.UpdateId()), errors)
 	}
 	j := G.ResponseToJson(r)
 	mk := J.NewMaker()
@@ -304,6 +309,8 @@ func makeHandler (newAction chan<- B.Actioner) func (w http.ResponseWriter, req
 	
 	return func (w http.ResponseWriter, req *http.Request) {
 		
+		l := lg.In(BA.NewId("q"))
+		
 		writeError := func (err error) {
 			m := J.NewMaker()
 			m.StartObject()
@@ -311,7 +318,7 @@ func makeHandler (newAction chan<- B.Actioner) func (w http.ResponseWriter, req
 			m.BuildField("errors")
 			m.BuildObject()
 			m.GetJson().Write(w)
-			lg.Println("***ERROR*** ", err)
+			l.Error(err)
 		}
 		
 		j, variableValues, opName, returnAddr, docS, error := readOpNameVars (req)
@@ -323,7 +330,7 @@ func makeHandler (newAction chan<- B.Actioner) func (w http.ResponseWriter, req
 		if doc == nil {
 			err := r.Errors()
 			M.Assert(!err.IsEmpty(), 102)
-			printErrors(err)
+			printErrors(l, err)
 			G.ResponseToJson(r).Write(w)
 			return
 		}
@@ -333,7 +340,7 @@ func makeHandler (newAction chan<- B.Actioner) func (w http.ResponseWriter, req
 		es := ts.ExecValidate(doc)
 		err := es.GetErrors()
 		if !err.IsEmpty() {
-			printErrors(err)
+			printErrors(l, err)
 			r := new(G.InstantResponse)
 			r.SetErrors(err)
 			G.ResponseToJson(r).Write(w)
@@ -363,7 +370,7 @@ func makeHandler (newAction chan<- B.Actioner) func (w http.ResponseWriter, req
 			responseStreamsByAddr[buildResponseStreamerByAddrKey(returnAddr, opName, j)] = rs
 			mapM.Unlock()
 		}
-		a := &action{es: es, doc: doc, opName: opName, varVals: j, variableValues: variableValues, w: w, c: make(chan bool)}
+		a := &action{es: es, doc: doc, opName: opName, varVals: j, variableValues: variableValues, w: w, c: make(chan bool), lg: l}
 		newAction <- a
 		<- a.c
 	}
@@ -402,7 +409,7 @@ func storeSubs () {
 func readSubs () {
 	f, err := os.Open(storeSubsPath)
 	if err != nil {
-		lg.Println(err)
+		lg.Warn(err)
 		return
 	}
 	defer f.Close()
@@ -607,14 +614,14 @@ func initAll () {
 	doc, r := G.ReadGraphQL(typeSystemPath)
 	err := r.Errors()
 	if !err.IsEmpty() {
-		printErrors(err)
+		printErrors(lg, err)
 		M.Halt(100)
 	}
 	M.Assert(doc != nil, 101)
 	if G.ExecutableDefinitions(doc) {
 		ts.Error("NoTypeSystemInDoc", "", "", nil, nil)
 		err := ts.GetErrors()
-		printErrors(err)
+		printErrors(lg, err)
 		M.Halt(102)
 	}
 	tsRead := false
@@ -629,13 +636,20 @@ func initAll () {
 	tsRead = ts.GetErrors().IsEmpty()
 	if !tsRead {
 		err := ts.GetErrors()
-		printErrors(err)
+		printErrors(lg, err)
 		M.Halt(103)
 	}
 } //initAll
 
+// Fix the address of the server and the path of the stored subscriptions from the configuration
+func setPaths () {
+	serverAddress = BA.ServerAddress()
+	storeSubsPath = F.Join(BA.RsrcDir(), storeSubsFile)
+} //setPaths
+
 func init () {
 	initAll()
+	BA.AddConfigProc(setPaths)
 } //init
 
 func Wrap (i ...interface{}) *G.OutputObjectValue {
@@ -662,3 +676,33 @@ func Unwrap (rootValue *G.OutputObjectValue, n int) interface{} {
 		return nil
 	}
 } //Unwrap
+
+// Number of values wrapped in rootValue
+func WrappedNb (rootValue *G.OutputObjectValue) int {
+	n := 0
+	for f := rootValue.First(); f != nil; f = rootValue.Next(f) {
+		n++
+	}
+	return n
+} //WrappedNb
+
+// Error returned by the fields whose argument 'atBlock' is not in the blockchain
+func UnknownBlock () *G.ErrorValue {
+	return G.MakeErrorValue("atBlock: this block is not in the blockchain")
+} //UnknownBlock
+
+// Read the optional argument 'atBlock'; given == false if it's absent or null; ok == false if the block is not in the blockchain (see UnknownBlock)
+func AtBlock (argumentValues *A.Tree) (past *B.Past, given, ok bool) {
+	var v G.Value
+	if G.GetValue(argumentValues, "atBlock", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			given = true
+			past, ok = B.PastAt(int32(v.Int))
+		case *G.NullValue:
+		default:
+			M.Halt(v, 100)
+		}
+	}
+	return
+} //AtBlock
@@ -0,0 +1,133 @@
+package identities
+
+import (
+
+	A	"util/avl"
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	F	"path/filepath"
+	G	"util/graphQL"
+	GQ	"duniter/gqlReceiver"
+	J	"encoding/json"
+	M	"util/misc"
+	S	"duniter/sandbox"
+	_	"duniter/certifications"
+	_	"duniter/sentries"
+	_	"duniter/static"
+	_	"util/graphQL/static"
+	_	"util/json/static"
+		"flag"
+		"os"
+		"reflect"
+		"strings"
+		"testing"
+
+)
+
+const (
+
+	// Hash of xavier, member of the fixture expiry from block 0, certified by alice and bob only, and excluded at block 53
+	xavier = "D65D9E91A4A10932B362B5FFA4EF67D0762D7F895AE4A882F8EC05DA061F93B8"
+	
+	// Block 101 added to the fixture expiry, where all the certifications of the block 0 have expired and are removed
+	expiredBlock = `{"number": 101, "hash": "00000000000000000000000000000000000000000000000000000000E0000065", "medianTime": 1552102328, "time": 1552102333, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": []}`
+
+)
+
+type (
+
+	// GraphQL response
+	response struct {
+		Data interface{}
+		Errors []struct {
+			Message string
+			Path []interface{}
+		}
+	}
+
+)
+
+func TestMain (m *testing.M) {
+	dir, err := os.MkdirTemp("", "wotWizard"); M.Assert(err == nil, err, 100)
+	BA.Configure(dir, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	B.Initialize()
+	S.Initialize()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Response to the GraphQL request query
+func execute (query string) (r response) {
+	doc, rr := G.ReadString(query)
+	M.Assert(doc != nil && rr.Errors().IsEmpty(), query, 100)
+	es := GQ.TS().ExecValidate(doc)
+	M.Assert(es.GetErrors().IsEmpty(), query, 101)
+	err := J.Unmarshal([]byte(G.ResponseToJson(es.Execute(doc, "", A.New())).GetFlatString()), &r); M.Assert(err == nil, err, 102)
+	return
+} //execute
+
+// Does the response to query hold the data data, given as JSON, and no error?
+func wantData (t *testing.T, query, data string) {
+	r := execute(query)
+	var d interface{}
+	err := J.Unmarshal([]byte(data), &d); M.Assert(err == nil, err, 100)
+	if len(r.Errors) != 0 || !reflect.DeepEqual(r.Data, d) {
+		t.Errorf("%s: got %v %v, want %s", query, r.Data, r.Errors, data)
+	}
+} //wantData
+
+func TestAtBlock (t *testing.T) {
+	buf, err := os.ReadFile(F.Join("..", "..", "..", "rsrc", "duniter", "Fixtures", "expiry", "blocks.jsonl")); M.Assert(err == nil, err, 100)
+	dir := t.TempDir()
+	err = os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(strings.TrimSpace(string(buf)) + "\n" + expiredBlock + "\n"), 0644); M.Assert(err == nil, err, 101)
+	B.UpdateOffline(dir, func () {
+		M.Want(B.LastBlock() == 101, t)
+
+		// Present: xavier is revoked, and the certifications he received have expired and been removed
+		wantData(t, `{idFromHash(hash: "` + xavier + `"){uid status received_certifications{certifications{from{uid}}}}}`,
+			`{"idFromHash": {"uid": "xavier", "status": "REVOKED", "received_certifications": {"certifications": []}}}`)
+
+		// Block before the removal of the certifications of xavier, whose certifiers are sorted by pubkeys
+		wantData(t, `{idFromHash(hash: "` + xavier + `", atBlock: 10){uid status received_certifications{certifications{from{uid}}}}}`,
+			`{"idFromHash": {"uid": "xavier", "status": "MEMBER", "received_certifications": {"certifications": [{"from": {"uid": "bob"}}, {"from": {"uid": "alice"}}]}}}`)
+		// A past identity which had left is REVOKED if it's revoked now
+		wantData(t, `{idFromHash(hash: "` + xavier + `", atBlock: 100){status received_certifications{certifications{from{uid}}}}}`,
+			`{"idFromHash": {"status": "REVOKED", "received_certifications": {"certifications": [{"from": {"uid": "bob"}}, {"from": {"uid": "alice"}}]}}}`)
+		wantData(t, `{identities(status: MEMBER, atBlock: 10){uid}}`,
+			`{"identities": [{"uid": "alice"}, {"uid": "bob"}, {"uid": "carol"}, {"uid": "dave"}, {"uid": "erin"}, {"uid": "frank"}, {"uid": "xavier"}]}`)
+		wantData(t, `{identities(status: REVOKED, atBlock: 10){uid}}`, `{"identities": []}`)
+		wantData(t, `{identities(status: REVOKED){uid}}`, `{"identities": [{"uid": "xavier"}]}`)
+		wantData(t, `{identities(status: NEWCOMER, atBlock: 10){uid}}`, `{"identities": []}`)
+		p, ok := B.PastAt(10)
+		M.Want(ok, t)
+		r := execute(`{sentryThreshold(atBlock: 10) sentries(atBlock: 10){uid}}`)
+		M.Want(len(r.Errors) == 0, t)
+		if d, ok := r.Data.(map[string]interface{}); ok {
+			M.Want(d["sentryThreshold"] == float64(p.SentryThreshold()), t)
+			ss, ok := d["sentries"].([]interface{})
+			M.Want(ok && len(ss) == len(p.Sentries()), t)
+		} else {
+			t.Error("No data")
+		}
+
+		// Unknown block: the same error for every field; idFromHash is nullable, the other fields are not, and their null makes the data null
+		for _, x := range []struct {field, query string; null bool} {
+			{"identities", `{identities(atBlock: 1000){uid}}`, true},
+			{"idFromHash", `{idFromHash(hash: "` + xavier + `", atBlock: 1000){uid}}`, false},
+			{"sentryThreshold", `{sentryThreshold(atBlock: 1000)}`, true},
+			{"sentries", `{sentries(atBlock: -1){uid}}`, true},
+		} {
+			r := execute(x.query)
+			ok := len(r.Errors) == 1 && r.Errors[0].Message == "atBlock: this block is not in the blockchain" && len(r.Errors[0].Path) == 2 && r.Errors[0].Path[1] == x.field
+			if x.null {
+				ok = ok && r.Data == nil
+			} else {
+				ok = ok && reflect.DeepEqual(r.Data, map[string]interface{}{x.field: nil})
+			}
+			if !ok {
+				t.Errorf("%s: got %v %v", x.query, r.Data, r.Errors)
+			}
+		}
+	})
+}
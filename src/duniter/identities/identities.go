@@ -54,8 +54,50 @@ func insert (l *G.ListValue, hash B.Hash) {
 	l.Append(GQ.Wrap(hash))
 } //insert
 
-func listBC (f filter, sortedByPubkey bool, from, to string) *G.ListValue {
+// Wrap an identity seen at the block of past, or at present if past == nil
+func wrapAt (hash B.Hash, past *B.Past) *G.OutputObjectValue {
+	if past == nil {
+		return GQ.Wrap(hash)
+	}
+	return GQ.Wrap(hash, past)
+} //wrapAt
+
+// Web of trust where the Identity rootValue is seen; nil for the present
+func pastOf (rootValue *G.OutputObjectValue) *B.Past {
+	if GQ.WrappedNb(rootValue) > 1 {
+		if past, ok := GQ.Unwrap(rootValue, 1).(*B.Past); ok {
+			return past
+		}
+	}
+	return nil
+} //pastOf
+
+// Status of pubkey at the block of past, in the form used by filters; ok == false if the identity didn't exist then
+func statusAt (past *B.Past, pubkey B.Pubkey) (member bool, exp int64, ok bool) {
+	var revoked bool
+	member, revoked, ok = past.IdPub(pubkey)
+	if revoked {
+		exp = BA.Revoked
+	}
+	return
+} //statusAt
+
+// List of identities in the blockchain; at the block of past if past != nil
+func listBC (f filter, past *B.Past, sortedByPubkey bool, from, to string) *G.ListValue {
+	
 	l := G.NewListValue()
+	
+	test := func (pubkey B.Pubkey, member bool, hash B.Hash, exp int64) {
+		ok := true
+		if past != nil {
+			member, exp, ok = statusAt(past, pubkey)
+		}
+		if ok && f(member, exp) {
+			l.Append(wrapAt(hash, past))
+		}
+	} //test
+	
+	//listBC
 	if sortedByPubkey {
 		fromP := B.Pubkey(from)
 		toP := B.Pubkey(to)
@@ -63,19 +105,15 @@ func listBC (f filter, sortedByPubkey bool, from, to string) *G.ListValue {
 		pubkey, ok := B.IdNextPubkey(false, &ir)
 		for ok && (toP == "" || pubkey < toP)  {
 			_, member, hash, _, _, exp, b := B.IdPubComplete(pubkey); M.Assert(b, 100)
-			if f(member, exp) {
-				insert(l, hash)
-			}
+			test(pubkey, member, hash, exp)
 			pubkey, ok = B.IdNextPubkey(false, &ir)
 		}
 	} else {
 		ir := B.IdPosUid(from)
 		uid, ok := B.IdNextUid(false, &ir)
 		for ok && (to == "" || BA.CompP(uid, to) == BA.Lt) {
-			_, member, hash, _, _, exp, b := B.IdUidComplete(uid); M.Assert(b, 101)
-			if f(member, exp) {
-				insert(l, hash)
-			}
+			pubkey, member, hash, _, _, exp, b := B.IdUidComplete(uid); M.Assert(b, 101)
+			test(pubkey, member, hash, exp)
 			uid, ok = B.IdNextUid(false, &ir)
 		}
 	}
@@ -169,14 +207,34 @@ func getLimits (argumentValues *A.Tree) (from, to string) {
 	return
 } //getLimits
 
+// Certifiers of pubkey at the block of past, and pubkey itself if it was a member then
+func certifiersAt (past *B.Past, pubkey B.Pubkey) B.PubkeysT {
+	cs := past.Certifiers(pubkey)
+	certifiers := make(B.PubkeysT, 0, len(cs) + 1)
+	if member, _, _ := past.IdPub(pubkey); member {
+		certifiers = append(certifiers, pubkey)
+	}
+	for _, c := range cs {
+		certifiers = append(certifiers, c.P)
+	}
+	return certifiers
+} //certifiersAt
+
 func identitiesR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	order := getOrder(argumentValues)
 	from, to := getLimits(argumentValues)
 	status := getStatus(argumentValues)
+	past, given, ok := GQ.AtBlock(argumentValues)
+	if given && !ok {
+		return GQ.UnknownBlock()
+	}
+	if given && status == IS.Newcomer { // Past sandboxes are not kept
+		return G.NewListValue()
+	}
 	if status == IS.Newcomer {
 		return listSB(order, from, to)
 	} else {
-		return listBC(filters[status], order, from, to)
+		return listBC(filters[status], past, order, from, to)
 	}
 } //identitiesR
 
@@ -228,6 +286,20 @@ func idFromHashR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Valu
 	switch h := v.(type) {
 	case *G.StringValue:
 		hash := B.Hash(h.String.S)
+		if past, given, ok := GQ.AtBlock(argumentValues); given {
+			if !ok {
+				return GQ.UnknownBlock()
+			}
+			var pubkey B.Pubkey
+			pubkey, ok = B.IdHash(hash)
+			if ok {
+				_, _, ok = past.IdPub(pubkey)
+			}
+			if !ok {
+				return G.MakeNullValue()
+			}
+			return GQ.Wrap(hash, past)
+		}
 		_, ok := B.IdHash(hash)
 		if !ok {
 			_, _, _, _, _, ok = S.IdHash(hash)
@@ -339,7 +411,10 @@ func identityHashR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Va
 func identityStatusR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
-		_, _, _, _, exp, inBC, member, ok := IS.Get(hash); M.Assert(ok, 100)
+		_, pub, _, _, exp, inBC, member, ok := IS.Get(hash); M.Assert(ok, 100)
+		if past := pastOf(rootValue); past != nil {
+			member, exp, ok = statusAt(past, pub); M.Assert(ok, 101)
+		}
 		var s string
 		if !inBC {
 			s = "NEWCOMER"
@@ -478,6 +553,9 @@ func identitySentryR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
 		_, pub, _, _, _, _, member, ok := IS.Get(hash); M.Assert(ok, 100)
+		if past := pastOf(rootValue); past != nil {
+			return G.MakeBooleanValue(past.IsSentry(pub))
+		}
 		if member {
 			return G.MakeBooleanValue(B.IsSentry(pub))
 		} else {
@@ -495,6 +573,14 @@ func identityRecCertsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree)
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
 		_, pub, _, _, _, inBC, _, ok := IS.Get(hash); M.Assert(ok, 100)
+		if past := pastOf(rootValue); past != nil {
+			l := G.NewListValue()
+			for _, pc := range past.Certifiers(pub) {
+				_, _, h, _, _, _, b := B.IdPubComplete(pc.P); M.Assert(b, 101)
+				l.Append(GQ.Wrap(h, hash, false, past, pc))
+			}
+			return GQ.Wrap(l, int64(-1)) // Past limits are unknown
+		}
 		_, _, limit, certifiers, _ := IS.RecCerts(hash, pub, inBC)
 		l := G.NewListValue()
 		e := certifiers.Next(nil)
@@ -516,6 +602,14 @@ func identitySentCertsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree)
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
 		_, pub, _, _, _, inBC, _, ok := IS.Get(hash); M.Assert(ok, 100)
+		if past := pastOf(rootValue); past != nil {
+			l := G.NewListValue()
+			for _, pc := range past.Certified(pub) {
+				_, _, h, _, _, _, b := B.IdPubComplete(pc.P); M.Assert(b, 101)
+				l.Append(GQ.Wrap(hash, h, false, past, pc))
+			}
+			return l
+		}
 		_, _, certified := IS.SentCerts(hash, pub, inBC)
 		l := G.NewListValue()
 		e := certified.Next(nil)
@@ -665,6 +759,10 @@ func identityDistanceR (rootValue *G.OutputObjectValue, argumentValues *A.Tree)
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
 		_, pub, _, _, _, inBC, member, ok := IS.Get(hash); M.Assert(ok, 100)
+		if past := pastOf(rootValue); past != nil {
+			dist := past.Distance(certifiersAt(past, pub))
+			return GQ.Wrap(dist, dist >= B.Pars().Xpercent)
+		}
 		_, _, _, _, certifiers := IS.RecCerts(hash, pub, inBC)
 		dist, distOk := IS.NotTooFar(pub, member, certifiers)
 		return GQ.Wrap(dist, distOk)
@@ -680,6 +778,9 @@ func identityQualityR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G
 	switch hash := GQ.Unwrap(rootValue, 0).(type) {
 	case B.Hash:
 		_, pub, _, _, _, inBC, member, ok := IS.Get(hash); M.Assert(ok, 100)
+		if past := pastOf(rootValue); past != nil {
+			return G.MakeFloat64Value(past.Quality(certifiersAt(past, pub)) * 100)
+		}
 		_, _, _, _, certifiers := IS.RecCerts(hash, pub, inBC)
 		return G.MakeFloat64Value(IS.CalcQuality(pub, member, certifiers) * 100)
 	case *G.NullValue:
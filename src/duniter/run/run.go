@@ -22,12 +22,15 @@ import (
 	A	"util/avl"
 	B	"duniter/blockchain"
 	BA	"duniter/basic"
+	CU	"duniter/currencies"
 	G	"util/graphQL"
 	GQ	"duniter/gqlReceiver"
 	S	"duniter/sandbox"
+	W	"duniter/wotWizard"
 	
 	_	"duniter/blocks"
 	_	"duniter/certifications"
+	_	"duniter/changes"
 	_	"duniter/events"
 	_	"duniter/history"
 	_	"duniter/identities"
@@ -37,6 +40,7 @@ import (
 	_	"duniter/wotWizardList"
 	
 		"fmt"
+		"os"
 
 )
 
@@ -48,9 +52,59 @@ const (
 
 func Start () {
 	fmt.Println("WotWizard version", version, "\n")
-	BA.Lg.Println("WotWizard version", version, "\n")
+	BA.NewLogger("run").Println("WotWizard version", version, "\n")
+	if BA.Currencies != "" {
+		CU.Start()
+		return
+	}
+	if BA.RestoreBackup != "" {
+		report, ok := B.RestoreBackup(BA.RestoreBackup)
+		for _, s := range report {
+			fmt.Println(s)
+		}
+		if ok {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+	if BA.ExportSnapshot != "" {
+		B.ExportSnapshot(BA.ExportSnapshot)
+		fmt.Println("Snapshot written into", BA.ExportSnapshot)
+		os.Exit(0)
+	}
+	if BA.ImportSnapshot != "" {
+		faults, ok := B.ImportSnapshot(BA.ImportSnapshot)
+		for _, s := range faults {
+			fmt.Println(s)
+		}
+		if ok {
+			fmt.Println("Snapshot", BA.ImportSnapshot, "imported")
+			os.Exit(0)
+		}
+		fmt.Println("Snapshot", BA.ImportSnapshot, "imported, but the database is faulty")
+		os.Exit(1)
+	}
+	if BA.CheckBase {
+		faults, ok := B.CheckBase(BA.RepairBase)
+		for _, s := range faults {
+			fmt.Println(s)
+		}
+		if ok {
+			fmt.Println("Database sound")
+			os.Exit(0)
+		}
+		fmt.Println("Database faulty")
+		os.Exit(1)
+	}
+	if BA.Backtest {
+		for _, s := range W.Backtest() {
+			fmt.Println(s)
+		}
+		os.Exit(0)
+	}
 	B.Initialize()
 	S.Initialize()
+	W.Initialize()
 	GQ.Start()
 }
 
@@ -22,6 +22,7 @@ import (
 	J	"util/json"
 	M	"util/misc"
 	Q	"database/sql"
+		"crypto/sha256"
 		"os"
 		"strings"
 	_	"github.com/mattn/go-sqlite3"
@@ -98,6 +99,7 @@ type (
 		Block int
 		Date int64
 		Identities []Identity
+		Renewals []Identity
 		Certifications []Certification
 	}
 	
@@ -105,14 +107,17 @@ type (
 
 var (
 	
-	sBase = B.SBase()
-	
 	// AVL trees
 	idUidT, // uid -> identity
 	idPubT, // pubkey -> identity
 	idHashT, // hash -> identity
+	renewT, // hash -> membership renewal of a member (as an identity with inBC = true)
 	certFromT, // from -> certification
 	certToT *A.Tree // toHash -> certification
+	
+	// Checksum of the content of the sandbox at the last export, and number of its changes
+	contentSum [sha256.Size]byte
+	version int
 
 )
 
@@ -206,6 +211,11 @@ func IdHash (hash Hash) (inBC bool, pubkey Pubkey, uid string, bnb int32, expire
 	return
 } //IdHash
 
+// Number of changes of the content of the sandbox since the start of the program, so that its users can know whether it changed since they read it
+func Version () int {
+	return version
+} //Version
+
 // Number of identities
 func IdLen () int {
 	return idHashT.NumberOfElems()
@@ -275,6 +285,22 @@ func IdNextHash (first bool, pos **A.Elem) (hash Hash, ok bool) {
 	return
 } //IdNextHash
 
+// hash of a member -> her pending membership renewal: number of the block of its blockstamp and its expiration date
+func Renewal (hash Hash) (bnb int32, expires_on int64, ok bool) {
+	e, ok, _ := renewT.Search(&idHashE{&identity{hash: hash}})
+	if ok {
+		r := e.Val().(*idHashE)
+		bnb = r.bnb
+		expires_on = r.expires_on
+	}
+	return
+} //Renewal
+
+// Number of pending membership renewals
+func RenewalsLen () int {
+	return renewT.NumberOfElems()
+} //RenewalsLen
+
 // (Pubkey, Hash) -> certification
 func certC (from Pubkey, toHash Hash) *certification {
 	c := &certification{from: from, toHash: toHash}
@@ -445,10 +471,10 @@ func pruneMembershipIds () {
 } //pruneMembershipIds
 
 // Scan the membership and the idty tables in the Duniter database and build idHashT, idPubT and idUidT; remove all items which reference a forked block
-func membershipIds (d *Q.DB) {
+func membershipIds (d *B.DuniterDB) {
+	sch := d.Schema()
 	// Membership applications
-	rows, err := d.Query("SELECT m.idtyHash, m.membership, m.issuer, m.number, m.userid, m.expires_on FROM membership m INNER JOIN block b ON m.blockHash = b.hash WHERE NOT b.fork ORDER BY m.blockNumber ASC")
-	M.Assert(err == nil, err, 100)
+	rows := d.Query("SELECT m.idtyHash, m.membership, m.issuer, m.number, m.userid, m.expires_on FROM membership m INNER JOIN block b ON m.blockHash = b.hash WHERE NOT " + sch.Fork("b") + " ORDER BY m.blockNumber ASC")
 	tr := A.New()
 	for rows.Next() {
 		var (
@@ -459,7 +485,7 @@ func membershipIds (d *Q.DB) {
 			uid string
 			expires_on int64
 		)
-		err = rows.Scan(&h, &inOrOut, &pubkey, &bnb, &uid, &expires_on)
+		err := rows.Scan(&h, &inOrOut, &pubkey, &bnb, &uid, &expires_on)
 		M.Assert(err == nil, err, 101)
 		M.Assert(h.Valid, 102); hash := h.String
 		id := &identity{hash: Hash(hash), expires_on: 0}
@@ -478,14 +504,20 @@ func membershipIds (d *Q.DB) {
 	if idHashT == nil {
 		idHashT = A.New()
 	}
+	renewT = A.New()
+	now := B.Now()
 	e := tr.Next(nil)
 	for e != nil { // For every membership applications
 		idH := e.Val().(*idHashE)
 		if p, ok := B.IdHash(idH.hash); ok { // If identity already in BC...
-			if uid, b, _, _, _, exp, ok := B.IdPubComplete(p); ok && !b && exp != BA.Revoked { // ... and if no more member but not revoked
+			if uid, b, _, _, app, exp, ok := B.IdPubComplete(p); ok && !b && exp != BA.Revoked { // ... and if no more member but not revoked
 				M.Assert(uid == idH.uid, 112)
 				id := &identity{inBC: true, hash: idH.hash, pubkey: p, uid: uid, bnb: idH.bnb, expires_on: M.Min64(M.Abs64(exp), idH.expires_on)}
 				idHashT.SearchIns(&idHashE{identity: id})
+			} else if ok && b && now < idH.expires_on && idH.bnb >= app { // ... or if member, membership renewal not written yet
+				M.Assert(uid == idH.uid, 114)
+				id := &identity{inBC: true, hash: idH.hash, pubkey: p, uid: uid, bnb: idH.bnb, expires_on: idH.expires_on}
+				renewT.SearchIns(&idHashE{identity: id})
 			}
 		} else {
 			_, ok := B.IdPub(idH.pubkey)
@@ -494,19 +526,19 @@ func membershipIds (d *Q.DB) {
 			}
 			if !ok { // Not in BC
 			// New identities
-				row := d.QueryRow("SELECT pubkey, uid, buid, expires_on FROM idty WHERE revocation_sig IS NULL AND hash = '" + string(idH.hash) + "'")
+				row := d.QueryRow("SELECT pubkey, uid, buid, expires_on FROM idty WHERE revocation_sig IS NULL AND hash = ?", string(idH.hash))
 				var (
 					pubkey,
 					uid,
 					buid string
 					e Q.NullInt64
 				)
-				err = row.Scan(&pubkey, &uid, &buid, &e)
+				err := row.Scan(&pubkey, &uid, &buid, &e)
 				M.Assert(err == nil || err == Q.ErrNoRows, err, 105)
 				if err == nil {
 					M.Assert(e.Valid, 106); expires_on := e.Int64
 					h := extractBlockId(buid)
-					row2 := d.QueryRow("SELECT fork FROM block WHERE hash = '" + string(h) + "'")
+					row2 := d.QueryRow("SELECT " + sch.Fork("b") + " FROM block b WHERE hash = ?", string(h))
 					var r bool
 					err = row2.Scan(&r)
 					M.Assert(err == nil, err, 108)
@@ -582,9 +614,8 @@ func pruneCertifications () {
 } //pruneCertifications
 
 // Builds certFromT and certToT from the Duniter database; remove all certifications where block_hash is in a fork
-func certifications (d *Q.DB) {
-	rows, err := d.Query("SELECT [from], [to], target, block_number, expires_on FROM cert INNER JOIN block ON cert.block_hash = block.hash WHERE NOT block.fork")
-	M.Assert(err == nil, err, 100)
+func certifications (d *B.DuniterDB) {
+	rows := d.Query("SELECT [from], [to], target, block_number, expires_on FROM cert INNER JOIN block b ON cert.block_hash = b.hash WHERE NOT " + d.Schema().Fork("b"))
 	now := B.Now()
 	if certFromT == nil {
 		certFromT = A.New(); certToT = A.New()
@@ -597,7 +628,8 @@ func certifications (d *Q.DB) {
 			bnb int32
 			e Q.NullInt64
 		)
-		err = rows.Scan(&f, &t, &h, &bnb, &e)
+		err := rows.Scan(&f, &t, &h, &bnb, &e)
+		M.Assert(err == nil, err, 102)
 		from := Pubkey(f)
 		to := Pubkey(t)
 		toHash := Hash(h)
@@ -652,6 +684,28 @@ func export () {
 	mk.BuildArray()
 	mk.BuildField("identities")
 	mk.StartArray()
+	e := renewT.Next(nil)
+	for e != nil {
+		r := e.Val().(*idHashE)
+		mk.StartObject()
+		mk.PushBoolean(r.inBC)
+		mk.BuildField("inBC")
+		mk.PushString(string(r.hash))
+		mk.BuildField("hash")
+		mk.PushString(string(r.pubkey))
+		mk.BuildField("pubkey")
+		mk.PushString(r.uid)
+		mk.BuildField("uid")
+		mk.PushInteger(int64(r.bnb))
+		mk.BuildField("bnb")
+		mk.PushInteger(r.expires_on)
+		mk.BuildField("expires_on")
+		mk.BuildObject()
+		e = renewT.Next(e)
+	}
+	mk.BuildArray()
+	mk.BuildField("renewals")
+	mk.StartArray()
 	var pos CertPos
 	ok = CertNextFrom(true, &pos, &el)
 	for ok {
@@ -677,17 +731,24 @@ func export () {
 	mk.BuildArray()
 	mk.BuildField("certifications")
 	mk.BuildObject()
-	f, err := os.Create(sBase); M.Assert(err == nil, err, 102)
-	mk.GetJson().Write(f)
+	j := mk.GetJson()
+	f, err := os.Create(B.SBase()); M.Assert(err == nil, err, 102)
+	j.Write(f)
+	sum := sha256.Sum256([]byte((&J.Object{Fields: j.(*J.Object).Fields[2:]}).GetFlatString())) // Without block and date
+	if sum != contentSum {
+		contentSum = sum
+		version++
+	}
 } //export
 
 func importSb (... interface{}) {
 	sd := new(SandboxData)
-	j := J.ReadFile(sBase); M.Assert(j != nil, 100)
+	j := J.ReadFile(B.SBase()); M.Assert(j != nil, 100)
 	J.ApplyTo(j, sd)
 	idUidT = A.New()
 	idPubT = A.New()
 	idHashT = A.New()
+	renewT = A.New()
 	certFromT = A.New()
 	certToT = A.New()
 	if sd.Identities != nil {
@@ -698,6 +759,12 @@ func importSb (... interface{}) {
 			_, b, _ = idPubT.SearchIns(&idPubE{identity: &id}); M.Assert(!b, 103)
 		}
 	}
+	if sd.Renewals != nil { // Absent from the files of the previous versions
+		for _, R := range sd.Renewals {
+			r := identity{inBC: R.InBC, hash: R.Hash, pubkey: R.Pubkey, uid: R.Uid, bnb: R.Bnb, expires_on: R.Expires_on}
+			_, b, _ := renewT.SearchIns(&idHashE{identity: &r}); M.Assert(!b, 104)
+		}
+	}
 	if sd.Certifications != nil {
 		for _, C := range sd.Certifications {
 			c := certification{from: C.From, to: C.To, toHash: C.ToHash, bnb: C.Bnb, expires_on: C.Expires_on}
@@ -712,20 +779,33 @@ func importSb (... interface{}) {
 			_, b, _ = e.Val().(*certToE).list.SearchIns(&certFromE{certification: &c}); M.Assert(!b, 108)
 		}
 	}
+	version++
 } //importSb
 
 // Scan the sandbox in the Duniter database
 func scan (... interface{}) {
-	BA.Lg.Println("Updating sandbox")
-	d, err := Q.Open(B.Driver(), BA.DuniBase)
-	M.Assert(err == nil, err, 100)
-	defer d.Close()
+	lg := BA.NewLogger("sandbox").In(B.UpdateId())
+	lg.Println("Updating sandbox")
+	var d *B.DuniterDB
+	path, ok := B.SqlBase()
+	if ok {
+		d = B.OpenDuniterDB(path)
+		defer d.Close()
+		ok = d.Schema().HasSandbox()
+	}
+	if !ok { // Other sources and some exports have no sandbox
+		idUidT = A.New(); idPubT = A.New(); idHashT = A.New(); renewT = A.New()
+		certFromT = A.New(); certToT = A.New()
+		export()
+		lg.Println("Sandbox empty")
+		return
+	}
 	pruneMembershipIds()
 	membershipIds(d)
 	pruneCertifications()
 	certifications(d)
 	export()
-	BA.Lg.Println("Sandbox updated")
+	lg.Println("Sandbox updated")
 } //scan
 
 func Initialize () {
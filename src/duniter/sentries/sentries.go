@@ -39,24 +39,50 @@ func (i1 *uid) Compare (i2 A.Comparer) A.Comp {
 }
 
 func sentryTR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	if past, given, ok := GQ.AtBlock(argumentValues); given {
+		if !ok {
+			return GQ.UnknownBlock()
+		}
+		return G.MakeIntValue(past.SentryThreshold())
+	}
 	return G.MakeIntValue(B.SentryThreshold())
 } //sentryTR
 
 func sentriesR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
-	l := G.NewListValue()
-	ids := A.New()
-	is := new(U.SetIterator)
-	pubkey, ok := B.NextSentry(true, &is)
-	for ok {
+	
+	insert := func (ids *A.Tree, pubkey B.Pubkey) {
 		var b bool
 		id := new(uid)
 		id.uid, _, id.hash, _, _, _, b = B.IdPubComplete(pubkey); M.Assert(b, 100)
 		_, b, _ = ids.SearchIns(id); M.Assert(!b, 101)
-		pubkey, ok = B.NextSentry(false, &is)
+	} //insert
+	
+	//sentriesR
+	l := G.NewListValue()
+	ids := A.New()
+	past, given, ok := GQ.AtBlock(argumentValues)
+	if given {
+		if !ok {
+			return GQ.UnknownBlock()
+		}
+		for _, pubkey := range past.Sentries() {
+			insert(ids, pubkey)
+		}
+	} else {
+		is := new(U.SetIterator)
+		pubkey, ok := B.NextSentry(true, &is)
+		for ok {
+			insert(ids, pubkey)
+			pubkey, ok = B.NextSentry(false, &is)
+		}
 	}
 	e := ids.Next(nil)
 	for e != nil {
-		l.Append(GQ.Wrap(e.Val().(*uid).hash))
+		if past == nil {
+			l.Append(GQ.Wrap(e.Val().(*uid).hash))
+		} else {
+			l.Append(GQ.Wrap(e.Val().(*uid).hash, past))
+		}
 		e = ids.Next(e)
 	}
 	return l
@@ -19,23 +19,29 @@ var (
 
 type Query {
 	
-	"'identities' lists all identities whose status is 'status' and whose uids is between 'start' (included) and 'end' (excluded), in increasing order and sorted by 'sortedBy'; if 'start' is absent or null, the list starts at the beginning, and stops at the end if 'end' is absent or null"
-	identities (status: Identity_Status! = MEMBER, sortedBy: Identity_Order! = UID, start: String! = "", end: String! = ""): [Identity!]!
+	"'identities' lists all identities whose status is 'status' and whose uids is between 'start' (included) and 'end' (excluded), in increasing order and sorted by 'sortedBy'; if 'start' is absent or null, the list starts at the beginning, and stops at the end if 'end' is absent or null; if 'atBlock' is present and not null, the statuses are those of the web of trust at block number 'atBlock' (see 'Identity'), and the list is empty for NEWCOMER; error if this block doesn't exist"
+	identities (status: Identity_Status! = MEMBER, sortedBy: Identity_Order! = UID, start: String! = "", end: String! = "", atBlock: Int): [Identity!]!
 	
 	"'idSearch' displays the list of identities whose pseudos or public keys begin with 'with.hint' and whose status is in 'with.status_list'."
 	idSearch (with: IdSearchInput! = {}): IdSearchOutput!
 	
-	"'idFromHash' retreives the 'Identity' whose hash is 'hash'; it returns null if this identity doesn't exist"
-	idFromHash (hash: Hash!): Identity
+	"'idFromHash' retreives the 'Identity' whose hash is 'hash'; it returns null if this identity doesn't exist; if 'atBlock' is present and not null, the identity is seen in the web of trust at block number 'atBlock', and null is returned if it didn't exist then (error if this block doesn't exist)"
+	idFromHash (hash: Hash!, atBlock: Int): Identity
 	
-	"Threshold for numbers of sent and received certifications to become sentry"
-	sentryThreshold: Int!
+	"Threshold for numbers of sent and received certifications to become sentry; at block number 'atBlock' if 'atBlock' is present and not null (error if this block doesn't exist)"
+	sentryThreshold (atBlock: Int): Int!
 	
-	"List of sentries, sorted by increasing uids"
-	sentries: [Identity!]!
+	"List of sentries, sorted by increasing uids; at block number 'atBlock' if 'atBlock' is present and not null (error if this block doesn't exist)"
+	sentries (atBlock: Int): [Identity!]!
 	
 	"Present block"
 	now: Block!
+	
+	"Last error met while reading the Duniter database since the start of the server; null if none"
+	lastIngestionError: IngestionError
+	
+	"Changes of the web of trust written in the blocks from 'fromBlock' to 'toBlock' (default: the last block), by increasing block numbers; blocks without changes are omitted, and so are the blocks before the last 'changesDepth' ones (setting of the server, 10000 by default), whose changes are no longer kept"
+	changes (fromBlock: Int! = 0, toBlock: Int): [BlockChanges!]!
 
 	"'wwFile' displays the WotWizard file, complete if 'full', or else with Dossier(s) containing at least 'Query.parameter(name: sigQty)' certifications only"
 	wwFile (full: Boolean! = false): File!
@@ -43,6 +49,18 @@ type Query {
 	"'wwResult' displays the content of the WotWizard window"
 	wwResult: WWResult!
 	
+	"'simulate' displays the content of the WotWizard window as it would be if the certifications 'extraCerts' and the membership applications 'extraMemberships' were added to the sandbox and if the certifications 'removedCerts' never reached the blockchain; the hypotheses Duniter would refuse (certification sent by a non-member, membership application of a member or of a revoked identity, ...) are ignored"
+	simulate (extraCerts: [HypoCertification!]! = [], extraMemberships: [HypoMembership!]! = [], removedCerts: [CertificationLink!]! = []): WWResult!
+	
+	"'suggestCertifiers' lists at most 'count' members whose certifications would raise the most the distance value of the NEWCOMER or MISSING identity of hash 'hash', i.e. the proportion of sentries reached by its certifiers (see 'ParameterName.xpercent'); members who don't raise it, who have already sent 'ParameterName.sigStock' certifications, or who can't certify before the expiration of the membership application because of 'ParameterName.sigPeriod', are left out"
+	suggestCertifiers (hash: Hash!, count: Int! = 10): [CertifierSuggestion!]!
+	
+	"'membersForecast' forecasts the exits of the members (expiration of their memberships or lack of certifications) and the renewals of their memberships during 'period' (infinite if absent or null), taking into account the membership applications and the certifications of the sandbox, and, with the entries of 'wwResult', the number of members"
+	membersForecast (period: Int64): MembersForecast!
+	
+	"'forecastAccuracy' compares the WotWizard forecasts recorded during 'period' before now (all of them if absent or null) with the actual entries: for each identity of a forecast which has entered since, the most probable date of entry of the forecast is compared with the date of the entry; the errors are distributed in bins of 'bin' seconds; a forecast is recorded at most every 'forecastEvery' (see the settings of the server)"
+	forecastAccuracy (period: Int64, bin: Int64! = 86400): ForecastAccuracy!
+	
 	"'memEnds' displays the list of members who are about to loose their memberships, in the order of event dates (bct); 'startFromNow' gives the period before the beginning of the list (0 if absent or null) , and 'period' gives the period covered by the list (infinite if absent or null)"
 	memEnds (startFromNow: Int64, period: Int64): [Identity!]!
 	
@@ -100,6 +118,9 @@ type Mutation {
 	
 	"'stopSubscription' erases the subscription whose name is 'name', which sends results at address 'returnAddr'; 'varVals' is a JSON object whose fields keys are the names of the variables (without '$') used in the subscription and whose fields values are their values"
 	stopSubscription (returnAddr: String!, name: String!, varVals: String): Void
+	
+	"'changeWotWizardSettings' changes the settings of the WotWizard engine for the next computations, until the server stops: 'maxSize' (in bytes; positive), 'timeBudget' (in seconds; 0 for no limit) and 'concurrencyWindow' (in seconds; 0 for the avgGenTime of the currency); the absent or null arguments leave their settings unchanged; 'token' must be the 'adminToken' setting of the server; returns the new settings, or null and an error naming 'token' or the argument at fault if 'adminToken' is empty, if 'token' is wrong or if an argument is out of range"
+	changeWotWizardSettings (token: String!, maxSize: Int64, timeBudget: Float, concurrencyWindow: Int64): WWSettings
 
 } #Mutation
 
@@ -122,10 +143,13 @@ type Subscription {
 	
 	"'certEnds' installs a subscription for the update of 'Query.certEnds' at every new block"
 	certEnds (startFromNow: Int64, period: Int64, missingIncluded: Boolean! = true): [Identity!]!
+	
+	"'changes' installs a subscription for the changes of the web of trust written in the blocks read at every update; the changes of the first of these blocks and of the following ones replace those sent before, if any (blockchain rollback); if an update reads more than 'changesDepth' blocks, only the changes of the last 'changesDepth' ones are sent"
+	changes: [BlockChanges!]!
 
 } #Subscription
 
-"WoT identity"
+"WoT identity; when seen at a past block (argument 'atBlock' of 'Query'), 'status', 'sentry', 'received_certifications', 'sent_certifications', 'distance' and 'quality' describe the web of trust at this block, and the other fields describe the present; a past identity is REVOKED if it is revoked now and had left the web of trust at this block, NEWCOMERs don't exist in the past, and 'received_certifications.limit' is null"
 type Identity {
 	
 	"Public key"
@@ -284,10 +308,10 @@ type Certification {
 	"Is certification in sandbox?"
 	pending: Boolean!
 	
-	"Registration block"
+	"Registration block; for a certification seen at a past block, beginning block of its uninterrupted validity period"
 	block: Block
 	
-	"Limit date (bct) of validity"
+	"Limit date (bct) of validity; for a certification seen at a past block, end date of its uninterrupted validity period, if it's over"
 	expires_on: Int64!
 	
 } #Certification
@@ -336,6 +360,83 @@ type CertEvent {
 
 } #CertEvent
 
+"Error met while reading the Duniter database"
+type IngestionError {
+	
+	"Number of the faulty block; null if unknown"
+	block: Int
+	
+	"Field of the block being read ('parameters', 'medianTime', 'expirations', 'joiners', 'actives', 'leavers', 'revoked', 'excluded' or 'certifications'), or 'source' if the database itself couldn't be read"
+	field: String!
+	
+	"Description of the error"
+	message: String!
+	
+	"Date of the error (utc)"
+	date: Int64!
+	
+	"The faulty entries of the block were skipped and reading went on with the rest of the block ('-onError quarantine' option); otherwise reading stopped before the block, which is checked again at each update; the block is checked before anything of it is written, so the WotWizard database is left at the last good block"
+	quarantined: Boolean!
+	
+	"Skipped entries, as 'field: entry: message', if quarantined; empty otherwise"
+	skipped: [String!]!
+	
+} #IngestionError
+
+"Changes of the web of trust written in a block"
+type BlockChanges {
+	
+	"The block"
+	block: Block!
+	
+	"Changes, in the order of their application"
+	changes: [Change!]!
+
+} #BlockChanges
+
+"Change of an identity or of a certification"
+type Change {
+	
+	"Kind of the change"
+	kind: ChangeKind!
+	
+	"Identity concerned, or certifier for certification changes"
+	identity: Identity!
+	
+	"Certified identity for certification changes; null otherwise"
+	certified: Identity
+
+} #Change
+
+"Kinds of 'Change'"
+enum ChangeKind {
+	
+	"The identity joined the web of trust, for the first time or not"
+	JOINED
+	
+	"The membership of the identity was renewed"
+	RENEWED
+	
+	"The identity asked to leave the web of trust"
+	LEFT
+	
+	"The identity was excluded from the web of trust"
+	EXCLUDED
+	
+	"The identity was revoked, explicitly or on expiration"
+	REVOKED
+	
+	"The certification was written for the first time"
+	CERT_WRITTEN
+	
+	"The certification was renewed"
+	CERT_REPLACED
+	
+	"The certification expired"
+	CERT_EXPIRED
+
+} #ChangeKind
+
 "Number & dates of a block"
 type Block {
 	
@@ -361,6 +462,42 @@ input DifferParams {
 	
 } #DifferParams
 
+"Hypothetical certification, for 'Query.simulate'"
+input HypoCertification {
+	
+	"Pubkey of the sender, who must be a member"
+	from: Pubkey!
+	
+	"Hash of the certified identity"
+	to: Hash!
+	
+	"Date (utc) of the sending of the certification; now if absent or null"
+	date: Int64
+	
+} #HypoCertification
+
+"Hypothetical membership application, for 'Query.simulate'"
+input HypoMembership {
+	
+	"Hash of the applicant identity, NEWCOMER or MISSING"
+	hash: Hash!
+	
+	"Date (utc) of the sending of the membership application; now if absent or null"
+	date: Int64
+	
+} #HypoMembership
+
+"Certification, in the blockchain or in the sandbox, supposed to never reach the blockchain, for 'Query.simulate'"
+input CertificationLink {
+	
+	"Pubkey of the sender"
+	from: Pubkey!
+	
+	"Hash of the certified identity"
+	to: Hash!
+	
+} #CertificationLink
+
 "Set of internal certifications and membership application dossiers available in sandbox"
 interface File {
 
@@ -434,9 +571,194 @@ type Dossier {
 	
 	"Expiration date"
 	limit: Int64!
+	
+	"Reasons delaying or preventing the entry of the newcomer, with the responsible certifiers and the dates when they clear"
+	diagnosis: [Blocking!]!
 
 } #Dossier
 
+"Reason delaying or preventing the entry of a 'Dossier'"
+type Blocking {
+	
+	reason: BlockingReason!
+	
+	"Certifier responsible for the delay, if any"
+	certifier: Identity
+	
+	"Date when the reason clears, or null if it doesn't clear by itself"
+	date: Int64
+
+} #Blocking
+
+"Kinds of 'Blocking'"
+enum BlockingReason {
+	
+	"The last membership application is more recent than 'ParameterName.msPeriod'"
+	MS_PERIOD
+	
+	"The certifier has sent a certification less than 'ParameterName.sigPeriod' ago"
+	SIG_PERIOD
+	
+	"The certifier has already sent 'ParameterName.sigStock' valid certifications; the date is the one when the first of them expires"
+	SIG_STOCK
+	
+	"Less than 'ParameterName.sigQty' certifications are available"
+	NOT_ENOUGH_CERTIFICATIONS
+	
+	"The available certifications don't fulfill the distance rule"
+	DISTANCE_RULE
+	
+	"The membership application expires before the entry date"
+	APPLICATION_EXPIRY
+
+} #BlockingReason
+
+"Member who could certify a newcomer, for 'Query.suggestCertifiers'"
+type CertifierSuggestion {
+	
+	certifier: Identity!
+	
+	"Distance value of the newcomer with this certification added to the available ones"
+	distance: Float!
+	
+	"Increase of the distance value brought by this certification"
+	gain: Float!
+	
+	"Date from which the certifier may send the certification"
+	date: Int64!
+
+} #CertifierSuggestion
+
+"Result of 'Query.membersForecast'; the certifications of the sandbox toward members are supposed to be written as soon as their senders may send them, in a random order when a sender has several of them, and the exits of members are supposed to have no effect on the certifications they sent"
+type MembersForecast {
+	
+	"Number of random draws of the orders of the certifications of the sandbox used to estimate the probabilities, or 0 if these orders don't matter"
+	samples_nb: Int!
+	
+	"Possible exits of members, sorted by dates"
+	exits: [MemberExit!]!
+	
+	"Possible renewals of memberships, sorted by dates"
+	renewals: [MembershipRenewal!]!
+	
+	"Expected numbers of members, at the present date and at every date when it changes, newcomers included"
+	members_count: [MembersCount!]!
+
+} #MembersForecast
+
+"Possible exit of a member"
+type MemberExit {
+	
+	member: Identity!
+	
+	"Date of the exit"
+	date: Int64!
+	
+	reason: ExitReason!
+	
+	"Probability of the exit"
+	proba: Float!
+	
+	"Half-width of the 95% confidence interval of 'proba' when it's estimated by sampling, 0 otherwise"
+	proba_margin: Float!
+
+} #MemberExit
+
+"Reasons of 'MemberExit'"
+enum ExitReason {
+	
+	"The membership expires without being renewed"
+	MEMBERSHIP_EXPIRY
+	
+	"Less than 'ParameterName.sigQty' certifications remain valid"
+	NOT_ENOUGH_CERTIFICATIONS
+
+} #ExitReason
+
+"Possible renewal of the membership of a member, who has a membership application in the sandbox"
+type MembershipRenewal {
+	
+	member: Identity!
+	
+	"Date of the renewal"
+	date: Int64!
+	
+	"New expiration date of the membership"
+	limit: Int64!
+	
+	"Probability of the renewal; the member may loose her certifications before"
+	proba: Float!
+	
+	"Half-width of the 95% confidence interval of 'proba' when it's estimated by sampling, 0 otherwise"
+	proba_margin: Float!
+
+} #MembershipRenewal
+
+"Expected number of members from 'date' on"
+type MembersCount {
+	
+	date: Int64!
+	
+	count: Float!
+
+} #MembersCount
+
+"Result of 'Query.forecastAccuracy'"
+type ForecastAccuracy {
+	
+	"Number of recorded forecasts compared"
+	forecasts_nb: Int!
+	
+	"Mean of the errors, in seconds, or null if no entry could be compared"
+	mean_error: Float
+	
+	"Mean of the absolute values of the errors, in seconds, or null if no entry could be compared"
+	mean_absolute_error: Float
+	
+	"Numbers of errors by bins, sorted by increasing errors; empty bins are left out"
+	distribution: [ErrorBin!]!
+	
+	"Compared entries, sorted by dates of forecasts, then by uids"
+	errors: [ForecastError!]!
+
+} #ForecastAccuracy
+
+"Comparison of the most probable date of entry of an identity in a recorded forecast with its actual entry"
+type ForecastError {
+	
+	member: Identity!
+	
+	"Last block when the forecast was computed"
+	forecast_block: Block!
+	
+	"Most probable date of entry, according to the forecast"
+	predicted_date: Int64!
+	
+	"The entry was predicted at 'predicted_date' or after"
+	after: Boolean!
+	
+	"Probability of 'predicted_date'"
+	proba: Float!
+	
+	"Block of the actual entry"
+	entry_block: Block!
+	
+	"'entry_block.bct' - 'predicted_date', in seconds"
+	error: Int64!
+
+} #ForecastError
+
+"Number of errors of 'ForecastAccuracy' between 'from' (included) and 'to' (excluded)"
+type ErrorBin {
+	
+	from: Int64!
+	
+	to: Int64!
+	
+	count: Int!
+
+} #ErrorBin
+
 "Result of 'Query.wwResult'"
 interface WWResult {
 	
@@ -452,6 +774,18 @@ interface WWResult {
 	"Number of internal certifications"
 	certifs_nb: Int!
 	
+	"Number of random draws of permutations used to estimate the probabilities, when the permutations are too many to be all computed (or always, according to the 'sampling' setting of the server); 0 if all the permutations were computed"
+	samples_nb: Int!
+	
+	"Settings of the WotWizard engine used for the computation"
+	settings: WWSettings!
+	
+	"True if some permutations were not computed because 'settings.max_size' was exceeded; according to the 'sampling' setting of the server, they were then drawn at random, or the entries which could not be computed are marked 'after'"
+	memory_budget_exceeded: Boolean!
+	
+	"True if some permutations were not computed because 'settings.time_budget' was exceeded; they were then treated as when 'settings.max_size' is exceeded"
+	time_budget_exceeded: Boolean!
+	
 	"'permutations' displays the list of WotWizard permutations; their number may be very big"
 	permutations: [WeightedPermutation!]!
 	
@@ -481,6 +815,18 @@ type WWResultS implements WWResult {
 	"Number of internal certifications"
 	certifs_nb: Int!
 	
+	"Number of random draws of permutations used to estimate the probabilities, when the permutations are too many to be all computed (or always, according to the 'sampling' setting of the server); 0 if all the permutations were computed"
+	samples_nb: Int!
+	
+	"Settings of the WotWizard engine used for the computation"
+	settings: WWSettings!
+	
+	"True if some permutations were not computed because 'settings.max_size' was exceeded; according to the 'sampling' setting of the server, they were then drawn at random, or the entries which could not be computed are marked 'after'"
+	memory_budget_exceeded: Boolean!
+	
+	"True if some permutations were not computed because 'settings.time_budget' was exceeded; they were then treated as when 'settings.max_size' is exceeded"
+	time_budget_exceeded: Boolean!
+	
 	"'permutations' displays the list of WotWizard permutations; their number may be very big"
 	permutations: [WeightedPermutation!]!
 	
@@ -492,6 +838,20 @@ type WWResultS implements WWResult {
 	
 } #WWResultS
 
+"Settings of the WotWizard engine"
+type WWSettings {
+	
+	"Greatest memory size, in bytes, allowed for the computation of the permutations of each independent cluster of dossiers"
+	max_size: Int64!
+	
+	"Greatest duration, in seconds, of the computation of the permutations of the WotWizard window; 0 if no limit"
+	time_budget: Float!
+	
+	"Dossiers whose dates differ by less than 'concurrency_window' seconds are concurrent, i.e. may enter in any order"
+	concurrency_window: Int64!
+
+} #WWSettings
+
 "A permutation weighted by a probability"
 type WeightedPermutation {
 	
@@ -530,6 +890,9 @@ type Forecast {
 	"Probability of the forecast"
 	proba: Float!
 	
+	"Half-width of the 95% confidence interval of 'proba' when it's estimated by sampling (see 'WWResult.samples_nb'); 0 if it's exact"
+	proba_margin: Float!
+	
 } #Forecast
 
 "Entry or exit of an identity"
@@ -0,0 +1,471 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package wotWizard
+
+// Accuracy of the forecasts of BuildEntries: they are recorded in forecastsDir, with the File they were computed from, at most every BA.ForecastEvery; the actual entries are read from the changelog of the blockchain at every update and kept in joinsPath; the most probable dates of the forecasts are compared with the actual entries, and the forecasts can be computed again from their Files (backtesting)
+
+import (
+	
+	A	"util/avl"
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	F	"path/filepath"
+	J	"encoding/json"
+	M	"util/misc"
+		"fmt"
+		"math"
+		"os"
+		"sort"
+		"sync"
+		"time"
+
+)
+
+const (
+	
+	// Directory of the recorded forecasts, in B.System()
+	forecastsDirName = "Forecasts"
+	// File of the actual entries, in B.System()
+	joinsName = "Joins.json"
+
+)
+
+type (
+	
+	// Recorded forecast of the entry of an identity at a date
+	Forecast struct {
+		Hash B.Hash
+		Id string
+		Date int64
+		After bool
+		Proba float64
+	}
+	
+	// Actual entry of an identity, first one or not
+	Join struct {
+		Hash B.Hash
+		Id string
+		Block int32
+		Date int64 // Median time of Block
+	}
+	
+	// Comparison of the most probable date of entry of an identity in a forecast with its actual entry
+	ForecastError struct {
+		Hash B.Hash
+		Id string
+		Block int32 // Last block when the forecast was computed
+		At int64 // Median time of Block
+		Predicted int64 // Most probable date of entry
+		After bool // The entry was predicted at Predicted or after
+		Proba float64 // Probability of Predicted
+		Join Join
+		Error int64 // Join.Date - Predicted
+	}
+	
+	// Number of errors e with From <= e < To
+	ErrorBin struct {
+		From,
+		To int64
+		Count int
+	}
+	
+	// Comparison of recorded forecasts with the actual entries
+	Accuracy struct {
+		Forecasts int // Number of forecasts compared
+		Errors []ForecastError // Sorted by dates of forecasts, then by ids
+		Mean, // Mean of the errors, in s
+		MeanAbs float64 // Mean of their absolute values, in s
+		Distribution []ErrorBin // Sorted by increasing errors; empty bins are left out
+	}
+	
+	// Recorded Certif
+	recCertif struct {
+		Date,
+		Limit int64
+		From,
+		To string
+		ToH B.Hash
+		FromP B.Pubkey
+	}
+	
+	// Recorded Dossier
+	recDossier struct {
+		Date,
+		MinDate,
+		Limit int64
+		Id string
+		Hash B.Hash
+		Pub B.Pubkey
+		PrincCertif int
+		ProportionOfSentries float64
+		Certifs []recCertif
+		Lost bool
+	}
+	
+	// Recorded element of a File; only one of the two fields is not nil
+	recCertOrDoss struct {
+		Certif *recCertif `json:",omitempty"`
+		Dossier *recDossier `json:",omitempty"`
+	}
+	
+	// Recorded forecast, with the File it was computed from
+	recForecast struct {
+		Block int32 // Last block when the forecast was computed
+		Date int64 // Median time of Block
+		Recorded int64 // Real time of the record, in s
+		Entries []Forecast
+		File []recCertOrDoss // nil in forecasts
+	}
+
+)
+
+var (
+	
+	// Set by setPaths
+	forecastsDir,
+	joinsPath string
+	
+	forecastsMut = new(sync.Mutex)
+	// Recorded forecasts, without their Files, sorted by blocks; nil if not read yet; under forecastsMut
+	forecasts []*recForecast
+	
+	// Actual entries, sorted by blocks; nil if not read yet; under forecastsMut
+	joins []Join
+
+)
+
+func forecastName (bnb int32) string {
+	return fmt.Sprintf("%09d.json", bnb)
+} //forecastName
+
+// Read the recorded forecast name; return nil if it can't be read
+func readForecast (name string, withFile bool) *recForecast {
+	bb, err := os.ReadFile(F.Join(forecastsDir, name))
+	if err != nil {
+		return nil
+	}
+	r := new(recForecast)
+	if J.Unmarshal(bb, r) != nil {
+		return nil
+	}
+	if !withFile {
+		r.File = nil
+	}
+	return r
+} //readForecast
+
+// Names of the files of the recorded forecasts, by increasing blocks
+func forecastNames () []string {
+	ds, err := os.ReadDir(forecastsDir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(ds))
+	for _, d := range ds {
+		if !d.IsDir() && F.Ext(d.Name()) == ".json" {
+			names = append(names, d.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+} //forecastNames
+
+// Read forecasts if it's not done yet; the caller must hold forecastsMut
+func loadForecasts () {
+	if forecasts != nil {
+		return
+	}
+	forecasts = make([]*recForecast, 0)
+	for _, name := range forecastNames() {
+		if r := readForecast(name, false); r != nil {
+			forecasts = append(forecasts, r)
+		}
+	}
+} //loadForecasts
+
+// Entries of occurDate, with elements of type PropDate
+func forecastsOf (occurDate *A.Tree) []Forecast {
+	fs := make([]Forecast, 0, occurDate.NumberOfElems())
+	e := occurDate.Next(nil)
+	for e != nil {
+		p := e.Val().(*PropDate)
+		fs = append(fs, Forecast{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After, Proba: p.Proba})
+		e = occurDate.Next(e)
+	}
+	return fs
+} //forecastsOf
+
+// File f -> recorded File
+func recordFile (f File) []recCertOrDoss {
+	
+	recC := func (c *Certif) recCertif {
+		return recCertif{Date: c.date, Limit: c.limit, From: *c.From, To: *c.To, ToH: *c.ToH, FromP: *c.fromP}
+	} //recC
+	
+	//recordFile
+	rf := make([]recCertOrDoss, len(f))
+	for i, cd := range f {
+		switch cd := cd.(type) {
+		case *Certif:
+			c := recC(cd)
+			rf[i].Certif = &c
+		case *Dossier:
+			d := &recDossier{Date: cd.date, MinDate: cd.MinDate, Limit: cd.limit, Id: *cd.Id, Hash: *cd.Hash, Pub: *cd.pub, PrincCertif: cd.PrincCertif, ProportionOfSentries: cd.ProportionOfSentries, Certifs: make([]recCertif, len(cd.Certifs)), Lost: cd.lost}
+			if math.IsNaN(d.ProportionOfSentries) { // No sentry; not representable in JSON
+				d.ProportionOfSentries = 0
+			}
+			for j, c := range cd.Certifs {
+				d.Certifs[j] = recC(c.(*Certif))
+			}
+			rf[i].Dossier = d
+		}
+	}
+	return rf
+} //recordFile
+
+// Recorded File rf -> File, whose distance rule is verified in wot
+func (r *recForecast) file (wot *B.Past) File {
+	
+	cert := func (c *recCertif, to Uid, toH Hash) *Certif {
+		cc := &Certif{date: c.Date, limit: c.Limit, From: new(string), To: to, ToH: toH, fromP: new(B.Pubkey)}
+		*cc.From = c.From
+		*cc.fromP = c.FromP
+		return cc
+	} //cert
+	
+	//file
+	f := make(File, len(r.File))
+	for i, cd := range r.File {
+		if cd.Certif != nil {
+			to := new(string); *to = cd.Certif.To
+			toH := new(B.Hash); *toH = cd.Certif.ToH
+			f[i] = cert(cd.Certif, to, toH)
+		} else {
+			rd := cd.Dossier
+			d := &Dossier{date: rd.Date, MinDate: rd.MinDate, limit: rd.Limit, Id: new(string), Hash: new(B.Hash), pub: new(B.Pubkey), PrincCertif: rd.PrincCertif, ProportionOfSentries: rd.ProportionOfSentries, Certifs: make(File, len(rd.Certifs)), lost: rd.Lost, wot: wot}
+			*d.Id = rd.Id; *d.Hash = rd.Hash; *d.pub = rd.Pub
+			for j := range rd.Certifs {
+				d.Certifs[j] = cert(&rd.Certifs[j], d.Id, d.Hash)
+			}
+			f[i] = d
+		}
+	}
+	return f
+} //file
+
+// Cmds
+// Record f and the forecast occurDate computed from it, if the last recorded forecast is older than BA.ForecastEvery and was computed at another block
+func recordForecast (f File, occurDate *A.Tree) {
+	if BA.ForecastEvery <= 0 {
+		return
+	}
+	forecastsMut.Lock()
+	defer forecastsMut.Unlock()
+	loadForecasts()
+	bnb := B.LastBlock()
+	if n := len(forecasts); n > 0 && (forecasts[n - 1].Block == bnb || time.Since(time.Unix(forecasts[n - 1].Recorded, 0)) < BA.ForecastEvery) {
+		return
+	}
+	r := &recForecast{Block: bnb, Date: B.Now(), Recorded: time.Now().Unix(), Entries: forecastsOf(occurDate), File: recordFile(f)}
+	bb, err := J.Marshal(r); M.Assert(err == nil, err, 100)
+	err = os.MkdirAll(forecastsDir, 0777); M.Assert(err == nil, err, 101)
+	err = os.WriteFile(F.Join(forecastsDir, forecastName(bnb)), bb, 0666); M.Assert(err == nil, err, 102)
+	r.File = nil
+	i := len(forecasts)
+	for i > 0 && forecasts[i - 1].Block >= bnb { // After a fork, bnb may be lower than the last recorded blocks
+		i--
+	}
+	if i < len(forecasts) && forecasts[i].Block == bnb {
+		forecasts[i] = r
+	} else {
+		forecasts = append(forecasts, nil)
+		copy(forecasts[i + 1:], forecasts[i:])
+		forecasts[i] = r
+	}
+} //recordForecast
+
+// Read joins if it's not done yet; return false if joinsPath doesn't exist yet; the caller must hold forecastsMut, unless the server is not running
+func loadJoins () bool {
+	if joins != nil {
+		return true
+	}
+	joins = make([]Join, 0)
+	bb, err := os.ReadFile(joinsPath)
+	if err != nil {
+		return false
+	}
+	err = J.Unmarshal(bb, &joins); M.Assert(err == nil, err, 100)
+	return true
+} //loadJoins
+
+// Updt
+// Replace, in joins, the entries of the blocks read by the last update
+func scanJoins (... interface{}) {
+	forecastsMut.Lock()
+	defer forecastsMut.Unlock()
+	from := B.ChangedFrom()
+	if !loadJoins() {
+		from = 0
+	}
+	i := len(joins)
+	for i > 0 && joins[i - 1].Block >= from {
+		i--
+	}
+	joins = joins[:i]
+	for _, bc := range B.Changes(from, B.LastBlock()) {
+		date, _, b := B.TimeOf(bc.Block); M.Assert(b, bc.Block, 100)
+		for _, c := range bc.Changes {
+			if c.Kind == B.Joined {
+				uid, _, hash, _, _, _, b := B.IdPubComplete(c.Pubkey); M.Assert(b, c.Pubkey, 101)
+				joins = append(joins, Join{Hash: hash, Id: uid, Block: bc.Block, Date: date})
+			}
+		}
+	}
+	bb, err := J.Marshal(joins); M.Assert(err == nil, err, 102)
+	err = os.WriteFile(joinsPath, bb, 0666); M.Assert(err == nil, err, 103)
+} //scanJoins
+
+// Compare the entries es forecast at block bnb, of median time at, with the actual entries of joins: for each identity, its most probable date of entry (BA.Never excepted) is compared with its first entry after bnb; identities which haven't entered yet are left out
+func compareEntries (bnb int32, at int64, es []Forecast) []ForecastError {
+	best := make(map[B.Hash]Forecast)
+	for _, e := range es {
+		if e.Date == BA.Never {
+			continue
+		}
+		if b, ok := best[e.Hash]; !ok || e.Proba > b.Proba || e.Proba == b.Proba && e.Date < b.Date {
+			best[e.Hash] = e
+		}
+	}
+	errs := make([]ForecastError, 0)
+	if len(best) == 0 {
+		return errs
+	}
+	i := sort.Search(len(joins), func (i int) bool {return joins[i].Block > bnb})
+	for ; i < len(joins); i++ {
+		j := joins[i]
+		if e, ok := best[j.Hash]; ok {
+			errs = append(errs, ForecastError{Hash: e.Hash, Id: e.Id, Block: bnb, At: at, Predicted: e.Date, After: e.After, Proba: e.Proba, Join: j, Error: j.Date - e.Date})
+			delete(best, j.Hash)
+		}
+	}
+	sort.Slice(errs, func (i, j int) bool {return BA.CompP(errs[i].Id, errs[j].Id) == BA.Lt})
+	return errs
+} //compareEntries
+
+// Mean and mean absolute value of the errors of errs, 0 if none
+func meanErrors (errs []ForecastError) (mean, meanAbs float64) {
+	if len(errs) == 0 {
+		return 0, 0
+	}
+	for _, e := range errs {
+		mean += float64(e.Error)
+		meanAbs += math.Abs(float64(e.Error))
+	}
+	n := float64(len(errs))
+	return mean / n, meanAbs / n
+} //meanErrors
+
+// Compare the forecasts recorded at median times from at least from with the actual entries; the errors are distributed in bins of width bin (at least 1 s)
+func ForecastAccuracy (from, bin int64) *Accuracy {
+	bin = M.Max64(bin, 1)
+	forecastsMut.Lock()
+	defer forecastsMut.Unlock()
+	loadForecasts()
+	loadJoins()
+	a := &Accuracy{Errors: make([]ForecastError, 0), Distribution: make([]ErrorBin, 0)}
+	for _, r := range forecasts {
+		if r.Date >= from && r.Block <= B.LastBlock() { // Forecasts of blocks removed by a fork are left out
+			a.Forecasts++
+			a.Errors = append(a.Errors, compareEntries(r.Block, r.Date, r.Entries)...)
+		}
+	}
+	a.Mean, a.MeanAbs = meanErrors(a.Errors)
+	counts := make(map[int64]int)
+	for _, e := range a.Errors {
+		k := e.Error / bin
+		if e.Error % bin < 0 {
+			k--
+		}
+		counts[k]++
+	}
+	for k, n := range counts {
+		a.Distribution = append(a.Distribution, ErrorBin{From: k * bin, To: (k + 1) * bin, Count: n})
+	}
+	sort.Slice(a.Distribution, func (i, j int) bool {return a.Distribution[i].From < a.Distribution[j].From})
+	return a
+} //ForecastAccuracy
+
+// Compute again the recorded forecasts from their Files, with the current settings and the web of trust of their blocks, compare them and the recorded ones with the actual entries, and return a report; the server must not be running
+func Backtest () (report []string) {
+	
+	days := func (s float64) string {
+		return fmt.Sprintf("%.1f d", s / 86400)
+	} //days
+	
+	//Backtest
+	names := forecastNames()
+	if len(names) == 0 {
+		return []string{"No recorded forecast in " + forecastsDir}
+	}
+	if !loadJoins() {
+		return []string{"No actual entry recorded in " + joinsPath}
+	}
+	B.Offline(func () {
+		allRec := make([]ForecastError, 0)
+		allNew := make([]ForecastError, 0)
+		for _, name := range names {
+			r := readForecast(name, true)
+			if r == nil {
+				report = append(report, "Forecast " + name + " unreadable")
+				continue
+			}
+			wot, ok := B.PastAt(r.Block)
+			if !ok {
+				report = append(report, fmt.Sprint("Forecast of block ", r.Block, " skipped: block not in the blockchain"))
+				continue
+			}
+			f := r.file(wot)
+			_, occurDate, _, samples, _ := calcEntries(f, r.Date)
+			errRec := compareEntries(r.Block, r.Date, r.Entries)
+			errNew := compareEntries(r.Block, r.Date, forecastsOf(occurDate))
+			allRec = append(allRec, errRec...)
+			allNew = append(allNew, errNew...)
+			_, absRec := meanErrors(errRec)
+			_, absNew := meanErrors(errNew)
+			s := fmt.Sprint("Block ", r.Block, " (", time.Unix(r.Date, 0).Local().Format("2/01/2006 15:04:05"), "): ", len(errRec), " entries compared, mean absolute error ", days(absRec), "; computed again: ", len(errNew), " entries compared, mean absolute error ", days(absNew))
+			if samples > 0 {
+				s += fmt.Sprint(" (", samples, " samples)")
+			}
+			report = append(report, s)
+		}
+		meanRec, absRec := meanErrors(allRec)
+		meanNew, absNew := meanErrors(allNew)
+		report = append(report, fmt.Sprint("Recorded forecasts: ", len(allRec), " entries compared, mean error ", days(meanRec), ", mean absolute error ", days(absRec)))
+		report = append(report, fmt.Sprint("Forecasts computed again: ", len(allNew), " entries compared, mean error ", days(meanNew), ", mean absolute error ", days(absNew)))
+	})
+	return
+} //Backtest
+
+func Initialize () {
+	B.AddUpdateProcUpdt(scanJoins)
+} //Initialize
+
+// Fix the paths of the recorded forecasts and entries, in the directory of the WotWizard database
+func setPaths () {
+	forecastsDir = F.Join(B.System(), forecastsDirName)
+	joinsPath = F.Join(B.System(), joinsName)
+} //setPaths
+
+func init () {
+	BA.AddConfigProc(setPaths)
+} //init
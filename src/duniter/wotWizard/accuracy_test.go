@@ -0,0 +1,95 @@
+package wotWizard
+
+import (
+
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+		"os"
+		"reflect"
+		"strings"
+		"testing"
+		"time"
+
+)
+
+// Start the test t without any recorded forecast or actual entry, and record every forecast computed at a new block
+func resetForecasts (t *testing.T) {
+	old := BA.ForecastEvery
+	BA.ForecastEvery = time.Nanosecond
+	clear := func () {
+		os.RemoveAll(forecastsDir)
+		os.Remove(joinsPath)
+		forecasts = nil
+		joins = nil
+	}
+	clear()
+	t.Cleanup(func () {BA.ForecastEvery = old; clear()})
+} //resetForecasts
+
+func TestCompareEntries (t *testing.T) {
+	resetForecasts(t)
+	joins = []Join{{Hash: "h1", Id: "a", Block: 5, Date: 1000}, {Hash: "h2", Id: "b", Block: 12, Date: 2000}, {Hash: "h1", Id: "a", Block: 15, Date: 3000}, {Hash: "h3", Id: "c", Block: 20, Date: 4000}}
+	es := []Forecast{
+		{Hash: "h1", Id: "a", Date: 2500, Proba: 0.3}, {Hash: "h1", Id: "a", Date: 2800, Proba: 0.7}, // The most probable date is kept
+		{Hash: "h2", Id: "b", Date: 2600, Proba: 0.4}, {Hash: "h2", Id: "b", Date: 2300, After: true, Proba: 0.4}, {Hash: "h2", Id: "b", Date: BA.Never, Proba: 0.2}, // The earliest one, in case of a tie
+		{Hash: "h3", Id: "c", Date: BA.Never, Proba: 1}, // Never entering: left out
+		{Hash: "h4", Id: "d", Date: 5000, Proba: 1}, // Not entered yet: left out
+	}
+	errs := compareEntries(10, 900, es)
+	want := []ForecastError{
+		{Hash: "h1", Id: "a", Block: 10, At: 900, Predicted: 2800, Proba: 0.7, Join: joins[2], Error: 200}, // The entry of the block 5 precedes the forecast
+		{Hash: "h2", Id: "b", Block: 10, At: 900, Predicted: 2300, After: true, Proba: 0.4, Join: joins[1], Error: -300},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Errorf("Got %+v, want %+v", errs, want)
+	}
+	mean, meanAbs := meanErrors(errs)
+	M.Want(mean == -50 && meanAbs == 250, t)
+	mean, meanAbs = meanErrors(nil)
+	M.Want(mean == 0 && meanAbs == 0, t)
+}
+
+func TestAccuracyAndBacktest (t *testing.T) {
+	setSettings(t, Settings{MaxSize: BA.MaxSize, Window: 300}, BA.SamplingNever)
+	resetForecasts(t)
+	const (
+		day = 86400
+		predicted = 1549467127 + 2 * day // Two days after the block 100
+		joined = 1549467427 // Median time of ginaBlock
+	)
+
+	// Forecast at the block 100 of the entry of gina, certified two days later
+	B.UpdateOffline(fixture("expiry"), func () {
+		d := dossier("gina", certif("alice", predicted, predicted + day), certif("bob", predicted, predicted + day), certif("carol", predicted, predicted + day))
+		*d.Hash = "0000000000000000000000000000000000000000000000000000000000006714"
+		M.Assert(d.fixPrinc(), 100)
+		f := File{d}
+		sortFile(f, 0)
+		_, occurDate, _, _, _ := calcEntries(f, B.Now())
+		recordForecast(f, occurDate)
+		recordForecast(f, occurDate) // Not recorded twice for the same block
+	})
+	M.Want(len(forecastNames()) == 1 && len(forecasts) == 1, t)
+
+	// gina joins in ginaBlock, two days minus 300 s before the forecast date
+	B.UpdateOffline(chain(t, ginaBlock), func () {
+		a := ForecastAccuracy(0, day)
+		M.Want(a.Forecasts == 1 && len(a.Errors) == 1, t)
+		e := a.Errors[0]
+		M.Want(e.Id == "gina" && e.Block == 100 && e.Predicted == predicted && e.Join.Block == 101 && e.Error == joined - predicted, t)
+		M.Want(a.Mean == joined - predicted && a.MeanAbs == predicted - joined, t)
+		M.Want(reflect.DeepEqual(a.Distribution, []ErrorBin{{From: -2 * day, To: - day, Count: 1}}), t) // Negative errors are rounded down
+		a = ForecastAccuracy(1549467127 + 1, day)
+		M.Want(a.Forecasts == 0 && len(a.Errors) == 0 && a.Mean == 0, t) // Forecasts recorded before from are left out
+	})
+
+	report := Backtest()
+	M.Want(len(report) == 3, t)
+	M.Want(strings.HasPrefix(report[0], "Block 100 (") && strings.HasSuffix(report[0], "): 1 entries compared, mean absolute error 2.0 d; computed again: 1 entries compared, mean absolute error 2.0 d"), t)
+	M.Want(report[1] == "Recorded forecasts: 1 entries compared, mean error -2.0 d, mean absolute error 2.0 d", t)
+	M.Want(report[2] == "Forecasts computed again: 1 entries compared, mean error -2.0 d, mean absolute error 2.0 d", t)
+	if t.Failed() {
+		t.Log(report)
+	}
+}
@@ -0,0 +1,400 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package wotWizard
+
+// Incremental computation: a File is split into independent clusters of Dossier(s), whose permutations are computed separately and kept in a cache, as long as neither their Dossier(s) nor the distances they used change; the File itself is extracted again only when the blockchain or the sandbox changed
+
+import (
+	
+	A	"util/avl"
+	B	"duniter/blockchain"
+	M	"util/misc"
+	S	"duniter/sandbox"
+		"fmt"
+		"math"
+		"strings"
+		"sync"
+		"time"
+
+)
+
+type (
+	
+	// Permutations of the entries of the Dossier(s) of a File, made of the permutations of its independent clusters: every combination of one permutation by cluster is a permutation of the File, whose probability is the product of theirs
+	Permutations struct {
+		clusters []*A.Tree // Sets of permutations of the clusters, with elements of type Set whose trees have elements of type Propagation; shared with the cache and never modified
+		now int64 // No entry can occur before now
+	}
+	
+	// State of the blockchain and of the sandbox; FillFile and the cache of permutations are valid as long as it doesn't change
+	stateT struct {
+		block int32
+		now int64
+		wot,
+		sandbox int
+	}
+	
+	// Result of FillFile, for a given minCertifs
+	filled struct {
+		f File
+		cNb,
+		dNb int
+	}
+	
+	// Distance computed by a distLog
+	distRec struct {
+		pubkeys B.PubkeysT
+		dist float64
+	}
+	
+	// Current web of trust, which records the distances computed during the computation of the permutations of a cluster, so that their changes can be detected later; safe for concurrent use
+	distLog struct {
+		mut sync.Mutex
+		d map[string] distRec
+	}
+	
+	// Permutations of a cluster in the cache
+	cached struct {
+		sets *A.Tree
+		samples int
+		memoryExceeded bool
+		dists *distLog
+		checked, // Number of the state where dists were found unchanged for the last time
+		used int // Number of the state where the permutations were used for the last time
+	}
+
+)
+
+var (
+	
+	fileMut = new(sync.Mutex) // Guards fileState and files
+	fileState stateT
+	files = make(map[int] *filled) // minCertifs -> FillFile(minCertifs) in fileState
+	
+	cacheMut = new(sync.Mutex) // Guards cacheState, stateNb and cache
+	cacheState stateT
+	stateNb int // Number of cacheState
+	cache = make(map[string] *cached) // settingsKey() + fileKey(cluster) -> permutations of cluster
+
+)
+
+// Cmds
+func state () stateT {
+	return stateT{block: B.LastBlock(), now: B.Now(), wot: B.WotVersion(), sandbox: S.Version()}
+} //state
+
+func (l *distLog) Distance (pubkeys B.PubkeysT) float64 {
+	var b strings.Builder
+	for _, p := range pubkeys {
+		b.WriteString(string(p))
+		b.WriteByte(' ')
+	}
+	dist := B.Distance(pubkeys)
+	l.mut.Lock()
+	l.d[b.String()] = distRec{pubkeys: pubkeys, dist: dist}
+	l.mut.Unlock()
+	return dist
+} //Distance
+
+// Say whether all the distances recorded in l are still the same in the current web of trust
+func (l *distLog) unchanged () bool {
+	for _, r := range l.d {
+		if dist := B.Distance(r.pubkeys); dist != r.dist && !(math.IsNaN(dist) && math.IsNaN(r.dist)) {
+			return false
+		}
+	}
+	return true
+} //unchanged
+
+// Extract f from the blockchain (Duniter1Blockchain) and the sandbox (Duniter1Sandbox) and sort it, like fillFile; the result is extracted again only when the blockchain or the sandbox changed since the last call with the same minCertifs, and a copy of it is returned
+func FillFile (minCertifs int) (f File, cNb, dNb int) {
+	st := state()
+	fileMut.Lock()
+	defer fileMut.Unlock()
+	if st != fileState {
+		fileState = st
+		files = make(map[int] *filled)
+	}
+	ff, ok := files[minCertifs]
+	if !ok {
+		ff = new(filled)
+		ff.f, ff.cNb, ff.dNb = fillFile(minCertifs)
+		files[minCertifs] = ff
+	}
+	return copyFile(ff.f, 0, nil), ff.cNb, ff.dNb
+} //FillFile
+
+// Split f into its independent clusters: Dossier(s) with a common certifier, a common uid or a common pubkey are in the same cluster, and internal certifications are in the cluster of the Dossier(s) certified by their senders; the order of f is kept in every cluster, and the internal certifications whose senders certify no Dossier are dropped, since they change nothing
+func clusters (f File) []File {
+	
+	parent := make([]int, len(f))
+	
+	find := func (i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	} //find
+	
+	owners := make(map[string] int) // First element of f concerned by each uid, pubkey or certifier
+	
+	// Put f[i] in the cluster of the first element concerned by key, if any
+	link := func (key string, i int) {
+		if j, ok := owners[key]; ok {
+			parent[find(i)] = find(j)
+		} else {
+			owners[key] = i
+		}
+	} //link
+	
+	//clusters
+	for i := range f {
+		parent[i] = i
+	}
+	for i, cd := range f {
+		if d, ok := cd.(*Dossier); ok {
+			link("i" + *d.Id, i)
+			link("p" + string(*d.pub), i)
+			for _, c := range d.Certifs {
+				link("c" + *c.(*Certif).From, i)
+			}
+		}
+	}
+	cs := make([]File, 0)
+	ranks := make(map[int] int) // Root -> rank in cs
+	for i, cd := range f {
+		if c, ok := cd.(*Certif); ok {
+			j, ok := owners["c" + *c.From]
+			if !ok {
+				continue
+			}
+			parent[i] = find(j)
+		}
+		r := find(i)
+		k, ok := ranks[r]
+		if !ok {
+			k = len(cs)
+			ranks[r] = k
+			cs = append(cs, make(File, 0))
+		}
+		cs[k] = append(cs[k], cd)
+	}
+	return cs
+} //clusters
+
+// Parameters the permutations depend on, apart from their File, with the effective settings s
+func settingsKey (s Settings) string {
+	return fmt.Sprintln(s.MaxSize, s.Window, sampling, samplesNb, samplingSeed, *B.Pars())
+} //settingsKey
+
+// Description of all the elements of f which CalcPermutations uses
+func fileKey (f File) string {
+	var b strings.Builder
+	for _, cd := range f {
+		switch cd := cd.(type) {
+		case *Certif:
+			fmt.Fprintln(&b, "C", *cd.From, *cd.fromP, *cd.To, *cd.ToH, cd.date, cd.limit)
+		case *Dossier:
+			fmt.Fprintln(&b, "D", *cd.Id, *cd.Hash, *cd.pub, cd.date, cd.MinDate, cd.limit, cd.PrincCertif, cd.lost, len(cd.Certifs))
+			for _, c := range cd.Certifs {
+				c := c.(*Certif)
+				fmt.Fprintln(&b, *c.From, *c.fromP, c.date, c.limit)
+			}
+		}
+	}
+	return b.String()
+} //fileKey
+
+// Cmds
+// Return the permutations of the cluster g in the current web of trust, computed with calcPermutations(g, s, deadline) and whose settings are described by key, from the cache if neither g nor the distances they use changed since they were computed; the permutations stopped by deadline are not put into the cache; g is not modified; the caller must hold cacheMut
+func clusterPermutations (g File, s Settings, key string, deadline time.Time) (sets *A.Tree, samples int, memoryExceeded, timeExceeded bool) {
+	key += fileKey(g)
+	if c, ok := cache[key]; ok && (c.checked == stateNb || c.dists.unchanged()) {
+		c.checked = stateNb
+		c.used = stateNb
+		return c.sets, c.samples, c.memoryExceeded, false
+	}
+	dists := &distLog{d: make(map[string] distRec)}
+	g = copyFile(g, 0, nil)
+	for _, cd := range g {
+		if d, ok := cd.(*Dossier); ok {
+			d.wot = dists
+		}
+	}
+	sets, samples, memoryExceeded, timeExceeded = calcPermutations(g, s, deadline)
+	if !timeExceeded {
+		cache[key] = &cached{sets: sets, samples: samples, memoryExceeded: memoryExceeded, dists: dists, checked: stateNb, used: stateNb}
+	}
+	return
+} //clusterPermutations
+
+// Return the permutations of the clusters cs, computed with the settings of run and stopped at deadline, if not zero, and note in run whether their budgets were exceeded; those whose Dossier(s) all use the current web of trust are taken from the cache or put into it
+func permutationsOf (cs []File, run *Run, deadline time.Time) (sets []*A.Tree, samples []int) {
+	sets = make([]*A.Tree, len(cs))
+	samples = make([]int, len(cs))
+	current := true
+	for _, g := range cs {
+		for _, cd := range g {
+			if d, ok := cd.(*Dossier); ok && d.wot != nil {
+				current = false
+			}
+		}
+	}
+	if !current {
+		for i, g := range cs {
+			var mem, tim bool
+			sets[i], samples[i], mem, tim = calcPermutations(copyFile(g, 0, nil), run.Settings, deadline)
+			run.MemoryExceeded = run.MemoryExceeded || mem
+			run.TimeExceeded = run.TimeExceeded || tim
+		}
+		return
+	}
+	cacheMut.Lock()
+	defer cacheMut.Unlock()
+	if st := state(); st != cacheState { // Forget the permutations unused during the previous state
+		cacheState = st
+		stateNb++
+		for key, c := range cache {
+			if c.used < stateNb - 1 {
+				delete(cache, key)
+			}
+		}
+	}
+	key := settingsKey(run.Settings)
+	for i, g := range cs {
+		var mem, tim bool
+		sets[i], samples[i], mem, tim = clusterPermutations(g, run.Settings, key, deadline)
+		run.MemoryExceeded = run.MemoryExceeded || mem
+		run.TimeExceeded = run.TimeExceeded || tim
+	}
+	return
+} //permutationsOf
+
+// Add to occurDate the entries of the Dossier(s) of the set of permutations sets, obtained with samples draws (0 if all permutations were computed), at now or later; sets is not modified
+func occurrences (sets *A.Tree, samples int, now int64, occurDate *A.Tree) {
+	
+	// Computing of Propagation(s) with their proba(s)
+	occur := A.New()
+	e := sets.Next(nil)
+	for e != nil {
+		s := e.Val().(*Set)
+		ee := s.T.Next(nil)
+		for ee != nil {
+			p := ee.Val().(*Propagation)
+			q := &Propagation{Hash: p.Hash, Id: p.Id, Date: M.Max64(p.Date, now), After: p.After}
+			eee, _, _ := occur.SearchIns(q)
+			eee.Val().(*Propagation).Proba += s.Proba
+			ee = s.T.Next(ee)
+		}
+		e = sets.Next(e)
+	}
+	
+	// For each uid, with increasing date(s), gather all Propagation(s) following a Propagation with After = true together
+	var pAfter *Propagation
+	id := ""
+	e = occur.Next(nil)
+	for e != nil {
+		ee := occur.Next(e)
+		p := e.Val().(*Propagation)
+		if p.Id != id {
+			id = p.Id
+			pAfter = nil
+		}
+		if pAfter == nil && p.After {
+			pAfter = p
+		} else if pAfter != nil {
+			pAfter.Proba += p.Proba
+			b := occur.Delete(p); M.Assert(b, 100)
+		}
+		e = ee
+	}
+	
+	// Propagation -> PropDate; with sampling, normal approximation of the confidence intervals
+	e = occur.Next(nil)
+	for e != nil {
+		p := e.Val().(*Propagation)
+		if samples > 0 {
+			p.Margin = 1.96 * math.Sqrt(p.Proba * (1. - p.Proba) / float64(samples))
+		}
+		_, b, _ := occurDate.SearchIns(&PropDate{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After, Proba: p.Proba, Margin: p.Margin}); M.Assert(!b, 101)
+		e = occur.Next(e)
+	}
+} //occurrences
+
+// Number of permutations, or math.MaxInt32 if they are more
+func (p *Permutations) Len () int {
+	n := 1
+	for _, sets := range p.clusters {
+		n *= sets.NumberOfElems()
+		if n > math.MaxInt32 {
+			return math.MaxInt32
+		}
+	}
+	return n
+} //Len
+
+// Return all the permutations, with elements of type Set whose trees have elements of type PropDate; their number may be very big
+func (p *Permutations) All () *A.Tree {
+	
+	all := A.New()
+	ts := make([]*A.Tree, len(p.clusters))
+	
+	// Combine the permutations of the clusters from the rank i, knowing the ones of the previous clusters in ts, and the product of their probabilities in proba
+	var combine func (i int, proba float64)
+	combine = func (i int, proba float64) {
+		if i < len(p.clusters) {
+			e := p.clusters[i].Next(nil)
+			for e != nil {
+				s := e.Val().(*Set)
+				ts[i] = s.T
+				combine(i + 1, proba * s.Proba)
+				e = p.clusters[i].Next(e)
+			}
+			return
+		}
+		s := &Set{Proba: proba, T: A.New()}
+		for _, t := range ts {
+			e := t.Next(nil)
+			for e != nil {
+				q := e.Val().(*Propagation)
+				_, b, _ := s.T.SearchIns(&Propagation{Hash: q.Hash, Id: q.Id, Date: M.Max64(q.Date, p.now), After: q.After}); M.Assert(!b, 100)
+				e = t.Next(e)
+			}
+		}
+		if e, b, _ := all.SearchIns(s); b { // Different permutations may become equal when their dates are put after now
+			e.Val().(*Set).Proba += proba
+		}
+	} //combine
+	
+	byDate := func (tId *A.Tree) *A.Tree {
+		tD :=A.New()
+		e := tId.Next(nil)
+		for e != nil {
+			p := e.Val().(*Propagation)
+			_, b, _ := tD.SearchIns(&PropDate{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After}); M.Assert(!b, 101)
+			e = tId.Next(e)
+		}
+		return tD
+	} //byDate
+	
+	//All
+	combine(0, 1.)
+	e := all.Next(nil)
+	for e != nil {
+		s := e.Val().(*Set)
+		s.T = byDate(s.T)
+		e = all.Next(e)
+	}
+	return all
+} //All
@@ -0,0 +1,240 @@
+package wotWizard
+
+import (
+
+	A	"util/avl"
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	F	"path/filepath"
+	M	"util/misc"
+		"fmt"
+		"math"
+		"os"
+		"sort"
+		"strings"
+		"testing"
+
+)
+
+const (
+
+	// Newcomer joining in ginaBlock
+	gina = "GinaGinaGinaGinaGinaGinaGinaGinaGinaGinaGina"
+
+	// Block 101 added to the fixture expiry, where gina joins, certified by alice, bob and carol
+	ginaBlock = `{"number": 101, "hash": "00000000000000000000000000000000000000000000000000000000E0000065", "medianTime": 1549467427, "time": 1549467432, "joiners": ["` + gina + `:sig:100-00000000000000000000000000000000000000000000000000000000E0000064:100-00000000000000000000000000000000000000000000000000000000E0000064:gina"], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": ["aTtLQKCrnnbjJ82GRupTHxZtMhwkoS9c49usyDAynbct:` + gina + `:100:sig", "E4vbLtyMtM4BT6mrLvHjSX5b6bhGFbHdBtK4oyYeSuYF:` + gina + `:100:sig", "bHJPPbeuTS1aqN6qpvs3hoxKMM8N9bvGYuKV8HG5kTLk:` + gina + `:100:sig"], "identities": [{"pub": "` + gina + `", "hash": "0000000000000000000000000000000000000000000000000000000000006714"}]}`
+
+	// Block 102, following ginaBlock, without any change
+	emptyBlock = `{"number": 102, "hash": "00000000000000000000000000000000000000000000000000000000E0000066", "medianTime": 1549467727, "time": 1549467732, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": [], "identities": []}`
+
+	// Block 101 of a fork of the previous chain, where gina doesn't join
+	forkBlock = `{"number": 101, "hash": "00000000000000000000000000000000000000000000000000000000F0000065", "medianTime": 1549467427, "time": 1549467432, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": [], "identities": []}`
+
+)
+
+// Directory of a copy of the fixture expiry, with the blocks added
+func chain (t *testing.T, blocks ... string) string {
+	buf, err := os.ReadFile(F.Join(fixture("expiry"), "blocks.jsonl")); M.Assert(err == nil, err, 100)
+	dir := t.TempDir()
+	err = os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(strings.TrimSpace(string(buf)) + "\n" + strings.Join(blocks, "\n") + "\n"), 0644); M.Assert(err == nil, err, 101)
+	return dir
+} //chain
+
+// Certification from the member of uid from, sent at date and valid until limit; its target is set by dossier
+func certif (from string, date, limit int64) *Certif {
+	p, ok := B.IdUid(from); M.Assert(ok, from, 100)
+	c := &Certif{date: date, limit: limit, From: new(string), fromP: new(B.Pubkey)}
+	*c.From = from; *c.fromP = p
+	return c
+} //certif
+
+// Internal certification from the member of uid from to the member of uid to, sent at date
+func internal (from, to string, date int64) *Certif {
+	c := certif(from, date, date + int64(B.Pars().SigWindow))
+	p, ok := B.IdUid(to); M.Assert(ok, to, 100)
+	_, _, h, _, _, _, ok := B.IdPubComplete(p); M.Assert(ok, to, 101)
+	c.To = new(string); c.ToH = new(B.Hash)
+	*c.To = to; *c.ToH = h
+	return c
+} //internal
+
+// Dossier of the newcomer uid, certified by certs
+func dossier (uid string, certs ... *Certif) *Dossier {
+	d := &Dossier{MinDate: B.Now(), limit: B.Now() + int64(B.Pars().MsWindow), Id: new(string), Hash: new(B.Hash), pub: new(B.Pubkey)}
+	*d.Id = uid; *d.Hash = B.Hash(strings.Repeat(uid, 64 / len(uid))); *d.pub = B.Pubkey(uid + "Pubkey")
+	for _, c := range certs {
+		c.To = d.Id; c.ToH = d.Hash
+		d.Certifs = append(d.Certifs, c)
+	}
+	return d
+} //dossier
+
+// File of two independent clusters, in the chain of ginaBlock: in the first one, n1 and n2 are certified by alice, bob and carol at close dates; in the second one, n3 is certified by dave, erin and frank, and the internal certification from gina to carol delays the certification of gina toward n4, so that the ones of dave and erin expire meanwhile, and the distance rule is checked for gina alone
+func twoClusters () File {
+	t := B.Now() + 86400
+	u := t + 86400
+	sw := int64(B.Pars().SigWindow)
+	f := File{
+		dossier("n1", certif("alice", t, t + sw), certif("bob", t, t + sw), certif("carol", t, t + sw)),
+		dossier("n2", certif("alice", t + 60, t + sw), certif("bob", t + 60, t + sw), certif("carol", t + 60, t + sw)),
+		internal("gina", "carol", u - 3600),
+		dossier("n3", certif("dave", u, u + sw), certif("erin", u, u + sw), certif("frank", u, u + sw)),
+		dossier("n4", certif("dave", u, u + 2 * 86400), certif("erin", u, u + 2 * 86400), certif("gina", u, u + sw)),
+	}
+	for _, cd := range f {
+		if d, ok := cd.(*Dossier); ok {
+			M.Assert(d.fixPrinc(), *d.Id, 100)
+		}
+	}
+	sortFile(f, 0)
+	return f
+} //twoClusters
+
+// Set the settings of the computation of permutations to s and sampling to sp for the duration of the test t
+func setSettings (t *testing.T, s Settings, sp string) {
+	old, oldSp := CurrentSettings(), sampling
+	ChangeSettings(s)
+	sampling = sp
+	t.Cleanup(func () {ChangeSettings(old); sampling = oldSp})
+} //setSettings
+
+// Empty the cache of permutations
+func clearCache () {
+	cacheMut.Lock()
+	cache = make(map[string] *cached)
+	cacheState = stateT{}
+	cacheMut.Unlock()
+} //clearCache
+
+// Description of the permutation p, whose tree has elements of type Propagation or PropDate, with its dates put at now or later; the entries are sorted by uids
+func permKey (p *Set, now int64) string {
+	ps := make([]string, 0)
+	for e := p.T.Next(nil); e != nil; e = p.T.Next(e) {
+		var q *Propagation
+		switch v := e.Val().(type) {
+		case *Propagation:
+			q = v
+		case *PropDate:
+			q = (*Propagation)(v)
+		}
+		ps = append(ps, fmt.Sprint(q.Id, M.Max64(q.Date, now), q.After))
+	}
+	sort.Strings(ps)
+	return strings.Join(ps, " ")
+} //permKey
+
+// Permutations of sets, by their descriptions, with their probabilities
+func permsOf (sets *A.Tree, now int64) map[string] float64 {
+	m := make(map[string] float64)
+	for e := sets.Next(nil); e != nil; e = sets.Next(e) {
+		s := e.Val().(*Set)
+		m[permKey(s, now)] += s.Proba
+	}
+	return m
+} //permsOf
+
+// Are the permutations p1 and p2 the same, with the same probabilities?
+func samePerms (p1, p2 map[string] float64) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for k, x := range p1 {
+		if y, ok := p2[k]; !ok || math.Abs(x - y) > 1e-9 {
+			return false
+		}
+	}
+	return true
+} //samePerms
+
+// Are the trees of PropDate t1 and t2 equal, probabilities included?
+func sameOccur (t1, t2 *A.Tree) bool {
+	if t1.NumberOfElems() != t2.NumberOfElems() {
+		return false
+	}
+	for e1, e2 := t1.Next(nil), t2.Next(nil); e1 != nil; e1, e2 = t1.Next(e1), t2.Next(e2) {
+		p1, p2 := e1.Val().(*PropDate), e2.Val().(*PropDate)
+		if p1.Id != p2.Id || p1.Date != p2.Date || p1.After != p2.After || math.Abs(p1.Proba - p2.Proba) > 1e-9 {
+			return false
+		}
+	}
+	return true
+} //sameOccur
+
+func TestClustersEqualMonolithic (t *testing.T) {
+	setSettings(t, Settings{MaxSize: BA.MaxSize, Window: 300}, BA.SamplingNever)
+	B.UpdateOffline(chain(t, ginaBlock), func () {
+		f := twoClusters()
+		cs := clusters(f)
+		M.Want(len(cs) == 2 && len(cs[0]) == 2 && len(cs[1]) == 3, t)
+		now := B.Now()
+		clearCache()
+		permutations, occurDate, _, samples, _ := calcEntries(f, now)
+		M.Want(samples == 0 && permutations.Len() == 2, t)
+		sets, samples := CalcPermutations(copyFile(f, 0, nil))
+		M.Want(samples == 0, t)
+		if !samePerms(permsOf(permutations.All(), now), permsOf(sets, now)) {
+			t.Errorf("Clustered permutations %v, monolithic ones %v", permsOf(permutations.All(), now), permsOf(sets, now))
+		}
+		occur := A.New()
+		occurrences(sets, 0, now, occur)
+		M.Want(sameOccur(occurDate, occur), t)
+
+		// Computed again from the cache
+		permutations, occurDate, _, _, _ = calcEntries(f, now)
+		M.Want(samePerms(permsOf(permutations.All(), now), permsOf(sets, now)) && sameOccur(occurDate, occur), t)
+	})
+}
+
+func TestClustersInvalidation (t *testing.T) {
+	setSettings(t, Settings{MaxSize: BA.MaxSize, Window: 300}, BA.SamplingNever)
+	var (
+		f File
+		p1 *Permutations
+	)
+	B.UpdateOffline(chain(t, ginaBlock), func () {
+		f = twoClusters()
+		clearCache()
+		p1, _, _, _, _ = calcEntries(f, B.Now())
+		cacheMut.Lock()
+		c, ok := cache[settingsKey(CurrentSettings().Effective()) + fileKey(clusters(f)[1])]
+		cacheMut.Unlock()
+		M.Want(ok && c.sets == p1.clusters[1], t)
+		_, ok = c.dists.d[gina + " "]
+		M.Want(ok, t) // The second cluster used the distance of gina
+
+		// Same File: both clusters come from the cache
+		p, _, _, _, _ := calcEntries(f, B.Now())
+		M.Want(p.clusters[0] == p1.clusters[0] && p.clusters[1] == p1.clusters[1], t)
+
+		// A membership application of n3 in the sandbox changes the second cluster only
+		g := copyFile(f, 0, nil)
+		for _, cd := range g {
+			if d, ok := cd.(*Dossier); ok && *d.Id == "n3" {
+				d.MinDate = d.date + 3600
+			}
+		}
+		sortFile(g, 0)
+		p, _, _, _, _ = calcEntries(g, B.Now())
+		M.Want(p.clusters[0] == p1.clusters[0] && p.clusters[1] != p1.clusters[1], t)
+	})
+
+	// A new block changes the state, but not the distances: both clusters are still valid
+	B.UpdateOffline(chain(t, ginaBlock, emptyBlock), func () {
+		M.Want(B.LastBlock() == 102, t)
+		p, _, _, _, _ := calcEntries(f, B.Now())
+		M.Want(p.clusters[0] == p1.clusters[0] && p.clusters[1] == p1.clusters[1], t)
+	})
+
+	// The fork removes gina from the web of trust, which changes the distance used by the second cluster only
+	B.UpdateOffline(chain(t, forkBlock), func () {
+		M.Want(B.LastBlock() == 101, t)
+		_, ok := B.IdPub(gina)
+		M.Want(!ok, t)
+		p, _, _, _, _ := calcEntries(f, B.Now())
+		M.Want(p.clusters[0] == p1.clusters[0] && p.clusters[1] != p1.clusters[1], t)
+	})
+
+	B.UpdateOffline(fixture("expiry"), func () {
+		M.Want(B.LastBlock() == 100, t)
+	})
+}
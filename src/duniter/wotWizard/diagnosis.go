@@ -0,0 +1,117 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package wotWizard
+
+// Diagnosis of the reasons delaying or preventing the entry of a Dossier
+
+import (
+	
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+	S	"duniter/sandbox"
+
+)
+
+const (
+	
+	// Blocking reasons
+	
+	MsPeriod BlockingReason = iota // The last membership application is too recent
+	SigPeriod // A certifier has sent a certification too recently
+	SigStock // A certifier has already sent B.pars.sigStock certifications
+	NotEnoughCertifs // Less than B.pars.sigQty certifications are available
+	DistanceRule // The available certifications don't fulfill the distance rule
+	ApplicationExpiry // The membership application expires before the entry date
+
+)
+
+type (
+	
+	BlockingReason int
+	
+	// Reason delaying or preventing the entry of a Dossier
+	Blocking struct {
+		Reason BlockingReason
+		Certifier B.Pubkey // Responsible certifier, "" if none
+		Date int64 // Date when the reason clears, or BA.Never if it doesn't clear by itself
+	}
+
+)
+
+// Date when p may send a new certification, if it has already sent B.pars.sigStock certifications: expiration date of its certification which expires first
+func stockFreeDate (p B.Pubkey) int64 {
+	d := int64(BA.Never)
+	var pos B.CertPos
+	if B.CertFrom(p, &pos) {
+		from, to, ok := pos.CertNextPos()
+		for ok {
+			_, exp, b := B.Cert(from, to); M.Assert(b, 100)
+			d = M.Min64(d, exp)
+			from, to, ok = pos.CertNextPos()
+		}
+	}
+	return d
+} //stockFreeDate
+
+// List the reasons delaying or preventing the entry of d, with d as computed by FillFile or SimulateFile
+func (d *Dossier) Diagnosis () []Blocking {
+	now := B.Now()
+	sigQty := int(B.Pars().SigQty)
+	bs := make([]Blocking, 0)
+	if d.MinDate > now {
+		bs = append(bs, Blocking{Reason: MsPeriod, Date: d.MinDate})
+	}
+	for i, cd := range d.Certifs {
+		c := cd.(*Certif)
+		if i < d.PrincCertif && c.date > now && c.date != BA.Never {
+			bs = append(bs, Blocking{Reason: SigPeriod, Certifier: *c.fromP, Date: c.date})
+		}
+	}
+	var pos S.CertPos
+	if S.CertTo(*d.Hash, &pos) {
+		from, toHash, ok := pos.CertNextPos()
+		for ok {
+			var posF B.CertPos
+			if _, member, _, _, _, _, b := B.IdPubComplete(from); b && member && B.CertFrom(from, &posF) && posF.CertPosLen() >= int(B.Pars().SigStock) {
+				_, _, exp, b := S.Cert(from, toHash); M.Assert(b, 100)
+				date := stockFreeDate(from)
+				if date > exp {
+					date = BA.Never
+				}
+				bs = append(bs, Blocking{Reason: SigStock, Certifier: from, Date: date})
+			}
+			from, toHash, ok = pos.CertNextPos()
+		}
+	}
+	if len(d.Certifs) < sigQty {
+		bs = append(bs, Blocking{Reason: NotEnoughCertifs, Date: BA.Never})
+	} else {
+		certs := (*pubList)(nil)
+		for _, cd := range d.Certifs {
+			c := cd.(*Certif)
+			certs = &pubList{pub: c.fromP, date: c.date, next: certs}
+		}
+		if _, _, ok := notTooFar(&certs, len(d.Certifs), d.wot); !ok {
+			bs = append(bs, Blocking{Reason: DistanceRule, Date: BA.Never})
+		}
+	}
+	date := M.Max64(d.MinDate, now)
+	if 0 < d.PrincCertif && d.PrincCertif <= len(d.Certifs) {
+		date = M.Max64(date, d.Certifs[d.PrincCertif - 1].(*Certif).date)
+	}
+	if date != BA.Never && date > d.limit {
+		bs = append(bs, Blocking{Reason: ApplicationExpiry, Date: BA.Never})
+	}
+	return bs
+} //Diagnosis
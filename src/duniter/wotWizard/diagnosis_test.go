@@ -0,0 +1,53 @@
+package wotWizard
+
+import (
+
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+		"reflect"
+		"testing"
+
+)
+
+// Dossier d with its PrincCertif fixed
+func fixed (d *Dossier) *Dossier {
+	d.fixPrinc()
+	return d
+} //fixed
+
+// Certification sent at date by the unknown identity of pubkey p, valid until limit
+func stranger (p B.Pubkey, date, limit int64) *Certif {
+	c := certif("alice", date, limit)
+	*c.From = string(p); *c.fromP = p
+	return c
+} //stranger
+
+func TestDiagnosis (t *testing.T) {
+	B.UpdateOffline(fixture("expiry"), func () {
+		now := B.Now()
+		sw := int64(B.Pars().SigWindow)
+		alice, ok := B.IdUid("alice"); M.Assert(ok, "alice", 100)
+		late := fixed(dossier("late", certif("alice", now, now + sw), certif("bob", now, now + sw), certif("carol", now, now + sw)))
+		late.MinDate = now + 3600
+		expiring := fixed(dossier("expiring", certif("bob", now, now + sw), certif("carol", now, now + sw), certif("alice", now + 7200, now + sw))) // Certifications sorted by dates, as in FillFile
+		expiring.limit = now + 3600
+		tests := []struct {
+			name string
+			d *Dossier
+			want []Blocking
+		}{
+			{"ready", fixed(dossier("ready", certif("alice", now, now + sw), certif("bob", now, now + sw), certif("carol", now, now + sw))), []Blocking{}},
+			{"msPeriod", late, []Blocking{{Reason: MsPeriod, Date: now + 3600}}},
+			{"sigPeriod", fixed(dossier("waiting", certif("bob", now, now + sw), certif("carol", now, now + sw), certif("alice", now + 600, now + sw))), []Blocking{{Reason: SigPeriod, Certifier: alice, Date: now + 600}}},
+			{"notEnoughCertifs", fixed(dossier("alone", certif("alice", now, now + sw), certif("bob", now, now + sw))), []Blocking{{Reason: NotEnoughCertifs, Date: BA.Never}}},
+			{"distanceRule", fixed(dossier("far", stranger("Stranger1", now, now + sw), stranger("Stranger2", now, now + sw), stranger("Stranger3", now, now + sw))), []Blocking{{Reason: DistanceRule, Date: BA.Never}}},
+			{"applicationExpiry", expiring, []Blocking{{Reason: SigPeriod, Certifier: alice, Date: now + 7200}, {Reason: ApplicationExpiry, Date: BA.Never}}},
+		}
+		for _, tt := range tests {
+			if got := tt.d.Diagnosis(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+			}
+		}
+	})
+}
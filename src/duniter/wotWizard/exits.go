@@ -0,0 +1,381 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package wotWizard
+
+// Forecast of the exits and membership renewals of members, and of the number of members, taking into account the membership renewals and the certifications of the sandbox
+
+// The certifications of the sandbox toward members are written as soon as their senders may send them; when a sender has several of them, they are written in a random order, one every B.pars.sigPeriod, all the orders being equiprobable; the probabilities are then estimated by drawing samplesNb orders. The exits of the members are supposed to have no effect on the certifications they sent, and the newcomers are supposed to stay members until the end of the forecast
+
+import (
+	
+	A	"util/avl"
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+	S	"duniter/sandbox"
+		"math"
+		"util/alea"
+		"util/sort"
+
+)
+
+const (
+	
+	// Exit reasons
+	
+	MembershipExpiry ExitReason = iota // The membership expires without being renewed
+	CertificationsLack // Less than B.pars.sigQty certifications remain valid
+
+)
+
+type (
+	
+	ExitReason int
+	
+	// Possible exit of a member
+	Exit struct {
+		Hash B.Hash
+		Id string
+		Date int64
+		Reason ExitReason
+		Proba, // Probability of this exit
+		Margin float64 // Half-width of the 95% confidence interval of Proba when it's estimated by sampling, 0 otherwise
+	}
+	
+	// Possible renewal of the membership of a member, with a pending membership application
+	Renewal struct {
+		Hash B.Hash
+		Id string
+		Date, // Date of the renewal
+		Limit int64 // New expiration date of the membership
+		Proba, // Probability of the renewal (the member may loose her certifications before)
+		Margin float64 // Half-width of the 95% confidence interval of Proba when it's estimated by sampling, 0 otherwise
+	}
+	
+	// Expected number of members from Date on
+	MembersCount struct {
+		Date int64
+		Count float64
+	}
+	
+	exits []Exit
+	
+	exitSort struct {
+		e exits
+	}
+	
+	renewals []Renewal
+	
+	renewalSort struct {
+		r renewals
+	}
+	
+	counts []MembersCount
+	
+	countSort struct {
+		c counts
+	}
+	
+	// Validity interval of a certification from from
+	validity struct {
+		from B.Pubkey
+		start,
+		end int64
+	}
+	
+	// Member, with her certifications and her pending documents
+	exitMember struct {
+		hash B.Hash
+		id string
+		exp, // Expiration of the membership
+		renewal int64 // Date of the pending membership renewal, or BA.Never if none or if it can't be written
+		certs []validity // Certifications in the blockchain
+		pending []*pendingCert // Certifications in sandbox
+		random bool // Some certifications of pending are drawn at random
+	}
+	
+	// Certification in sandbox toward a member
+	pendingCert struct {
+		from B.Pubkey
+		to *exitMember
+		limit, // Expiration date in sandbox
+		date int64 // Date of writing in the current draw, or BA.Never if the certification expires before
+	}
+	
+	// Sender of certifications in sandbox
+	pendingSender struct {
+		next int64 // Date when the sender may send her next certification
+		certs []*pendingCert
+	}
+	
+	// Key of the maps of results
+	exitKey struct {
+		m *exitMember
+		date int64
+		reason ExitReason
+	}
+
+)
+
+func (s *exitSort) Swap (p1, p2 int) {
+	s.e[p1], s.e[p2] = s.e[p2], s.e[p1]
+}
+
+func (s *exitSort) Less (p1, p2 int) bool {
+	e1 := &s.e[p1]; e2 := &s.e[p2]
+	return e1.Date < e2.Date || e1.Date == e2.Date && (BA.CompP(e1.Id, e2.Id) == BA.Lt || e1.Id == e2.Id && e1.Reason < e2.Reason)
+}
+
+func (s *renewalSort) Swap (p1, p2 int) {
+	s.r[p1], s.r[p2] = s.r[p2], s.r[p1]
+}
+
+func (s *renewalSort) Less (p1, p2 int) bool {
+	r1 := &s.r[p1]; r2 := &s.r[p2]
+	return r1.Date < r2.Date || r1.Date == r2.Date && BA.CompP(r1.Id, r2.Id) == BA.Lt
+}
+
+func (s *countSort) Swap (p1, p2 int) {
+	s.c[p1], s.c[p2] = s.c[p2], s.c[p1]
+}
+
+func (s *countSort) Less (p1, p2 int) bool {
+	return s.c[p1].Date < s.c[p2].Date
+}
+
+// Fix the dates of writing of the certifications of s, in the order of s.certs
+func (s *pendingSender) write () {
+	date := s.next
+	for _, c := range s.certs {
+		if date <= c.limit {
+			c.date = date
+			date += int64(B.Pars().SigPeriod)
+		} else {
+			c.date = BA.Never
+		}
+	}
+} //write
+
+// Exit of m, if her pending certifications are written at their dates, and whether her pending membership renewal is written before
+func (m *exitMember) exit () (date int64, reason ExitReason, renewed bool) {
+	now := B.Now()
+	sigValidity := int64(B.Pars().SigValidity)
+	vs := make([]validity, len(m.certs))
+	copy(vs, m.certs)
+	for _, c := range m.pending {
+		if c.date == BA.Never {
+			continue
+		}
+		i := 0
+		for i < len(vs) && !(vs[i].from == c.from && c.date <= vs[i].end) {
+			i++
+		}
+		if i < len(vs) { // Renewal of a certification
+			vs[i].end = M.Max64(vs[i].end, c.date + sigValidity)
+		} else {
+			vs = append(vs, validity{from: c.from, start: c.date, end: c.date + sigValidity})
+		}
+	}
+	// The number of valid certifications can only decrease at now or at the end of a validity
+	certsEnd := int64(BA.Never)
+	count := func (t int64) int {
+		n := 0
+		for _, v := range vs {
+			if v.start <= t && t < v.end {
+				n++
+			}
+		}
+		return n
+	}
+	sigQty := int(B.Pars().SigQty)
+	if count(now) < sigQty {
+		certsEnd = now
+	} else {
+		for _, v := range vs {
+			if v.end < certsEnd && count(v.end) < sigQty {
+				certsEnd = v.end
+			}
+		}
+	}
+	memEnd := m.exp
+	if m.renewal < certsEnd && m.renewal < m.exp {
+		memEnd = m.renewal + int64(B.Pars().MsValidity)
+		renewed = true
+	}
+	if certsEnd < memEnd {
+		date = certsEnd; reason = CertificationsLack
+	} else {
+		date = memEnd; reason = MembershipExpiry
+	}
+	date = M.Max64(date, now) // Members already expired leave with the next block
+	return
+} //exit
+
+// Forecast, until the date end, the exits and the membership renewals of the members, and the expected number of members, including the newcomers of BuildEntries; samples is the number of draws of the orders of the certifications in sandbox, or 0 if these orders don't matter
+func ForecastMembers (end int64) (ex []Exit, rs []Renewal, cs []MembersCount, samples int) {
+	now := B.Now()
+	pars := B.Pars()
+	
+	// Members
+	ms := make(map[B.Hash] *exitMember)
+	var pst *B.Position
+	uid, ok := B.IdNextUidM(true, &pst)
+	for ok {
+		p, member, hash, _, app, exp, b := B.IdUidComplete(uid); M.Assert(b && member, 100)
+		m := &exitMember{hash: hash, id: uid, exp: exp, renewal: BA.Never, certs: make([]validity, 0), pending: make([]*pendingCert, 0)}
+		var pos B.CertPos
+		if B.CertTo(p, &pos) {
+			from, to, okP := pos.CertNextPos()
+			for okP {
+				_, exp, b := B.Cert(from, to); M.Assert(b, 101)
+				m.certs = append(m.certs, validity{from: from, start: now, end: exp})
+				from, to, okP = pos.CertNextPos()
+			}
+		}
+		if _, limit, b := S.Renewal(hash); b {
+			leTi, _, b := B.TimeOf(app); M.Assert(b, 102)
+			date := M.Max64(now, leTi + int64(pars.MsPeriod))
+			certifiers := make(B.PubkeysT, 0)
+			for _, v := range m.certs {
+				if v.end > date {
+					certifiers = append(certifiers, v.from)
+				}
+			}
+			if date <= limit && B.DistanceRuleOk(certifiers) {
+				m.renewal = date
+			}
+		}
+		ms[hash] = m
+		uid, ok = B.IdNextUidM(false, &pst)
+	}
+	
+	// Certifications in sandbox toward members
+	senders := make([]*pendingSender, 0)
+	var (pos S.CertPos; el *A.Elem)
+	ok = S.CertNextFrom(true, &pos, &el)
+	for ok {
+		s := (*pendingSender)(nil)
+		from, toHash, okP := pos.CertNextPos()
+		for okP {
+			if m, b := ms[toHash]; b && canCertify(from) {
+				_, _, limit, b := S.Cert(from, toHash); M.Assert(b, 103)
+				if s == nil {
+					s = &pendingSender{next: M.Max64(now, fixCertNextDate(from)), certs: make([]*pendingCert, 0)}
+					senders = append(senders, s)
+				}
+				c := &pendingCert{from: from, to: m, limit: limit}
+				s.certs = append(s.certs, c)
+				m.pending = append(m.pending, c)
+			}
+			from, toHash, okP = pos.CertNextPos()
+		}
+		ok = S.CertNextFrom(false, &pos, &el)
+	}
+	random := make([]*exitMember, 0) // Members whose exits depend on the draws
+	for _, s := range senders {
+		s.write()
+		if len(s.certs) > 1 {
+			for _, c := range s.certs {
+				if !c.to.random {
+					c.to.random = true
+					random = append(random, c.to)
+				}
+			}
+		}
+	}
+	
+	// Draws
+	exitNbs := make(map[exitKey] int) // Numbers of draws giving each exit
+	renewalNbs := make(map[*exitMember] int) // Numbers of draws giving each renewal
+	draw := func (m *exitMember) {
+		date, reason, renewed := m.exit()
+		if date <= end {
+			exitNbs[exitKey{m: m, date: date, reason: reason}]++
+		}
+		if renewed && m.renewal <= end {
+			renewalNbs[m]++
+		}
+	}
+	for _, m := range ms {
+		if !m.random {
+			draw(m)
+		}
+	}
+	samples = 0
+	if len(random) > 0 {
+		samples = samplesNb
+		gen := alea.New()
+		gen.Randomize(samplingSeed)
+		for i := 0; i < samples; i++ {
+			for _, s := range senders {
+				if n := len(s.certs); n > 1 {
+					for j := n - 1; j > 0; j-- {
+						k := int(gen.IntRand(0, int64(j + 1)))
+						s.certs[j], s.certs[k] = s.certs[k], s.certs[j]
+					}
+					s.write()
+				}
+			}
+			for _, m := range random {
+				draw(m)
+			}
+		}
+	}
+	proba := func (m *exitMember, n int) (p, margin float64) {
+		if !m.random {
+			return 1., 0.
+		}
+		p = float64(n) / float64(samples)
+		margin = 1.96 * math.Sqrt(p * (1. - p) / float64(samples))
+		return
+	}
+	
+	// Results
+	ex = make(exits, 0, len(exitNbs))
+	changes := make(counts, 0) // Changes of the number of members
+	for k, n := range exitNbs {
+		p, margin := proba(k.m, n)
+		ex = append(ex, Exit{Hash: k.m.hash, Id: k.m.id, Date: k.date, Reason: k.reason, Proba: p, Margin: margin})
+		changes = append(changes, MembersCount{Date: k.date, Count: - p})
+	}
+	rs = make(renewals, 0, len(renewalNbs))
+	for m, n := range renewalNbs {
+		p, margin := proba(m, n)
+		rs = append(rs, Renewal{Hash: m.hash, Id: m.id, Date: m.renewal, Limit: m.renewal + int64(pars.MsValidity), Proba: p, Margin: margin})
+	}
+	_, _, _, _, occurDate, _, _, _, _ := BuildEntries()
+	e := occurDate.Next(nil)
+	for e != nil {
+		if p := e.Val().(*PropDate); p.Date != BA.Never && p.Date <= end {
+			changes = append(changes, MembersCount{Date: M.Max64(p.Date, now), Count: p.Proba})
+		}
+		e = occurDate.Next(e)
+	}
+	var ts sort.TS
+	ts.Sorter = &exitSort{e: ex}
+	ts.QuickSort(0, len(ex) - 1)
+	ts.Sorter = &renewalSort{r: rs}
+	ts.QuickSort(0, len(rs) - 1)
+	ts.Sorter = &countSort{c: changes}
+	ts.QuickSort(0, len(changes) - 1)
+	cs = counts{{Date: now, Count: float64(len(ms))}}
+	for _, c := range changes {
+		last := &cs[len(cs) - 1]
+		if c.Date == last.Date {
+			last.Count += c.Count
+		} else {
+			cs = append(cs, MembersCount{Date: c.Date, Count: last.Count + c.Count})
+		}
+	}
+	return
+} //ForecastMembers
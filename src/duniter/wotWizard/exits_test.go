@@ -0,0 +1,82 @@
+package wotWizard
+
+import (
+
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+		"testing"
+
+)
+
+func TestExit (t *testing.T) {
+	B.UpdateOffline(fixture("expiry"), func () {
+		now := B.Now()
+		pars := B.Pars()
+		sv := int64(pars.SigValidity)
+		const day = 86400
+		// Three certifications ending at now + 10, 20 and 30 days
+		member := func (exp, renewal int64, pending ... *pendingCert) *exitMember {
+			m := &exitMember{exp: exp, renewal: renewal, pending: pending}
+			for i, from := range []B.Pubkey{"p1", "p2", "p3"} {
+				m.certs = append(m.certs, validity{from: from, start: now, end: now + int64(i + 1) * 10 * day})
+			}
+			return m
+		}
+		pending := func (from B.Pubkey, date int64) *pendingCert {
+			return &pendingCert{from: from, date: date}
+		}
+		tests := []struct {
+			name string
+			m *exitMember
+			date int64
+			reason ExitReason
+			renewed bool
+		}{
+			{"certifications", member(BA.Never, BA.Never), now + 10 * day, CertificationsLack, false},
+			{"new certifier", member(BA.Never, BA.Never, pending("p4", now + 5 * day)), now + 20 * day, CertificationsLack, false},
+			{"late certifier", member(BA.Never, BA.Never, pending("p4", now + 15 * day)), now + 10 * day, CertificationsLack, false},
+			{"renewed certification", member(BA.Never, BA.Never, pending("p1", now + 5 * day)), now + 20 * day, CertificationsLack, false},
+			{"unwritten certification", member(BA.Never, BA.Never, pending("p4", BA.Never)), now + 10 * day, CertificationsLack, false},
+			{"membership", member(now + 5 * day, BA.Never), now + 5 * day, MembershipExpiry, false},
+			{"membership renewed", member(now + 5 * day, now + day, pending("p1", now), pending("p2", now), pending("p3", now)), now + day + int64(pars.MsValidity), MembershipExpiry, true},
+			{"renewal too late", member(now + 50 * day, now + 15 * day), now + 10 * day, CertificationsLack, false},
+			{"renewal after expiry", member(now + 5 * day, now + 6 * day), now + 5 * day, MembershipExpiry, false},
+			{"already expired", member(now - day, BA.Never), now, MembershipExpiry, false},
+		}
+		M.Want(sv > day + int64(pars.MsValidity), t) // The renewed certifications outlive the renewed membership
+		for _, tt := range tests {
+			date, reason, renewed := tt.m.exit()
+			if date != tt.date || reason != tt.reason || renewed != tt.renewed {
+				t.Errorf("%s: got (%d, %d, %v), want (%d, %d, %v)", tt.name, date, reason, renewed, tt.date, tt.reason, tt.renewed)
+			}
+		}
+	})
+}
+
+func TestForecastMembers (t *testing.T) {
+	const (
+		certsEnd = 1488987127 + 63115200 // Certifications of the block 0
+		ginaEnd = 1549467127 + 31557600 // Membership of gina, whose application refers to the block 100
+	)
+	B.UpdateOffline(chain(t, ginaBlock), func () {
+		now := B.Now()
+		ex, rs, cs, samples := ForecastMembers(BA.Never)
+		M.Want(len(rs) == 0 && samples == 0, t)
+		uids := []string{"alice", "bob", "carol", "dave", "erin", "frank"}
+		ok := len(ex) == len(uids) + 1
+		for i := 0; ok && i < len(uids); i++ {
+			e := ex[i]
+			ok = e.Id == uids[i] && e.Date == certsEnd && e.Reason == CertificationsLack && e.Proba == 1 && e.Margin == 0
+		}
+		ok = ok && ex[len(uids)].Id == "gina" && ex[len(uids)].Date == ginaEnd && ex[len(uids)].Reason == MembershipExpiry
+		if !ok {
+			t.Errorf("Exits %+v", ex)
+		}
+		M.Want(len(cs) == 3 && cs[0] == MembersCount{Date: now, Count: 7} && cs[1] == MembersCount{Date: certsEnd, Count: 1} && cs[2] == MembersCount{Date: ginaEnd, Count: 0}, t)
+
+		// Only the exits until end
+		ex, _, cs, _ = ForecastMembers(certsEnd - 1)
+		M.Want(len(ex) == 0 && len(cs) == 1, t)
+	})
+}
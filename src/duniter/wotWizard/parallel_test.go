@@ -0,0 +1,78 @@
+package wotWizard
+
+import (
+
+	A	"util/avl"
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+		"fmt"
+		"runtime"
+		"testing"
+		"time"
+
+)
+
+// File of n concurrent Dossier(s), certified by alice, bob and carol at dates one minute apart: every order of entry is possible
+func concurrent (n int) File {
+	t := B.Now() + 86400
+	sw := int64(B.Pars().SigWindow)
+	f := make(File, n)
+	for i := range f {
+		d := t + int64(i) * 60
+		f[i] = dossier(fmt.Sprint("n", i), certif("alice", d, t + sw), certif("bob", d, t + sw), certif("carol", d, t + sw))
+	}
+	for _, cd := range f {
+		M.Assert(cd.(*Dossier).fixPrinc(), 100)
+	}
+	sortFile(f, 0)
+	return f
+} //concurrent
+
+// Are the sets of permutations sets1 and sets2 exactly the same, probabilities included?
+func sameSets (sets1, sets2 *A.Tree) bool {
+	if sets1.NumberOfElems() != sets2.NumberOfElems() {
+		return false
+	}
+	for e1, e2 := sets1.Next(nil), sets2.Next(nil); e1 != nil; e1, e2 = sets1.Next(e1), sets2.Next(e2) {
+		s1, s2 := e1.Val().(*Set), e2.Val().(*Set)
+		if s1.Compare(s2) != A.Eq || s1.Proba != s2.Proba {
+			return false
+		}
+	}
+	return true
+} //sameSets
+
+// Compute the permutations of f with the settings s on procs goroutines at most
+func permutationsOn (procs int, f File, s Settings) (sets *A.Tree, memoryExceeded bool) {
+	old := runtime.GOMAXPROCS(procs)
+	defer runtime.GOMAXPROCS(old)
+	sets, _, memoryExceeded, _ = calcPermutations(copyFile(f, 0, nil), s, time.Time{})
+	return
+} //permutationsOn
+
+func TestParallelDeterminism (t *testing.T) {
+	setSettings(t, Settings{MaxSize: BA.MaxSize, Window: 300}, BA.SamplingNever)
+	B.UpdateOffline(fixture("expiry"), func () {
+		f := concurrent(5)
+		n := M.Max(4, runtime.NumCPU())
+		for _, x := range []struct {maxSize int64; exceeded bool} {
+			{BA.MaxSize, false},
+			{fileSize(f, 0) * 40, true}, // The tree of permutations is cut when the budget is exhausted
+		} {
+			s := Settings{MaxSize: x.maxSize, Window: 300}
+			sets1, mem1 := permutationsOn(1, f, s)
+			M.Want(mem1 == x.exceeded, t)
+			if !x.exceeded {
+				M.Want(sets1.NumberOfElems() == 120, t) // 5!
+			}
+			for i := 0; i < 5; i++ {
+				setsN, memN := permutationsOn(n, f, s)
+				if memN != mem1 || !sameSets(sets1, setsN) {
+					t.Errorf("MaxSize %d: %d permutations on 1 goroutine, %d on %d goroutines", x.maxSize, sets1.NumberOfElems(), setsN.NumberOfElems(), n)
+					break
+				}
+			}
+		}
+	})
+}
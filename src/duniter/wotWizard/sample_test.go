@@ -0,0 +1,56 @@
+package wotWizard
+
+import (
+
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+		"math"
+		"testing"
+		"time"
+
+)
+
+// Set samplingSeed to seed for the duration of the test t
+func setSeed (t *testing.T, seed int64) {
+	old := samplingSeed
+	samplingSeed = seed
+	t.Cleanup(func () {samplingSeed = old})
+} //setSeed
+
+func TestSampleReproducible (t *testing.T) {
+	setSeed(t, 42)
+	B.UpdateOffline(fixture("expiry"), func () {
+		f := concurrent(5)
+		sets := sample(copyFile(f, 0, nil), 1000, 300)
+		M.Want(sets.NumberOfElems() > 1, t)
+		M.Want(sameSets(sets, sample(copyFile(f, 0, nil), 1000, 300)), t)
+		samplingSeed = 43
+		M.Want(!sameSets(sets, sample(copyFile(f, 0, nil), 1000, 300)), t)
+	})
+}
+
+func TestSampleConvergence (t *testing.T) {
+	setSettings(t, Settings{MaxSize: BA.MaxSize, Window: 300}, BA.SamplingNever)
+	setSeed(t, 42)
+	B.UpdateOffline(fixture("expiry"), func () {
+		f := concurrent(3)
+		exact, _, _, _ := calcPermutations(copyFile(f, 0, nil), Settings{MaxSize: BA.MaxSize, Window: 300}, time.Time{})
+		M.Want(exact.NumberOfElems() == 6, t) // 3!
+		for _, nb := range []int{1000, 100000} {
+			sampled := sample(copyFile(f, 0, nil), nb, 300)
+			M.Want(sampled.NumberOfElems() == exact.NumberOfElems(), t)
+			for e := exact.Next(nil); e != nil; e = exact.Next(e) {
+				s := e.Val().(*Set)
+				p := 0.
+				if ee, ok, _ := sampled.Search(s); ok {
+					p = ee.Val().(*Set).Proba
+				}
+				// Within four standard deviations of the exact probability
+				if math.Abs(p - s.Proba) > 4 * math.Sqrt(s.Proba * (1 - s.Proba) / float64(nb)) {
+					t.Errorf("%d samples: probability %f instead of %f", nb, p, s.Proba)
+				}
+			}
+		}
+	})
+}
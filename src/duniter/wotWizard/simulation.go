@@ -0,0 +1,185 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package wotWizard
+
+// What-if simulations: hypothetical certifications and membership applications are laid over the File before the computation of entries
+
+import (
+	
+	A	"util/avl"
+	B	"duniter/blockchain"
+	M	"util/misc"
+	S	"duniter/sandbox"
+		"math"
+		"time"
+
+)
+
+type (
+	
+	// Hypothetical certification from From to the identity of hash To, sent at the date Date
+	HypoCertif struct {
+		From B.Pubkey
+		To B.Hash
+		Date int64
+	}
+	
+	// Hypothetical membership application of the identity of hash Hash, sent at the date Date
+	HypoMembership struct {
+		Hash B.Hash
+		Date int64
+	}
+	
+	// Certification from From to the identity of hash To, supposed to never reach the blockchain
+	CertifLink struct {
+		From B.Pubkey
+		To B.Hash
+	}
+	
+	// Set of hypotheses laid over the sandbox; membership applications are applied first, then removed certifications, then extra certifications
+	Hypothesis struct {
+		ExtraCerts []HypoCertif
+		ExtraMemberships []HypoMembership
+		RemovedCerts []CertifLink
+	}
+
+)
+
+// Extract f from the blockchain and the sandbox, like FillFile(B.Pars().SigQty), but with the hypotheses of h laid over the sandbox; hypotheses Duniter would refuse (certification sent by a non-member, membership application of a member or of an unknown identity, ...) are ignored
+func SimulateFile (h *Hypothesis) (f File, cNb, dNb int) {
+	
+	// Return the rank of the certification from from in certs, or -1 if none
+	find := func (certs File, from B.Pubkey) int {
+		for i, cd := range certs {
+			if *cd.(*Certif).fromP == from {
+				return i
+			}
+		}
+		return -1
+	} //find
+	
+	// SimulateFile
+	var g File
+	g, cNb, _ = FillFile(0)
+	now := B.Now()
+	sigQty := int(B.Pars().SigQty)
+	ds := make([]*Dossier, 0) // Dossiers, in the order of g, new ones last
+	dossiers := make(map[B.Hash] *Dossier) // The same, by hashes
+	certs := make([]*Certif, 0) // Internal certifications
+	for _, cd := range g {
+		switch cd := cd.(type) {
+		case *Dossier:
+			ds = append(ds, cd)
+			dossiers[*cd.Hash] = cd
+		case *Certif:
+			certs = append(certs, cd)
+		}
+	}
+	
+	for _, hm := range h.ExtraMemberships {
+		date := M.Max64(hm.Date, now)
+		if d, ok := dossiers[hm.Hash]; ok {
+			d.MinDate = M.Max64(d.MinDate, date)
+			continue
+		}
+		p, inBC := B.IdHash(hm.Hash)
+		if !inBC {
+			continue
+		}
+		uid, member, _, _, app, exp, b := B.IdPubComplete(p); M.Assert(b, 100)
+		if member || exp < 0 { // Members renew their memberships, leavers and revoked identities can't come back
+			continue
+		}
+		leTi, _, b := B.TimeOf(app); M.Assert(b, 101)
+		d := &Dossier{MinDate: M.Max64(leTi + int64(B.Pars().MsPeriod), date), limit: date + int64(B.Pars().MsWindow), Id: new(string), Hash: new(B.Hash), pub: new(B.Pubkey)}
+		*d.Id = uid; *d.Hash = hm.Hash; *d.pub = p
+		d.Certifs = certifsTo(d, true)
+		ds = append(ds, d)
+		dossiers[hm.Hash] = d
+	}
+	
+	for _, r := range h.RemovedCerts {
+		if d, ok := dossiers[r.To]; ok {
+			if i := find(d.Certifs, r.From); i >= 0 {
+				d.Certifs = append(d.Certifs[:i], d.Certifs[i + 1:]...)
+			}
+		}
+		for i, c := range certs {
+			if *c.fromP == r.From && *c.ToH == r.To {
+				certs = append(certs[:i], certs[i + 1:]...)
+				break
+			}
+		}
+		if to, _, _, ok := S.Cert(r.From, r.To); ok {
+			if _, member, _, _, _, _, b := B.IdPubComplete(to); b && member {
+				cNb--
+			}
+		}
+	}
+	
+	for _, hc := range h.ExtraCerts {
+		if !canCertify(hc.From) {
+			continue
+		}
+		from, b := B.IdPub(hc.From); M.Assert(b, 102)
+		sent := M.Max64(hc.Date, now)
+		date := M.Max64(sent, fixCertNextDate(hc.From))
+		limit := sent + int64(B.Pars().SigWindow)
+		c := &Certif{date: date, limit: limit, From: new(string), ToH: new(B.Hash), fromP: new(B.Pubkey)}
+		*c.From = from; *c.ToH = hc.To; *c.fromP = hc.From
+		if d, ok := dossiers[hc.To]; ok {
+			if find(d.Certifs, hc.From) < 0 && M.Max64(date, d.MinDate) <= limit {
+				c.To = d.Id; c.ToH = d.Hash
+				d.Certifs = append(d.Certifs, c)
+			}
+		} else if p, inBC := B.IdHash(hc.To); inBC && date <= limit {
+			if uid, member, _, _, _, _, b := B.IdPubComplete(p); b && member {
+				c.To = &uid
+				certs = append(certs, c)
+				cNb++
+			}
+		}
+	}
+	
+	// Keep only the dossiers which could enter, and the internal certifications whose senders are also senders of certifications in these dossiers
+	useful := A.New()
+	dNb = 0
+	f = make(File, 0)
+	for _, d := range ds {
+		if d.fixPrinc() && len(d.Certifs) >= sigQty {
+			dNb++
+			f = append(f, d)
+			d.usefulSenders(useful)
+		}
+	}
+	if dNb == 0 {
+		return
+	}
+	for _, c := range certs {
+		if _, b, _ := useful.Search(&pubSet{p: *c.fromP}); b {
+			f = append(f, c)
+		}
+	}
+	sortFile(f, 0)
+	return
+} //SimulateFile
+
+// Calculate the set of entries with the hypotheses of h, like BuildEntries
+func Simulate (h *Hypothesis) (f File, cNb, dNb int, permutations *Permutations, occurDate, occurName *A.Tree, duration int64, samples int, run Run) {
+	ti := time.Now()
+	f, cNb, dNb = SimulateFile(h)
+	permutations, occurDate, occurName, samples, run = CalcEntries(f)
+	duration = int64(math.Round(time.Since(ti).Seconds()))
+	return
+} //Simulate
+
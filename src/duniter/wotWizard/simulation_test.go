@@ -0,0 +1,170 @@
+package wotWizard
+
+import (
+
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	F	"path/filepath"
+	G	"util/graphQL"
+	J	"encoding/json"
+	M	"util/misc"
+	S	"duniter/sandbox"
+	_	"util/graphQL/static"
+	_	"util/json/static"
+		"flag"
+		"os"
+		"strings"
+		"testing"
+
+)
+
+type (
+
+	// Request of regression.json, with its expected response
+	regression struct {
+		Title,
+		Query string
+		Response struct {
+			Data struct {
+				Simulate simulation
+			}
+		}
+	}
+
+	// Fields of a response to a simulate request
+	simulation struct {
+		Dossiers_nb int
+		Certifs_nb int // 0 if not requested
+		ForecastsByDates []forecast
+	}
+
+	forecast struct {
+		Id struct {
+			Uid,
+			Status string
+		}
+		Date int64
+		After bool
+		Proba float64
+	}
+
+)
+
+func TestMain (m *testing.M) {
+	dir, err := os.MkdirTemp("", "wotWizard"); M.Assert(err == nil, err, 100)
+	BA.Configure(dir, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	B.Initialize()
+	S.Initialize()
+	Initialize()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Directory of the fixture name
+func fixture (name string) string {
+	dir, err := F.Abs(F.Join("..", "..", "..", "rsrc", "duniter", "Fixtures", name)); M.Assert(err == nil, err, 100)
+	return dir
+} //fixture
+
+// Hypotheses given by the arguments of the field simulate of the GraphQL request query
+func hypothesis (t *testing.T, query string) *Hypothesis {
+
+	// Value of the field name of o, or nil if absent
+	field := func (o *G.InputObjectValue, name string) G.Value {
+		var v G.Value
+		if G.GetObjectValueInputField(o, name, &v) {
+			return v
+		}
+		return nil
+	} //field
+
+	str := func (o *G.InputObjectValue, name string) string {
+		if v, ok := field(o, name).(*G.StringValue); ok {
+			return v.String.S
+		}
+		return ""
+	} //str
+
+	date := func (o *G.InputObjectValue) int64 {
+		if v, ok := field(o, "date").(*G.IntValue); ok {
+			return v.Int
+		}
+		return 0
+	} //date
+
+	//hypothesis
+	doc, r := G.ReadString(query)
+	M.Assert(doc != nil && r.Errors().IsEmpty(), query, 100)
+	f := doc.Defs[0].(*G.OperationDefinition).SelSet[0].(*G.Field)
+	M.Assert(f.Name.S == "simulate", f.Name.S, 101)
+	h := new(Hypothesis)
+	for _, a := range f.Arguments {
+		l, ok := a.Value.(*G.ListValue); M.Assert(ok, a.Name.S, 102)
+		for e := l.First(); e != nil; e = l.Next(e) {
+			o := e.Value.(*G.InputObjectValue)
+			switch a.Name.S {
+			case "extraCerts":
+				h.ExtraCerts = append(h.ExtraCerts, HypoCertif{From: B.Pubkey(str(o, "from")), To: B.Hash(str(o, "to")), Date: date(o)})
+			case "extraMemberships":
+				h.ExtraMemberships = append(h.ExtraMemberships, HypoMembership{Hash: B.Hash(str(o, "hash")), Date: date(o)})
+			case "removedCerts":
+				h.RemovedCerts = append(h.RemovedCerts, CertifLink{From: B.Pubkey(str(o, "from")), To: B.Hash(str(o, "to"))})
+			default:
+				t.Fatal("Unknown argument", a.Name.S)
+			}
+		}
+	}
+	return h
+} //hypothesis
+
+// Status of the identity of hash h, as given by the GraphQL server
+func status (h B.Hash) string {
+	p, ok := B.IdHash(h)
+	if !ok {
+		return "NEWCOMER"
+	}
+	_, member, _, _, _, exp, _ := B.IdPubComplete(p)
+	switch {
+	case member:
+		return "MEMBER"
+	case exp == BA.Revoked:
+		return "REVOKED"
+	default:
+		return "MISSING"
+	}
+} //status
+
+// Run the simulate requests of the file regression.json of the fixture expiry and compare their results with the expected ones
+func TestRegression (t *testing.T) {
+	dir := fixture("expiry")
+	bs, err := os.ReadFile(F.Join(dir, "regression.json")); M.Assert(err == nil, err, 100)
+	var rs []regression
+	err = J.Unmarshal(bs, &rs); M.Assert(err == nil, err, 101)
+	M.Want(len(rs) > 0, t)
+	B.UpdateOffline(dir, func () {
+		M.Want(B.LastBlock() == 100, t)
+		for _, r := range rs {
+			_, cNb, dNb, _, occurDate, _, _, _, _ := Simulate(hypothesis(t, r.Query))
+			want := r.Response.Data.Simulate
+			got := simulation{Dossiers_nb: dNb, ForecastsByDates: make([]forecast, 0)}
+			if strings.Contains(r.Query, "certifs_nb") {
+				got.Certifs_nb = cNb
+			}
+			for e := occurDate.Next(nil); e != nil; e = occurDate.Next(e) {
+				p := e.Val().(*PropDate)
+				var fc forecast
+				fc.Id.Uid = p.Id; fc.Id.Status = status(p.Hash)
+				fc.Date = p.Date; fc.After = p.After; fc.Proba = p.Proba
+				got.ForecastsByDates = append(got.ForecastsByDates, fc)
+			}
+			ok := got.Dossiers_nb == want.Dossiers_nb && got.Certifs_nb == want.Certifs_nb && len(got.ForecastsByDates) == len(want.ForecastsByDates)
+			for i := 0; ok && i < len(want.ForecastsByDates); i++ {
+				ok = got.ForecastsByDates[i] == want.ForecastsByDates[i]
+			}
+			if !ok {
+				t.Errorf("%s: got %+v, want %+v", r.Title, got, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,105 @@
+/*
+WotWizard
+
+Copyright (C) 2017-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License  for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA  02111-1307, USA.
+*/
+
+package wotWizard
+
+// Members whose certifications would help a newcomer to fulfill the distance rule
+
+import (
+	
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	M	"util/misc"
+	S	"duniter/sandbox"
+		"util/sort"
+
+)
+
+type (
+	
+	// Member who could certify a newcomer
+	Suggestion struct {
+		Certifier B.Pubkey
+		uid string
+		Distance, // Distance value of the newcomer with the certification of Certifier added to the available ones
+		Gain float64 // Increase of the distance value brought by this certification
+		Date int64 // Date from which Certifier may send the certification
+	}
+	
+	suggestions []Suggestion
+	
+	suggestionSort struct {
+		s suggestions
+	}
+
+)
+
+func (s *suggestionSort) Swap (p1, p2 int) {
+	s.s[p1], s.s[p2] = s.s[p2], s.s[p1]
+}
+
+func (s *suggestionSort) Less (p1, p2 int) bool {
+	s1 := &s.s[p1]; s2 := &s.s[p2]
+	return s1.Gain > s2.Gain || s1.Gain == s2.Gain && (s1.Date < s2.Date || s1.Date == s2.Date && BA.CompP(s1.uid, s2.uid) == BA.Lt)
+}
+
+// Return at most count members whose certifications toward the newcomer or missing identity of hash hash would raise the most its distance value, i.e. the proportion of sentries reached by its certifiers, along with this value for the certifications available now in base; only members who may still send certifications (according to B.pars.sigStock) and who may send them before the expiration of the membership application (according to B.pars.sigPeriod) are considered, and the members who don't raise the distance value are left out; the result is sorted by decreasing gains, then by dates
+func SuggestCertifiers (hash B.Hash, count int) (base float64, ss []Suggestion) {
+	ss = make([]Suggestion, 0)
+	d := &Dossier{Id: new(string), Hash: new(B.Hash), pub: new(B.Pubkey)}
+	*d.Hash = hash
+	idInBC, p, _, _, limit, ok := S.IdHash(hash)
+	if !ok {
+		p, idInBC = B.IdHash(hash)
+		if !idInBC {
+			return
+		}
+		_, member, _, _, _, exp, b := B.IdPubComplete(p); M.Assert(b, 100)
+		if member || exp < 0 {
+			return
+		}
+		limit = BA.Never
+	}
+	*d.pub = p
+	certs := certifsTo(d, idInBC)
+	certifiers := make(B.PubkeysT, len(certs))
+	for i, cd := range certs {
+		certifiers[i] = *cd.(*Certif).fromP
+	}
+	base, gains := B.DistanceGains(certifiers)
+	now := B.Now()
+	for q, g := range gains {
+		if g <= 0 || q == p || !canCertify(q) {
+			continue
+		}
+		i := 0
+		for i < len(certifiers) && certifiers[i] != q {
+			i++
+		}
+		if i < len(certifiers) {
+			continue
+		}
+		date := M.Max64(now, fixCertNextDate(q))
+		if date > limit {
+			continue
+		}
+		uid, b := B.IdPub(q); M.Assert(b, 101)
+		ss = append(ss, Suggestion{Certifier: q, uid: uid, Distance: base + g, Gain: g, Date: date})
+	}
+	var ts sort.TS
+	ts.Sorter = &suggestionSort{s: ss}
+	ts.QuickSort(0, len(ss) - 1)
+	if count < len(ss) {
+		ss = ss[:M.Max(count, 0)]
+	}
+	return
+} //SuggestCertifiers
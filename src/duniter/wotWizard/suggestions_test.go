@@ -0,0 +1,121 @@
+package wotWizard
+
+import (
+
+	B	"duniter/blockchain"
+	F	"path/filepath"
+	M	"util/misc"
+		"fmt"
+		"math"
+		"os"
+		"strings"
+		"testing"
+
+)
+
+const (
+
+	// Parameters of the fixture expiry
+	lineParameters = "0.0488:86400:1000:432000:100:5259600:63115200:3:5259600:5259600:0.8:31557600:5:24:300:12:0.67:1488970800:1490094000:15778800"
+
+	lineTime0 = 1488987127
+
+)
+
+// Pubkey, identity hash and uid of the ith member of the line, or of the missing identity x if i == 0
+func lineId (i int) (p B.Pubkey, h B.Hash, uid string) {
+	if i == 0 {
+		return B.Pubkey("LineX" + strings.Repeat("x", 39)), B.Hash(strings.Repeat("0", 62) + "FF"), "x"
+	}
+	return B.Pubkey(fmt.Sprintf("Line%02d%s", i, strings.Repeat("p", 38))), B.Hash(fmt.Sprintf("%062d%02d", 0, i)), fmt.Sprintf("m%02d", i)
+} //lineId
+
+// Directory of a chain of two blocks, whose members m01 ... m10 form a line, where each member certifies its neighbours; the certification from m09 to m10 is sent in the block 1, so that m09 may certify again later than the others; x, certified by m01, is excluded in the block 1. Since the sentry threshold is 2, the sentries are m02 ... m09, and a certifier reaches, in four steps, the members at most four places away from it
+func line (t *testing.T) string {
+	var joiners, certs, ids []string
+	for i := 0; i <= 10; i++ {
+		p, h, uid := lineId(i)
+		joiners = append(joiners, fmt.Sprintf(`"%s:sig:0-%064d:0-%064d:%s"`, p, 0, 0, uid))
+		ids = append(ids, fmt.Sprintf(`{"pub": "%s", "hash": "%s"}`, p, h))
+	}
+	cert := func (i, j int) string {
+		p, _, _ := lineId(i)
+		q, _, _ := lineId(j)
+		return fmt.Sprintf(`"%s:%s:0:sig"`, p, q)
+	}
+	certs = append(certs, cert(1, 0))
+	for i := 1; i < 10; i++ {
+		if i < 9 {
+			certs = append(certs, cert(i, i + 1))
+		}
+		certs = append(certs, cert(i + 1, i))
+	}
+	x, _, _ := lineId(0)
+	blocks := []string{
+		fmt.Sprintf(`{"number": 0, "hash": "%058d%06X", "medianTime": %d, "time": %d, "parameters": "%s", "joiners": [%s], "actives": [], "leavers": [], "revoked": [], "excluded": [], "certifications": [%s], "identities": [%s]}`, 0, 0xF1000, lineTime0, lineTime0 + 5, lineParameters, strings.Join(joiners, ", "), strings.Join(certs, ", "), strings.Join(ids, ", ")),
+		fmt.Sprintf(`{"number": 1, "hash": "%058d%06X", "medianTime": %d, "time": %d, "joiners": [], "actives": [], "leavers": [], "revoked": [], "excluded": ["%s"], "certifications": [%s], "identities": []}`, 0, 0xF1001, lineTime0 + 300, lineTime0 + 305, x, cert(9, 10)),
+	}
+	dir := t.TempDir()
+	err := os.WriteFile(F.Join(dir, "blocks.jsonl"), []byte(strings.Join(blocks, "\n") + "\n"), 0644); M.Assert(err == nil, err, 100)
+	return dir
+} //line
+
+// Pubkeys of the members of the line of ranks is
+func linePubkeys (is ... int) B.PubkeysT {
+	ps := make(B.PubkeysT, len(is))
+	for k, i := range is {
+		ps[k], _, _ = lineId(i)
+	}
+	return ps
+} //linePubkeys
+
+func TestDistanceGains (t *testing.T) {
+	B.UpdateOffline(line(t), func () {
+		M.Want(B.LastBlock() == 1 && B.SentriesLen() == 8, t)
+		for _, certifiers := range []B.PubkeysT{{}, linePubkeys(1), linePubkeys(1, 10), linePubkeys(3, 4)} {
+			base, gains := B.DistanceGains(certifiers)
+			M.Want(base == B.Distance(append(B.PubkeysT(nil), certifiers...)), t)
+			for i := 1; i <= 10; i++ {
+				p := linePubkeys(i)[0]
+				want := B.Distance(append(append(B.PubkeysT(nil), certifiers...), p)) - base
+				if math.Abs(gains[p] - want) > 1e-9 {
+					t.Errorf("Gain of m%02d for %v: got %v, want %v", i, certifiers, gains[p], want)
+				}
+			}
+		}
+	})
+}
+
+func TestSuggestCertifiers (t *testing.T) {
+	B.UpdateOffline(line(t), func () {
+		_, h, _ := lineId(0)
+		base, ss := SuggestCertifiers(h, 20)
+		M.Want(base == 0.5, t) // m01 reaches m02 ... m05
+		type sugg struct {
+			uid string
+			gain float64
+			date int64
+		}
+		date := int64(lineTime0 + B.Pars().SigPeriod)
+		want := []sugg{
+			{"m05", 0.5, date}, {"m06", 0.5, date}, {"m07", 0.5, date}, {"m08", 0.5, date}, {"m10", 0.5, date},
+			{"m09", 0.5, date + 300}, // m09 has certified m10 later
+			{"m04", 0.375, date}, {"m03", 0.25, date}, {"m02", 0.125, date},
+		}
+		ok := len(ss) == len(want)
+		for i := 0; ok && i < len(want); i++ {
+			s := ss[i]
+			ok = s.uid == want[i].uid && s.Gain == want[i].gain && s.Distance == base + s.Gain && s.Date == want[i].date
+		}
+		if !ok {
+			t.Errorf("Got %+v, want %+v", ss, want)
+		}
+		_, ss = SuggestCertifiers(h, 3)
+		M.Want(len(ss) == 3 && ss[2].uid == "m07", t)
+
+		// m05 is a member: no suggestion
+		_, h, _ = lineId(5)
+		base, ss = SuggestCertifiers(h, 20)
+		M.Want(base == 0 && len(ss) == 0, t)
+	})
+}
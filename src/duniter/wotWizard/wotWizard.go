@@ -12,10 +12,6 @@ You should have received a copy of the GNU General Public License along with thi
 
 package wotWizard
 
-/**************************************************************************************
-Bug : Dans calcRec, si la date de passage d'un dossier devient supérieure à la date limite d'une de ses certifications, il faut en tenir compte : enlever la certification, recalculer PrincCertif, etc...
-***************************************************************************************/
-
 // For versions 1.4+ of Duniter
 // This version suppose equiprobable all external certifications (toward a non-member identity) which are concurrent at the same date, and process concurrent internal certifications (toward an already-member identity) afterwards
 
@@ -27,14 +23,12 @@ import (
 	M	"util/misc"
 	S	"duniter/sandbox"
 		"math"
+		"runtime"
+		"sync"
+		"sync/atomic"
 		"time"
 		"unsafe"
-
-)
-
-const (
-	
-	maxSizeDef = 430000000 // Default value for the greatest allowed allocated memory size
+		"util/alea"
 
 )
 
@@ -72,6 +66,13 @@ type (
 		PrincCertif int // Rank of the certification whose entry date gives the entry date of the dossier (1 <= PrincCertif <= len(Certifs)
 		ProportionOfSentries float64 // Proportion of sentries reachable through B.pars.stepMax steps
 		Certifs File // Array of certifications
+		lost bool // Certifications have expired before the entry date and the remaining ones don't allow the entry any more
+		wot web // Web of trust where the distance rule is verified; the current one if nil
+	}
+	
+	// Web of trust, giving the % of sentries reached by arrays of certifiers' pubkeys
+	web interface {
+		Distance (pubkeys B.PubkeysT) float64
 	}
 )
 
@@ -100,6 +101,7 @@ type (
 		Date int64 // A possible date of her entry
 		After bool // After = true if this entry can occur at the date Date or after (uncertainty due to incomplete computation)
 		Proba float64 // Probability of this entry
+		Margin float64 // Half-width of the 95% confidence interval of Proba when it's estimated by sampling, 0 otherwise
 	}
 	
 	// AVL trees of PropName and PropDate are the ouput formats of procedures CalcEntries and BuildEntries
@@ -120,66 +122,107 @@ type (
 		date int64
 	}
 	
-	// The virtual stack elements (stored in a queue) used in the main procedure CalcPermutations and representing the tree of all possible permutations of Dossier(s) in the final order of the File f
+	// Nodes of the tree of all possible permutations of Dossier(s) in the final order of the File f, used in the main procedure CalcPermutations and processed level by level
 	node struct {
-		next, // Next node in the queue (or brother in the possibilities tree)
-		stack *node // Previous node in the stack (or father in the possibilities tree)
+		father *node // Father in the possibilities tree
 		f File // The File used at this point
 		step, // Current position in f
-		sons, // Number of sons in the possibilities tree
-		seenSons int // Number of sons already seen
+		first, // Rank in father.f of the Dossier entering first in this node
+		sons int // Number of sons in the possibilities tree
 		sets *A.Tree; // Set of permutations of entries of Dossier(s) found in the descendants of this node
 	}
 	
-	// Queue (or fifo list) of node(s)
-	queue struct {
-		end *node
+	// Memory budget of CalcPermutations, safe for concurrent use
+	budget struct {
+		used,
+		max int64
 	}
 	
 	// Set of Pubkeys, used in FillFile
 	pubSet struct {
 		p B.Pubkey
 	}
+	
+	// Settings of the computation of permutations
+	Settings struct {
+		MaxSize int64 // Maximum allowed memory size for the computation of the permutations of each independent cluster of a File
+		TimeBudget time.Duration // Maximum duration of the computation of the permutations of a File; no limit if 0
+		Window int64 // Dossier(s) whose dates differ by less than Window seconds are concurrent; B.Pars().AvgGenTime if 0
+	}
+	
+	// Effective settings of a computation of entries, and whether its budgets were exceeded
+	Run struct {
+		Settings
+		MemoryExceeded, // Some permutations were not computed since MaxSize was exceeded
+		TimeExceeded bool // Some permutations were not computed since TimeBudget was exceeded
+	}
 
 )
 
 var (
 	
-	// Maximum allowed memory size for the execution of CalcPermutations
-	maxSize int64 = maxSizeDef
+	// Settings of CalcPermutations, guarded by settingsMut; set by readSettings
+	settings Settings
+	settingsMut = new(sync.RWMutex)
+	
+	// When permutations are sampled instead of being all computed: BA.SamplingNever, BA.SamplingAuto (when maxSize is exceeded) or BA.SamplingAlways
+	sampling string
+	// Number of samples and seed of the random generator, for sampling
+	samplesNb int
+	samplingSeed int64
 
 )
 
-// Standard managing procedures for queue
-
-func (q *queue) init () {
-	q.end = nil
+func (b *budget) add (size int64) {
+	atomic.AddInt64(&b.used, size)
 }
 
-func (q *queue) isEmpty () bool {
-	return q.end == nil
+// Say whether the budget is not exceeded
+func (b *budget) ok () bool {
+	return atomic.LoadInt64(&b.used) <= b.max
 }
 
-func (q *queue) put (n *node) {
-	if q.end == nil {
-		q.end = n
-		n.next = n
-	} else {
-		n.next = q.end.next
-		q.end.next = n
-		q.end = n
+// Run do(i) for all 0 <= i < n, on at most runtime.GOMAXPROCS(0) goroutines; a panic in do is raised again in the calling goroutine
+func parallel (n int, do func (i int)) {
+	w := runtime.GOMAXPROCS(0)
+	if w > n {
+		w = n
 	}
-}
-
-func (q *queue) get () (n *node) {
-	M.Assert(q.end != nil, 20)
-	n = q.end.next
-	q.end.next = n.next
-	if q.end == n {
-		q.end = nil
+	if w <= 1 {
+		for i := 0; i < n; i++ {
+			do(i)
+		}
+		return
 	}
-	return
-}
+	next := int64(-1)
+	var (
+		wg sync.WaitGroup
+		mut sync.Mutex
+		failure interface{}
+	)
+	wg.Add(w)
+	for k := 0; k < w; k++ {
+		go func () {
+			defer func () {
+				if r := recover(); r != nil {
+					mut.Lock()
+					if failure == nil {
+						failure = r
+					}
+					mut.Unlock()
+				}
+				wg.Done()
+			}()
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				do(i)
+			}
+		}()
+	}
+	wg.Wait()
+	if failure != nil {
+		panic(failure)
+	}
+} //parallel
 
 // Comparison procedures for Propagation, PropName, PropDate and Set
 
@@ -305,12 +348,29 @@ func (p *PropName) Copy () A.Copier {
 	return q
 }
 
-// Copy f and return its copy; the copy is shallow for elements of ranks less than deepFrom, and deep afterwards; size is the size of the copy
+// Size of the copy of f made by copyFile(f, deepFrom, ...)
+func fileSize (f File, deepFrom int) (size int64) {
+	var g File
+	size = int64(unsafe.Sizeof(g)) + int64(len(f)) * int64(unsafe.Sizeof(g[0]))
+	for i := deepFrom; i < len(f); i++ {
+		switch cd := f[i].(type) {
+		case *Certif:
+			size += int64(unsafe.Sizeof(*cd))
+		case *Dossier:
+			size += int64(unsafe.Sizeof(*cd)) + int64(unsafe.Sizeof(g)) + int64(len(cd.Certifs)) * (int64(unsafe.Sizeof(g[0])) + int64(unsafe.Sizeof(Certif{})))
+		}
+	}
+	return
+}
+
+// Copy f and return its copy; the copy is shallow for elements of ranks less than deepFrom, and deep afterwards; if size != nil, the size of the copy is added to *size
 func copyFile (f File, deepFrom int, size *int64) File {
 	l := len(f)
 	M.Assert(deepFrom <= l, 20)
+	if size != nil {
+		*size += fileSize(f, deepFrom)
+	}
 	g := make(File, l)
-	*size += int64(unsafe.Sizeof(g)) + int64(l) * int64(unsafe.Sizeof(g[0]))
 	for i := 0; i < deepFrom; i++ {
 		g[i] = f[i]
 	}
@@ -319,19 +379,15 @@ func copyFile (f File, deepFrom int, size *int64) File {
 		case *Certif:
 			c := new(Certif)
 			*c = *cd
-			*size += int64(unsafe.Sizeof(*c))
 			g[i] = c
 		case *Dossier:
 			d := new(Dossier)
 			*d = *cd
-			*size += int64(unsafe.Sizeof(*d))
 			g[i] = d
 			h := make(File, len(cd.Certifs))
-			*size += int64(unsafe.Sizeof(h)) + int64(len(cd.Certifs)) * int64(unsafe.Sizeof(h[0]))
 			for j := 0; j < len(cd.Certifs); j++ {
 				c := new(Certif)
 				*c = *cd.Certifs[j].(*Certif)
-				*size += int64(unsafe.Sizeof(*c))
 				h[j] = c
 			}
 			d.Certifs = h
@@ -340,7 +396,15 @@ func copyFile (f File, deepFrom int, size *int64) File {
 	return g
 }
 
-func calcPrinc (certifs File) (princCertif int) {
+// Array of certifiers' pubkeys -> % of sentries reached in the web of trust wot, or in the current one if wot == nil
+func distance (wot web, certifiers B.PubkeysT) float64 {
+	if wot == nil {
+		return B.Distance(certifiers)
+	}
+	return wot.Distance(certifiers)
+} //distance
+
+func calcPrinc (certifs File, wot web) (princCertif int) {
 	n := len(certifs)
 	princCertif = int(B.Pars().SigQty) - 1
 	var ok bool
@@ -350,7 +414,7 @@ func calcPrinc (certifs File) (princCertif int) {
 		for j := 0; j < princCertif; j++ {
 			certifiers[j] = *certifs[j].(*Certif).fromP
 		}
-		ok = B.DistanceRuleOk(certifiers)
+		ok = distance(wot, certifiers) >= B.Pars().Xpercent
 		if ok || princCertif == n {break}
 	}
 	M.Assert(ok, 60)
@@ -374,20 +438,41 @@ func fixCertNextDate (p B.Pubkey) (d int64) {
 	return
 }
 
-// Fix the disponibility dates of the elements of f, always after the current date
-func fileDates (f File) {
-	for i := 0; i < len(f); i++ {
+// Fix the entry date of d, always after d.MinDate; if d has at least B.pars.sigQty certifications, those whose limit dates are before the entry date are removed, PrincCertif is computed again, and the entry becomes impossible if the remaining certifications are not sufficient any more
+func (d *Dossier) fixDate () {
+	for {
+		if len(d.Certifs) == 0 || d.lost {
+			d.date = 0
+		} else {
+			d.date = d.Certifs[d.PrincCertif - 1].(*Certif).date
+		}
+		d.date = M.Max64(d.date, d.MinDate)
+		if d.lost || d.date > d.limit {
+			d.date = BA.Never
+		}
+		if d.date == BA.Never || d.PrincCertif < int(B.Pars().SigQty) {
+			return
+		}
+		certs := make(File, 0, len(d.Certifs))
+		for _, cd := range d.Certifs {
+			if cd.(*Certif).limit >= d.date {
+				certs = append(certs, cd)
+			}
+		}
+		if len(certs) == len(d.Certifs) {
+			return
+		}
+		d.Certifs = certs
+		d.lost = !d.fixPrinc() || len(d.Certifs) < int(B.Pars().SigQty)
+	}
+} //fixDate
+
+// Fix the disponibility dates of the elements of f, starting at position i0, always after the current date; the Dossier(s) before i0 have already entered and their dates don't change any more (and they may be shared with other copies of f, see CalcPermutations)
+func fileDates (f File, i0 int) {
+	for i := i0; i < len(f); i++ {
 		switch cd := f[i].(type) {
 		case *Dossier:
-			if len(cd.Certifs) == 0 {
-				cd.date = 0
-			} else {
-				cd.date = cd.Certifs[cd.PrincCertif - 1].(*Certif).date
-			}
-			cd.date = M.Max64(cd.date, cd.MinDate)
-			if cd.date > cd.limit {
-				cd.date = BA.Never
-			}
+			cd.fixDate()
 		default:
 		}
 	}
@@ -437,13 +522,13 @@ func sortFile (f File, i0 int) {
 	for i := i0; i < len(f); i++ {
 		switch cd := f[i].(type) {
 		case *Dossier:
-			if sortAll(cd.Certifs, 0, cd.PrincCertif - 1) {
-				cd.PrincCertif = calcPrinc(cd.Certifs)
+			if sortAll(cd.Certifs, 0, cd.PrincCertif - 1) && !cd.lost {
+				cd.PrincCertif = calcPrinc(cd.Certifs, cd.wot)
 			}
 		default:
 		}
 	}
-	fileDates(f)
+	fileDates(f, i0)
 	sortAll(f, i0, 0)
 } //sortFile
 
@@ -572,7 +657,7 @@ func sortPubList (c **pubList) {
 }
 
 // Say whether the list of certifiers' Pubkey(s) c verifies the Duniter's distance rule and gives, in proportionOfSentries the proportion of sentries members reachable in less than B.pars.stepMax steps
-func notTooFar (c **pubList, n int) (needed int, proportionOfSentries float64, ok bool) {
+func notTooFar (c **pubList, n int, wot web) (needed int, proportionOfSentries float64, ok bool) {
 	if n == 0 {
 		needed = 0
 		proportionOfSentries = 0.
@@ -586,7 +671,7 @@ func notTooFar (c **pubList, n int) (needed int, proportionOfSentries float64, o
 			cc = cc.next
 		}
 		needed = n
-		proportionOfSentries = B.Distance(certifiers)
+		proportionOfSentries = distance(wot, certifiers)
 		ok = proportionOfSentries >= B.Pars().Xpercent
 	} else {
 		sortPubList(c)
@@ -599,7 +684,7 @@ func notTooFar (c **pubList, n int) (needed int, proportionOfSentries float64, o
 				certifiers[j] = *cc.pub
 				cc = cc.next
 			}
-			proportionOfSentries = B.Distance(certifiers)
+			proportionOfSentries = distance(wot, certifiers)
 			ok = proportionOfSentries >= B.Pars().Xpercent
 			if ok || needed == n {break}
 		}
@@ -607,22 +692,155 @@ func notTooFar (c **pubList, n int) (needed int, proportionOfSentries float64, o
 	return
 }
 
-// WotWizard main procedure; return in sets all the elements of type Set, i.e. all the possible permutations in the order of entries of the Dossier(s) in f, along with their probabilities
-func CalcPermutations (f File) *A.Tree {
+// Say whether the identity p may send a new certification: it is a member and has not sent B.pars.sigStock certifications yet
+func canCertify (p B.Pubkey) bool {
+	_, member, _, _, _, _, ok := B.IdPubComplete(p)
+	var posBF B.CertPos
+	return ok && member && (!B.CertFrom(p, &posBF) || posBF.CertPosLen() < int(B.Pars().SigStock))
+} //canCertify
+
+// Return the certifications toward d which could be used in d, those of the blockchain first (if idInBC) and then those of the sandbox; don't consider certifications sent by a non-member or by a member who already has sent sigStock (100) certifications or certifications whose limit date is smaller than d.MinDate
+func certifsTo (d *Dossier, idInBC bool) File {
+	certs := make(File, 0)
+	var posB B.CertPos
+	if idInBC && B.CertTo(*d.pub, &posB) {
+		from, to, okP := posB.CertNextPos()
+		for okP {
+			if canCertify(from) {
+				_, exp, b := B.Cert(from, to); M.Assert(b, 100)
+				if exp > d.MinDate {
+					c := &Certif{date: BA.Already, limit: exp, From: new(string), To: d.Id, ToH: d.Hash}
+					c.fromP = new(B.Pubkey); *c.fromP = from
+					*c.From, b = B.IdPub(from); M.Assert(b, 101)
+					certs = append(certs, c)
+				}
+			}
+			from, to, okP = posB.CertNextPos()
+		}
+	}
+	k := len(certs)
+	var pos S.CertPos
+	if S.CertTo(*d.Hash, &pos) {
+		from, toHash, okP := pos.CertNextPos()
+		for okP {
+			i := 0
+			for i < k && *certs[i].(*Certif).fromP != from {
+				i++
+			}
+			if i >= k && canCertify(from) {
+				_, _, exp, b := S.Cert(from, toHash); M.Assert(b, 102)
+				date := fixCertNextDate(from)
+				if M.Max64(date, d.MinDate) <= exp { // Not-expired certification
+					c := &Certif{date: date, limit: exp, From: new(string), To: d.Id, ToH: d.Hash}
+					c.fromP = new(B.Pubkey); *c.fromP = from
+					*c.From, b = B.IdPub(from); M.Assert(b, 103)
+					certs = append(certs, c)
+				}
+			}
+			from, toHash, okP = pos.CertNextPos()
+		}
+	}
+	return certs
+} //certifsTo
+
+// Fix d.PrincCertif and d.ProportionOfSentries with the help of notTooFar, and say whether the certifications of d verify the distance rule
+func (d *Dossier) fixPrinc () (ok bool) {
+	certs := (*pubList)(nil)
+	for _, cd := range d.Certifs {
+		c := cd.(*Certif)
+		certs = &pubList{pub: c.fromP, date: c.date, next: certs}
+	}
+	d.PrincCertif, d.ProportionOfSentries, ok = notTooFar(&certs, len(d.Certifs), d.wot)
+	return
+} //fixPrinc
+
+// Add to useful the senders of the certifications of d which are not in the blockchain yet
+func (d *Dossier) usefulSenders (useful *A.Tree) {
+	for _, cd := range d.Certifs {
+		if c := cd.(*Certif); c.date != BA.Already {
+			useful.SearchIns(&pubSet{p: *c.fromP})
+		}
+	}
+} //usefulSenders
+
+func isCertif (cd CertOrDoss) bool {
+	_, ok := cd.(*Certif)
+	return ok
+} //isCertif
+
+// Return the end (excluded) of the group of Dossier(s) of f beginning at step whose dates are too close, i.e. closer than window, for their order of entry to be known; return step + 1 if f[step] is not concurrent with f[step + 1]
+func concurrentEnd (f File, step int, window int64) int {
+	j := step + 1
+	if isCertif(f[step]) {
+		return j
+	}
+	for j < len(f) && !isCertif(f[j]) && f[j].Date() - f[step].Date() < window {
+		j++
+	}
+	return j
+} //concurrentEnd
+
+// Return the set containing, as unique element, the list of entries in f; the entries of the Dossier(s) beyond step may occur later
+func setOf (f File, step int) *Set {
+	set := &Set{Proba: 1., T: A.New()}
+	for i := 0; i < len(f); i++ {
+		switch cd := f[i].(type) {
+		case *Dossier:
+			_, b, _ := set.T.SearchIns(&Propagation{Hash: *cd.Hash, Id: *cd.Id, Date: cd.date, After: i >= step && (cd.date != BA.Never)}); M.Assert(!b, 100)
+		default:
+		}
+	}
+	return set
+} //setOf
+
+// Draw nb random orders of entries of the Dossier(s) in f, all the concurrent Dossier(s) being equiprobable at each step, and return the set of the permutations obtained, along with their estimated probabilities; the random generator is initialized with samplingSeed, so that the result is reproducible
+func sample (f File, nb int, window int64) *A.Tree {
+	gen := alea.New()
+	gen.Randomize(samplingSeed)
+	sets := A.New()
+	for i := 0; i < nb; i++ {
+		g := copyFile(f, 0, nil)
+		step := 0
+		for step < len(g) && g[step].Date() != BA.Never {
+			if j := concurrentEnd(g, step, window); j > step + 1 {
+				k := step + int(gen.IntRand(0, int64(j - step)))
+				g[k], g[step] = g[step], g[k]
+			}
+			propagate(&g, step)
+			step++
+		}
+		s := setOf(g, step)
+		s.Proba = 0.
+		e, _, _ := sets.SearchIns(s)
+		e.Val().(*Set).Proba++ // Number of draws, for the moment
+	}
+	e := sets.Next(nil)
+	for e != nil {
+		e.Val().(*Set).Proba /= float64(nb)
+		e = sets.Next(e)
+	}
+	return sets
+} //sample
+
+// WotWizard main procedure; return in sets all the elements of type Set, i.e. all the possible permutations in the order of entries of the Dossier(s) in f, along with their probabilities; when they are too many (or always, according to sampling), the permutations are drawn at random instead and samples is the number of draws (0 if all permutations were computed)
+func CalcPermutations (f File) (sets *A.Tree, samples int) {
+	s := CurrentSettings()
+	var deadline time.Time
+	if s.TimeBudget > 0 {
+		deadline = time.Now().Add(s.TimeBudget)
+	}
+	sets, samples, _, _ = calcPermutations(f, s.Effective(), deadline)
+	return
+}
+
+// CalcPermutations with the effective settings s, and the computation of the tree of permutations stopped at deadline, if not zero; memoryExceeded and timeExceeded say whether s.MaxSize or deadline stopped it
+func calcPermutations (f File, s Settings, deadline time.Time) (sets *A.Tree, samples int, memoryExceeded, timeExceeded bool) {
 
 	// Put into n.sets the set containing, as unique element, the list of entries in n.f
 	evaluate := func (n *node) {
 		n.sets = A.New()
-		set := new(Set); set.T = A.New()
-		for i := 0; i < len(n.f); i++ {
-			switch cd := n.f[i].(type) {
-			case *Dossier:
-				_, b, _ := set.T.SearchIns(&Propagation{Hash: *cd.Hash, Id: *cd.Id, Date: cd.date, After: i >= n.step && (cd.date != BA.Never)}); M.Assert(!b, 100)
-			default:
-			}
-		}
+		set := setOf(n.f, n.step)
 		n.f = nil
-		set.Proba = 1.
 		_, b, _ := n.sets.SearchIns(set); M.Assert(!b, 101)
 	}
 	
@@ -643,135 +861,131 @@ func CalcPermutations (f File) *A.Tree {
 	
 	var ok bool
 
-	// Process f in the order of its elements by calling propagate as long as successive Dossier(s) have the same date, and call itself (by creating new sons' nodes) for every first possible entry in a set of Dossier(s) with the same date and with a number of Dossier(s) greater than one, merge all the sets of possible permutations returned in sons' nodes into their father's node. It's not a recursive procedure, but it uses queues (fifo lists) to process all possible events in breadth-first order
-	calcRec := func (f File) (sets *A.Tree) {
-		
-		isCertif := func (cd CertOrDoss) bool {
-			_, ok := cd.(*Certif)
-			return ok
+	// Call propagate on n.f as long as the Dossier entering at n.step has no concurrent
+	forward := func (n *node) {
+		for n.step < len(n.f) && n.f[n.step].Date() != BA.Never && concurrentEnd(n.f, n.step, s.Window) == n.step + 1 {
+			propagate(&n.f, n.step)
+			n.step++
 		}
-		
-		// calcRec
-		stackSize := int64(0)
-		var q1, q2 queue
-		q1.init(); q2.init()
-		root := &node{stack: nil, f: f, step: 0, seenSons: 0, sets: nil}
-		stackSize += int64(unsafe.Sizeof(*root))
-		q1.put(root)
-		for !q1.isEmpty() {
-			n := q1.get()
-			for n.step < len(n.f) && n.f[n.step].Date() != BA.Never && (n.step >= len(n.f) - 1 ||  isCertif(n.f[n.step]) ||  isCertif(n.f[n.step + 1]) || n.f[n.step + 1].Date() - n.f[n.step].Date() >= int64(B.Pars().AvgGenTime)) {
-				propagate(&n.f, n.step)
-				n.step++
-			}
-			ok = ok && stackSize <= maxSize
-			if n.step >= len(n.f) || n.f[n.step].Date() == BA.Never || !ok {
-				evaluate(n)
-				q2.put(n)
-			} else {
-				// Assertion: n.step < len(n.f) - 1 && !isCertif(n.f[n.step]) && !isCertif(n.f[n.step + 1]) && n.f[n.step + 1].date - n.f[n.step].date < int64(B.Pars().AvgGenTime)
-				j := n.step + 2
-				for j < len(n.f) && !isCertif(n.f[j]) && n.f[j].Date() - n.f[n.step].Date() < int64(B.Pars().AvgGenTime) {
-					j++
+	}
+	
+	// Process f in the order of its elements by calling propagate as long as successive Dossier(s) have the same date, and create new sons' nodes for every first possible entry in a set of Dossier(s) with the same date and with a number of Dossier(s) greater than one, merge all the sets of possible permutations returned in sons' nodes into their father's node. The tree is built level by level: the decisions to evaluate or to branch, and the memory they need, are taken in the order of the nodes, so that the result doesn't depend on the scheduling, and then the sons of the level are created and propagated in parallel; the merging is done from the leaves up, the sons of each father in their order, the fathers in parallel
+	calcRec := func (f File) (sets *A.Tree) {
+		bud := &budget{max: s.MaxSize}
+		root := &node{father: nil, f: f, step: 0, sets: nil}
+		bud.add(int64(unsafe.Sizeof(*root)))
+		forward(root)
+		levels := make([][]*node, 0)
+		level := []*node{root}
+		for len(level) > 0 {
+			levels = append(levels, level)
+			leaves := make([]*node, 0)
+			next := make([]*node, 0)
+			for _, n := range level {
+				if ok && !bud.ok() {
+					ok = false
+					memoryExceeded = true
 				}
-				n.sons = j - n.step
-				for j := n.step; j < n.step + n.sons; j++ {
-					m := &node{sons: 0, seenSons: 0, stack: n, f: copyFile(n.f, n.step, &stackSize)}
-					stackSize += int64(unsafe.Sizeof(*m))
-					m.f[j], m.f[n.step] = m.f[n.step], m.f[j]
-					propagate(&m.f, n.step)
-					m.step = n.step + 1
-					m.sets = nil
-					q1.put(m)
+				if ok && !deadline.IsZero() && time.Now().After(deadline) {
+					ok = false
+					timeExceeded = true
 				}
+				if n.step >= len(n.f) || n.f[n.step].Date() == BA.Never || !ok {
+					leaves = append(leaves, n)
+				} else {
+					// Assertion: n.step < len(n.f) - 1 && !isCertif(n.f[n.step]) && !isCertif(n.f[n.step + 1]) && n.f[n.step + 1].date - n.f[n.step].date < s.Window
+					n.sons = concurrentEnd(n.f, n.step, s.Window) - n.step
+					bud.add(int64(n.sons) * (fileSize(n.f, n.step) + int64(unsafe.Sizeof(*n))))
+					for j := n.step; j < n.step + n.sons; j++ {
+						next = append(next, &node{father: n, first: j, sons: 0, sets: nil})
+					}
+				}
+			}
+			parallel(len(leaves), func (i int) {
+				evaluate(leaves[i])
+			})
+			parallel(len(next), func (i int) {
+				m := next[i]; n := m.father
+				m.f = copyFile(n.f, n.step, nil)
+				m.f[m.first], m.f[n.step] = m.f[n.step], m.f[m.first]
+				propagate(&m.f, n.step)
+				m.step = n.step + 1
+				forward(m)
+			})
+			for _, n := range level {
 				n.f = nil
 			}
+			level = next
 		}
-		for !q2.isEmpty() {
-			m := q2.get()
-			if m.seenSons != m.sons {
-				q2.put(m)
-			} else {
-				n := m.stack
-				if n != nil {
-					if n.sets == nil {
-						n.sets = A.New()
-						q2.put(n)
-					}
-					n.seenSons++
-					addProba(n.sets, m.sets, n.sons)
+		for i := len(levels) - 1; i > 0; i-- {
+			sons := levels[i] // The sons of a same father are consecutive
+			starts := make([]int, 0)
+			for j, m := range sons {
+				if j == 0 || m.father != sons[j - 1].father {
+					starts = append(starts, j)
 				}
 			}
+			parallel(len(starts), func (k int) {
+				j := starts[k]
+				n := sons[j].father
+				n.sets = A.New()
+				for ; j < len(sons) && sons[j].father == n; j++ {
+					addProba(n.sets, sons[j].sets, n.sons)
+					sons[j].sets = nil
+				}
+			})
 		}
 		sets = root.sets
 		return
 	}
 	
-	// CalcPermutations
-	ok = true
-	sets := calcRec(f)
+	// calcPermutations
+	samples = 0
+	switch sampling {
+	case BA.SamplingAlways:
+		sets, samples = sample(f, samplesNb, s.Window), samplesNb
+	case BA.SamplingAuto:
+		ok = true
+		sets = calcRec(copyFile(f, 0, nil))
+		if !ok {
+			sets, samples = sample(f, samplesNb, s.Window), samplesNb
+		}
+	default:
+		ok = true
+		sets = calcRec(f)
+	}
 	M.Assert(sets != nil, 60)
-	return sets
+	return
 }
 
-// Merge the Set(s) returned by CalcPermutations and combine their probabilities; return the list of entries sorted by date(s) (occurDate with elements of type PropDate) or by id(s) (occurName with elements of type PropName)
-func CalcEntries (f File) (sets, occurDate, occurName *A.Tree) {
-	sets = CalcPermutations(f)
-	
-	// Computing of Propagation(s) with their proba(s)
-	now := B.Now()
-	occur := A.New()
-	e := sets.Next(nil)
-	for e != nil {
-		s := e.Val().(*Set)
-		ee := s.T.Next(nil)
-		for ee != nil {
-			p := ee.Val().(*Propagation)
-			p.Date = M.Max64(p.Date, now)
-			p.Proba = 0.
-			eee, _, _ := occur.SearchIns(p)
-			p = eee.Val().(*Propagation)
-			p.Proba += s.Proba
-			ee = s.T.Next(ee)
-		}
-		e = sets.Next(e)
-	}
-	
-	// For each uid, with increasing date(s), gather all Propagation(s) following a Propagation with After = true together
-	var pAfter *Propagation
-	id := ""
-	e = occur.Next(nil)
-	for e != nil {
-		ee := occur.Next(e)
-		p := e.Val().(*Propagation)
-		if p.Id != id {
-			id = p.Id
-			pAfter = nil
-		}
-		if pAfter == nil && p.After {
-			pAfter = p
-		} else if pAfter != nil {
-			pAfter.Proba += p.Proba
-			b := occur.Delete(p); M.Assert(b, 100)
-		}
-		e = ee
+// Compute the permutations of the independent clusters of f with CalcPermutations, and combine their probabilities; return the list of entries sorted by date(s) (occurDate with elements of type PropDate) or by id(s) (occurName with elements of type PropName), and the effective settings of the computation in run; f is not modified
+func CalcEntries (f File) (permutations *Permutations, occurDate, occurName *A.Tree, samples int, run Run) {
+	return calcEntries(f, B.Now())
+}
+
+// CalcEntries, with now as current date
+func calcEntries (f File, now int64) (permutations *Permutations, occurDate, occurName *A.Tree, samples int, run Run) {
+	run.Settings = CurrentSettings().Effective()
+	var deadline time.Time
+	if run.TimeBudget > 0 {
+		deadline = time.Now().Add(run.TimeBudget)
 	}
-	
-	// Propagation -> PropDate
+	cs := clusters(f)
+	sets, smp := permutationsOf(cs, &run, deadline)
+	permutations = &Permutations{clusters: sets, now: now}
 	occurDate = A.New()
-	e = occur.Next(nil)
-	for e != nil {
-		p := e.Val().(*Propagation)
-		_, b, _ := occurDate.SearchIns(&PropDate{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After, Proba: p.Proba}); M.Assert(!b, 101)
-		e = occur.Next(e)
+	samples = 0
+	for i, s := range sets {
+		occurrences(s, smp[i], now, occurDate)
+		samples = M.Max(samples, smp[i])
 	}
 	
 	// PropDate -> PropName
 	occurName = A.New()
-	e = occurDate.Next(nil)
+	e := occurDate.Next(nil)
 	for e != nil {
 		p := e.Val().(*PropDate)
-		_, b, _ := occurName.SearchIns(&PropName{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After, Proba: p.Proba}); M.Assert(!b, 102)
+		_, b, _ := occurName.SearchIns(&PropName{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After, Proba: p.Proba, Margin: p.Margin}); M.Assert(!b, 102)
 		e = occurDate.Next(e)
 	}
 	return
@@ -798,7 +1012,7 @@ Règle de délai inter-renouvellements : cette information est facile à déduir
 */
 
 // Extract f from the blockchain (Duniter1Blockchain) and the sandbox (Duniter1Sandbox) and sort it; minCertifs is the minimum number of certifications by Dossier required (at least 1 if minCertifs < 1); keep only valid elements
-func FillFile (minCertifs int) (f File, cNb, dNb int) {
+func fillFile (minCertifs int) (f File, cNb, dNb int) {
 	
 	type (
 		
@@ -827,119 +1041,19 @@ func FillFile (minCertifs int) (f File, cNb, dNb int) {
 			bb = !member && exp >= 0 && exp2 > minDate
 		}
 		if bb { // identity in sandBox or (not member & not leaving & new membership application date later than previous one plus msPeriod)
-			nbCertifs := 0; certs := (*pubList)(nil)
-			var posBI B.CertPos
-			if idInBC && B.CertTo(to, &posBI) {
-				posB := posBI
-				for {
-					from, _, okP := posB.CertNextPos()
-					if okP {
-						_, member, _, _, _, _, bb := B.IdPubComplete(from)
-						var posBF B.CertPos
-						bb = bb && member && (!B.CertFrom(from, &posBF) || posBF.CertPosLen() < int(B.Pars().SigStock))
-						if bb {
-							_, exp, b := B.Cert(from, to); M.Assert(b, 103)
-							bb = exp > minDate
-						}
-						if bb { // Don't consider certifications sent by a non-member or by a member who already has sent sigStock (100) certifications or certifications whose limit date is smaller than minDate
-							nbCertifs++
-							certs = &pubList{pub: &from, date: BA.Already, next: certs}
-						}
-					}
-					if !okP {break}
-				}
-			}
-			var posI S.CertPos
-			if b = S.CertTo(toHash, &posI); b {
-				pos := posI
-				for {
-					from, toHash, okP := pos.CertNextPos()
-					if okP {
-						c := certs
-						for c != nil && *c.pub != from {
-							c = c.next
-						}
-						if c != nil {
-							continue
-						}
-					}
-					if okP {
-						_, member, _, _, _, _, bb := B.IdPubComplete(from)
-						var posBF B.CertPos
-						bb = bb && member && (!B.CertFrom(from, &posBF) || posBF.CertPosLen() < int(B.Pars().SigStock))
-						if bb {
-							_, _, exp, b := S.Cert(from, toHash); M.Assert(b, 104)
-							date := fixCertNextDate(from)
-							if M.Max64(date, minDate) <= exp { // Not-expired certification
-								nbCertifs++
-								certs = &pubList{pub: &from, date: date, next: certs}
-							}
-						}
-					}
-					if !okP {break}
-				}
-			}
-			var (princCertif int; proportionOfSentries float64)
-			bb := nbCertifs >= minCertifs
+			d := &Dossier{MinDate: minDate, Id: new(string), Hash: new(B.Hash), pub: new(B.Pubkey)}
+			_, *d.pub, *d.Id, _, d.limit, b = S.IdHash(toHash); M.Assert(b, 105)
+			*d.Hash = toHash
+			d.Certifs = certifsTo(d, idInBC)
+			bb := len(d.Certifs) >= minCertifs
 			if bb {
-				princCertif, proportionOfSentries, b = notTooFar(&certs, nbCertifs)
-				bb = b || minCertifs < int(B.Pars().SigQty)
+				bb = d.fixPrinc() || minCertifs < int(B.Pars().SigQty)
 			}
 			if bb {
 				dNb++
-				d := &Dossier{MinDate: minDate, PrincCertif: princCertif, ProportionOfSentries: proportionOfSentries, Id: new(string), Hash: new(B.Hash), pub: new(B.Pubkey)}
 				l.next = new(cdList); l = l.next
 				l.cd = d
-				var idInBC bool
-				idInBC, *d.pub, *d.Id, _, d.limit, b = S.IdHash(toHash); M.Assert(b, 105)
-				*d.Hash = toHash
-				d.Certifs = make(File, nbCertifs)
-				j := 0
-				if idInBC {
-					from, to, okP := posBI.CertNextPos()
-					for okP {
-						_, member, _, _, _, _, bb := B.IdPubComplete(from)
-						var posBF B.CertPos
-						bb = bb && member &&(!B.CertFrom(from, &posBF) || posBF.CertPosLen() < int(B.Pars().SigStock))
-						if bb {
-							_, exp, b := B.Cert(from, to); M.Assert(b, 106)
-							if exp > minDate {
-								c := &Certif{date: BA.Already, limit: exp, From: new(string), To: d.Id, ToH: d.Hash}
-								c.fromP = new(B.Pubkey); *c.fromP = from
-								*c.From, b = B.IdPub(from); M.Assert(b, 107)
-								d.Certifs[j] = c
-								j++
-							}
-						}
-						from, to, okP = posBI.CertNextPos()
-					}
-				}
-				k := j
-				from, toHash, okP := posI.CertNextPos()
-				for okP {
-					i := 0
-					for i < k && *d.Certifs[i].(*Certif).fromP != from {
-						i++
-					}
-					if i >= k {
-						_, b, _, _, _, _, bb := B.IdPubComplete(from)
-						var posBF B.CertPos
-						bb = bb && b &&  (!B.CertFrom(from, &posBF) || posBF.CertPosLen() < int(B.Pars().SigStock))
-						if bb {
-							_, _, exp, b := S.Cert(from, toHash); M.Assert(b, 106)
-							date := fixCertNextDate(from)
-							if M.Max64(date, minDate) <= exp {
-								useful.SearchIns(&pubSet{p: from})
-								c := &Certif{date: date, limit: exp, From: new(string), To: d.Id, ToH: d.Hash}
-								c.fromP = new(B.Pubkey); *c.fromP = from
-								*c.From, b = B.IdPub(from); M.Assert(b, 107)
-								d.Certifs[j] = c
-								j++
-							}
-						}
-					}
-					from, toHash, okP = posI.CertNextPos()
-				}
+				d.usefulSenders(useful)
 			}
 		}
 		toHash, ok = S.IdNextHash(false, &el)
@@ -994,41 +1108,59 @@ func FillFile (minCertifs int) (f File, cNb, dNb int) {
 		sortFile(f, 0)
 	}
 	return
-} //FillFile
+} //fillFile
 
 // Calculate the current set of entries, sorted by dates (occur) and by names (invOccur)
-func BuildEntries () (f File, cNb, dNb int, permutations, occurDate, occurName *A.Tree, duration int64) {
-	
-	byDate := func (tId *A.Tree) *A.Tree {
-		tD :=A.New()
-		e := tId.Next(nil)
-		for e != nil {
-			p := e.Val().(*Propagation)
-			_, b, _ := tD.SearchIns(&PropDate{Hash: p.Hash, Id: p.Id, Date: p.Date, After: p.After}); M.Assert(!b, 101)
-			e = tId.Next(e)
-		}
-		return tD
-	} //byDate
-	
-	//BuildEntries
+func BuildEntries () (f File, cNb, dNb int, permutations *Permutations, occurDate, occurName *A.Tree, duration int64, samples int, run Run) {
 	ti := time.Now()
 	f, cNb, dNb = FillFile(int(B.Pars().SigQty))
-	n := int64(0)
-	permutations, occurDate, occurName = CalcEntries(copyFile(f, 0, &n))
-	e := permutations.Next(nil)
-	for e != nil {
-		s := e.Val().(*Set)
-		s.T = byDate(s.T)
-		e = permutations.Next(e)
-	}
+	permutations, occurDate, occurName, samples, run = CalcEntries(f)
 	duration = int64(math.Round(time.Since(ti).Seconds()))
+	recordForecast(f, occurDate)
 	return
 }
 
+// Settings with Window fixed
+func (s Settings) Effective () Settings {
+	if s.Window <= 0 {
+		s.Window = int64(B.Pars().AvgGenTime)
+	}
+	return s
+} //Effective
+
+// Settings of the next computations of permutations, as given at the start of the server or by the last ChangeSettings
+func CurrentSettings () Settings {
+	settingsMut.RLock()
+	defer settingsMut.RUnlock()
+	return settings
+} //CurrentSettings
+
+// Change the settings of the next computations of permutations; s.MaxSize must be positive, and s.TimeBudget and s.Window not negative
+func ChangeSettings (s Settings) {
+	M.Assert(s.MaxSize > 0 && s.TimeBudget >= 0 && s.Window >= 0, s, 20)
+	settingsMut.Lock()
+	settings = s
+	settingsMut.Unlock()
+} //ChangeSettings
+
 func MaxSize () int64 {
-	return maxSize
+	return CurrentSettings().MaxSize
 }
 
 func ChangeParameters (newMaxSize int64) {
-	maxSize = newMaxSize
+	s := CurrentSettings()
+	s.MaxSize = newMaxSize
+	ChangeSettings(s)
 }
+
+// Read the settings of CalcPermutations and of sampling from the configuration
+func readSettings () {
+	settings = Settings{MaxSize: BA.MaxSize, TimeBudget: BA.TimeBudget, Window: int64(BA.ConcurrencyWindow / time.Second)}
+	sampling = BA.Sampling
+	samplesNb = BA.Samples
+	samplingSeed = BA.SamplingSeed
+} //readSettings
+
+func init () {
+	BA.AddConfigProc(readSettings)
+} //init
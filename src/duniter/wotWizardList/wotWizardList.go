@@ -21,6 +21,9 @@ import (
 	GQ	"duniter/gqlReceiver"
 	M	"util/misc"
 	W	"duniter/wotWizard"
+		"crypto/subtle"
+		"strconv"
+		"time"
 		/*
 		"fmt"
 		*/
@@ -29,8 +32,24 @@ import (
 
 var (
 	
+	lg = BA.NewLogger("wotWizardList")
+	
 	fileStream = GQ.CreateStream("wwFile")
 	resultStream = GQ.CreateStream("wwResult")
+	
+	reasonNames = [...]string{
+		W.MsPeriod: "MS_PERIOD",
+		W.SigPeriod: "SIG_PERIOD",
+		W.SigStock: "SIG_STOCK",
+		W.NotEnoughCertifs: "NOT_ENOUGH_CERTIFICATIONS",
+		W.DistanceRule: "DISTANCE_RULE",
+		W.ApplicationExpiry: "APPLICATION_EXPIRY",
+	}
+	
+	exitReasonNames = [...]string{
+		W.MembershipExpiry: "MEMBERSHIP_EXPIRY",
+		W.CertificationsLack: "NOT_ENOUGH_CERTIFICATIONS",
+	}
 
 )
 
@@ -61,10 +80,113 @@ func wwFileR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 } //wwFileR
 
 func wwResultR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
-	_, cNb, dNb, permutations, occurDate, occurName, duration := W.BuildEntries()
-	return GQ.Wrap(B.LastBlock(), permutations, occurDate, occurName, duration, dNb, cNb)
+	_, cNb, dNb, permutations, occurDate, occurName, duration, samples, run := W.BuildEntries()
+	return GQ.Wrap(B.LastBlock(), permutations, occurDate, occurName, duration, dNb, cNb, samples, run)
 } //wwResultR
 
+func simulateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	
+	// Value of the field name of o, or "" if absent or null
+	getString := func (o *G.InputObjectValue, name string) string {
+		var v G.Value
+		if G.GetObjectValueInputField(o, name, &v) {
+			switch v := v.(type) {
+			case *G.StringValue:
+				return v.String.S
+			case *G.NullValue:
+			default:
+				M.Halt(v, 100)
+			}
+		}
+		return ""
+	} //getString
+	
+	// Value of the field "date" of o, or 0 (i.e. now) if absent or null
+	getDate := func (o *G.InputObjectValue) int64 {
+		var v G.Value
+		if G.GetObjectValueInputField(o, "date", &v) {
+			switch v := v.(type) {
+			case *G.IntValue:
+				return v.Int
+			case *G.NullValue:
+			default:
+				M.Halt(v, 101)
+			}
+		}
+		return 0
+	} //getDate
+	
+	// Call do for each element of the list argument name
+	forEach := func (name string, do func (o *G.InputObjectValue)) {
+		var v G.Value
+		ok := G.GetValue(argumentValues, name, &v); M.Assert(ok, 102)
+		switch v := v.(type) {
+		case *G.ListValue:
+			for l := v.First(); l != nil; l = v.Next(l) {
+				do(l.Value.(*G.InputObjectValue))
+			}
+		default:
+			M.Halt(v, 103)
+		}
+	} //forEach
+	
+	//simulateR
+	h := new(W.Hypothesis)
+	forEach("extraCerts", func (o *G.InputObjectValue) {
+		h.ExtraCerts = append(h.ExtraCerts, W.HypoCertif{From: B.Pubkey(getString(o, "from")), To: B.Hash(getString(o, "to")), Date: getDate(o)})
+	})
+	forEach("extraMemberships", func (o *G.InputObjectValue) {
+		h.ExtraMemberships = append(h.ExtraMemberships, W.HypoMembership{Hash: B.Hash(getString(o, "hash")), Date: getDate(o)})
+	})
+	forEach("removedCerts", func (o *G.InputObjectValue) {
+		h.RemovedCerts = append(h.RemovedCerts, W.CertifLink{From: B.Pubkey(getString(o, "from")), To: B.Hash(getString(o, "to"))})
+	})
+	_, cNb, dNb, permutations, occurDate, occurName, duration, samples, run := W.Simulate(h)
+	return GQ.Wrap(B.LastBlock(), permutations, occurDate, occurName, duration, dNb, cNb, samples, run)
+} //simulateR
+
+func suggestCertifiersR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	var (v G.Value; hash B.Hash; count int)
+	ok := G.GetValue(argumentValues, "hash", &v); M.Assert(ok, 100)
+	switch v := v.(type) {
+	case *G.StringValue:
+		hash = B.Hash(v.String.S)
+	default:
+		M.Halt(v, 101)
+	}
+	ok = G.GetValue(argumentValues, "count", &v); M.Assert(ok, 102)
+	switch v := v.(type) {
+	case *G.IntValue:
+		count = int(v.Int)
+	default:
+		M.Halt(v, 103)
+	}
+	_, ss := W.SuggestCertifiers(hash, count)
+	l := G.NewListValue()
+	for _, s := range ss {
+		l.Append(GQ.Wrap(s))
+	}
+	return l
+} //suggestCertifiersR
+
+func membersForecastR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	end := int64(M.MaxInt64)
+	var v G.Value
+	if G.GetValue(argumentValues, "period", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			if now := B.Now(); v.Int < M.MaxInt64 - now {
+				end = now + v.Int
+			}
+		case *G.NullValue:
+		default:
+			M.Halt(v, 100)
+		}
+	}
+	ex, rs, cs, samples := W.ForecastMembers(end)
+	return GQ.Wrap(ex, rs, cs, samples)
+} //membersForecastR
+
 func resNowR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch b := GQ.Unwrap(rootValue, 0).(type) {
 	case int32:
@@ -77,8 +199,8 @@ func resNowR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 
 func resPermsNbR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch permutations := GQ.Unwrap(rootValue, 1).(type) {
-	case *A.Tree:
-		return G.MakeIntValue(permutations.NumberOfElems())
+	case *W.Permutations:
+		return G.MakeIntValue(permutations.Len())
 	default:
 		M.Halt(permutations, 100)
 		return nil
@@ -87,12 +209,13 @@ func resPermsNbR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Valu
 
 func resPermsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch permutations := GQ.Unwrap(rootValue, 1).(type) {
-	case *A.Tree:
+	case *W.Permutations:
+		all := permutations.All()
 		l := G.NewListValue()
-		e := permutations.Next(nil)
+		e := all.Next(nil)
 		for e != nil {
 			l.Append(GQ.Wrap(e.Val().(*W.Set)))
-			e = permutations.Next(e)
+			e = all.Next(e)
 		}
 		return l
 	default:
@@ -131,6 +254,16 @@ func resCertifsNbR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Va
 	}
 } //resCertifsNbR
 
+func resSamplesNbR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch samples := GQ.Unwrap(rootValue, 7).(type) {
+	case int:
+		return G.MakeIntValue(samples)
+	default:
+		M.Halt(samples, 100)
+		return nil
+	}
+} //resSamplesNbR
+
 func resByDatesR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch occurDate := GQ.Unwrap(rootValue, 2).(type) {
 	case *A.Tree:
@@ -280,6 +413,18 @@ func forecastProbaR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.V
 	}
 } //forecastProbaR
 
+func forecastMarginR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch forecast := GQ.Unwrap(rootValue, 0).(type) {
+	case *W.PropDate:
+		return G.MakeFloat64Value(forecast.Margin)
+	case *W.PropName:
+		return G.MakeFloat64Value(forecast.Margin)
+	default:
+		M.Halt(forecast, 100)
+		return nil
+	}
+} //forecastMarginR
+
 func fileNowR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
 	switch b := GQ.Unwrap(rootValue, 0).(type) {
 	case int32:
@@ -429,9 +574,514 @@ func dossierLimitR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Va
 	}
 } //dossierLimitR
 
+func dossierDiagnosisR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch d := GQ.Unwrap(rootValue, 0).(type) {
+	case *W.Dossier:
+		l := G.NewListValue()
+		for _, b := range d.Diagnosis() {
+			l.Append(GQ.Wrap(b))
+		}
+		return l
+	default:
+		M.Halt(d, 100)
+		return nil
+	}
+} //dossierDiagnosisR
+
+func blockingReasonR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch b := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Blocking:
+		return G.MakeEnumValue(reasonNames[b.Reason])
+	default:
+		M.Halt(b, 100)
+		return nil
+	}
+} //blockingReasonR
+
+func blockingCertifierR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch b := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Blocking:
+		if b.Certifier == "" {
+			return G.MakeNullValue()
+		}
+		_, _, hash, _, _, _, ok := B.IdPubComplete(b.Certifier); M.Assert(ok, 101)
+		return GQ.Wrap(hash)
+	default:
+		M.Halt(b, 100)
+		return nil
+	}
+} //blockingCertifierR
+
+func blockingDateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch b := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Blocking:
+		if b.Date == BA.Never {
+			return G.MakeNullValue()
+		}
+		return G.MakeInt64Value(b.Date)
+	default:
+		M.Halt(b, 100)
+		return nil
+	}
+} //blockingDateR
+
+func suggCertifierR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch s := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Suggestion:
+		_, _, hash, _, _, _, ok := B.IdPubComplete(s.Certifier); M.Assert(ok, 101)
+		return GQ.Wrap(hash)
+	default:
+		M.Halt(s, 100)
+		return nil
+	}
+} //suggCertifierR
+
+func suggDistanceR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch s := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Suggestion:
+		return G.MakeFloat64Value(s.Distance)
+	default:
+		M.Halt(s, 100)
+		return nil
+	}
+} //suggDistanceR
+
+func suggGainR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch s := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Suggestion:
+		return G.MakeFloat64Value(s.Gain)
+	default:
+		M.Halt(s, 100)
+		return nil
+	}
+} //suggGainR
+
+func suggDateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch s := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Suggestion:
+		return G.MakeInt64Value(s.Date)
+	default:
+		M.Halt(s, 100)
+		return nil
+	}
+} //suggDateR
+
+func mfSamplesNbR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch samples := GQ.Unwrap(rootValue, 3).(type) {
+	case int:
+		return G.MakeIntValue(samples)
+	default:
+		M.Halt(samples, 100)
+		return nil
+	}
+} //mfSamplesNbR
+
+func mfExitsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch ex := GQ.Unwrap(rootValue, 0).(type) {
+	case []W.Exit:
+		l := G.NewListValue()
+		for _, e := range ex {
+			l.Append(GQ.Wrap(e))
+		}
+		return l
+	default:
+		M.Halt(ex, 100)
+		return nil
+	}
+} //mfExitsR
+
+func mfRenewalsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch rs := GQ.Unwrap(rootValue, 1).(type) {
+	case []W.Renewal:
+		l := G.NewListValue()
+		for _, r := range rs {
+			l.Append(GQ.Wrap(r))
+		}
+		return l
+	default:
+		M.Halt(rs, 100)
+		return nil
+	}
+} //mfRenewalsR
+
+func mfCountR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch cs := GQ.Unwrap(rootValue, 2).(type) {
+	case []W.MembersCount:
+		l := G.NewListValue()
+		for _, c := range cs {
+			l.Append(GQ.Wrap(c))
+		}
+		return l
+	default:
+		M.Halt(cs, 100)
+		return nil
+	}
+} //mfCountR
+
+func exitMemberR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Exit:
+		return GQ.Wrap(e.Hash)
+	default:
+		M.Halt(e, 100)
+		return nil
+	}
+} //exitMemberR
+
+func exitDateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Exit:
+		return G.MakeInt64Value(e.Date)
+	default:
+		M.Halt(e, 100)
+		return nil
+	}
+} //exitDateR
+
+func exitReasonR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Exit:
+		return G.MakeEnumValue(exitReasonNames[e.Reason])
+	default:
+		M.Halt(e, 100)
+		return nil
+	}
+} //exitReasonR
+
+func exitProbaR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Exit:
+		return G.MakeFloat64Value(e.Proba)
+	default:
+		M.Halt(e, 100)
+		return nil
+	}
+} //exitProbaR
+
+func exitMarginR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Exit:
+		return G.MakeFloat64Value(e.Margin)
+	default:
+		M.Halt(e, 100)
+		return nil
+	}
+} //exitMarginR
+
+func renewalMemberR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch r := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Renewal:
+		return GQ.Wrap(r.Hash)
+	default:
+		M.Halt(r, 100)
+		return nil
+	}
+} //renewalMemberR
+
+func renewalDateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch r := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Renewal:
+		return G.MakeInt64Value(r.Date)
+	default:
+		M.Halt(r, 100)
+		return nil
+	}
+} //renewalDateR
+
+func renewalLimitR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch r := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Renewal:
+		return G.MakeInt64Value(r.Limit)
+	default:
+		M.Halt(r, 100)
+		return nil
+	}
+} //renewalLimitR
+
+func renewalProbaR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch r := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Renewal:
+		return G.MakeFloat64Value(r.Proba)
+	default:
+		M.Halt(r, 100)
+		return nil
+	}
+} //renewalProbaR
+
+func renewalMarginR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch r := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Renewal:
+		return G.MakeFloat64Value(r.Margin)
+	default:
+		M.Halt(r, 100)
+		return nil
+	}
+} //renewalMarginR
+
+func countDateR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch c := GQ.Unwrap(rootValue, 0).(type) {
+	case W.MembersCount:
+		return G.MakeInt64Value(c.Date)
+	default:
+		M.Halt(c, 100)
+		return nil
+	}
+} //countDateR
+
+func countCountR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	switch c := GQ.Unwrap(rootValue, 0).(type) {
+	case W.MembersCount:
+		return G.MakeFloat64Value(c.Count)
+	default:
+		M.Halt(c, 100)
+		return nil
+	}
+} //countCountR
+
+func forecastAccuracyR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	from := int64(M.MinInt64)
+	var v G.Value
+	if G.GetValue(argumentValues, "period", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			from = B.Now() - M.Max64(v.Int, 0)
+		case *G.NullValue:
+		default:
+			M.Halt(v, 100)
+		}
+	}
+	var bin int64
+	ok := G.GetValue(argumentValues, "bin", &v); M.Assert(ok, 101)
+	switch v := v.(type) {
+	case *G.IntValue:
+		bin = v.Int
+	default:
+		M.Halt(v, 102)
+	}
+	return GQ.Wrap(W.ForecastAccuracy(from, bin))
+} //forecastAccuracyR
+
+func accuracyOf (rootValue *G.OutputObjectValue) *W.Accuracy {
+	switch a := GQ.Unwrap(rootValue, 0).(type) {
+	case *W.Accuracy:
+		return a
+	default:
+		M.Halt(a, 100)
+		return nil
+	}
+} //accuracyOf
+
+func accForecastsNbR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeIntValue(accuracyOf(rootValue).Forecasts)
+} //accForecastsNbR
+
+func accMeanR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	a := accuracyOf(rootValue)
+	if len(a.Errors) == 0 {
+		return G.MakeNullValue()
+	}
+	return G.MakeFloat64Value(a.Mean)
+} //accMeanR
+
+func accMeanAbsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	a := accuracyOf(rootValue)
+	if len(a.Errors) == 0 {
+		return G.MakeNullValue()
+	}
+	return G.MakeFloat64Value(a.MeanAbs)
+} //accMeanAbsR
+
+func accDistributionR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	l := G.NewListValue()
+	for _, b := range accuracyOf(rootValue).Distribution {
+		l.Append(GQ.Wrap(b))
+	}
+	return l
+} //accDistributionR
+
+func accErrorsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	l := G.NewListValue()
+	for _, e := range accuracyOf(rootValue).Errors {
+		l.Append(GQ.Wrap(e))
+	}
+	return l
+} //accErrorsR
+
+func forecastErrorOf (rootValue *G.OutputObjectValue) W.ForecastError {
+	switch e := GQ.Unwrap(rootValue, 0).(type) {
+	case W.ForecastError:
+		return e
+	default:
+		M.Halt(e, 100)
+		return W.ForecastError{}
+	}
+} //forecastErrorOf
+
+func feMemberR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return GQ.Wrap(forecastErrorOf(rootValue).Hash)
+} //feMemberR
+
+func feForecastBlockR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return GQ.Wrap(forecastErrorOf(rootValue).Block)
+} //feForecastBlockR
+
+func fePredictedR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(forecastErrorOf(rootValue).Predicted)
+} //fePredictedR
+
+func feAfterR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeBooleanValue(forecastErrorOf(rootValue).After)
+} //feAfterR
+
+func feProbaR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeFloat64Value(forecastErrorOf(rootValue).Proba)
+} //feProbaR
+
+func feEntryBlockR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return GQ.Wrap(forecastErrorOf(rootValue).Join.Block)
+} //feEntryBlockR
+
+func feErrorR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(forecastErrorOf(rootValue).Error)
+} //feErrorR
+
+func errorBinOf (rootValue *G.OutputObjectValue) W.ErrorBin {
+	switch b := GQ.Unwrap(rootValue, 0).(type) {
+	case W.ErrorBin:
+		return b
+	default:
+		M.Halt(b, 100)
+		return W.ErrorBin{}
+	}
+} //errorBinOf
+
+func binFromR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(errorBinOf(rootValue).From)
+} //binFromR
+
+func binToR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(errorBinOf(rootValue).To)
+} //binToR
+
+func binCountR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeIntValue(errorBinOf(rootValue).Count)
+} //binCountR
+
+func runOf (rootValue *G.OutputObjectValue) W.Run {
+	switch run := GQ.Unwrap(rootValue, 8).(type) {
+	case W.Run:
+		return run
+	default:
+		M.Halt(run, 100)
+		return W.Run{}
+	}
+} //runOf
+
+func resSettingsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return GQ.Wrap(runOf(rootValue).Settings)
+} //resSettingsR
+
+func resMemoryExceededR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeBooleanValue(runOf(rootValue).MemoryExceeded)
+} //resMemoryExceededR
+
+func resTimeExceededR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeBooleanValue(runOf(rootValue).TimeExceeded)
+} //resTimeExceededR
+
+func changeSettingsR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	var v G.Value
+	ok := G.GetValue(argumentValues, "token", &v); M.Assert(ok, 100)
+	var token string
+	switch v := v.(type) {
+	case *G.StringValue:
+		token = v.String.S
+	default:
+		M.Halt(v, 101)
+	}
+	if BA.AdminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(BA.AdminToken)) != 1 {
+		lg.Warn("changeWotWizardSettings refused: wrong token, or no adminToken setting")
+		return G.MakeErrorValue("token: wrong token, or no adminToken setting")
+	}
+	s := W.CurrentSettings()
+	if G.GetValue(argumentValues, "maxSize", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			if v.Int <= 0 {
+				return G.MakeErrorValue("maxSize: must be positive")
+			}
+			s.MaxSize = v.Int
+		case *G.NullValue:
+		default:
+			return G.MakeErrorValue("maxSize: integer expected")
+		}
+	}
+	if G.GetValue(argumentValues, "timeBudget", &v) {
+		secs, given := -1., true
+		switch v := v.(type) {
+		case *G.FloatValue:
+			secs = v.Float
+		case *G.IntValue:
+			secs = float64(v.Int)
+		case *G.NullValue:
+			given = false
+		default:
+			return G.MakeErrorValue("timeBudget: number expected")
+		}
+		if given {
+			if !(secs >= 0 && secs <= float64(M.MaxInt64 / int64(time.Second))) {
+				return G.MakeErrorValue("timeBudget: must be between 0 and " + strconv.FormatInt(M.MaxInt64 / int64(time.Second), 10) + " seconds")
+			}
+			s.TimeBudget = time.Duration(secs * float64(time.Second))
+		}
+	}
+	if G.GetValue(argumentValues, "concurrencyWindow", &v) {
+		switch v := v.(type) {
+		case *G.IntValue:
+			if v.Int < 0 {
+				return G.MakeErrorValue("concurrencyWindow: must not be negative")
+			}
+			s.Window = v.Int
+		case *G.NullValue:
+		default:
+			return G.MakeErrorValue("concurrencyWindow: integer expected")
+		}
+	}
+	W.ChangeSettings(s)
+	lg.Println("WotWizard settings changed: maxSize", s.MaxSize, "timeBudget", s.TimeBudget, "concurrencyWindow", s.Window)
+	return GQ.Wrap(s.Effective())
+} //changeSettingsR
+
+func settingsOf (rootValue *G.OutputObjectValue) W.Settings {
+	switch s := GQ.Unwrap(rootValue, 0).(type) {
+	case W.Settings:
+		return s
+	default:
+		M.Halt(s, 100)
+		return W.Settings{}
+	}
+} //settingsOf
+
+func setMaxSizeR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(settingsOf(rootValue).MaxSize)
+} //setMaxSizeR
+
+func setTimeBudgetR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeFloat64Value(settingsOf(rootValue).TimeBudget.Seconds())
+} //setTimeBudgetR
+
+func setWindowR (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+	return G.MakeInt64Value(settingsOf(rootValue).Window)
+} //setWindowR
+
 func fixFieldResolvers (ts G.TypeSystem) {
 	ts.FixFieldResolver("Query", "wwFile", wwFileR)
 	ts.FixFieldResolver("Query", "wwResult", wwResultR)
+	ts.FixFieldResolver("Query", "simulate", simulateR)
+	ts.FixFieldResolver("Query", "suggestCertifiers", suggestCertifiersR)
+	ts.FixFieldResolver("Query", "membersForecast", membersForecastR)
+	ts.FixFieldResolver("Query", "forecastAccuracy", forecastAccuracyR)
+	ts.FixFieldResolver("Mutation", "changeWotWizardSettings", changeSettingsR)
 	ts.FixFieldResolver("FileS", "now", fileNowR)
 	ts.FixFieldResolver("FileS", "certifs_dossiers", fileCDR)
 	ts.FixFieldResolver("FileS", "certifs_nb", fileCNbR)
@@ -446,11 +1096,57 @@ func fixFieldResolvers (ts G.TypeSystem) {
 	ts.FixFieldResolver("Dossier", "minDate", dossierMinDateR)
 	ts.FixFieldResolver("Dossier", "date", dossierDateR)
 	ts.FixFieldResolver("Dossier", "limit", dossierLimitR)
+	ts.FixFieldResolver("Dossier", "diagnosis", dossierDiagnosisR)
+	ts.FixFieldResolver("Blocking", "reason", blockingReasonR)
+	ts.FixFieldResolver("Blocking", "certifier", blockingCertifierR)
+	ts.FixFieldResolver("Blocking", "date", blockingDateR)
+	ts.FixFieldResolver("CertifierSuggestion", "certifier", suggCertifierR)
+	ts.FixFieldResolver("CertifierSuggestion", "distance", suggDistanceR)
+	ts.FixFieldResolver("CertifierSuggestion", "gain", suggGainR)
+	ts.FixFieldResolver("CertifierSuggestion", "date", suggDateR)
+	ts.FixFieldResolver("MembersForecast", "samples_nb", mfSamplesNbR)
+	ts.FixFieldResolver("MembersForecast", "exits", mfExitsR)
+	ts.FixFieldResolver("MembersForecast", "renewals", mfRenewalsR)
+	ts.FixFieldResolver("MembersForecast", "members_count", mfCountR)
+	ts.FixFieldResolver("MemberExit", "member", exitMemberR)
+	ts.FixFieldResolver("MemberExit", "date", exitDateR)
+	ts.FixFieldResolver("MemberExit", "reason", exitReasonR)
+	ts.FixFieldResolver("MemberExit", "proba", exitProbaR)
+	ts.FixFieldResolver("MemberExit", "proba_margin", exitMarginR)
+	ts.FixFieldResolver("MembershipRenewal", "member", renewalMemberR)
+	ts.FixFieldResolver("MembershipRenewal", "date", renewalDateR)
+	ts.FixFieldResolver("MembershipRenewal", "limit", renewalLimitR)
+	ts.FixFieldResolver("MembershipRenewal", "proba", renewalProbaR)
+	ts.FixFieldResolver("MembershipRenewal", "proba_margin", renewalMarginR)
+	ts.FixFieldResolver("MembersCount", "date", countDateR)
+	ts.FixFieldResolver("MembersCount", "count", countCountR)
+	ts.FixFieldResolver("ForecastAccuracy", "forecasts_nb", accForecastsNbR)
+	ts.FixFieldResolver("ForecastAccuracy", "mean_error", accMeanR)
+	ts.FixFieldResolver("ForecastAccuracy", "mean_absolute_error", accMeanAbsR)
+	ts.FixFieldResolver("ForecastAccuracy", "distribution", accDistributionR)
+	ts.FixFieldResolver("ForecastAccuracy", "errors", accErrorsR)
+	ts.FixFieldResolver("ForecastError", "member", feMemberR)
+	ts.FixFieldResolver("ForecastError", "forecast_block", feForecastBlockR)
+	ts.FixFieldResolver("ForecastError", "predicted_date", fePredictedR)
+	ts.FixFieldResolver("ForecastError", "after", feAfterR)
+	ts.FixFieldResolver("ForecastError", "proba", feProbaR)
+	ts.FixFieldResolver("ForecastError", "entry_block", feEntryBlockR)
+	ts.FixFieldResolver("ForecastError", "error", feErrorR)
+	ts.FixFieldResolver("ErrorBin", "from", binFromR)
+	ts.FixFieldResolver("ErrorBin", "to", binToR)
+	ts.FixFieldResolver("ErrorBin", "count", binCountR)
+	ts.FixFieldResolver("WWSettings", "max_size", setMaxSizeR)
+	ts.FixFieldResolver("WWSettings", "time_budget", setTimeBudgetR)
+	ts.FixFieldResolver("WWSettings", "concurrency_window", setWindowR)
 	ts.FixFieldResolver("WWResultS", "now", resNowR)
 	ts.FixFieldResolver("WWResultS", "computation_duration", resDurationR)
 	ts.FixFieldResolver("WWResultS", "permutations_nb", resPermsNbR)
 	ts.FixFieldResolver("WWResultS", "dossiers_nb", resDossiersNbR)
 	ts.FixFieldResolver("WWResultS", "certifs_nb", resCertifsNbR)
+	ts.FixFieldResolver("WWResultS", "samples_nb", resSamplesNbR)
+	ts.FixFieldResolver("WWResultS", "settings", resSettingsR)
+	ts.FixFieldResolver("WWResultS", "memory_budget_exceeded", resMemoryExceededR)
+	ts.FixFieldResolver("WWResultS", "time_budget_exceeded", resTimeExceededR)
 	ts.FixFieldResolver("WWResultS", "permutations", resPermsR)
 	ts.FixFieldResolver("WWResultS", "forecastsByDates", resByDatesR)
 	ts.FixFieldResolver("WWResultS", "forecastsByNames", resByNamesR)
@@ -463,6 +1159,7 @@ func fixFieldResolvers (ts G.TypeSystem) {
 	ts.FixFieldResolver("Forecast", "date", forecastDateR)
 	ts.FixFieldResolver("Forecast", "after", forecastAfterR)
 	ts.FixFieldResolver("Forecast", "proba", forecastProbaR)
+	ts.FixFieldResolver("Forecast", "proba_margin", forecastMarginR)
 	ts.FixFieldResolver("Subscription", "wwFile", wwFileR)
 	ts.FixFieldResolver("Subscription", "wwResult", wwResultR)
 } //fixFieldResolvers
@@ -0,0 +1,130 @@
+package wotWizardList
+
+import (
+
+	A	"util/avl"
+	B	"duniter/blockchain"
+	BA	"duniter/basic"
+	F	"path/filepath"
+	G	"util/graphQL"
+	GQ	"duniter/gqlReceiver"
+	J	"encoding/json"
+	M	"util/misc"
+	S	"duniter/sandbox"
+	W	"duniter/wotWizard"
+	_	"duniter/static"
+	_	"util/graphQL/static"
+	_	"util/json/static"
+		"flag"
+		"os"
+		"reflect"
+		"strings"
+		"testing"
+		"time"
+
+)
+
+type (
+
+	// GraphQL response
+	response struct {
+		Data interface{}
+		Errors []struct {
+			Message string
+		}
+	}
+
+)
+
+func TestMain (m *testing.M) {
+	dir, err := os.MkdirTemp("", "wotWizard"); M.Assert(err == nil, err, 100)
+	BA.Configure(dir, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	B.Initialize()
+	S.Initialize()
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Response to the GraphQL request query, which must be valid
+func execute (query string) (r response) {
+	doc, rr := G.ReadString(query)
+	M.Assert(doc != nil && rr.Errors().IsEmpty(), query, 100)
+	es := GQ.TS().ExecValidate(doc)
+	M.Assert(es.GetErrors().IsEmpty(), query, 101)
+	err := J.Unmarshal([]byte(G.ResponseToJson(es.Execute(doc, "", A.New())).GetFlatString()), &r); M.Assert(err == nil, err, 102)
+	return
+} //execute
+
+// Set BA.AdminToken to token, and keep the settings of WotWizard, for the duration of the test t
+func setAdmin (t *testing.T, token string) {
+	oldToken, old := BA.AdminToken, W.CurrentSettings()
+	BA.AdminToken = token
+	t.Cleanup(func () {BA.AdminToken = oldToken; W.ChangeSettings(old)})
+} //setAdmin
+
+// Directory of the fixture name
+func fixture (name string) string {
+	return F.Join("..", "..", "..", "rsrc", "duniter", "Fixtures", name)
+} //fixture
+
+// Does the mutation query fail with a single error message beginning with prefix, leaving the settings unchanged?
+func wantRefused (t *testing.T, query, prefix string) {
+	s := W.CurrentSettings()
+	r := execute(query)
+	d, ok := r.Data.(map[string]interface{})
+	if !ok || d["changeWotWizardSettings"] != nil || len(r.Errors) != 1 || !strings.HasPrefix(r.Errors[0].Message, prefix) || W.CurrentSettings() != s {
+		t.Errorf("%s: got %v %v, want an error beginning with %q", query, r.Data, r.Errors, prefix)
+	}
+} //wantRefused
+
+func TestChangeSettingsAuth (t *testing.T) {
+	B.UpdateOffline(fixture("basic"), func () {
+		setAdmin(t, "")
+		wantRefused(t, `mutation {changeWotWizardSettings(token: "", maxSize: 1000){max_size}}`, "token: ")
+		BA.AdminToken = "secret"
+		wantRefused(t, `mutation {changeWotWizardSettings(token: "", maxSize: 1000){max_size}}`, "token: ")
+		wantRefused(t, `mutation {changeWotWizardSettings(token: "secreT", maxSize: 1000){max_size}}`, "token: ")
+		r := execute(`mutation {changeWotWizardSettings(token: "secret", maxSize: 1000){max_size}}`)
+		M.Want(len(r.Errors) == 0 && W.CurrentSettings().MaxSize == 1000, t)
+	})
+}
+
+func TestChangeSettingsValidation (t *testing.T) {
+	B.UpdateOffline(fixture("basic"), func () {
+		setAdmin(t, "secret")
+		for _, x := range []struct {args, setting string} {
+			{`maxSize: 0`, "maxSize"},
+			{`maxSize: -1000`, "maxSize"},
+			{`timeBudget: -0.5`, "timeBudget"},
+			{`timeBudget: 1e30`, "timeBudget"},
+			{`concurrencyWindow: -1`, "concurrencyWindow"},
+			{`maxSize: 1000, concurrencyWindow: -1`, "concurrencyWindow"}, // Nothing is changed
+		} {
+			wantRefused(t, `mutation {changeWotWizardSettings(token: "secret", ` + x.args + `){max_size}}`, x.setting + ": ")
+		}
+		// Wrong types are rejected before execution
+		for _, args := range []string{`maxSize: "big"`, `timeBudget: "long"`, `concurrencyWindow: 1.5`} {
+			doc, r := G.ReadString(`mutation {changeWotWizardSettings(token: "secret", ` + args + `){max_size}}`)
+			M.Assert(doc != nil && r.Errors().IsEmpty(), args, 100)
+			M.Want(!GQ.TS().ExecValidate(doc).GetErrors().IsEmpty(), t)
+		}
+	})
+}
+
+func TestChangeSettingsEffect (t *testing.T) {
+	B.UpdateOffline(fixture("basic"), func () {
+		setAdmin(t, "secret")
+		W.ChangeSettings(W.Settings{MaxSize: 5000, TimeBudget: 2 * time.Second, Window: 600})
+		r := execute(`mutation {changeWotWizardSettings(token: "secret", maxSize: 1000, timeBudget: 1.5){max_size time_budget concurrency_window}}`)
+		want := map[string]interface{}{"changeWotWizardSettings": map[string]interface{}{"max_size": float64(1000), "time_budget": 1.5, "concurrency_window": float64(600)}}
+		M.Want(len(r.Errors) == 0 && reflect.DeepEqual(r.Data, want), t)
+		M.Want(W.CurrentSettings() == W.Settings{MaxSize: 1000, TimeBudget: 1500 * time.Millisecond, Window: 600}, t)
+
+		// Null arguments keep their settings, and a zero window gives the avgGenTime of the currency
+		r = execute(`mutation {changeWotWizardSettings(token: "secret", maxSize: null, timeBudget: 0, concurrencyWindow: 0){max_size time_budget concurrency_window}}`)
+		want = map[string]interface{}{"changeWotWizardSettings": map[string]interface{}{"max_size": float64(1000), "time_budget": float64(0), "concurrency_window": float64(B.Pars().AvgGenTime)}}
+		M.Want(len(r.Errors) == 0 && reflect.DeepEqual(r.Data, want), t)
+		M.Want(W.CurrentSettings() == W.Settings{MaxSize: 1000}, t)
+	})
+}
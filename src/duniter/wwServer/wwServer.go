@@ -14,12 +14,16 @@ package main
 
 import (
 	
-	"duniter/run"
-	"runtime"
+	BA	"duniter/basic"
+		"duniter/run"
+		"flag"
+		"os"
+		"runtime"
 
 )
 
 func main () {
 	runtime.GOMAXPROCS(runtime.NumCPU())
+	BA.Configure(BA.DefaultRsrcDir(), flag.CommandLine, os.Args[1:])
 	run.Start()
 }
@@ -15,14 +15,16 @@ package basicPrint
 import (
 	
 	A	"util/avl"
+	C	"util/config"
 	F	"path/filepath"
 	M	"util/misc"
 	R	"util/resources"
 	SM	"util/strMapping"
-		"text/scanner"
 		"errors"
-		"fmt"
+		"flag"
 		"os"
+		"strings"
+		"text/scanner"
 		"time"
 		"unicode"
 
@@ -46,14 +48,20 @@ const (
 	Eq = A.Eq
 	Gt = A.Gt
 	
-	serverDefaultAddress = "localhost:8080"
-	serverAddressName = "serverAddress.txt"
+	// Name of the configuration file
+	configName = "config.json"
+	// Environment variables of the configuration begin with envPrefix + "_"
+	envPrefix = "WWC"
 	
+	serverDefaultAddress = "localhost:8080"
 	subDefaultAddress = "localhost:9090"
-	subAddressName = "subAddress.txt"
-	
 	htmlDefaultAddress = "localhost:7070"
+	
+	// Former files of the configuration
+	serverAddressName = "serverAddress.txt"
+	subAddressName = "subAddress.txt"
 	htmlAddressName = "htmlAddress.txt"
+	authorizationsName = "Authorizations.txt"
 
 )
 
@@ -66,9 +74,12 @@ type (
 var (
 	
 	wd = R.FindDir()
-	serverAddress = serverDefaultAddress
-	subAddress = subDefaultAddress
-	htmlAddress = htmlDefaultAddress
+	
+	cfg = C.New(envPrefix, flag.CommandLine)
+	serverAddress = cfg.String("server", serverDefaultAddress, "Address of the WotWizard GraphQL server")
+	subAddress = cfg.String("subAddress", subDefaultAddress, "Address where the client receives the notifications of subscriptions")
+	htmlAddress = cfg.String("htmlAddress", htmlDefaultAddress, "Address of the web server of the client")
+	authorizations = cfg.Strings("authorizations", nil, "Views shown in the index of the client, or null for all of them")
 
 )
 
@@ -148,42 +159,79 @@ func CompP (s1, s2 string) Comp {
 } //CompP
 
 func ServerAddress () string {
-	return serverAddress
+	return *serverAddress
 }
 
 func SubAddress () string {
-	return subAddress
+	return *subAddress
 }
 
 func HtmlAddress () string {
-	return htmlAddress
+	return *htmlAddress
 }
 
-func fixAddress (adrName string, adr *string) {
-	name := F.Join(wd, "duniterClient", adrName)
-	f, err := os.Open(name)
-	if err == nil {
-		defer f.Close()
-		s := new(scanner.Scanner)
-		s.Init(f)
-		s.Error = func(s *scanner.Scanner, msg string) {panic(errors.New("File " + name + " incorrect"))}
-		s.Mode = scanner.ScanStrings
-		s.Scan()
-		ss := s.TokenText()
-		M.Assert(ss[0] == '"' && ss[len(ss) - 1] == '"', ss, 101)
-		*adr = ss[1:len(ss) - 1]
-	} else {
-		f, err := os.Create(name)
-		M.Assert(err == nil, err, 102)
-		defer f.Close()
-		fmt.Fprint(f, "\"" + *adr + "\"")
+// Views shown in the index of the client; nil for all of them
+func Authorizations () []string {
+	return *authorizations
+}
+
+// Path of the configuration file
+func ConfigPath () string {
+	return cfg.Path()
+}
+
+// Read the quoted strings of the former configuration file name, if it exists
+func readLegacy (name string) (ss []string, ok bool) {
+	f, err := os.Open(F.Join(wd, "duniterClient", name))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	s := new(scanner.Scanner)
+	s.Init(f)
+	s.Error = func(s *scanner.Scanner, msg string) {panic(errors.New("File " + name + " incorrect"))}
+	s.Mode = scanner.ScanStrings
+	ss = make([]string, 0)
+	for s.Scan() != scanner.EOF {
+		t := s.TokenText()
+		M.Assert(t[0] == '"' && t[len(t) - 1] == '"', t, 101)
+		ss = append(ss, t[1:len(t) - 1])
 	}
-} // fixAddress
+	return ss, true
+} //readLegacy
+
+// Read the former files of the configuration, if they exist
+func legacyConfig () map[string] string {
+	m := make(map[string] string)
+	for _, l := range [...]struct{name, setting string}{{serverAddressName, "server"}, {subAddressName, "subAddress"}, {htmlAddressName, "htmlAddress"}} {
+		if ss, ok := readLegacy(l.name); ok && len(ss) == 1 {
+			m[l.setting] = ss[0]
+		}
+	}
+	if ss, ok := readLegacy(authorizationsName); ok {
+		m["authorizations"] = strings.Join(ss, ",")
+	}
+	return m
+} //legacyConfig
 
 func init () {
 	dir := F.Join(wd, "duniterClient")
 	err := os.MkdirAll(dir, 0777); M.Assert(err == nil, err, 100)
-	fixAddress(serverAddressName, &serverAddress)
-	fixAddress(subAddressName, &subAddress)
-	fixAddress(htmlAddressName, &htmlAddress)
+	for name, addr := range map[string] *string{"server": serverAddress, "subAddress": subAddress, "htmlAddress": htmlAddress} {
+		a := addr
+		cfg.Check(name, func () error {
+			if *a == "" {
+				return errors.New("empty address")
+			}
+			return nil
+		})
+	}
+	config := flag.String("config", F.Join(dir, configName), "Path of the configuration file")
+	flag.Parse()
+	if err := cfg.Load(*config, legacyConfig); err != nil {
+		C.Exit(err)
+	}
+	for _, name := range [...]string{serverAddressName, subAddressName, htmlAddressName, authorizationsName} {
+		os.Remove(F.Join(dir, name)) // Replaced by the configuration file
+	}
 }
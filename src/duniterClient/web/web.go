@@ -16,17 +16,14 @@ import (
 	
 	A	"util/avl"
 	BA	"duniterClient/basicPrint"
-	F	"path/filepath"
+	C	"util/config"
 	GS	"duniterClient/gqlSender"
 	J	"util/json"
 	M	"util/misc"
-	R	"util/resources"
 	SM	"util/strMapping"
 		"errors"
 		"fmt"
 		"net/http"
-		"os"
-		"text/scanner"
 		"html/template"
 
 )
@@ -61,8 +58,6 @@ const (
 		{{end}}
 	`
 	
-	authorizationsName = "Authorizations.txt"
-	
 	Language_cookie_name = "v59ipaE3MoQDDtpt9edL"
 
 )
@@ -120,39 +115,23 @@ func (s1 stringComp) Compare (s2 A.Comparer) A.Comp {
 	}
 }
 
+// Keep only the views authorized by the configuration, if it lists them
 func initAuthorizations () {
-	name := F.Join(R.FindDir(), "duniterClient", authorizationsName)
-	f, err := os.Open(name)
-	if err == nil {
-		defer f.Close()
-		s := new(scanner.Scanner)
-		s.Init(f)
-		s.Error = func(s *scanner.Scanner, msg string) {panic(errors.New("File " + name + " incorrect"))}
-		s.Mode = scanner.ScanStrings
-		auth := make(map[string] *int)
-		for s.Scan() != scanner.EOF {
-			ss := s.TokenText()
-			M.Assert(ss[0] == '"' && ss[len(ss) - 1] == '"', ss, 101)
-			auth[ss[1:len(ss) - 1]] = nil
-		}
-		for view, _ := range packagesD {
-			if _, ok := auth[view]; !ok {
-				delete(packages, view)
-				delete(packagesD, view)
-			}
-		}
-	} else {
-		f, err := os.Create(name)
-		M.Assert(err == nil, err, 102)
-		defer f.Close()
-		t := A.New()
-		for view, _ := range packagesD {
-			t.SearchIns(stringComp(view))
+	auth := BA.Authorizations()
+	if auth == nil {
+		return
+	}
+	views := make(map[string] bool)
+	for _, view := range auth {
+		if _, ok := packagesD[view]; !ok {
+			C.Exit(errors.New(BA.ConfigPath() + ": \"authorizations\": unknown view \"" + view + "\""))
 		}
-		e := t.Next(nil)
-		for e != nil {
-			fmt.Fprintln(f, "\"" + e.Val().(stringComp) + "\"")
-			e = t.Next(e)
+		views[view] = true
+	}
+	for view, _ := range packagesD {
+		if !views[view] {
+			delete(packages, view)
+			delete(packagesD, view)
 		}
 	}
 }
@@ -0,0 +1,458 @@
+/*
+util: Set of tools.
+
+Copyright (C) 2001-2020 Gérard Meunier
+
+This program is free software; you can redistribute it and/or modify it under the terms of the GNU General Public License as published by the Free Software Foundation; either version 3 of the License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful, but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License along with this program; if not, write to the Free Software Foundation, Inc., 59 Temple Place - Suite 330, Boston, MA 02111-1307, USA.
+*/
+
+package config
+
+// Configuration of a program: a set of named settings, read from a JSON file (an object whose fields are the settings), overridden by environment variables, themselves overridden by options of the command line, and validated.
+// The environment variable of the setting "logSize" of a set of prefix "WW" is "WW_LOG_SIZE", and its option is "-logSize".
+
+import (
+	
+	J	"encoding/json"
+		"bytes"
+		"errors"
+		"flag"
+		"fmt"
+		"os"
+		"strconv"
+		"strings"
+		"time"
+		"unicode"
+
+)
+
+const (
+	
+	// Sources of the values of settings
+	Default = iota
+	File
+	Env
+	CommandLine
+
+)
+
+type (
+	
+	// Setting
+	entry struct {
+		name,
+		usage string
+		val interface{} // Pointer to the value: *string, *int, *int64, *bool, *time.Duration or *[]string
+		def interface{} // Default value
+		check func () error // Validation of *val, or nil
+		stored bool // Written into the file when given on the command line
+		source int // Source of the present value
+		fileRaw J.RawMessage // Value in the file, or nil
+		flagRaw *string // Value of the option, or nil
+	}
+	
+	// Flag of an entry; its value is only recorded, and set by Load
+	flagValue struct {
+		e *entry
+	}
+	
+	// Set of settings
+	Set struct {
+		envPrefix string
+		flags *flag.FlagSet // Where the options of the settings are defined
+		entries map[string] *entry
+		order []*entry
+		path string
+	}
+
+)
+
+var (
+	
+	sourceNames = [...]string{Default: "default", File: "file", Env: "environment", CommandLine: "command line"}
+
+)
+
+// Create a new set of settings, whose environment variables begin with envPrefix + "_", and whose options are defined in flags (usually flag.CommandLine)
+func New (envPrefix string, flags *flag.FlagSet) *Set {
+	return &Set{envPrefix: envPrefix, flags: flags, entries: make(map[string] *entry)}
+} //New
+
+// Name of the environment variable of the setting name
+func (s *Set) EnvName (name string) string {
+	var b strings.Builder
+	b.WriteString(s.envPrefix)
+	b.WriteString("_")
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+} //EnvName
+
+func (s *Set) add (name, usage string, val, def interface{}) {
+	if _, ok := s.entries[name]; ok {
+		panic(errors.New("Setting " + name + " defined twice"))
+	}
+	e := &entry{name: name, usage: usage, val: val, def: def}
+	s.entries[name] = e
+	s.order = append(s.order, e)
+	s.flags.Var(&flagValue{e}, name, usage + " (environment variable " + s.EnvName(name) + ")")
+} //add
+
+// Define a string setting
+func (s *Set) String (name, def, usage string) *string {
+	p := new(string); *p = def
+	s.add(name, usage, p, def)
+	return p
+} //String
+
+// Define an int setting
+func (s *Set) Int (name string, def int, usage string) *int {
+	p := new(int); *p = def
+	s.add(name, usage, p, def)
+	return p
+} //Int
+
+// Define an int64 setting
+func (s *Set) Int64 (name string, def int64, usage string) *int64 {
+	p := new(int64); *p = def
+	s.add(name, usage, p, def)
+	return p
+} //Int64
+
+// Define a bool setting
+func (s *Set) Bool (name string, def bool, usage string) *bool {
+	p := new(bool); *p = def
+	s.add(name, usage, p, def)
+	return p
+} //Bool
+
+// Define a duration setting; it's written like "90s" or "1h30m"
+func (s *Set) Duration (name string, def time.Duration, usage string) *time.Duration {
+	p := new(time.Duration); *p = def
+	s.add(name, usage, p, def)
+	return p
+} //Duration
+
+// Define a list of strings setting; it's a JSON array in the file and a comma separated list elsewhere; nil is written null
+func (s *Set) Strings (name string, def []string, usage string) *[]string {
+	p := new([]string); *p = def
+	s.add(name, usage, p, def)
+	return p
+} //Strings
+
+func (s *Set) get (name string) *entry {
+	e, ok := s.entries[name]
+	if !ok {
+		panic(errors.New("Unknown setting " + name))
+	}
+	return e
+} //get
+
+// Fix the validation of the setting name; check is called after Load has set all values, and returns an error if the value is incorrect
+func (s *Set) Check (name string, check func () error) {
+	s.get(name).check = check
+} //Check
+
+// When given on the command line, the setting name is written into the file, so that it's kept for the next starts
+func (s *Set) Store (name string) {
+	s.get(name).stored = true
+} //Store
+
+// Source of the value of the setting name: Default, File, Env or CommandLine
+func (s *Set) Source (name string) int {
+	return s.get(name).source
+} //Source
+
+// Path of the file of s, once loaded
+func (s *Set) Path () string {
+	return s.path
+} //Path
+
+func (f *flagValue) String () string {
+	if f == nil || f.e == nil {
+		return ""
+	}
+	return format(f.e.def)
+} //String
+
+func (f *flagValue) Set (v string) error {
+	if _, err := parse(f.e.val, v); err != nil {
+		return err
+	}
+	f.e.flagRaw = &v
+	return nil
+} //Set
+
+func (f *flagValue) IsBoolFlag () bool {
+	_, ok := f.e.val.(*bool)
+	return ok
+} //IsBoolFlag
+
+// Format the value v like in the environment
+func format (v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	}
+	panic(fmt.Sprint("Unknown type of setting: ", v))
+} //format
+
+// Parse the string v into a value of the type pointed by p, and return it
+func parse (p interface{}, v string) (interface{}, error) {
+	switch p.(type) {
+	case *string:
+		return v, nil
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("integer expected, instead of \"" + v + "\"")
+		}
+		return n, nil
+	case *int64:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("integer expected, instead of \"" + v + "\"")
+		}
+		return n, nil
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("true or false expected, instead of \"" + v + "\"")
+		}
+		return b, nil
+	case *time.Duration:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.New("duration expected (e.g. \"90s\" or \"1h30m\"), instead of \"" + v + "\"")
+		}
+		return d, nil
+	case *[]string:
+		if v == "" {
+			return []string{}, nil
+		}
+		return strings.Split(v, ","), nil
+	}
+	panic(fmt.Sprint("Unknown type of setting: ", p))
+} //parse
+
+// Decode the JSON value raw into a value of the type pointed by p, and return it
+func decode (p interface{}, raw J.RawMessage) (interface{}, error) {
+	switch p.(type) {
+	case *time.Duration:
+		var v string
+		if err := J.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New("duration string expected (e.g. \"90s\" or \"1h30m\"), instead of " + string(raw))
+		}
+		return parse(p, v)
+	case *[]string:
+		var v []string
+		if err := J.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New("array of strings or null expected, instead of " + string(raw))
+		}
+		return v, nil
+	default:
+		v := newOf(p)
+		if err := J.Unmarshal(raw, v); err != nil {
+			var kind string
+			switch p.(type) {
+			case *string:
+				kind = "string"
+			case *int, *int64:
+				kind = "integer"
+			case *bool:
+				kind = "true or false"
+			}
+			return nil, errors.New(kind + " expected, instead of " + string(raw))
+		}
+		return deref(v), nil
+	}
+} //decode
+
+// Encode the value v in JSON
+func encode (v interface{}) J.RawMessage {
+	if d, ok := v.(time.Duration); ok {
+		v = d.String()
+	}
+	b, err := J.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+} //encode
+
+func newOf (p interface{}) interface{} {
+	switch p.(type) {
+	case *string:
+		return new(string)
+	case *int:
+		return new(int)
+	case *int64:
+		return new(int64)
+	case *bool:
+		return new(bool)
+	}
+	panic(fmt.Sprint("Unknown type of setting: ", p))
+} //newOf
+
+func deref (p interface{}) interface{} {
+	switch p := p.(type) {
+	case *string:
+		return *p
+	case *int:
+		return *p
+	case *int64:
+		return *p
+	case *bool:
+		return *p
+	case *time.Duration:
+		return *p
+	case *[]string:
+		return *p
+	}
+	panic(fmt.Sprint("Unknown type of setting: ", p))
+} //deref
+
+func assign (p, v interface{}) {
+	switch p := p.(type) {
+	case *string:
+		*p = v.(string)
+	case *int:
+		*p = v.(int)
+	case *int64:
+		*p = v.(int64)
+	case *bool:
+		*p = v.(bool)
+	case *time.Duration:
+		*p = v.(time.Duration)
+	case *[]string:
+		*p = v.([]string)
+	default:
+		panic(fmt.Sprint("Unknown type of setting: ", p))
+	}
+} //assign
+
+// Read the values of the settings of s from the file path, then from the environment, then from the command line, whose flags must have been parsed before, and check them. If path doesn't exist, it's created with the values of the settings given by legacy, if not nil, and their default values otherwise; legacy returns these values formatted like in the environment, by setting names. All errors found are returned together
+func (s *Set) Load (path string, legacy func () map[string] string) error {
+	s.path = path
+	var errs []string
+	addErr := func (where string, err error) {
+		errs = append(errs, where + ": " + err.Error())
+	}
+	create := false
+	buf, err := os.ReadFile(path)
+	if err == nil {
+		var fields map[string] J.RawMessage
+		d := J.NewDecoder(bytes.NewReader(buf))
+		if err := d.Decode(&fields); err != nil {
+			return errors.New(path + ": incorrect JSON object: " + err.Error())
+		}
+		for name, raw := range fields {
+			if e, ok := s.entries[name]; ok {
+				e.fileRaw = raw
+			} else {
+				addErr(path, errors.New("unknown setting \"" + name + "\""))
+			}
+		}
+	} else if os.IsNotExist(err) {
+		create = true
+		if legacy != nil {
+			for name, v := range legacy() {
+				e := s.get(name)
+				if x, err := parse(e.val, v); err == nil {
+					e.fileRaw = encode(x)
+				} else {
+					addErr(path, fmt.Errorf("former value of \"%s\": %s", name, err))
+				}
+			}
+		}
+	} else {
+		return err
+	}
+	save := create
+	for _, e := range s.order {
+		if e.fileRaw != nil {
+			if v, err := decode(e.val, e.fileRaw); err == nil {
+				assign(e.val, v)
+				e.source = File
+			} else {
+				addErr(path + ": \"" + e.name + "\"", err)
+			}
+		}
+		if env, ok := os.LookupEnv(s.EnvName(e.name)); ok {
+			if v, err := parse(e.val, env); err == nil {
+				assign(e.val, v)
+				e.source = Env
+			} else {
+				addErr(s.EnvName(e.name), err)
+			}
+		}
+		if e.flagRaw != nil {
+			v, _ := parse(e.val, *e.flagRaw)
+			assign(e.val, v)
+			e.source = CommandLine
+			if e.stored {
+				raw := encode(v)
+				save = save || !bytes.Equal(raw, e.fileRaw)
+				e.fileRaw = raw
+			}
+		}
+	}
+	for _, e := range s.order {
+		if e.check != nil {
+			if err := e.check(); err != nil {
+				addErr("\"" + e.name + "\" (" + sourceNames[e.source] + ")", err)
+			}
+		}
+	}
+	if errs != nil {
+		return errors.New(strings.Join(errs, "\n"))
+	}
+	if save {
+		return s.save()
+	}
+	return nil
+} //Load
+
+// Write the file of s, with the values read from it, or the default ones, and the stored values of the command line
+func (s *Set) save () error {
+	var b bytes.Buffer
+	b.WriteString("{\n")
+	for i, e := range s.order {
+		raw := e.fileRaw
+		if raw == nil {
+			raw = encode(e.def)
+		}
+		b.WriteString("\t" + string(encode(e.name)) + ": " + string(raw))
+		if i < len(s.order) - 1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return os.WriteFile(s.path, b.Bytes(), 0644)
+} //save
+
+// Print the errors err of Load and stop the program
+func Exit (err error) {
+	fmt.Fprintln(os.Stderr, "Incorrect configuration:")
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(2)
+} //Exit
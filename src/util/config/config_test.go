@@ -0,0 +1,98 @@
+package config
+
+import (
+	
+	F	"path/filepath"
+	M	"util/misc"
+		"errors"
+		"flag"
+		"os"
+		"strings"
+		"testing"
+		"time"
+
+)
+
+// New set of settings, with its own set of flags
+func newSet () (*Set, *flag.FlagSet) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	return New("TEST", fs), fs
+} //newSet
+
+func TestEnvName (t *testing.T) {
+	s, _ := newSet()
+	M.Want(s.EnvName("du") == "TEST_DU", t)
+	M.Want(s.EnvName("logSize") == "TEST_LOG_SIZE", t)
+}
+
+func TestOrder (t *testing.T) {
+	path := F.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"name": "file", "size": 3, "every": "2h", "list": ["a", "b"]}`), 0644); M.Assert(err == nil, err, 100)
+	s, fs := newSet()
+	name := s.String("name", "def", "")
+	size := s.Int("size", 1, "")
+	every := s.Duration("every", time.Hour, "")
+	list := s.Strings("list", nil, "")
+	on := s.Bool("on", false, "")
+	gap := s.Int64("gap", 10, "")
+	t.Setenv("TEST_SIZE", "5")
+	t.Setenv("TEST_ON", "true")
+	err = fs.Parse([]string{"-size", "7"}); M.Assert(err == nil, err, 101)
+	err = s.Load(path, nil)
+	M.Want(err == nil, t)
+	M.Want(*name == "file" && s.Source("name") == File, t)
+	M.Want(*size == 7 && s.Source("size") == CommandLine, t)
+	M.Want(*every == 2 * time.Hour && s.Source("every") == File, t)
+	M.Want(len(*list) == 2 && (*list)[0] == "a" && (*list)[1] == "b", t)
+	M.Want(*on && s.Source("on") == Env, t)
+	M.Want(*gap == 10 && s.Source("gap") == Default, t)
+}
+
+func TestErrors (t *testing.T) {
+	path := F.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"size": "big", "unknown": 1, "level": "loud"}`), 0644); M.Assert(err == nil, err, 100)
+	s, _ := newSet()
+	s.Int("size", 1, "")
+	level := s.String("level", "info", "")
+	s.Check("level", func () error {
+		if *level != "info" && *level != "debug" {
+			return errors.New("\"info\" or \"debug\" expected")
+		}
+		return nil
+	})
+	err = s.Load(path, nil)
+	M.Want(err != nil, t)
+	if err == nil {
+		return
+	}
+	msgs := strings.Split(err.Error(), "\n")
+	M.Want(len(msgs) == 3, t)
+	M.Want(msgs[0] == path + ": unknown setting \"unknown\"", t)
+	M.Want(msgs[1] == path + ": \"size\": integer expected, instead of \"big\"", t)
+	M.Want(msgs[2] == "\"level\" (file): \"info\" or \"debug\" expected", t)
+}
+
+func TestCreate (t *testing.T) {
+	path := F.Join(t.TempDir(), "config.json")
+	s, _ := newSet()
+	addr := s.String("address", "localhost:8080", "")
+	s.Store("address")
+	du := s.String("du", "", "")
+	legacy := func () map[string] string {
+		return map[string] string{"du": "/old/path"}
+	}
+	err := s.Load(path, legacy)
+	M.Want(err == nil, t)
+	M.Want(*du == "/old/path" && *addr == "localhost:8080", t)
+	
+	s, fs := newSet()
+	addr = s.String("address", "localhost:8080", "")
+	s.Store("address")
+	du = s.String("du", "", "")
+	err = fs.Parse([]string{"-address", "localhost:8081"}); M.Assert(err == nil, err, 100)
+	err = s.Load(path, nil)
+	M.Want(err == nil && *du == "/old/path" && *addr == "localhost:8081", t)
+	buf, err := os.ReadFile(path); M.Assert(err == nil, err, 101)
+	M.Want(strings.Contains(string(buf), `"address": "localhost:8081"`), t)
+	M.Want(strings.Contains(string(buf), `"du": "/old/path"`), t)
+}
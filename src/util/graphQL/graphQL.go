@@ -353,6 +353,7 @@ type (
 	InstantResponse struct { // Response
 		errors *A.Tree
 		Data *OutputObjectValue
+		nullData bool // Data == nil because a non-null field of the root is null, and not because the request wasn't executed
 	}
 	
 	SubscribeResponse struct { // Response
@@ -627,6 +628,10 @@ type (
 	NullValue struct { // Value
 	}
 	
+	ErrorValue struct { // Value; returned by a field resolver which fails: Message is reported as an error of the field, whose value becomes null
+		Message string
+	}
+	
 	BooleanValue struct { // Value
 		Boolean bool
 	}
@@ -711,6 +716,8 @@ func (*EnumValue) isValue () {}
 
 func (*NullValue) isValue () {}
 
+func (*ErrorValue) isValue () {}
+
 func (*BooleanValue) isValue () {}
 
 func (*StringValue) isValue () {}
@@ -3824,15 +3831,31 @@ func (ts *typeSystem) validateValueIV (name *StrPtr, value, defVal Value, typ Ty
 	if d != nil {
 		switch d := d.(type) {
 		case *InputObjectTypeDefinition:
-			value = InstantiateVariable(value, vd)
-			if value != nil {
-				switch value := value.(type) {
-				case *InputObjectValue:
-					ValidateInputObject(value, d, name, vd)
-				default:
-					ts.Error("IncorrectValueType", name.S, "", name.P, nil)
+			var validate func (value Value, typ Type)
+			validate = func (value Value, typ Type) {
+				if t, ok := typ.(*NonNullType); ok {
+					typ = t.NullT
 				}
-			}
+				value = InstantiateVariable(value, vd)
+				if value != nil {
+					switch value := value.(type) {
+					case *InputObjectValue:
+						ValidateInputObject(value, d, name, vd)
+					case *ListValue:
+						if t, ok := typ.(*ListType); ok {
+							for l := value.First(); l != nil; l = value.Next(l) {
+								validate(l.Value, t.ItemT)
+							}
+						} else {
+							ts.Error("IncorrectValueType", name.S, "", name.P, nil)
+						}
+					case *NullValue:
+					default:
+						ts.Error("IncorrectValueType", name.S, "", name.P, nil)
+					}
+				}
+			} //validate
+			validate(value, typ)
 		default:
 		}
 	}
@@ -4244,6 +4267,10 @@ func MakeNullValue () *NullValue {
 	return &NullValue{}
 } //MakeNullValue
 
+func MakeErrorValue (mes string) *ErrorValue {
+	return &ErrorValue{Message: mes}
+} //MakeErrorValue
+
 func MakeBooleanValue (b bool) *BooleanValue {
 	return &BooleanValue{b}
 } //MakeBooleanValue
@@ -5136,6 +5163,7 @@ func (ts *typeSystem) mergeSelectionSets (fields *fieldRing) SelectionSet {
 	return selectionSet
 } //mergeSelectionSets
 
+// Return nil if the value is null, with an error already reported, and must be propagated up to the first nullable field or list item (null values in non-null fields or list items, and failing resolvers)
 func (es *execSystem) completeValue (fieldType Type, fields *fieldRing, result Value, variableDefinitions VariableDefinitions, variableValues *A.Tree, pathB *pathBuilder) Value { // *ValMapItem
 	
 	MakeObject := func (name string, value Value) *OutputObjectValue {
@@ -5148,12 +5176,12 @@ func (es *execSystem) completeValue (fieldType Type, fields *fieldRing, result V
 	if f, ok := fieldType.(*NonNullType); ok {
 		innerType := f.NullT
 		completedResult := es.completeValue(innerType, fields, result, variableDefinitions, variableValues, pathB)
-		nul := completedResult == nil
-		if !nul {
-			_, nul = completedResult.(*NullValue)
+		if completedResult == nil { // Already reported
+			return nil
 		}
-		if nul {
+		if _, nul := completedResult.(*NullValue); nul {
 			es.Error("NullValueWithNonNullType", "", "", nil, pathB.getPath())
+			return nil
 		}
 		return completedResult
 	}
@@ -5175,7 +5203,14 @@ func (es *execSystem) completeValue (fieldType Type, fields *fieldRing, result V
 		i := 0
 		for vl := res.First(); vl != nil; vl = res.Next(vl) {
 			resultItem := vl.Value
-			listValue.Append(es.completeValue(innerType, fields, resultItem, variableDefinitions, variableValues, pathB.pushPathNb(i)))
+			completedItem := es.completeValue(innerType, fields, resultItem, variableDefinitions, variableValues, pathB.pushPathNb(i))
+			if completedItem == nil {
+				if _, ok := innerType.(*NonNullType); ok { // The list itself is null
+					return nil
+				}
+				completedItem = MakeNullValue()
+			}
+			listValue.Append(completedItem)
 			i++
 		}
 		return listValue
@@ -5212,7 +5247,10 @@ func (es *execSystem) completeValue (fieldType Type, fields *fieldRing, result V
 		}
 	}
 	subSelectionSet := es.mergeSelectionSets(fields)
-	return es.executeSelectionSet(subSelectionSet, objectType, obj, variableDefinitions, variableValues, pathB, true)
+	if ov := es.executeSelectionSet(subSelectionSet, objectType, obj, variableDefinitions, variableValues, pathB, true); ov != nil {
+		return ov
+	}
+	return nil
 } //completeValue
 
 func (es *execSystem) executeField (objectType *ObjectTypeDefinition, objectValue *OutputObjectValue, fieldType Type, fields *fieldRing, variableDefinitions VariableDefinitions, variableValues *A.Tree, pathB *pathBuilder) Value { // *ValMapItem
@@ -5221,11 +5259,21 @@ func (es *execSystem) executeField (objectType *ObjectTypeDefinition, objectValu
 	pathBB := pathB.pushPathString(field.Alias)
 	argumentValues := es.coerceArgumentValues(objectType, field, variableDefinitions, variableValues, pathBB)
 	resolvedValue := es.resolveFieldValue(objectType, objectValue, fieldName, argumentValues, pathBB)
+	if e, ok := resolvedValue.(*ErrorValue); ok {
+		es.Error("ResolverFailed", e.Message, "", nil, pathBB.getPath())
+		return nil
+	}
 	return es.completeValue(fieldType, fields, resolvedValue, variableDefinitions, variableValues, pathBB)
 } //executeField
 
+// Return nil if a non-null field is null: the object is null then
 func (es *execSystem) executeSelectionSet (selectionSet SelectionSet, objectType *ObjectTypeDefinition, objectValue *OutputObjectValue, variableDefinitions VariableDefinitions, variableValues *A.Tree, pathB *pathBuilder, parallel bool) *OutputObjectValue { // *ValMapItem
 	
+	var (
+		nul = false // A non-null field is null
+		nulMut sync.Mutex
+	)
+	
 	executeSelection := func (groupedFieldSet *orderedFieldsMap, responseKey *StrPtr, fieldPtr *ObjectField) {
 		e, b, _ := groupedFieldSet.t.Search(&fieldRingElem{NameMapItem: NameMapItem{responseKey}}); M.Assert(b, 100)
 		fields := e.Val().(*fieldRingElem).fr
@@ -5234,6 +5282,14 @@ func (es *execSystem) executeSelectionSet (selectionSet SelectionSet, objectType
 		if field != nil {
 			fieldType := field.Type
 			responseValue := es.executeField(objectType, objectValue, fieldType, fields, variableDefinitions, variableValues, pathB)
+			if responseValue == nil {
+				if _, ok := fieldType.(*NonNullType); ok {
+					nulMut.Lock()
+					nul = true
+					nulMut.Unlock()
+				}
+				responseValue = MakeNullValue()
+			}
 			fieldPtr.Value = responseValue
 		}
 	} //executeSelection
@@ -5274,6 +5330,9 @@ func (es *execSystem) executeSelectionSet (selectionSet SelectionSet, objectType
 			}
 		}
 	}
+	if nul {
+		return nil
+	}
 	return resultMap
 } //executeSelectionSet
 
@@ -5285,7 +5344,7 @@ func (es *execSystem) executeSubscriptionEvent (subscription *OperationDefinitio
 	subscriptionType := newES.root[SubscriptionOp]
 	selectionSet := subscription.SelSet
 	data := newES.executeSelectionSet(selectionSet, subscriptionType, initialValue, subscription.VarDefs, variableValues, pathB.pushPathString(subscriptionType.Name), true)
-	return &InstantResponse{newES.GetErrors(), data}
+	return &InstantResponse{newES.GetErrors(), data, data == nil}
 } //executeSubscriptionEvent
 
 func (ts *typeSystem) getStreamResolver (fieldName *StrPtr) StreamResolver {
@@ -5346,7 +5405,7 @@ func (es *execSystem) executeQuery (query *OperationDefinition, variableValues *
 	selectionSet := query.SelSet
 	variableDefinitions := query.VarDefs
 	data := es.executeSelectionSet(selectionSet, queryType, initialValue, variableDefinitions, variableValues, pathB.pushPathString(queryType.Name), true)
-	return &InstantResponse{Data: data}
+	return &InstantResponse{Data: data, nullData: data == nil}
 } //executeQuery
 
 func (es *execSystem) executeMutation (mutation *OperationDefinition, variableValues *A.Tree, initialValue *OutputObjectValue) Response { // *ValMapItem
@@ -5355,7 +5414,7 @@ func (es *execSystem) executeMutation (mutation *OperationDefinition, variableVa
 	selectionSet := mutation.SelSet
 	variableDefinitions := mutation.VarDefs
 	data := es.executeSelectionSet(selectionSet, mutationType, initialValue, variableDefinitions, variableValues, pathB.pushPathString(mutationType.Name), false)
-	return &InstantResponse{Data: data}
+	return &InstantResponse{Data: data, nullData: data == nil}
 } //executeMutation
 
 func (es *execSystem) subscribe (subscription *OperationDefinition, variableValues *A.Tree, initialValue *OutputObjectValue) Response { // *ValMapItem
@@ -5409,11 +5468,11 @@ func (es *execSystem) GetOperation (operationName string) *OperationDefinition {
 func (es *execSystem) executeRequest (operationName *StrPtr, variableValues *A.Tree, initialValue *OutputObjectValue) Response { // *ValMapItem
 	operation := es.getOperation(operationName)
 	if operation == nil {
-		return &InstantResponse{es.GetErrors(), nil}
+		return &InstantResponse{errors: es.GetErrors()}
 	}
 	coercedVariableValues := es.coerceVariableValues(operation, variableValues)
 	if !es.GetErrors().IsEmpty() {
-		return &InstantResponse{es.GetErrors(), nil}
+		return &InstantResponse{errors: es.GetErrors()}
 	}
 	var r Response
 	switch operation.OpType {
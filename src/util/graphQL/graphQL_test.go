@@ -0,0 +1,132 @@
+package graphQL_test
+
+import (
+
+	A	"util/avl"
+	G	"util/graphQL"
+	J	"encoding/json"
+	M	"util/misc"
+	_	"util/graphQL/static"
+		"reflect"
+		"testing"
+
+)
+
+type (
+
+	// Scalarer without specific scalars
+	noScalars struct {
+	}
+
+)
+
+func (noScalars) FixScalarCoercer (scalarName string, sc *G.ScalarCoercer) {
+} //FixScalarCoercer
+
+// Type system built from the definitions defs
+func newTypeSystem (defs string) G.TypeSystem {
+	doc, r := G.ReadString(defs)
+	M.Assert(doc != nil && r.Errors().IsEmpty(), 100)
+	ts := G.Dir.NewTypeSystem(noScalars{})
+	ts.InitTypeSystem(doc)
+	M.Assert(ts.GetErrors().IsEmpty(), 101)
+	return ts
+} //newTypeSystem
+
+func TestValidateInputList (t *testing.T) {
+	ts := newTypeSystem(`
+		type Query {
+			simulate (certs: [Cert!]!): Int
+		}
+		input Cert {
+			from: String!
+			to: String!
+		}
+	`)
+	for _, x := range []struct {args string; ok bool} {
+		{`[]`, true},
+		{`[{from: "a", to: "b"}]`, true},
+		{`[{from: "a", to: "b"}, {from: "b", to: "c"}]`, true},
+		{`{from: "a", to: "b"}`, true}, // A single value is coerced into a list
+		{`null`, false},
+		{`[null]`, false},
+		{`[{from: "a", to: "b"}, null]`, false},
+		{`[{from: "a"}]`, false},
+		{`[{from: "a", to: null}]`, false},
+		{`[{from: "a", to: "b", by: "c"}]`, false},
+		{`[[{from: "a", to: "b"}]]`, false},
+		{`["a"]`, false},
+	} {
+		doc, r := G.ReadString("{simulate(certs: " + x.args + ")}")
+		M.Assert(doc != nil && r.Errors().IsEmpty(), x.args, 100)
+		es := ts.ExecValidate(doc)
+		if es.GetErrors().IsEmpty() != x.ok {
+			t.Error(x.args)
+		}
+	}
+}
+
+// Resolver returning v
+func constR (v G.Value) G.FieldResolver {
+	return func (rootValue *G.OutputObjectValue, argumentValues *A.Tree) G.Value {
+		return v
+	}
+} //constR
+
+// List of the values vs
+func list (vs ... G.Value) *G.ListValue {
+	l := G.NewListValue()
+	for _, v := range vs {
+		l.Append(v)
+	}
+	return l
+} //list
+
+func TestNullPropagation (t *testing.T) {
+	ts := newTypeSystem(`
+		type Query {
+			obj: Obj
+			objs: [Obj!]
+			failing: Int
+			nonNull: Int!
+			items: [Int!]
+			nullItems: [Int]!
+		}
+		type Obj {
+			x: Int!
+			y: Int
+		}
+	`)
+	ts.FixInitialValue(G.NewOutputObjectValue())
+	ts.FixFieldResolver("Query", "obj", constR(G.NewOutputObjectValue()))
+	ts.FixFieldResolver("Query", "objs", constR(list(G.NewOutputObjectValue(), G.NewOutputObjectValue())))
+	ts.FixFieldResolver("Query", "failing", constR(G.MakeErrorValue("failing")))
+	ts.FixFieldResolver("Query", "nonNull", constR(G.MakeErrorValue("nonNull")))
+	ts.FixFieldResolver("Query", "items", constR(list(G.MakeIntValue(1), G.MakeNullValue())))
+	ts.FixFieldResolver("Query", "nullItems", constR(list(G.MakeIntValue(1), G.MakeNullValue())))
+	ts.FixFieldResolver("Obj", "x", constR(G.MakeErrorValue("x")))
+	ts.FixFieldResolver("Obj", "y", constR(G.MakeIntValue(2)))
+	for _, x := range []struct {query, data string; errors int} {
+		{`{obj{y}}`, `{"obj": {"y": 2}}`, 0},
+		{`{obj{x y}}`, `{"obj": null}`, 1}, // A null non-null field makes its object null
+		{`{objs{x}}`, `{"objs": null}`, 1}, // and then the list of non-null objects, without completing its other items
+		{`{failing}`, `{"failing": null}`, 1},
+		{`{failing nonNull}`, `null`, 2}, // A null non-null root field makes the data null
+		{`{items}`, `{"items": null}`, 1},
+		{`{nullItems}`, `{"nullItems": [1, null]}`, 0},
+	} {
+		doc, r := G.ReadString(x.query)
+		M.Assert(doc != nil && r.Errors().IsEmpty(), x.query, 100)
+		es := ts.ExecValidate(doc)
+		M.Assert(es.GetErrors().IsEmpty(), x.query, 101)
+		var got, want struct {
+			Data interface{}
+			Errors []interface{}
+		}
+		err := J.Unmarshal([]byte(G.ResponseToJson(es.Execute(doc, "", A.New())).GetFlatString()), &got); M.Assert(err == nil, err, 102)
+		err = J.Unmarshal([]byte(x.data), &want.Data); M.Assert(err == nil, err, 103)
+		if !reflect.DeepEqual(got.Data, want.Data) || len(got.Errors) != x.errors {
+			t.Errorf("%s: got %v and %d errors, want %v and %d errors", x.query, got.Data, len(got.Errors), want.Data, x.errors)
+		}
+	}
+}
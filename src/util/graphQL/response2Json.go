@@ -101,9 +101,12 @@ func makeObject (mk *J.Maker, ov *OutputObjectValue) {
 	mk.BuildObject()
 } //makeObject
 
-func buildInstant (mk *J.Maker, data *OutputObjectValue) {
-	if data != nil {
-		makeObject(mk, data)
+func buildInstant (mk *J.Maker, r *InstantResponse) {
+	if r.Data != nil {
+		makeObject(mk, r.Data)
+		mk.BuildField("data")
+	} else if r.nullData {
+		mk.PushNull()
 		mk.BuildField("data")
 	}
 } //buildInstant
@@ -114,7 +117,7 @@ func ResponseToJson (r Response) J.Json {
 	makeErrors(mk, r.Errors())
 	switch r := r.(type) {
 	case *InstantResponse:
-		buildInstant(mk, r.Data)
+		buildInstant(mk, r)
 	case *SubscribeResponse:
 	}
 	mk.BuildObject()
@@ -84,6 +84,7 @@ GQL_NullVarWithNonNullType	^0: Null variable with non-null type
 GQL_ObjectExtWoDef	^0: object extension without definition
 GQL_QueryRootNotDefined	The query root is not defined
 GQL_ResolverAlrdyDefined	The resolver for ^1 in ^0 is already defined
+GQL_ResolverFailed	^0
 GQL_ResolverNotDefined	The resolver for ^1 in ^0 is not defined
 GQL_RootAlrdyDefined	The root ^0 is already defined
 GQL_RootNotDefinedFor	The root for ^0 operation is not defined